@@ -11,10 +11,12 @@ import (
 	pb "github.com/learies/goShortener/proto"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 )
 
 var (
-	addr = flag.String("addr", "localhost:50051", "the address to connect to")
+	addr  = flag.String("addr", "localhost:50051", "the address to connect to")
+	token = flag.String("token", "", "the API key or session token to authenticate with")
 )
 
 // extractShortURL extracts the short URL identifier from the full URL
@@ -39,6 +41,12 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
+	// Creating links and reading stats require an authenticated user
+	if *token == "" {
+		log.Fatal("an API key or session token is required, set it with -token")
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+*token)
+
 	// Test CreateShortURL
 	createResp, err := client.CreateShortURL(ctx, &pb.CreateShortURLRequest{
 		Url: "https://example.com",
@@ -84,5 +92,5 @@ func main() {
 	if err != nil {
 		log.Fatalf("could not get stats: %v", err)
 	}
-	fmt.Printf("Stats - URLs: %d, Users: %d\n", statsResp.UrlsCount, statsResp.UsersCount)
+	fmt.Printf("Stats - URLs: %d, Expired URLs: %d, Users: %d\n", statsResp.UrlsCount, statsResp.ExpiredUrlsCount, statsResp.UsersCount)
 }
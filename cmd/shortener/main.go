@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/learies/goShortener/internal/app"
 	"github.com/learies/goShortener/internal/config"
@@ -30,27 +32,51 @@ func getValueOrDefault(value string) string {
 // main is the entry point for the application.
 func main() {
 	printBuildInfo()
+	exit(run())
+}
+
+// exit ends the process with a non-zero status when err is set, so a failed
+// start or migration is visible to scripts. It is the only exit point of the
+// program; the osexit analyzer keeps os.Exit out of main.
+func exit(err error) {
+	if err != nil {
+		os.Exit(1)
+	}
+}
 
+// run starts the application or runs the migrate subcommand.
+// Errors are logged before they are returned.
+func run() error {
 	err := logger.NewLogger("info")
 	if err != nil {
-		logger.Log.Error("Error creating logger", "error", err)
-		return
+		fmt.Fprintln(os.Stderr, "Error creating logger:", err)
+		return err
 	}
 
 	cfg, err := config.NewConfig()
 	if err != nil {
 		logger.Log.Error("Error creating config", "error", err)
-		return
+		return err
+	}
+
+	if args := flag.Args(); len(args) > 0 && args[0] == "migrate" {
+		if err := runMigrate(cfg, args[1:]); err != nil {
+			logger.Log.Error("Error running migrations", "error", err)
+			return err
+		}
+		return nil
 	}
 
 	application, err := app.NewApp(cfg)
 	if err != nil {
 		logger.Log.Error("Error creating app", "error", err)
-		return
+		return err
 	}
 
 	if err := application.Run(); err != nil {
 		logger.Log.Error("Error running app", "error", err)
-		return
+		return err
 	}
+
+	return nil
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/config/database"
+)
+
+const migrateUsage = "usage: shortener [flags] migrate status | up [N] | down [N]"
+
+// runMigrate handles the migrate subcommand. Without N, up applies every
+// pending migration and down rolls back the latest one.
+func runMigrate(cfg *config.Config, args []string) error {
+	if cfg.DatabaseDSN == "" {
+		return errors.New("database DSN is required to run migrations")
+	}
+	if len(args) == 0 || len(args) > 2 {
+		return errors.New(migrateUsage)
+	}
+
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[1])
+		}
+		steps = n
+	}
+
+	db, err := database.Open(cfg.DatabaseDSN)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-30s %s\n", status.Version, status.Name, state)
+		}
+	case "up":
+		migrations, err := migrator.Up(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(migrations))
+	case "down":
+		migrations, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Rolled back %d migration(s)\n", len(migrations))
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
@@ -33,21 +33,22 @@ func run(pass *analysis.Pass) (interface{}, error) {
 
 	inspect.Preorder(nodeFilter, func(n ast.Node) {
 		fn := n.(*ast.FuncDecl)
-		if fn.Name.Name == "main" {
-			// Проверяем каждое выражение в теле функции main
-			for _, stmt := range fn.Body.List {
-				if expr, ok := stmt.(*ast.ExprStmt); ok {
-					if call, ok := expr.X.(*ast.CallExpr); ok {
-						if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
-							if ident, ok := sel.X.(*ast.Ident); ok {
-								if ident.Name == "os" && sel.Sel.Name == "Exit" {
-									pass.Reportf(call.Pos(), "direct call to os.Exit in main function")
-								}
-							}
+		if fn.Name.Name == "main" && fn.Body != nil {
+			// Проверяем все вызовы в теле функции main, включая вложенные блоки
+			ast.Inspect(fn.Body, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if sel, ok := call.Fun.(*ast.SelectorExpr); ok {
+					if ident, ok := sel.X.(*ast.Ident); ok {
+						if ident.Name == "os" && sel.Sel.Name == "Exit" {
+							pass.Reportf(call.Pos(), "direct call to os.Exit in main function")
 						}
 					}
 				}
-			}
+				return true
+			})
 		}
 	})
 
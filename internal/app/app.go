@@ -16,6 +16,7 @@ import (
 	grpcserver "github.com/learies/goShortener/internal/grpc"
 	"github.com/learies/goShortener/internal/router"
 	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/services/worker"
 	"github.com/learies/goShortener/internal/store"
 	"google.golang.org/grpc/reflection"
 )
@@ -25,35 +26,79 @@ type App struct {
 	Config *config.Config
 	Router *router.Router
 	Server *http.Server
+	Store  store.Store
 	// gRPC server
 	GRPCServer *grpcserver.Server
+	// Reaper purges expired links in the background
+	Reaper *worker.Reaper
+	// Clicks records redirects for link analytics
+	Clicks *worker.ClickTracker
+	// Deleter deletes user URLs in the background
+	Deleter *worker.Deleter
 }
 
 // NewApp is a function that creates a new App instance.
 func NewApp(cfg *config.Config) (*App, error) {
-	router := router.NewRouter()
-
 	store, err := store.NewStore(*cfg)
 	if err != nil {
 		logger.Log.Error("Failed to setup store", "error", err)
 		return nil, err
 	}
 
-	urlShortener := services.NewURLShortenerService(store, cfg.BaseURL)
+	shortener, err := services.NewShortenerStrategy(cfg.ShortenerStrategy, services.StrategyOptions{
+		Length:   cfg.ShortURLLength,
+		Sequence: store,
+	})
+	if err != nil {
+		logger.Log.Error("Failed to setup shortener", "error", err)
+		store.Close()
+		return nil, err
+	}
+
+	// HTTP и gRPC используют общие ограничитель попыток и проверки URL
+	policies, err := router.NewPolicies(cfg)
+	if err != nil {
+		logger.Log.Error("Failed to setup URL policies", "error", err)
+		store.Close()
+		return nil, err
+	}
+
+	deleter := worker.NewDeleter(store, worker.DeleterOptions{
+		QueueSize:     cfg.DeleteQueueSize,
+		BatchSize:     cfg.DeleteBatchSize,
+		Workers:       cfg.DeleteWorkers,
+		FlushInterval: cfg.DeleteFlushInterval,
+		FlushTimeout:  cfg.DeleteFlushTimeout,
+	})
+	urlShortener := services.NewURLShortenerService(store, cfg.BaseURL, shortener, deleter, policies.Passwords, policies.Normalizer, policies.URLPolicy)
+	clicks := worker.NewClickTracker(store, worker.ClickTrackerOptions{})
 
-	if err := router.Routes(cfg, store, urlShortener); err != nil {
+	router := router.NewRouter()
+	if err := router.Routes(cfg, store, urlShortener, clicks, deleter, policies); err != nil {
 		logger.Log.Error("Failed to setup routes", "error", err)
+		clicks.Close()
+		deleter.Close()
+		store.Close()
 		return nil, err
 	}
 
 	// Create gRPC server
-	grpcServer := grpcserver.NewServer(urlShortener)
+	grpcServer := grpcserver.NewServer(urlShortener, services.NewAPIKeyService(store), policies.Keys, cfg.TrustedSubnet)
 	reflection.Register(grpcServer.Server)
 
 	return &App{
 		Config:     cfg,
 		Router:     router,
+		Store:      store,
 		GRPCServer: grpcServer,
+		Clicks:     clicks,
+		Deleter:    deleter,
+		Reaper: &worker.Reaper{
+			Purger:            store,
+			Interval:          cfg.ReaperInterval,
+			Retention:         cfg.ExpiredRetention,
+			DeleteGracePeriod: cfg.DeleteGracePeriod,
+		},
 	}, nil
 }
 
@@ -106,6 +151,19 @@ func (a *App) Run() error {
 		}()
 	}
 
+	// Запускаем удаление истекших ссылок, если задан интервал
+	reaperCtx, stopReaper := context.WithCancel(context.Background())
+	defer stopReaper()
+	reaperDone := make(chan struct{})
+	if a.Reaper != nil && a.Reaper.Interval > 0 {
+		go func() {
+			defer close(reaperDone)
+			a.Reaper.Run(reaperCtx)
+		}()
+	} else {
+		close(reaperDone)
+	}
+
 	// Ждем сигнала завершения
 	<-stop
 	logger.Log.Info("Shutting down servers...")
@@ -125,6 +183,25 @@ func (a *App) Run() error {
 		a.GRPCServer.GracefulStop()
 	}
 
+	// Останавливаем фоновые задачи до закрытия хранилища,
+	// оставшиеся в очереди переходы записываются
+	stopReaper()
+	<-reaperDone
+	if a.Clicks != nil {
+		a.Clicks.Close()
+	}
+	if a.Deleter != nil {
+		stats := a.Deleter.Stats()
+		logger.Log.Info("Draining deletion queue", "queued", stats.QueueDepth)
+		a.Deleter.Close()
+	}
+
+	// Закрываем хранилище после остановки серверов
+	if err := a.Store.Close(); err != nil {
+		logger.Log.Error("Failed to close store", "error", err)
+		return fmt.Errorf("failed to close store: %w", err)
+	}
+
 	logger.Log.Info("Servers exited properly")
 	return nil
 }
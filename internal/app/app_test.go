@@ -23,7 +23,7 @@ import (
 // MockStore реализует интерфейс store.Store для тестирования
 type MockStore struct{}
 
-func (m *MockStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
+func (m *MockStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
 	return nil
 }
 
@@ -47,8 +47,80 @@ func (m *MockStore) Ping() error {
 	return nil
 }
 
-func (m *MockStore) GetStats(ctx context.Context) (int, int, error) {
-	return 0, 0, nil
+func (m *MockStore) Close() error {
+	return nil
+}
+
+func (m *MockStore) GetStats(ctx context.Context) (models.Stats, error) {
+	return models.Stats{}, nil
+}
+
+func (m *MockStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *MockStore) RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	return 0, nil
+}
+
+func (m *MockStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	return models.URLVersion{}, nil
+}
+
+func (m *MockStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+	return nil, nil
+}
+
+func (m *MockStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	return -1, nil
+}
+
+func (m *MockStore) RecordClicks(ctx context.Context, clicks []models.Click) error {
+	return nil
+}
+
+func (m *MockStore) GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+	return models.LinkStats{}, nil
+}
+
+func (m *MockStore) NextID(ctx context.Context) (int64, error) {
+	return 1, nil
+}
+
+func (m *MockStore) CreateAccount(ctx context.Context, account models.Account) error {
+	return nil
+}
+
+func (m *MockStore) GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error) {
+	return models.Account{}, nil
+}
+
+func (m *MockStore) GetAccountByEmail(ctx context.Context, email string) (models.Account, error) {
+	return models.Account{}, nil
+}
+
+func (m *MockStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
+func (m *MockStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	return nil
+}
+
+func (m *MockStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return models.APIKey{}, nil
+}
+
+func (m *MockStore) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	return nil, nil
+}
+
+func (m *MockStore) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	return models.APIKey{}, nil
 }
 
 func init() {
@@ -3,20 +3,94 @@ package config
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // Config is a struct that holds the configuration for the application.
 type Config struct {
-	Address       string
-	BaseURL       string
-	FilePath      string
-	DatabaseDSN   string
-	EnableHTTPS   bool
-	CertFile      string
-	KeyFile       string
-	TrustedSubnet string
+	Address string
+	BaseURL string
+	// StorageBackend selects the store: memory, file or postgres.
+	// When empty, the backend is chosen by which of DatabaseDSN and FilePath is set.
+	StorageBackend string
+	FilePath       string
+	// File storage durability and compaction
+	FileSyncPolicy      string
+	FileCompactInterval time.Duration
+	DatabaseDSN         string
+	// Short URL generation strategy and code length
+	ShortenerStrategy string
+	ShortURLLength    int
+	// Read-through cache in front of the store; CacheSize 0 disables it
+	CacheSize        int
+	CacheTTL         time.Duration
+	CacheNegativeTTL time.Duration
+	// Expired links are purged every ReaperInterval once they have been
+	// expired for longer than ExpiredRetention
+	ReaperInterval   time.Duration
+	ExpiredRetention time.Duration
+	// Deleted links can be restored for DeleteGracePeriod, after that
+	// the reaper removes them permanently
+	DeleteGracePeriod time.Duration
+	// User URLs are deleted in the background: DeleteWorkers write batches of
+	// DeleteBatchSize URLs from a queue of DeleteQueueSize, at least every
+	// DeleteFlushInterval; a write taking longer than DeleteFlushTimeout fails
+	DeleteQueueSize     int
+	DeleteBatchSize     int
+	DeleteWorkers       int
+	DeleteFlushInterval time.Duration
+	DeleteFlushTimeout  time.Duration
+	// RedirectCode is the HTTP status of redirects for links without their own;
+	// permanent redirects may be cached by clients for RedirectCacheMaxAge
+	RedirectCode        int
+	RedirectCacheMaxAge time.Duration
+	// ForcePreview shows the preview page instead of redirecting for every link;
+	// the page warns about destinations on FlaggedDomains and their subdomains
+	ForcePreview   bool
+	FlaggedDomains []string
+	// Protected links allow PasswordMaxAttempts failed password attempts per
+	// client IP on each link within PasswordAttemptWindow, and ten times as
+	// many per link from all clients; 0 disables the limit
+	PasswordMaxAttempts   int
+	PasswordAttemptWindow time.Duration
+	// Client IPs are taken from the connection; TrustProxyHeaders takes them
+	// from X-Real-IP or X-Forwarded-For, which only a trusted proxy may set
+	TrustProxyHeaders bool
+	// Original URLs are brought to a canonical form by the URLNormalization
+	// rules, "none" disables them; strip-tracking removes TrackingParams,
+	// a trailing "*" matching any suffix
+	URLNormalization []string
+	TrackingParams   []string
+	// Hosts of original URLs are checked against the URLAllowlistFile and
+	// URLDenylistFile, reloaded when changed at most every URLListReloadInterval;
+	// private and loopback targets are rejected unless AllowPrivateURLs is set
+	URLAllowlistFile      string
+	URLDenylistFile       string
+	URLListReloadInterval time.Duration
+	AllowPrivateURLs      bool
+	// User tokens are signed with JWTSecret (HS256) or the key in JWTKeyFile
+	// (RS256 or EdDSA for PEM keys); the previous secrets and key files still
+	// verify tokens, so keys can be rotated without logging users out
+	JWTSecret           string
+	JWTKeyFile          string
+	JWTPreviousSecrets  []string
+	JWTPreviousKeyFiles []string
+	// User sessions last SessionTTL and are renewed for the same user once
+	// less than SessionRenewBefore is left, 0 disables renewal; the token
+	// cookie is secure with HTTPS or CookieSecure (behind a TLS proxy)
+	SessionTTL         time.Duration
+	SessionRenewBefore time.Duration
+	CookieDomain       string
+	CookieSecure       bool
+	CookieSameSite     string
+	EnableHTTPS        bool
+	CertFile           string
+	KeyFile            string
+	TrustedSubnet      string
 	// gRPC server configuration
 	GRPCAddress string
 	EnableGRPC  bool
@@ -31,11 +105,47 @@ func getEnv(key, defaultValue string) string {
 	return value
 }
 
+// splitList is a function that splits a comma-separated list and drops empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // NewConfig is a function that creates a new Config instance.
 func NewConfig() (*Config, error) {
 	defaultAddress := ":8080"
 	defaultBaseURL := "http://localhost" + defaultAddress
 	defaultGRPCAddress := ":50051"
+	defaultFileSyncPolicy := "interval"
+	defaultFileCompactInterval := 10 * time.Minute
+	defaultShortenerStrategy := "random"
+	defaultShortURLLength := 8
+	defaultCacheSize := 10000
+	defaultCacheTTL := time.Minute
+	defaultCacheNegativeTTL := 5 * time.Second
+	defaultReaperInterval := time.Minute
+	defaultExpiredRetention := 24 * time.Hour
+	defaultDeleteGracePeriod := 7 * 24 * time.Hour
+	defaultDeleteQueueSize := 10000
+	defaultDeleteBatchSize := 100
+	defaultDeleteWorkers := 2
+	defaultDeleteFlushInterval := 500 * time.Millisecond
+	defaultDeleteFlushTimeout := 10 * time.Second
+	defaultRedirectCode := 307
+	defaultRedirectCacheMaxAge := 24 * time.Hour
+	defaultPasswordMaxAttempts := 5
+	defaultPasswordAttemptWindow := 15 * time.Minute
+	defaultURLNormalization := []string{"lowercase", "default-port", "clean-path", "idn", "sort-query", "strip-tracking"}
+	defaultURLListReloadInterval := 30 * time.Second
+	defaultSessionTTL := 30 * 24 * time.Hour
+	defaultSessionRenewBefore := 7 * 24 * time.Hour
+	defaultCookieSameSite := "lax"
+	defaultTrackingParams := []string{"utm_*", "fbclid", "gclid", "yclid", "msclkid", "mc_cid", "mc_eid", "igshid"}
 	var defaultFilePath string
 	var defaultDatabaseDSN string
 	var defaultCertFile string
@@ -46,8 +156,46 @@ func NewConfig() (*Config, error) {
 	configPath := flag.String("c", getEnv("CONFIG", ""), "path to configuration file")
 	address := flag.String("a", "", "address to start the HTTP server")
 	baseURL := flag.String("b", "", "base URL for shortened URLs")
+	storageBackend := flag.String("storage", "", "storage backend: memory, file or postgres")
 	filePath := flag.String("f", "", "path to the file for storing URL data")
+	fileSyncPolicy := flag.String("file-sync", "", "file storage sync policy: always, interval or never")
+	fileCompactInterval := flag.Duration("file-compact-interval", 0, "interval between file storage compactions")
 	databaseDSN := flag.String("d", "", "database DSN")
+	shortenerStrategy := flag.String("shortener", "", "short URL strategy: random, counter, hash or words")
+	shortURLLength := flag.Int("short-url-length", 0, "length of generated short URLs")
+	cacheSize := flag.Int("cache-size", -1, "number of short URLs kept in the cache, 0 disables it")
+	cacheTTL := flag.Duration("cache-ttl", 0, "time to keep found short URLs in the cache")
+	cacheNegativeTTL := flag.Duration("cache-negative-ttl", 0, "time to keep missing short URLs in the cache")
+	reaperInterval := flag.Duration("reaper-interval", 0, "interval between purges of expired short URLs")
+	expiredRetention := flag.Duration("expired-retention", 0, "time to keep expired short URLs before purging them")
+	deleteGracePeriod := flag.Duration("delete-grace-period", 0, "time during which deleted short URLs can be restored before purging them")
+	deleteQueueSize := flag.Int("delete-queue-size", 0, "number of user URLs waiting for deletion")
+	deleteBatchSize := flag.Int("delete-batch-size", 0, "number of user URLs deleted in one batch")
+	deleteWorkers := flag.Int("delete-workers", 0, "number of workers deleting user URLs")
+	deleteFlushInterval := flag.Duration("delete-flush-interval", 0, "longest time user URLs wait for a full deletion batch")
+	deleteFlushTimeout := flag.Duration("delete-flush-timeout", 0, "time limit for deleting one batch of user URLs")
+	redirectCode := flag.Int("redirect-code", 0, "default HTTP status of redirects: 301, 302, 307 or 308")
+	redirectCacheMaxAge := flag.Duration("redirect-cache-max-age", -1, "time clients may cache permanent redirects, 0 disables caching")
+	forcePreview := flag.Bool("force-preview", false, "show the preview page instead of redirecting for every link")
+	flaggedDomains := flag.String("flagged-domains", "", "comma-separated domains flagged as unsafe on the preview page")
+	passwordMaxAttempts := flag.Int("password-max-attempts", -1, "failed password attempts allowed per client IP on a link, 0 disables the limit")
+	passwordAttemptWindow := flag.Duration("password-attempt-window", 0, "window in which failed password attempts are counted")
+	trustProxyHeaders := flag.Bool("trust-proxy-headers", false, "take client IPs from X-Real-IP or X-Forwarded-For set by a trusted proxy")
+	urlNormalization := flag.String("url-normalization", "", "comma-separated URL normalization rules: lowercase, default-port, clean-path, idn, sort-query, strip-tracking or none")
+	trackingParams := flag.String("tracking-params", "", "comma-separated query parameters removed by strip-tracking, a trailing * matches any suffix")
+	urlAllowlistFile := flag.String("url-allowlist", "", "path to the file with hosts allowed as URL destinations")
+	urlDenylistFile := flag.String("url-denylist", "", "path to the file with hosts denied as URL destinations")
+	urlListReloadInterval := flag.Duration("url-list-reload-interval", -1, "interval between checks of the URL allowlist and denylist for changes, 0 disables reloading")
+	allowPrivateURLs := flag.Bool("allow-private-urls", false, "allow shortening URLs with private and loopback hosts")
+	jwtSecret := flag.String("jwt-secret", "", "secret that signs user tokens with HS256")
+	jwtKeyFile := flag.String("jwt-key-file", "", "path to the PEM key (RS256 or EdDSA) or secret that signs user tokens")
+	jwtPreviousSecrets := flag.String("jwt-previous-secrets", "", "comma-separated previous secrets that still verify user tokens")
+	jwtPreviousKeyFiles := flag.String("jwt-previous-key-files", "", "comma-separated paths to previous keys that still verify user tokens")
+	sessionTTL := flag.Duration("session-ttl", 0, "lifetime of user sessions")
+	sessionRenewBefore := flag.Duration("session-renew-before", -1, "time before expiry when user sessions are renewed, 0 disables renewal")
+	cookieDomain := flag.String("cookie-domain", "", "domain of the session cookie")
+	cookieSecure := flag.Bool("cookie-secure", false, "mark the session cookie secure even without HTTPS")
+	cookieSameSite := flag.String("cookie-samesite", "", "SameSite attribute of the session cookie: lax, strict or none")
 	enableHTTPS := flag.Bool("s", false, "enable HTTPS server")
 	certFile := flag.String("cert", "", "path to SSL certificate file")
 	keyFile := flag.String("key", "", "path to SSL private key file")
@@ -67,20 +215,47 @@ func NewConfig() (*Config, error) {
 
 	// Создаем базовую конфигурацию с дефолтными значениями
 	cfg := &Config{
-		Address:       defaultAddress,
-		BaseURL:       defaultBaseURL,
-		FilePath:      defaultFilePath,
-		DatabaseDSN:   defaultDatabaseDSN,
-		EnableHTTPS:   false,
-		CertFile:      defaultCertFile,
-		KeyFile:       defaultKeyFile,
-		TrustedSubnet: defaultTrustedSubnet,
-		GRPCAddress:   defaultGRPCAddress,
-		EnableGRPC:    false,
+		Address:               defaultAddress,
+		BaseURL:               defaultBaseURL,
+		FilePath:              defaultFilePath,
+		FileSyncPolicy:        defaultFileSyncPolicy,
+		FileCompactInterval:   defaultFileCompactInterval,
+		DatabaseDSN:           defaultDatabaseDSN,
+		ShortenerStrategy:     defaultShortenerStrategy,
+		ShortURLLength:        defaultShortURLLength,
+		CacheSize:             defaultCacheSize,
+		CacheTTL:              defaultCacheTTL,
+		CacheNegativeTTL:      defaultCacheNegativeTTL,
+		ReaperInterval:        defaultReaperInterval,
+		ExpiredRetention:      defaultExpiredRetention,
+		DeleteGracePeriod:     defaultDeleteGracePeriod,
+		DeleteQueueSize:       defaultDeleteQueueSize,
+		DeleteBatchSize:       defaultDeleteBatchSize,
+		DeleteWorkers:         defaultDeleteWorkers,
+		DeleteFlushInterval:   defaultDeleteFlushInterval,
+		DeleteFlushTimeout:    defaultDeleteFlushTimeout,
+		RedirectCode:          defaultRedirectCode,
+		RedirectCacheMaxAge:   defaultRedirectCacheMaxAge,
+		PasswordMaxAttempts:   defaultPasswordMaxAttempts,
+		PasswordAttemptWindow: defaultPasswordAttemptWindow,
+		URLNormalization:      defaultURLNormalization,
+		TrackingParams:        defaultTrackingParams,
+		URLListReloadInterval: defaultURLListReloadInterval,
+		SessionTTL:            defaultSessionTTL,
+		SessionRenewBefore:    defaultSessionRenewBefore,
+		CookieSameSite:        defaultCookieSameSite,
+		EnableHTTPS:           false,
+		CertFile:              defaultCertFile,
+		KeyFile:               defaultKeyFile,
+		TrustedSubnet:         defaultTrustedSubnet,
+		GRPCAddress:           defaultGRPCAddress,
+		EnableGRPC:            false,
 	}
 
 	// Применяем значения из JSON конфигурации (низший приоритет)
-	cfg.mergeConfig(jsonConfig)
+	if err := cfg.mergeConfig(jsonConfig); err != nil {
+		return nil, err
+	}
 
 	// Применяем значения из переменных окружения (средний приоритет)
 	if envAddress := getEnv("SERVER_ADDRESS", ""); envAddress != "" {
@@ -89,12 +264,206 @@ func NewConfig() (*Config, error) {
 	if envBaseURL := getEnv("BASE_URL", ""); envBaseURL != "" {
 		cfg.BaseURL = envBaseURL
 	}
+	if envStorageBackend := getEnv("STORAGE_BACKEND", ""); envStorageBackend != "" {
+		cfg.StorageBackend = envStorageBackend
+	}
 	if envFilePath := getEnv("FILE_STORAGE_PATH", ""); envFilePath != "" {
 		cfg.FilePath = envFilePath
 	}
+	if envFileSyncPolicy := getEnv("FILE_STORAGE_SYNC", ""); envFileSyncPolicy != "" {
+		cfg.FileSyncPolicy = envFileSyncPolicy
+	}
+	if envFileCompactInterval := getEnv("FILE_STORAGE_COMPACT_INTERVAL", ""); envFileCompactInterval != "" {
+		interval, err := time.ParseDuration(envFileCompactInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FILE_STORAGE_COMPACT_INTERVAL: %w", err)
+		}
+		cfg.FileCompactInterval = interval
+	}
 	if envDatabaseDSN := getEnv("DATABASE_DSN", ""); envDatabaseDSN != "" {
 		cfg.DatabaseDSN = envDatabaseDSN
 	}
+	if envShortenerStrategy := getEnv("SHORTENER_STRATEGY", ""); envShortenerStrategy != "" {
+		cfg.ShortenerStrategy = envShortenerStrategy
+	}
+	if envShortURLLength := getEnv("SHORT_URL_LENGTH", ""); envShortURLLength != "" {
+		length, err := strconv.Atoi(envShortURLLength)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SHORT_URL_LENGTH: %w", err)
+		}
+		cfg.ShortURLLength = length
+	}
+	if envCacheSize := getEnv("CACHE_SIZE", ""); envCacheSize != "" {
+		size, err := strconv.Atoi(envCacheSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CACHE_SIZE: %w", err)
+		}
+		cfg.CacheSize = size
+	}
+	if envCacheTTL := getEnv("CACHE_TTL", ""); envCacheTTL != "" {
+		ttl, err := time.ParseDuration(envCacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CACHE_TTL: %w", err)
+		}
+		cfg.CacheTTL = ttl
+	}
+	if envCacheNegativeTTL := getEnv("CACHE_NEGATIVE_TTL", ""); envCacheNegativeTTL != "" {
+		ttl, err := time.ParseDuration(envCacheNegativeTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CACHE_NEGATIVE_TTL: %w", err)
+		}
+		cfg.CacheNegativeTTL = ttl
+	}
+	if envReaperInterval := getEnv("REAPER_INTERVAL", ""); envReaperInterval != "" {
+		interval, err := time.ParseDuration(envReaperInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REAPER_INTERVAL: %w", err)
+		}
+		cfg.ReaperInterval = interval
+	}
+	if envExpiredRetention := getEnv("EXPIRED_RETENTION", ""); envExpiredRetention != "" {
+		retention, err := time.ParseDuration(envExpiredRetention)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXPIRED_RETENTION: %w", err)
+		}
+		cfg.ExpiredRetention = retention
+	}
+	if envDeleteGracePeriod := getEnv("DELETE_GRACE_PERIOD", ""); envDeleteGracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(envDeleteGracePeriod)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_GRACE_PERIOD: %w", err)
+		}
+		cfg.DeleteGracePeriod = gracePeriod
+	}
+	if envDeleteQueueSize := getEnv("DELETE_QUEUE_SIZE", ""); envDeleteQueueSize != "" {
+		value, err := strconv.Atoi(envDeleteQueueSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_QUEUE_SIZE: %w", err)
+		}
+		cfg.DeleteQueueSize = value
+	}
+	if envDeleteBatchSize := getEnv("DELETE_BATCH_SIZE", ""); envDeleteBatchSize != "" {
+		value, err := strconv.Atoi(envDeleteBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_BATCH_SIZE: %w", err)
+		}
+		cfg.DeleteBatchSize = value
+	}
+	if envDeleteWorkers := getEnv("DELETE_WORKERS", ""); envDeleteWorkers != "" {
+		value, err := strconv.Atoi(envDeleteWorkers)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_WORKERS: %w", err)
+		}
+		cfg.DeleteWorkers = value
+	}
+	if envDeleteFlushInterval := getEnv("DELETE_FLUSH_INTERVAL", ""); envDeleteFlushInterval != "" {
+		value, err := time.ParseDuration(envDeleteFlushInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_FLUSH_INTERVAL: %w", err)
+		}
+		cfg.DeleteFlushInterval = value
+	}
+	if envDeleteFlushTimeout := getEnv("DELETE_FLUSH_TIMEOUT", ""); envDeleteFlushTimeout != "" {
+		value, err := time.ParseDuration(envDeleteFlushTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DELETE_FLUSH_TIMEOUT: %w", err)
+		}
+		cfg.DeleteFlushTimeout = value
+	}
+	if envRedirectCode := getEnv("REDIRECT_CODE", ""); envRedirectCode != "" {
+		code, err := strconv.Atoi(envRedirectCode)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIRECT_CODE: %w", err)
+		}
+		cfg.RedirectCode = code
+	}
+	if envRedirectCacheMaxAge := getEnv("REDIRECT_CACHE_MAX_AGE", ""); envRedirectCacheMaxAge != "" {
+		maxAge, err := time.ParseDuration(envRedirectCacheMaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("invalid REDIRECT_CACHE_MAX_AGE: %w", err)
+		}
+		cfg.RedirectCacheMaxAge = maxAge
+	}
+	if envForcePreview := getEnv("FORCE_PREVIEW", ""); envForcePreview == "true" {
+		cfg.ForcePreview = true
+	}
+	if envFlaggedDomains := getEnv("FLAGGED_DOMAINS", ""); envFlaggedDomains != "" {
+		cfg.FlaggedDomains = splitList(envFlaggedDomains)
+	}
+	if envPasswordMaxAttempts := getEnv("PASSWORD_MAX_ATTEMPTS", ""); envPasswordMaxAttempts != "" {
+		attempts, err := strconv.Atoi(envPasswordMaxAttempts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PASSWORD_MAX_ATTEMPTS: %w", err)
+		}
+		cfg.PasswordMaxAttempts = attempts
+	}
+	if envPasswordAttemptWindow := getEnv("PASSWORD_ATTEMPT_WINDOW", ""); envPasswordAttemptWindow != "" {
+		window, err := time.ParseDuration(envPasswordAttemptWindow)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PASSWORD_ATTEMPT_WINDOW: %w", err)
+		}
+		cfg.PasswordAttemptWindow = window
+	}
+	if envTrustProxyHeaders := getEnv("TRUST_PROXY_HEADERS", ""); envTrustProxyHeaders == "true" {
+		cfg.TrustProxyHeaders = true
+	}
+	if envURLNormalization := getEnv("URL_NORMALIZATION", ""); envURLNormalization != "" {
+		cfg.URLNormalization = splitList(envURLNormalization)
+	}
+	if envTrackingParams := getEnv("TRACKING_PARAMS", ""); envTrackingParams != "" {
+		cfg.TrackingParams = splitList(envTrackingParams)
+	}
+	if envURLAllowlistFile := getEnv("URL_ALLOWLIST_FILE", ""); envURLAllowlistFile != "" {
+		cfg.URLAllowlistFile = envURLAllowlistFile
+	}
+	if envURLDenylistFile := getEnv("URL_DENYLIST_FILE", ""); envURLDenylistFile != "" {
+		cfg.URLDenylistFile = envURLDenylistFile
+	}
+	if envURLListReloadInterval := getEnv("URL_LIST_RELOAD_INTERVAL", ""); envURLListReloadInterval != "" {
+		interval, err := time.ParseDuration(envURLListReloadInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL_LIST_RELOAD_INTERVAL: %w", err)
+		}
+		cfg.URLListReloadInterval = interval
+	}
+	if envAllowPrivateURLs := getEnv("ALLOW_PRIVATE_URLS", ""); envAllowPrivateURLs == "true" {
+		cfg.AllowPrivateURLs = true
+	}
+	if envJWTSecret := getEnv("JWT_SECRET", ""); envJWTSecret != "" {
+		cfg.JWTSecret = envJWTSecret
+	}
+	if envJWTKeyFile := getEnv("JWT_KEY_FILE", ""); envJWTKeyFile != "" {
+		cfg.JWTKeyFile = envJWTKeyFile
+	}
+	if envJWTPreviousSecrets := getEnv("JWT_PREVIOUS_SECRETS", ""); envJWTPreviousSecrets != "" {
+		cfg.JWTPreviousSecrets = splitList(envJWTPreviousSecrets)
+	}
+	if envJWTPreviousKeyFiles := getEnv("JWT_PREVIOUS_KEY_FILES", ""); envJWTPreviousKeyFiles != "" {
+		cfg.JWTPreviousKeyFiles = splitList(envJWTPreviousKeyFiles)
+	}
+	if envSessionTTL := getEnv("SESSION_TTL", ""); envSessionTTL != "" {
+		ttl, err := time.ParseDuration(envSessionTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_TTL: %w", err)
+		}
+		cfg.SessionTTL = ttl
+	}
+	if envSessionRenewBefore := getEnv("SESSION_RENEW_BEFORE", ""); envSessionRenewBefore != "" {
+		renewBefore, err := time.ParseDuration(envSessionRenewBefore)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SESSION_RENEW_BEFORE: %w", err)
+		}
+		cfg.SessionRenewBefore = renewBefore
+	}
+	if envCookieDomain := getEnv("COOKIE_DOMAIN", ""); envCookieDomain != "" {
+		cfg.CookieDomain = envCookieDomain
+	}
+	if envCookieSecure := getEnv("COOKIE_SECURE", ""); envCookieSecure == "true" {
+		cfg.CookieSecure = true
+	}
+	if envCookieSameSite := getEnv("COOKIE_SAMESITE", ""); envCookieSameSite != "" {
+		cfg.CookieSameSite = envCookieSameSite
+	}
 	if envEnableHTTPS := getEnv("ENABLE_HTTPS", ""); envEnableHTTPS == "true" {
 		cfg.EnableHTTPS = true
 	}
@@ -122,12 +491,126 @@ func NewConfig() (*Config, error) {
 	if *baseURL != "" {
 		cfg.BaseURL = *baseURL
 	}
+	if *storageBackend != "" {
+		cfg.StorageBackend = *storageBackend
+	}
 	if *filePath != "" {
 		cfg.FilePath = *filePath
 	}
+	if *fileSyncPolicy != "" {
+		cfg.FileSyncPolicy = *fileSyncPolicy
+	}
+	if *fileCompactInterval != 0 {
+		cfg.FileCompactInterval = *fileCompactInterval
+	}
 	if *databaseDSN != "" {
 		cfg.DatabaseDSN = *databaseDSN
 	}
+	if *shortenerStrategy != "" {
+		cfg.ShortenerStrategy = *shortenerStrategy
+	}
+	if *shortURLLength != 0 {
+		cfg.ShortURLLength = *shortURLLength
+	}
+	if *cacheSize >= 0 {
+		cfg.CacheSize = *cacheSize
+	}
+	if *cacheTTL != 0 {
+		cfg.CacheTTL = *cacheTTL
+	}
+	if *cacheNegativeTTL != 0 {
+		cfg.CacheNegativeTTL = *cacheNegativeTTL
+	}
+	if *reaperInterval != 0 {
+		cfg.ReaperInterval = *reaperInterval
+	}
+	if *expiredRetention != 0 {
+		cfg.ExpiredRetention = *expiredRetention
+	}
+	if *deleteGracePeriod != 0 {
+		cfg.DeleteGracePeriod = *deleteGracePeriod
+	}
+	if *deleteQueueSize != 0 {
+		cfg.DeleteQueueSize = *deleteQueueSize
+	}
+	if *deleteBatchSize != 0 {
+		cfg.DeleteBatchSize = *deleteBatchSize
+	}
+	if *deleteWorkers != 0 {
+		cfg.DeleteWorkers = *deleteWorkers
+	}
+	if *deleteFlushInterval != 0 {
+		cfg.DeleteFlushInterval = *deleteFlushInterval
+	}
+	if *deleteFlushTimeout != 0 {
+		cfg.DeleteFlushTimeout = *deleteFlushTimeout
+	}
+	if *redirectCode != 0 {
+		cfg.RedirectCode = *redirectCode
+	}
+	if *redirectCacheMaxAge >= 0 {
+		cfg.RedirectCacheMaxAge = *redirectCacheMaxAge
+	}
+	if *forcePreview {
+		cfg.ForcePreview = true
+	}
+	if *flaggedDomains != "" {
+		cfg.FlaggedDomains = splitList(*flaggedDomains)
+	}
+	if *passwordMaxAttempts >= 0 {
+		cfg.PasswordMaxAttempts = *passwordMaxAttempts
+	}
+	if *passwordAttemptWindow != 0 {
+		cfg.PasswordAttemptWindow = *passwordAttemptWindow
+	}
+	if *trustProxyHeaders {
+		cfg.TrustProxyHeaders = true
+	}
+	if *urlNormalization != "" {
+		cfg.URLNormalization = splitList(*urlNormalization)
+	}
+	if *trackingParams != "" {
+		cfg.TrackingParams = splitList(*trackingParams)
+	}
+	if *urlAllowlistFile != "" {
+		cfg.URLAllowlistFile = *urlAllowlistFile
+	}
+	if *urlDenylistFile != "" {
+		cfg.URLDenylistFile = *urlDenylistFile
+	}
+	if *urlListReloadInterval >= 0 {
+		cfg.URLListReloadInterval = *urlListReloadInterval
+	}
+	if *allowPrivateURLs {
+		cfg.AllowPrivateURLs = true
+	}
+	if *jwtSecret != "" {
+		cfg.JWTSecret = *jwtSecret
+	}
+	if *jwtKeyFile != "" {
+		cfg.JWTKeyFile = *jwtKeyFile
+	}
+	if *jwtPreviousSecrets != "" {
+		cfg.JWTPreviousSecrets = splitList(*jwtPreviousSecrets)
+	}
+	if *jwtPreviousKeyFiles != "" {
+		cfg.JWTPreviousKeyFiles = splitList(*jwtPreviousKeyFiles)
+	}
+	if *sessionTTL != 0 {
+		cfg.SessionTTL = *sessionTTL
+	}
+	if *sessionRenewBefore >= 0 {
+		cfg.SessionRenewBefore = *sessionRenewBefore
+	}
+	if *cookieDomain != "" {
+		cfg.CookieDomain = *cookieDomain
+	}
+	if *cookieSecure {
+		cfg.CookieSecure = true
+	}
+	if *cookieSameSite != "" {
+		cfg.CookieSameSite = *cookieSameSite
+	}
 	if *enableHTTPS {
 		cfg.EnableHTTPS = true
 	}
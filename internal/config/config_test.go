@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -400,3 +401,1051 @@ func TestConfigPriority(t *testing.T) {
 		})
 	}
 }
+
+func TestStorageConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                        os.Getenv("CONFIG"),
+		"STORAGE_BACKEND":               os.Getenv("STORAGE_BACKEND"),
+		"FILE_STORAGE_SYNC":             os.Getenv("FILE_STORAGE_SYNC"),
+		"FILE_STORAGE_COMPACT_INTERVAL": os.Getenv("FILE_STORAGE_COMPACT_INTERVAL"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name                string
+		envVars             map[string]string
+		args                []string
+		expectedBackend     string
+		expectedSyncPolicy  string
+		expectedCompactTime time.Duration
+		wantErr             bool
+	}{
+		{
+			name:                "Defaults",
+			expectedSyncPolicy:  "interval",
+			expectedCompactTime: 10 * time.Minute,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"STORAGE_BACKEND":               "file",
+				"FILE_STORAGE_SYNC":             "always",
+				"FILE_STORAGE_COMPACT_INTERVAL": "30s",
+			},
+			expectedBackend:     "file",
+			expectedSyncPolicy:  "always",
+			expectedCompactTime: 30 * time.Second,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"STORAGE_BACKEND":   "file",
+				"FILE_STORAGE_SYNC": "always",
+			},
+			args:                []string{"-storage", "memory", "-file-sync", "never", "-file-compact-interval", "1h"},
+			expectedBackend:     "memory",
+			expectedSyncPolicy:  "never",
+			expectedCompactTime: time.Hour,
+		},
+		{
+			name: "Invalid compact interval",
+			envVars: map[string]string{
+				"FILE_STORAGE_COMPACT_INTERVAL": "often",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedBackend, cfg.StorageBackend)
+			assert.Equal(t, tt.expectedSyncPolicy, cfg.FileSyncPolicy)
+			assert.Equal(t, tt.expectedCompactTime, cfg.FileCompactInterval)
+		})
+	}
+}
+
+func TestCacheConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":             os.Getenv("CONFIG"),
+		"CACHE_SIZE":         os.Getenv("CACHE_SIZE"),
+		"CACHE_TTL":          os.Getenv("CACHE_TTL"),
+		"CACHE_NEGATIVE_TTL": os.Getenv("CACHE_NEGATIVE_TTL"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name                string
+		envVars             map[string]string
+		args                []string
+		expectedSize        int
+		expectedTTL         time.Duration
+		expectedNegativeTTL time.Duration
+		wantErr             bool
+	}{
+		{
+			name:                "Defaults",
+			expectedSize:        10000,
+			expectedTTL:         time.Minute,
+			expectedNegativeTTL: 5 * time.Second,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"CACHE_SIZE":         "100",
+				"CACHE_TTL":          "30s",
+				"CACHE_NEGATIVE_TTL": "1s",
+			},
+			expectedSize:        100,
+			expectedTTL:         30 * time.Second,
+			expectedNegativeTTL: time.Second,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"CACHE_SIZE": "100",
+			},
+			// Нулевой размер отключает кэш и не должен считаться отсутствием флага
+			args:                []string{"-cache-size", "0", "-cache-ttl", "1h"},
+			expectedSize:        0,
+			expectedTTL:         time.Hour,
+			expectedNegativeTTL: 5 * time.Second,
+		},
+		{
+			name: "Invalid cache size",
+			envVars: map[string]string{
+				"CACHE_SIZE": "many",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedSize, cfg.CacheSize)
+			assert.Equal(t, tt.expectedTTL, cfg.CacheTTL)
+			assert.Equal(t, tt.expectedNegativeTTL, cfg.CacheNegativeTTL)
+		})
+	}
+}
+
+func TestExpirationConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":              os.Getenv("CONFIG"),
+		"REAPER_INTERVAL":     os.Getenv("REAPER_INTERVAL"),
+		"EXPIRED_RETENTION":   os.Getenv("EXPIRED_RETENTION"),
+		"DELETE_GRACE_PERIOD": os.Getenv("DELETE_GRACE_PERIOD"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name              string
+		envVars           map[string]string
+		args              []string
+		expectedInterval  time.Duration
+		expectedRetention time.Duration
+		expectedGrace     time.Duration
+		wantErr           bool
+	}{
+		{
+			name:              "Defaults",
+			expectedInterval:  time.Minute,
+			expectedRetention: 24 * time.Hour,
+			expectedGrace:     7 * 24 * time.Hour,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"REAPER_INTERVAL":     "10s",
+				"EXPIRED_RETENTION":   "0s",
+				"DELETE_GRACE_PERIOD": "48h",
+			},
+			expectedInterval:  10 * time.Second,
+			expectedRetention: 0,
+			expectedGrace:     48 * time.Hour,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"REAPER_INTERVAL": "10s",
+			},
+			args:              []string{"-reaper-interval", "5m", "-expired-retention", "168h", "-delete-grace-period", "720h"},
+			expectedInterval:  5 * time.Minute,
+			expectedRetention: 168 * time.Hour,
+			expectedGrace:     720 * time.Hour,
+		},
+		{
+			name: "Invalid retention",
+			envVars: map[string]string{
+				"EXPIRED_RETENTION": "forever",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid grace period",
+			envVars: map[string]string{
+				"DELETE_GRACE_PERIOD": "a week",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedInterval, cfg.ReaperInterval)
+			assert.Equal(t, tt.expectedRetention, cfg.ExpiredRetention)
+			assert.Equal(t, tt.expectedGrace, cfg.DeleteGracePeriod)
+		})
+	}
+}
+
+func TestDeleterConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                os.Getenv("CONFIG"),
+		"DELETE_QUEUE_SIZE":     os.Getenv("DELETE_QUEUE_SIZE"),
+		"DELETE_BATCH_SIZE":     os.Getenv("DELETE_BATCH_SIZE"),
+		"DELETE_WORKERS":        os.Getenv("DELETE_WORKERS"),
+		"DELETE_FLUSH_INTERVAL": os.Getenv("DELETE_FLUSH_INTERVAL"),
+		"DELETE_FLUSH_TIMEOUT":  os.Getenv("DELETE_FLUSH_TIMEOUT"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name             string
+		envVars          map[string]string
+		args             []string
+		expectedQueue    int
+		expectedBatch    int
+		expectedWorkers  int
+		expectedInterval time.Duration
+		expectedTimeout  time.Duration
+		wantErr          bool
+	}{
+		{
+			name:             "Defaults",
+			expectedQueue:    10000,
+			expectedBatch:    100,
+			expectedWorkers:  2,
+			expectedInterval: 500 * time.Millisecond,
+			expectedTimeout:  10 * time.Second,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"DELETE_QUEUE_SIZE":     "500",
+				"DELETE_BATCH_SIZE":     "50",
+				"DELETE_WORKERS":        "4",
+				"DELETE_FLUSH_INTERVAL": "1s",
+				"DELETE_FLUSH_TIMEOUT":  "30s",
+			},
+			expectedQueue:    500,
+			expectedBatch:    50,
+			expectedWorkers:  4,
+			expectedInterval: time.Second,
+			expectedTimeout:  30 * time.Second,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"DELETE_WORKERS": "4",
+			},
+			args:             []string{"-delete-workers", "8", "-delete-batch-size", "10", "-delete-flush-timeout", "5s"},
+			expectedQueue:    10000,
+			expectedBatch:    10,
+			expectedWorkers:  8,
+			expectedInterval: 500 * time.Millisecond,
+			expectedTimeout:  5 * time.Second,
+		},
+		{
+			name: "Invalid batch size",
+			envVars: map[string]string{
+				"DELETE_BATCH_SIZE": "many",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid flush timeout",
+			envVars: map[string]string{
+				"DELETE_FLUSH_TIMEOUT": "soon",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedQueue, cfg.DeleteQueueSize)
+			assert.Equal(t, tt.expectedBatch, cfg.DeleteBatchSize)
+			assert.Equal(t, tt.expectedWorkers, cfg.DeleteWorkers)
+			assert.Equal(t, tt.expectedInterval, cfg.DeleteFlushInterval)
+			assert.Equal(t, tt.expectedTimeout, cfg.DeleteFlushTimeout)
+		})
+	}
+}
+
+func TestRedirectConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                 os.Getenv("CONFIG"),
+		"REDIRECT_CODE":          os.Getenv("REDIRECT_CODE"),
+		"REDIRECT_CACHE_MAX_AGE": os.Getenv("REDIRECT_CACHE_MAX_AGE"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name           string
+		envVars        map[string]string
+		args           []string
+		expectedCode   int
+		expectedMaxAge time.Duration
+		wantErr        bool
+	}{
+		{
+			name:           "Defaults",
+			expectedCode:   307,
+			expectedMaxAge: 24 * time.Hour,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"REDIRECT_CODE":          "301",
+				"REDIRECT_CACHE_MAX_AGE": "1h",
+			},
+			expectedCode:   301,
+			expectedMaxAge: time.Hour,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"REDIRECT_CODE": "301",
+			},
+			args:           []string{"-redirect-code", "308", "-redirect-cache-max-age", "0s"},
+			expectedCode:   308,
+			expectedMaxAge: 0,
+		},
+		{
+			name: "Invalid code",
+			envVars: map[string]string{
+				"REDIRECT_CODE": "permanent",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedCode, cfg.RedirectCode)
+			assert.Equal(t, tt.expectedMaxAge, cfg.RedirectCacheMaxAge)
+		})
+	}
+}
+
+func TestPreviewConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":          os.Getenv("CONFIG"),
+		"FORCE_PREVIEW":   os.Getenv("FORCE_PREVIEW"),
+		"FLAGGED_DOMAINS": os.Getenv("FLAGGED_DOMAINS"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"force_preview": true, "flagged_domains": ["json.example"]}`), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name            string
+		envVars         map[string]string
+		args            []string
+		expectedForce   bool
+		expectedDomains []string
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"FORCE_PREVIEW":   "true",
+				"FLAGGED_DOMAINS": "evil.com, , phish.example",
+			},
+			expectedForce:   true,
+			expectedDomains: []string{"evil.com", "phish.example"},
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"FLAGGED_DOMAINS": "evil.com",
+			},
+			args:            []string{"-force-preview", "-flagged-domains", "flag.example"},
+			expectedForce:   true,
+			expectedDomains: []string{"flag.example"},
+		},
+		{
+			name:            "JSON config",
+			args:            []string{"-c", configFile},
+			expectedForce:   true,
+			expectedDomains: []string{"json.example"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedForce, cfg.ForcePreview)
+			assert.Equal(t, tt.expectedDomains, cfg.FlaggedDomains)
+		})
+	}
+}
+
+func TestPasswordConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                  os.Getenv("CONFIG"),
+		"PASSWORD_MAX_ATTEMPTS":   os.Getenv("PASSWORD_MAX_ATTEMPTS"),
+		"PASSWORD_ATTEMPT_WINDOW": os.Getenv("PASSWORD_ATTEMPT_WINDOW"),
+		"TRUST_PROXY_HEADERS":     os.Getenv("TRUST_PROXY_HEADERS"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tests := []struct {
+		name             string
+		envVars          map[string]string
+		args             []string
+		expectedAttempts int
+		expectedWindow   time.Duration
+		expectedTrust    bool
+		wantErr          bool
+	}{
+		{
+			name:             "Defaults",
+			expectedAttempts: 5,
+			expectedWindow:   15 * time.Minute,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"PASSWORD_MAX_ATTEMPTS":   "10",
+				"PASSWORD_ATTEMPT_WINDOW": "1h",
+				"TRUST_PROXY_HEADERS":     "true",
+			},
+			expectedAttempts: 10,
+			expectedWindow:   time.Hour,
+			expectedTrust:    true,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"PASSWORD_MAX_ATTEMPTS": "10",
+			},
+			args:             []string{"-password-max-attempts", "0", "-password-attempt-window", "5m", "-trust-proxy-headers"},
+			expectedAttempts: 0,
+			expectedWindow:   5 * time.Minute,
+			expectedTrust:    true,
+		},
+		{
+			name: "Invalid attempts",
+			envVars: map[string]string{
+				"PASSWORD_MAX_ATTEMPTS": "many",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedAttempts, cfg.PasswordMaxAttempts)
+			assert.Equal(t, tt.expectedWindow, cfg.PasswordAttemptWindow)
+			assert.Equal(t, tt.expectedTrust, cfg.TrustProxyHeaders)
+		})
+	}
+}
+
+func TestURLNormalizationConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":            os.Getenv("CONFIG"),
+		"URL_NORMALIZATION": os.Getenv("URL_NORMALIZATION"),
+		"TRACKING_PARAMS":   os.Getenv("TRACKING_PARAMS"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"url_normalization": ["lowercase"], "tracking_params": ["ref"]}`), 0644)
+	require.NoError(t, err)
+
+	defaultRules := []string{"lowercase", "default-port", "clean-path", "idn", "sort-query", "strip-tracking"}
+	defaultParams := []string{"utm_*", "fbclid", "gclid", "yclid", "msclkid", "mc_cid", "mc_eid", "igshid"}
+
+	tests := []struct {
+		name           string
+		envVars        map[string]string
+		args           []string
+		expectedRules  []string
+		expectedParams []string
+	}{
+		{
+			name:           "Defaults",
+			expectedRules:  defaultRules,
+			expectedParams: defaultParams,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"URL_NORMALIZATION": "none",
+				"TRACKING_PARAMS":   "ref, , src_*",
+			},
+			expectedRules:  []string{"none"},
+			expectedParams: []string{"ref", "src_*"},
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"URL_NORMALIZATION": "none",
+			},
+			args:           []string{"-url-normalization", "lowercase,idn", "-tracking-params", "fbclid"},
+			expectedRules:  []string{"lowercase", "idn"},
+			expectedParams: []string{"fbclid"},
+		},
+		{
+			name:           "JSON config",
+			args:           []string{"-c", configFile},
+			expectedRules:  []string{"lowercase"},
+			expectedParams: []string{"ref"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedRules, cfg.URLNormalization)
+			assert.Equal(t, tt.expectedParams, cfg.TrackingParams)
+		})
+	}
+}
+
+func TestURLPolicyConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                   os.Getenv("CONFIG"),
+		"URL_ALLOWLIST_FILE":       os.Getenv("URL_ALLOWLIST_FILE"),
+		"URL_DENYLIST_FILE":        os.Getenv("URL_DENYLIST_FILE"),
+		"URL_LIST_RELOAD_INTERVAL": os.Getenv("URL_LIST_RELOAD_INTERVAL"),
+		"ALLOW_PRIVATE_URLS":       os.Getenv("ALLOW_PRIVATE_URLS"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"url_denylist_file": "/json/deny.txt", "url_list_reload_interval": "0s", "allow_private_urls": true}`), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		envVars          map[string]string
+		args             []string
+		expectedAllow    string
+		expectedDeny     string
+		expectedInterval time.Duration
+		expectedPrivate  bool
+	}{
+		{
+			name:             "Defaults",
+			expectedInterval: 30 * time.Second,
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"URL_ALLOWLIST_FILE":       "/env/allow.txt",
+				"URL_DENYLIST_FILE":        "/env/deny.txt",
+				"URL_LIST_RELOAD_INTERVAL": "1m",
+				"ALLOW_PRIVATE_URLS":       "true",
+			},
+			expectedAllow:    "/env/allow.txt",
+			expectedDeny:     "/env/deny.txt",
+			expectedInterval: time.Minute,
+			expectedPrivate:  true,
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"URL_DENYLIST_FILE":        "/env/deny.txt",
+				"URL_LIST_RELOAD_INTERVAL": "1m",
+			},
+			args:             []string{"-url-denylist", "/flag/deny.txt", "-url-list-reload-interval", "0", "-allow-private-urls"},
+			expectedDeny:     "/flag/deny.txt",
+			expectedInterval: 0,
+			expectedPrivate:  true,
+		},
+		{
+			name:             "JSON config",
+			args:             []string{"-c", configFile},
+			expectedDeny:     "/json/deny.txt",
+			expectedInterval: 0,
+			expectedPrivate:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedAllow, cfg.URLAllowlistFile)
+			assert.Equal(t, tt.expectedDeny, cfg.URLDenylistFile)
+			assert.Equal(t, tt.expectedInterval, cfg.URLListReloadInterval)
+			assert.Equal(t, tt.expectedPrivate, cfg.AllowPrivateURLs)
+		})
+	}
+}
+
+func TestJWTConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":                 os.Getenv("CONFIG"),
+		"JWT_SECRET":             os.Getenv("JWT_SECRET"),
+		"JWT_KEY_FILE":           os.Getenv("JWT_KEY_FILE"),
+		"JWT_PREVIOUS_SECRETS":   os.Getenv("JWT_PREVIOUS_SECRETS"),
+		"JWT_PREVIOUS_KEY_FILES": os.Getenv("JWT_PREVIOUS_KEY_FILES"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"jwt_key_file": "/json/key.pem", "jwt_previous_key_files": ["/json/old.pub"]}`), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                    string
+		envVars                 map[string]string
+		args                    []string
+		expectedSecret          string
+		expectedKeyFile         string
+		expectedPreviousSecrets []string
+		expectedPreviousFiles   []string
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"JWT_SECRET":             "env-secret",
+				"JWT_PREVIOUS_SECRETS":   "old-secret, older-secret",
+				"JWT_PREVIOUS_KEY_FILES": "/env/old.pub",
+			},
+			expectedSecret:          "env-secret",
+			expectedPreviousSecrets: []string{"old-secret", "older-secret"},
+			expectedPreviousFiles:   []string{"/env/old.pub"},
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"JWT_SECRET":   "env-secret",
+				"JWT_KEY_FILE": "/env/key.pem",
+			},
+			args:                    []string{"-jwt-secret", "flag-secret", "-jwt-key-file", "/flag/key.pem", "-jwt-previous-secrets", "flag-old"},
+			expectedSecret:          "flag-secret",
+			expectedKeyFile:         "/flag/key.pem",
+			expectedPreviousSecrets: []string{"flag-old"},
+		},
+		{
+			name:                  "JSON config",
+			args:                  []string{"-c", configFile},
+			expectedKeyFile:       "/json/key.pem",
+			expectedPreviousFiles: []string{"/json/old.pub"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedSecret, cfg.JWTSecret)
+			assert.Equal(t, tt.expectedKeyFile, cfg.JWTKeyFile)
+			assert.Equal(t, tt.expectedPreviousSecrets, cfg.JWTPreviousSecrets)
+			assert.Equal(t, tt.expectedPreviousFiles, cfg.JWTPreviousKeyFiles)
+		})
+	}
+}
+
+func TestSessionConfig(t *testing.T) {
+	originalEnvVars := map[string]string{
+		"CONFIG":               os.Getenv("CONFIG"),
+		"SESSION_TTL":          os.Getenv("SESSION_TTL"),
+		"SESSION_RENEW_BEFORE": os.Getenv("SESSION_RENEW_BEFORE"),
+		"COOKIE_DOMAIN":        os.Getenv("COOKIE_DOMAIN"),
+		"COOKIE_SECURE":        os.Getenv("COOKIE_SECURE"),
+		"COOKIE_SAMESITE":      os.Getenv("COOKIE_SAMESITE"),
+	}
+	originalArgs := os.Args
+
+	defer func() {
+		for key, value := range originalEnvVars {
+			if value != "" {
+				os.Setenv(key, value)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+		os.Args = originalArgs
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	}()
+
+	tmpDir := t.TempDir()
+	configFile := filepath.Join(tmpDir, "config.json")
+	err := os.WriteFile(configFile, []byte(`{"session_ttl": "72h", "session_renew_before": "0s", "cookie_domain": "json.example", "cookie_secure": true, "cookie_samesite": "none"}`), 0644)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name                string
+		envVars             map[string]string
+		args                []string
+		expectedTTL         time.Duration
+		expectedRenewBefore time.Duration
+		expectedDomain      string
+		expectedSecure      bool
+		expectedSameSite    string
+	}{
+		{
+			name:                "Defaults",
+			expectedTTL:         30 * 24 * time.Hour,
+			expectedRenewBefore: 7 * 24 * time.Hour,
+			expectedSameSite:    "lax",
+		},
+		{
+			name: "Env vars",
+			envVars: map[string]string{
+				"SESSION_TTL":          "24h",
+				"SESSION_RENEW_BEFORE": "1h",
+				"COOKIE_DOMAIN":        "env.example",
+				"COOKIE_SECURE":        "true",
+				"COOKIE_SAMESITE":      "strict",
+			},
+			expectedTTL:         24 * time.Hour,
+			expectedRenewBefore: time.Hour,
+			expectedDomain:      "env.example",
+			expectedSecure:      true,
+			expectedSameSite:    "strict",
+		},
+		{
+			name: "Flags override env vars",
+			envVars: map[string]string{
+				"SESSION_TTL":   "24h",
+				"COOKIE_DOMAIN": "env.example",
+			},
+			args:                []string{"-session-ttl", "12h", "-session-renew-before", "0", "-cookie-domain", "flag.example", "-cookie-samesite", "strict"},
+			expectedTTL:         12 * time.Hour,
+			expectedRenewBefore: 0,
+			expectedDomain:      "flag.example",
+			expectedSameSite:    "strict",
+		},
+		{
+			name:                "JSON config",
+			args:                []string{"-c", configFile},
+			expectedTTL:         72 * time.Hour,
+			expectedRenewBefore: 0,
+			expectedDomain:      "json.example",
+			expectedSecure:      true,
+			expectedSameSite:    "none",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key := range originalEnvVars {
+				os.Unsetenv(key)
+			}
+			for key, value := range tt.envVars {
+				os.Setenv(key, value)
+			}
+
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+			os.Args = append([]string{"cmd"}, tt.args...)
+
+			cfg, err := NewConfig()
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedTTL, cfg.SessionTTL)
+			assert.Equal(t, tt.expectedRenewBefore, cfg.SessionRenewBefore)
+			assert.Equal(t, tt.expectedDomain, cfg.CookieDomain)
+			assert.Equal(t, tt.expectedSecure, cfg.CookieSecure)
+			assert.Equal(t, tt.expectedSameSite, cfg.CookieSameSite)
+		})
+	}
+
+	t.Run("Invalid duration", func(t *testing.T) {
+		for key := range originalEnvVars {
+			os.Unsetenv(key)
+		}
+		os.Setenv("SESSION_TTL", "forever")
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = []string{"cmd"}
+
+		_, err := NewConfig()
+		assert.Error(t, err)
+	})
+}
@@ -25,3 +25,34 @@ func GetUserID(ctx context.Context) (uuid.UUID, bool) {
 func WithUserID(ctx context.Context, userID uuid.UUID) context.Context {
 	return context.WithValue(ctx, userIDContextKey, userID)
 }
+
+// scopesContextKey is a global variable that holds the context key for the scopes of an API key.
+var scopesContextKey = &contextKey{"scopes"}
+
+// WithScopes is a function that adds the scopes of the API key that
+// authenticated the request to the context.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesContextKey, scopes)
+}
+
+// GetScopes is a function that retrieves the scopes of the API key from the
+// context. It reports false for requests authenticated without an API key.
+func GetScopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesContextKey).([]string)
+	return scopes, ok
+}
+
+// HasScope is a function that reports whether the request may act with the
+// scope. Requests authenticated without an API key have all scopes.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, ok := GetScopes(ctx)
+	if !ok {
+		return true
+	}
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
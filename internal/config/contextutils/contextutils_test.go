@@ -45,3 +45,29 @@ func TestUserIDContext(t *testing.T) {
 		assert.Equal(t, uuid.UUID{}, userID, "UserID should be empty for wrong type")
 	})
 }
+
+func TestScopesContext(t *testing.T) {
+	t.Run("Context without scopes has all scopes", func(t *testing.T) {
+		// Запрос без API-ключа не ограничен областями доступа
+		_, ok := GetScopes(context.Background())
+		assert.False(t, ok, "GetScopes should return false for empty context")
+		assert.True(t, HasScope(context.Background(), "links:write"))
+	})
+
+	t.Run("Context with scopes", func(t *testing.T) {
+		// Создаем контекст с областями доступа ключа
+		ctx := WithScopes(context.Background(), []string{"links:read"})
+
+		scopes, ok := GetScopes(ctx)
+		assert.True(t, ok, "GetScopes should return true")
+		assert.Equal(t, []string{"links:read"}, scopes)
+		assert.True(t, HasScope(ctx, "links:read"))
+		assert.False(t, HasScope(ctx, "links:write"))
+	})
+
+	t.Run("Context with empty scopes", func(t *testing.T) {
+		// Ключ без областей доступа ничего не разрешает
+		ctx := WithScopes(context.Background(), []string{})
+		assert.False(t, HasScope(ctx, "links:read"))
+	})
+}
@@ -2,40 +2,45 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"fmt"
 
 	_ "github.com/jackc/pgx/v5/stdlib"
 )
 
-// Connect is a function that connects to the database.
+// Connect is a function that connects to the database and applies pending migrations.
 func Connect(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("pgx", dsn)
+	db, err := Open(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := initialize(db); err != nil {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
+	if _, err := migrator.Up(context.Background(), 0); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
 	return db, nil
 }
 
-// initialize is a function that initializes the database.
-func initialize(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS urls (
-		uuid UUID PRIMARY KEY,
-		short_url VARCHAR(8) NOT NULL UNIQUE,
-		original_url TEXT NOT NULL UNIQUE,
-		user_id UUID NOT NULL,
-		is_deleted BOOLEAN NOT NULL DEFAULT FALSE
-	);`
-
-	_, err := db.Exec(query)
+// Open is a function that connects to the database without touching its schema.
+func Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open("pgx", dsn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config/logger"
+)
+
+func init() {
+	// Инициализация логгера для тестов
+	logger.Log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Embedded migrations", func(t *testing.T) {
+		migrations, err := Migrations()
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+
+		// Версии идут по возрастанию без повторов
+		for i := 1; i < len(migrations); i++ {
+			assert.Less(t, migrations[i-1].Version, migrations[i].Version)
+		}
+		assert.Equal(t, int64(1), migrations[0].Version)
+		assert.Equal(t, "create_urls", migrations[0].Name)
+	})
+
+	t.Run("Ordered by version", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0010_second.up.sql":   {Data: []byte("CREATE TABLE b ();")},
+			"m/0010_second.down.sql": {Data: []byte("DROP TABLE b;")},
+			"m/0002_first.up.sql":    {Data: []byte("CREATE TABLE a ();")},
+			"m/0002_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+			"m/README.md":            {Data: []byte("ignored")},
+		}
+
+		migrations, err := loadMigrations(fsys, "m")
+		require.NoError(t, err)
+		assert.Equal(t, []Migration{
+			{Version: 2, Name: "first", Up: "CREATE TABLE a ();", Down: "DROP TABLE a;"},
+			{Version: 10, Name: "second", Up: "CREATE TABLE b ();", Down: "DROP TABLE b;"},
+		}, migrations)
+	})
+
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Missing down step",
+			fsys: fstest.MapFS{
+				"m/0001_init.up.sql": {Data: []byte("CREATE TABLE a ();")},
+			},
+		},
+		{
+			name: "Invalid version",
+			fsys: fstest.MapFS{
+				"m/first_init.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+				"m/first_init.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+		},
+		{
+			name: "Missing name",
+			fsys: fstest.MapFS{
+				"m/0001.up.sql":   {Data: []byte("CREATE TABLE a ();")},
+				"m/0001.down.sql": {Data: []byte("DROP TABLE a;")},
+			},
+		},
+		{
+			name: "Conflicting names",
+			fsys: fstest.MapFS{
+				"m/0001_init.up.sql":  {Data: []byte("CREATE TABLE a ();")},
+				"m/0001_other.up.sql": {Data: []byte("CREATE TABLE b ();")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadMigrations(tt.fsys, "m")
+			assert.Error(t, err)
+		})
+	}
+}
+
+// TestMigrator требует базу данных, поэтому запускается только при заданном TEST_DATABASE_DSN.
+func TestMigrator(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	db, err := Open(dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	for _, status := range statuses {
+		assert.True(t, status.Applied, "migration %d", status.Version)
+	}
+
+	// Откатываем последнюю миграцию и применяем ее снова
+	rolledBack, err := migrator.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, rolledBack, 1)
+
+	statuses, err = migrator.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, statuses[len(statuses)-1].Applied)
+
+	applied, err := migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Equal(t, rolledBack, applied)
+
+	// Повторный запуск ничего не делает
+	applied, err = migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+}
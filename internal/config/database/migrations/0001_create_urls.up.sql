@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS urls (
+	uuid UUID PRIMARY KEY,
+	short_url VARCHAR(8) NOT NULL UNIQUE,
+	original_url TEXT NOT NULL UNIQUE,
+	user_id UUID NOT NULL,
+	is_deleted BOOLEAN NOT NULL DEFAULT FALSE
+);
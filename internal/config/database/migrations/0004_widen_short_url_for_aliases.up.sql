@@ -0,0 +1 @@
+ALTER TABLE urls ALTER COLUMN short_url TYPE VARCHAR(64);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS urls_expires_at_idx;
+ALTER TABLE urls DROP COLUMN expires_at;
@@ -0,0 +1,2 @@
+ALTER TABLE urls ADD COLUMN expires_at TIMESTAMPTZ;
+CREATE INDEX urls_expires_at_idx ON urls (expires_at) WHERE expires_at IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS link_visitors;
+DROP TABLE IF EXISTS link_user_agents;
+DROP TABLE IF EXISTS link_referrers;
+DROP TABLE IF EXISTS link_clicks;
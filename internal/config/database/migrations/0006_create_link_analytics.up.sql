@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS link_clicks (
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE ON UPDATE CASCADE,
+	bucket TIMESTAMPTZ NOT NULL,
+	clicks BIGINT NOT NULL,
+	PRIMARY KEY (short_url, bucket)
+);
+
+CREATE TABLE IF NOT EXISTS link_referrers (
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE ON UPDATE CASCADE,
+	referrer TEXT NOT NULL,
+	clicks BIGINT NOT NULL,
+	PRIMARY KEY (short_url, referrer)
+);
+
+CREATE TABLE IF NOT EXISTS link_user_agents (
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE ON UPDATE CASCADE,
+	user_agent TEXT NOT NULL,
+	clicks BIGINT NOT NULL,
+	PRIMARY KEY (short_url, user_agent)
+);
+
+CREATE TABLE IF NOT EXISTS link_visitors (
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE ON UPDATE CASCADE,
+	ip TEXT NOT NULL,
+	PRIMARY KEY (short_url, ip)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN deleted_at TIMESTAMPTZ;
+UPDATE urls SET deleted_at = now() WHERE is_deleted;
+CREATE INDEX urls_deleted_at_idx ON urls (deleted_at) WHERE is_deleted;
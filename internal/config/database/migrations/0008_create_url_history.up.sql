@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS url_history (
+	short_url VARCHAR(64) NOT NULL REFERENCES urls (short_url) ON DELETE CASCADE ON UPDATE CASCADE,
+	version INTEGER NOT NULL,
+	original_url TEXT NOT NULL,
+	previous_url TEXT NOT NULL,
+	changed_at TIMESTAMPTZ NOT NULL,
+	changed_by UUID NOT NULL,
+	PRIMARY KEY (short_url, version)
+);
@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN redirect_code SMALLINT NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE urls ADD COLUMN created_at TIMESTAMPTZ;
+ALTER TABLE urls ALTER COLUMN created_at SET DEFAULT now();
+ALTER TABLE urls ADD COLUMN title TEXT NOT NULL DEFAULT '';
+ALTER TABLE urls ADD COLUMN preview BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS accounts (
+	id UUID PRIMARY KEY,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
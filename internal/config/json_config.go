@@ -2,16 +2,63 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
+	"time"
 )
 
 // JSONConfig представляет структуру конфигурации из JSON файла
 type JSONConfig struct {
 	ServerAddress   string `json:"server_address"`
 	BaseURL         string `json:"base_url"`
+	StorageBackend  string `json:"storage_backend"`
 	FileStoragePath string `json:"file_storage_path"`
-	DatabaseDSN     string `json:"database_dsn"`
-	EnableHTTPS     bool   `json:"enable_https"`
+	FileStorageSync string `json:"file_storage_sync"`
+	// FileStorageCompactInterval задается строкой длительности, например "10m"
+	FileStorageCompactInterval string `json:"file_storage_compact_interval"`
+	DatabaseDSN                string `json:"database_dsn"`
+	ShortenerStrategy          string `json:"shortener_strategy"`
+	ShortURLLength             int    `json:"short_url_length"`
+	// CacheSize задается указателем, чтобы отличать 0 (кэш выключен) от отсутствия значения
+	CacheSize         *int   `json:"cache_size"`
+	CacheTTL          string `json:"cache_ttl"`
+	CacheNegativeTTL  string `json:"cache_negative_ttl"`
+	ReaperInterval    string `json:"reaper_interval"`
+	ExpiredRetention  string `json:"expired_retention"`
+	DeleteGracePeriod string `json:"delete_grace_period"`
+	// Параметры фонового удаления ссылок пользователей
+	DeleteQueueSize     int    `json:"delete_queue_size"`
+	DeleteBatchSize     int    `json:"delete_batch_size"`
+	DeleteWorkers       int    `json:"delete_workers"`
+	DeleteFlushInterval string `json:"delete_flush_interval"`
+	DeleteFlushTimeout  string `json:"delete_flush_timeout"`
+	RedirectCode        int    `json:"redirect_code"`
+	// RedirectCacheMaxAge задается строкой длительности, "0s" выключает кэширование
+	RedirectCacheMaxAge string   `json:"redirect_cache_max_age"`
+	ForcePreview        bool     `json:"force_preview"`
+	FlaggedDomains      []string `json:"flagged_domains"`
+	// PasswordMaxAttempts задается указателем, чтобы отличать 0 (без ограничения) от отсутствия значения
+	PasswordMaxAttempts   *int     `json:"password_max_attempts"`
+	PasswordAttemptWindow string   `json:"password_attempt_window"`
+	TrustProxyHeaders     bool     `json:"trust_proxy_headers"`
+	URLNormalization      []string `json:"url_normalization"`
+	TrackingParams        []string `json:"tracking_params"`
+	URLAllowlistFile      string   `json:"url_allowlist_file"`
+	URLDenylistFile       string   `json:"url_denylist_file"`
+	// URLListReloadInterval задается строкой длительности, "0s" выключает перезагрузку
+	URLListReloadInterval string   `json:"url_list_reload_interval"`
+	AllowPrivateURLs      bool     `json:"allow_private_urls"`
+	JWTSecret             string   `json:"jwt_secret"`
+	JWTKeyFile            string   `json:"jwt_key_file"`
+	JWTPreviousSecrets    []string `json:"jwt_previous_secrets"`
+	JWTPreviousKeyFiles   []string `json:"jwt_previous_key_files"`
+	SessionTTL            string   `json:"session_ttl"`
+	// SessionRenewBefore задается строкой длительности, "0s" выключает продление
+	SessionRenewBefore string `json:"session_renew_before"`
+	CookieDomain       string `json:"cookie_domain"`
+	CookieSecure       bool   `json:"cookie_secure"`
+	CookieSameSite     string `json:"cookie_samesite"`
+	EnableHTTPS        bool   `json:"enable_https"`
 }
 
 // loadJSONConfig загружает конфигурацию из JSON файла
@@ -42,9 +89,9 @@ func loadJSONConfig(configPath string) (*JSONConfig, error) {
 }
 
 // mergeConfig объединяет значения из JSON конфигурации с существующей конфигурацией
-func (c *Config) mergeConfig(jsonConfig *JSONConfig) {
+func (c *Config) mergeConfig(jsonConfig *JSONConfig) error {
 	if jsonConfig == nil {
-		return
+		return nil
 	}
 
 	if jsonConfig.ServerAddress != "" {
@@ -53,11 +100,171 @@ func (c *Config) mergeConfig(jsonConfig *JSONConfig) {
 	if jsonConfig.BaseURL != "" {
 		c.BaseURL = jsonConfig.BaseURL
 	}
+	if jsonConfig.StorageBackend != "" {
+		c.StorageBackend = jsonConfig.StorageBackend
+	}
 	if jsonConfig.FileStoragePath != "" {
 		c.FilePath = jsonConfig.FileStoragePath
 	}
+	if jsonConfig.FileStorageSync != "" {
+		c.FileSyncPolicy = jsonConfig.FileStorageSync
+	}
+	if jsonConfig.FileStorageCompactInterval != "" {
+		interval, err := time.ParseDuration(jsonConfig.FileStorageCompactInterval)
+		if err != nil {
+			return fmt.Errorf("invalid file_storage_compact_interval: %w", err)
+		}
+		c.FileCompactInterval = interval
+	}
 	if jsonConfig.DatabaseDSN != "" {
 		c.DatabaseDSN = jsonConfig.DatabaseDSN
 	}
+	if jsonConfig.ShortenerStrategy != "" {
+		c.ShortenerStrategy = jsonConfig.ShortenerStrategy
+	}
+	if jsonConfig.ShortURLLength != 0 {
+		c.ShortURLLength = jsonConfig.ShortURLLength
+	}
+	if jsonConfig.CacheSize != nil {
+		c.CacheSize = *jsonConfig.CacheSize
+	}
+	if jsonConfig.CacheTTL != "" {
+		ttl, err := time.ParseDuration(jsonConfig.CacheTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cache_ttl: %w", err)
+		}
+		c.CacheTTL = ttl
+	}
+	if jsonConfig.CacheNegativeTTL != "" {
+		ttl, err := time.ParseDuration(jsonConfig.CacheNegativeTTL)
+		if err != nil {
+			return fmt.Errorf("invalid cache_negative_ttl: %w", err)
+		}
+		c.CacheNegativeTTL = ttl
+	}
+	if jsonConfig.ReaperInterval != "" {
+		interval, err := time.ParseDuration(jsonConfig.ReaperInterval)
+		if err != nil {
+			return fmt.Errorf("invalid reaper_interval: %w", err)
+		}
+		c.ReaperInterval = interval
+	}
+	if jsonConfig.ExpiredRetention != "" {
+		retention, err := time.ParseDuration(jsonConfig.ExpiredRetention)
+		if err != nil {
+			return fmt.Errorf("invalid expired_retention: %w", err)
+		}
+		c.ExpiredRetention = retention
+	}
+	if jsonConfig.DeleteGracePeriod != "" {
+		gracePeriod, err := time.ParseDuration(jsonConfig.DeleteGracePeriod)
+		if err != nil {
+			return fmt.Errorf("invalid delete_grace_period: %w", err)
+		}
+		c.DeleteGracePeriod = gracePeriod
+	}
+	if jsonConfig.DeleteQueueSize != 0 {
+		c.DeleteQueueSize = jsonConfig.DeleteQueueSize
+	}
+	if jsonConfig.DeleteBatchSize != 0 {
+		c.DeleteBatchSize = jsonConfig.DeleteBatchSize
+	}
+	if jsonConfig.DeleteWorkers != 0 {
+		c.DeleteWorkers = jsonConfig.DeleteWorkers
+	}
+	if jsonConfig.DeleteFlushInterval != "" {
+		value, err := time.ParseDuration(jsonConfig.DeleteFlushInterval)
+		if err != nil {
+			return fmt.Errorf("invalid delete_flush_interval: %w", err)
+		}
+		c.DeleteFlushInterval = value
+	}
+	if jsonConfig.DeleteFlushTimeout != "" {
+		value, err := time.ParseDuration(jsonConfig.DeleteFlushTimeout)
+		if err != nil {
+			return fmt.Errorf("invalid delete_flush_timeout: %w", err)
+		}
+		c.DeleteFlushTimeout = value
+	}
+	if jsonConfig.RedirectCode != 0 {
+		c.RedirectCode = jsonConfig.RedirectCode
+	}
+	if jsonConfig.RedirectCacheMaxAge != "" {
+		maxAge, err := time.ParseDuration(jsonConfig.RedirectCacheMaxAge)
+		if err != nil {
+			return fmt.Errorf("invalid redirect_cache_max_age: %w", err)
+		}
+		c.RedirectCacheMaxAge = maxAge
+	}
+	c.ForcePreview = c.ForcePreview || jsonConfig.ForcePreview
+	if len(jsonConfig.FlaggedDomains) > 0 {
+		c.FlaggedDomains = jsonConfig.FlaggedDomains
+	}
+	if jsonConfig.PasswordMaxAttempts != nil {
+		c.PasswordMaxAttempts = *jsonConfig.PasswordMaxAttempts
+	}
+	if jsonConfig.PasswordAttemptWindow != "" {
+		window, err := time.ParseDuration(jsonConfig.PasswordAttemptWindow)
+		if err != nil {
+			return fmt.Errorf("invalid password_attempt_window: %w", err)
+		}
+		c.PasswordAttemptWindow = window
+	}
+	c.TrustProxyHeaders = c.TrustProxyHeaders || jsonConfig.TrustProxyHeaders
+	if len(jsonConfig.URLNormalization) > 0 {
+		c.URLNormalization = jsonConfig.URLNormalization
+	}
+	if len(jsonConfig.TrackingParams) > 0 {
+		c.TrackingParams = jsonConfig.TrackingParams
+	}
+	if jsonConfig.URLAllowlistFile != "" {
+		c.URLAllowlistFile = jsonConfig.URLAllowlistFile
+	}
+	if jsonConfig.URLDenylistFile != "" {
+		c.URLDenylistFile = jsonConfig.URLDenylistFile
+	}
+	if jsonConfig.URLListReloadInterval != "" {
+		interval, err := time.ParseDuration(jsonConfig.URLListReloadInterval)
+		if err != nil {
+			return fmt.Errorf("invalid url_list_reload_interval: %w", err)
+		}
+		c.URLListReloadInterval = interval
+	}
+	c.AllowPrivateURLs = c.AllowPrivateURLs || jsonConfig.AllowPrivateURLs
+	if jsonConfig.JWTSecret != "" {
+		c.JWTSecret = jsonConfig.JWTSecret
+	}
+	if jsonConfig.JWTKeyFile != "" {
+		c.JWTKeyFile = jsonConfig.JWTKeyFile
+	}
+	if len(jsonConfig.JWTPreviousSecrets) > 0 {
+		c.JWTPreviousSecrets = jsonConfig.JWTPreviousSecrets
+	}
+	if len(jsonConfig.JWTPreviousKeyFiles) > 0 {
+		c.JWTPreviousKeyFiles = jsonConfig.JWTPreviousKeyFiles
+	}
+	if jsonConfig.SessionTTL != "" {
+		ttl, err := time.ParseDuration(jsonConfig.SessionTTL)
+		if err != nil {
+			return fmt.Errorf("invalid session_ttl: %w", err)
+		}
+		c.SessionTTL = ttl
+	}
+	if jsonConfig.SessionRenewBefore != "" {
+		renewBefore, err := time.ParseDuration(jsonConfig.SessionRenewBefore)
+		if err != nil {
+			return fmt.Errorf("invalid session_renew_before: %w", err)
+		}
+		c.SessionRenewBefore = renewBefore
+	}
+	if jsonConfig.CookieDomain != "" {
+		c.CookieDomain = jsonConfig.CookieDomain
+	}
+	c.CookieSecure = c.CookieSecure || jsonConfig.CookieSecure
+	if jsonConfig.CookieSameSite != "" {
+		c.CookieSameSite = jsonConfig.CookieSameSite
+	}
 	c.EnableHTTPS = c.EnableHTTPS || jsonConfig.EnableHTTPS
+
+	return nil
 }
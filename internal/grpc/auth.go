@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/middleware"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+	pb "github.com/learies/goShortener/proto"
+)
+
+// APIKeyVerifier looks up an active API key. It returns
+// storeerr.ErrAPIKeyNotFound for unknown and revoked keys.
+type APIKeyVerifier interface {
+	Verify(ctx context.Context, key string) (models.APIKey, error)
+}
+
+// methodScopes are the scopes a caller needs to call the methods. Every
+// listed method requires an authenticated user; methods that are not listed
+// are open to anonymous callers.
+var methodScopes = map[string]string{
+	pb.URLShortener_CreateShortURL_FullMethodName:      models.ScopeLinksWrite,
+	pb.URLShortener_CreateBatchShortURL_FullMethodName: models.ScopeLinksWrite,
+	pb.URLShortener_UpdateShortURL_FullMethodName:      models.ScopeLinksWrite,
+	pb.URLShortener_GetUserURLs_FullMethodName:         models.ScopeLinksRead,
+	pb.URLShortener_DeleteUserURLs_FullMethodName:      models.ScopeLinksDelete,
+	pb.URLShortener_GetStats_FullMethodName:            models.ScopeStatsRead,
+	pb.URLShortener_GetLinkStats_FullMethodName:        models.ScopeStatsRead,
+}
+
+// AuthInterceptor authenticates calls with a credential passed in the
+// "authorization" metadata as "Bearer <credential>". A credential with
+// models.APIKeyPrefix is an API key checked by apiKeys, which may be nil to
+// disable API keys; the call then has only the scopes of the key. Any other
+// credential is a session token verified with keys. The authenticated user is
+// put into the context. Calls of methods listed in methodScopes are rejected
+// with Unauthenticated without a valid credential and with PermissionDenied
+// outside the scopes of the key.
+func AuthInterceptor(apiKeys APIKeyVerifier, keys *middleware.Keyring) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		credential, ok := bearerKey(ctx)
+		if ok {
+			var err error
+			if apiKeys != nil && strings.HasPrefix(credential, models.APIKeyPrefix) {
+				ctx, err = authenticateAPIKey(ctx, apiKeys, credential)
+			} else {
+				ctx, err = authenticateSession(ctx, keys, credential)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		scope, scoped := methodScopes[info.FullMethod]
+		if !scoped {
+			return handler(ctx, req)
+		}
+		if _, ok := contextutils.GetUserID(ctx); !ok {
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		}
+		if !contextutils.HasScope(ctx, scope) {
+			return nil, status.Errorf(codes.PermissionDenied, "API key lacks scope %s", scope)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// authenticateAPIKey puts the owner of the API key and its scopes into the context.
+func authenticateAPIKey(ctx context.Context, apiKeys APIKeyVerifier, key string) (context.Context, error) {
+	apiKey, err := apiKeys.Verify(ctx, key)
+	if errors.Is(err, storeerr.ErrAPIKeyNotFound) {
+		return nil, status.Error(codes.Unauthenticated, "API key is invalid or revoked")
+	}
+	if err != nil {
+		logger.Log.Error("Failed to verify API key", "error", err)
+		return nil, status.Error(codes.Internal, "could not verify API key")
+	}
+
+	ctx = contextutils.WithUserID(ctx, apiKey.UserID)
+	return contextutils.WithScopes(ctx, apiKey.Scopes), nil
+}
+
+// authenticateSession puts the user of a session token signed with keys into
+// the context. Session tokens are not limited by scopes.
+func authenticateSession(ctx context.Context, keys *middleware.Keyring, token string) (context.Context, error) {
+	if keys == nil {
+		return nil, status.Error(codes.Unauthenticated, "token is invalid")
+	}
+
+	claims := &middleware.Claims{}
+	err := keys.Parse(token, claims)
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, status.Error(codes.Unauthenticated, "token expired")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "token is invalid")
+	}
+	userID, err := uuid.Parse(claims.UserID)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "token is invalid")
+	}
+
+	return contextutils.WithUserID(ctx, userID), nil
+}
+
+// bearerKey returns the credential of the "authorization" metadata.
+func bearerKey(ctx context.Context) (string, bool) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", false
+	}
+	for _, value := range md.Get("authorization") {
+		scheme, token, found := strings.Cut(value, " ")
+		if found && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token), true
+		}
+	}
+	return "", false
+}
+
+// authenticatedUserID returns the authenticated user of a call. The user ID
+// of the request is never trusted: calls without an authenticated identity
+// are rejected with Unauthenticated, and requested, when set, must name the
+// authenticated user.
+func authenticatedUserID(ctx context.Context, requested string) (uuid.UUID, error) {
+	userID, ok := contextutils.GetUserID(ctx)
+	if !ok {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "authentication required")
+	}
+
+	if requested != "" && requested != userID.String() {
+		return uuid.Nil, status.Error(codes.PermissionDenied, "cannot act for another user")
+	}
+	return userID, nil
+}
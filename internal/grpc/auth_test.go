@@ -0,0 +1,146 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/learies/goShortener/internal/middleware"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+	pb "github.com/learies/goShortener/proto"
+)
+
+// stubVerifier принимает ключи из своей карты
+type stubVerifier map[string]models.APIKey
+
+func (v stubVerifier) Verify(ctx context.Context, key string) (models.APIKey, error) {
+	apiKey, ok := v[key]
+	if !ok {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+	return apiKey, nil
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	owner := uuid.New()
+	reader := models.APIKeyPrefix + "reader"
+	key, err := middleware.NewHMACKey([]byte("grpc-auth-test-secret-of-32-bytes"))
+	require.NoError(t, err)
+	keys, err := middleware.NewKeyring(key)
+	require.NoError(t, err)
+	interceptor := AuthInterceptor(stubVerifier{
+		reader: {ID: uuid.New(), UserID: owner, Scopes: []string{models.ScopeLinksRead}},
+	}, keys)
+
+	// session подписывает токен сессии пользователя со сроком жизни ttl
+	session := func(t *testing.T, userID string, ttl time.Duration) string {
+		t.Helper()
+		token, err := keys.Sign(&middleware.Claims{
+			UserID:           userID,
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl))},
+		})
+		require.NoError(t, err)
+		return token
+	}
+
+	// call вызывает метод через перехватчик и возвращает пользователя вызова
+	call := func(method, authorization, requested string) (uuid.UUID, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		result, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			return authenticatedUserID(ctx, requested)
+		})
+		if err != nil {
+			return uuid.Nil, err
+		}
+		return result.(uuid.UUID), nil
+	}
+
+	t.Run("API key identifies the user", func(t *testing.T) {
+		userID, err := call(pb.URLShortener_GetUserURLs_FullMethodName, "Bearer "+reader, "")
+		require.NoError(t, err)
+		assert.Equal(t, owner, userID)
+
+		userID, err = call(pb.URLShortener_GetUserURLs_FullMethodName, "bearer "+reader, owner.String())
+		require.NoError(t, err)
+		assert.Equal(t, owner, userID)
+	})
+
+	t.Run("API key cannot act for another user", func(t *testing.T) {
+		_, err := call(pb.URLShortener_GetUserURLs_FullMethodName, "Bearer "+reader, uuid.New().String())
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Missing scope", func(t *testing.T) {
+		for _, method := range []string{
+			pb.URLShortener_CreateShortURL_FullMethodName,
+			pb.URLShortener_DeleteUserURLs_FullMethodName,
+			pb.URLShortener_GetLinkStats_FullMethodName,
+		} {
+			_, err := call(method, "Bearer "+reader, "")
+			assert.Equal(t, codes.PermissionDenied, status.Code(err), method)
+		}
+	})
+
+	t.Run("Invalid API key", func(t *testing.T) {
+		_, err := call(pb.URLShortener_GetUserURLs_FullMethodName, "Bearer "+models.APIKeyPrefix+"revoked", "")
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Session token identifies the user", func(t *testing.T) {
+		// Токен сессии не ограничен областями доступа
+		for _, method := range []string{
+			pb.URLShortener_CreateShortURL_FullMethodName,
+			pb.URLShortener_UpdateShortURL_FullMethodName,
+			pb.URLShortener_GetLinkStats_FullMethodName,
+		} {
+			userID, err := call(method, "Bearer "+session(t, owner.String(), time.Hour), "")
+			require.NoError(t, err, method)
+			assert.Equal(t, owner, userID, method)
+		}
+
+		_, err := call(pb.URLShortener_GetUserURLs_FullMethodName, "Bearer "+session(t, owner.String(), time.Hour), uuid.New().String())
+		assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	})
+
+	t.Run("Invalid session token", func(t *testing.T) {
+		for name, token := range map[string]string{
+			"expired":    session(t, owner.String(), -time.Hour),
+			"no user":    session(t, "", time.Hour),
+			"not signed": "not-a-token",
+		} {
+			_, err := call(pb.URLShortener_GetUserURLs_FullMethodName, "Bearer "+token, "")
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), name)
+		}
+	})
+
+	t.Run("Call without credentials", func(t *testing.T) {
+		// Пользователь из запроса не принимается ни одним методом с областью доступа
+		for method := range methodScopes {
+			_, err := call(method, "", owner.String())
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), method)
+
+			_, err = call(method, "Basic dXNlcjpwYXNz", owner.String())
+			assert.Equal(t, codes.Unauthenticated, status.Code(err), method)
+		}
+
+		// Открытые методы доступны анонимно
+		result, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: pb.URLShortener_GetOriginalURL_FullMethodName}, func(ctx context.Context, req any) (any, error) {
+			return "ok", nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "ok", result)
+	})
+}
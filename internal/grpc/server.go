@@ -2,28 +2,42 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
-
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/middleware"
 	"github.com/learies/goShortener/internal/models"
 	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/store/storeerr"
 	pb "github.com/learies/goShortener/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 )
 
 // Server implements the gRPC URLShortener service
 type Server struct {
 	pb.UnimplementedURLShortenerServer
 	*grpc.Server
-	service *services.URLShortenerService
+	service       *services.URLShortenerService
+	trustedSubnet string
 }
 
-// NewServer creates a new gRPC server instance
-func NewServer(service *services.URLShortenerService) *Server {
+// NewServer creates a new gRPC server instance. Calls are authenticated with
+// API keys checked by apiKeys, which may be nil to disable API keys, and with
+// session tokens signed with keys. GetStats is served only to clients from
+// trustedSubnet in CIDR format, like the HTTP stats endpoint.
+func NewServer(service *services.URLShortenerService, apiKeys APIKeyVerifier, keys *middleware.Keyring, trustedSubnet string) *Server {
 	s := &Server{
-		Server:  grpc.NewServer(),
-		service: service,
+		Server:        grpc.NewServer(grpc.UnaryInterceptor(AuthInterceptor(apiKeys, keys))),
+		service:       service,
+		trustedSubnet: trustedSubnet,
 	}
 	pb.RegisterURLShortenerServer(s.Server, s)
 	return s
@@ -31,10 +45,49 @@ func NewServer(service *services.URLShortenerService) *Server {
 
 // CreateShortURL implements the CreateShortURL RPC method
 func (s *Server) CreateShortURL(ctx context.Context, req *pb.CreateShortURLRequest) (*pb.CreateShortURLResponse, error) {
-	// TODO: Get user ID from context (implement authentication)
-	userID := uuid.New()
+	userID, err := authenticatedUserID(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := services.ExpiryOptions(req.ExpiresIn, req.ExpiresAt)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	redirectOpts, err := services.RedirectOptions(int(req.RedirectCode))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts = append(opts, redirectOpts...)
+	previewOpts, err := services.PreviewOptions(req.Title, req.Preview)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts = append(opts, previewOpts...)
+	passwordOpts, err := services.PasswordOptions(req.Password)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts = append(opts, passwordOpts...)
+	limitOpts, err := services.MaxClicksOptions(int(req.MaxClicks))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts = append(opts, limitOpts...)
 
-	result, err := s.service.CreateShortURL(ctx, req.Url, userID)
+	result, err := s.service.CreateShortURL(ctx, req.Url, req.Alias, userID, opts...)
+	var conflict *storeerr.ErrConflict
+	var violation *services.PolicyViolation
+	switch {
+	case errors.As(err, &violation):
+		return nil, policyError(violation)
+	case errors.As(err, &conflict):
+		return nil, status.Errorf(codes.AlreadyExists, "URL is already shortened as %s", result)
+	case errors.Is(err, services.ErrAliasTaken):
+		return nil, status.Error(codes.AlreadyExists, services.ErrAliasTaken.Error())
+	case errors.Is(err, services.ErrInvalidAlias), services.IsInvalidURL(err):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create short URL: %w", err)
 	}
@@ -46,7 +99,17 @@ func (s *Server) CreateShortURL(ctx context.Context, req *pb.CreateShortURLReque
 
 // GetOriginalURL implements the GetOriginalURL RPC method
 func (s *Server) GetOriginalURL(ctx context.Context, req *pb.GetOriginalURLRequest) (*pb.GetOriginalURLResponse, error) {
-	result, err := s.service.GetOriginalURL(ctx, req.ShortUrl)
+	result, err := s.service.GetOriginalURL(ctx, req.ShortUrl, req.Password, peerIP(ctx))
+	switch {
+	case errors.Is(err, services.ErrURLExpired), errors.Is(err, storeerr.ErrLinkExhausted):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, services.ErrPasswordRequired):
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, services.ErrWrongPassword):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, services.ErrTooManyAttempts):
+		return nil, status.Error(codes.ResourceExhausted, err.Error())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get original URL: %w", err)
 	}
@@ -58,18 +121,37 @@ func (s *Server) GetOriginalURL(ctx context.Context, req *pb.GetOriginalURLReque
 
 // CreateBatchShortURL implements the CreateBatchShortURL RPC method
 func (s *Server) CreateBatchShortURL(ctx context.Context, req *pb.CreateBatchShortURLRequest) (*pb.CreateBatchShortURLResponse, error) {
-	// TODO: Get user ID from context (implement authentication)
-	userID := uuid.New()
+	userID, err := authenticatedUserID(ctx, "")
+	if err != nil {
+		return nil, err
+	}
 
 	batchRequest := make([]models.ShortenBatchRequest, len(req.Urls))
 	for i, url := range req.Urls {
 		batchRequest[i] = models.ShortenBatchRequest{
 			CorrelationID: url.CorrelationId,
 			OriginalURL:   url.OriginalUrl,
+			ExpiresIn:     url.ExpiresIn,
+			ExpiresAt:     url.ExpiresAt,
+			RedirectCode:  int(url.RedirectCode),
+			Title:         url.Title,
+			Preview:       url.Preview,
+			Password:      url.Password,
+			MaxClicks:     int(url.MaxClicks),
 		}
 	}
 
 	result, err := s.service.CreateBatchShortURL(ctx, batchRequest, userID)
+	var violation *services.PolicyViolation
+	if errors.As(err, &violation) {
+		return nil, policyError(violation)
+	}
+	if services.IsInvalidLinkOption(err) || services.IsInvalidURL(err) {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if errors.As(err, new(*storeerr.ErrConflict)) {
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create batch short URLs: %w", err)
 	}
@@ -90,10 +172,9 @@ func (s *Server) CreateBatchShortURL(ctx context.Context, req *pb.CreateBatchSho
 
 // GetUserURLs implements the GetUserURLs RPC method
 func (s *Server) GetUserURLs(ctx context.Context, req *pb.GetUserURLsRequest) (*pb.GetUserURLsResponse, error) {
-	// TODO: Get user ID from context (implement authentication)
-	userID, err := uuid.Parse(req.UserId)
+	userID, err := authenticatedUserID(ctx, req.UserId)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
 	}
 
 	result, err := s.service.GetUserURLs(ctx, userID)
@@ -110,6 +191,13 @@ func (s *Server) GetUserURLs(ctx context.Context, req *pb.GetUserURLsRequest) (*
 			ShortUrl:    url.ShortURL,
 			OriginalUrl: url.OriginalURL,
 		}
+		if url.ExpiresAt != nil {
+			response.Urls[i].ExpiresAt = url.ExpiresAt.Format(time.RFC3339)
+		}
+		if url.ClicksLeft != nil {
+			clicksLeft := int32(*url.ClicksLeft)
+			response.Urls[i].ClicksLeft = &clicksLeft
+		}
 	}
 
 	return response, nil
@@ -117,10 +205,9 @@ func (s *Server) GetUserURLs(ctx context.Context, req *pb.GetUserURLsRequest) (*
 
 // DeleteUserURLs implements the DeleteUserURLs RPC method
 func (s *Server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsRequest) (*pb.DeleteUserURLsResponse, error) {
-	// TODO: Get user ID from context (implement authentication)
-	userID, err := uuid.Parse(req.UserId)
+	userID, err := authenticatedUserID(ctx, req.UserId)
 	if err != nil {
-		return nil, fmt.Errorf("invalid user ID: %w", err)
+		return nil, err
 	}
 
 	err = s.service.DeleteUserURLs(ctx, userID, req.ShortUrls)
@@ -133,15 +220,158 @@ func (s *Server) DeleteUserURLs(ctx context.Context, req *pb.DeleteUserURLsReque
 	}, nil
 }
 
-// GetStats implements the GetStats RPC method
+// GetStats implements the GetStats RPC method for clients from the trusted subnet
 func (s *Server) GetStats(ctx context.Context, req *pb.GetStatsRequest) (*pb.GetStatsResponse, error) {
-	urlsCount, usersCount, err := s.service.GetStats(ctx)
+	if err := s.checkTrustedPeer(ctx); err != nil {
+		return nil, err
+	}
+
+	stats, err := s.service.GetStats(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get stats: %w", err)
 	}
 
 	return &pb.GetStatsResponse{
-		UrlsCount:  int32(urlsCount),
-		UsersCount: int32(usersCount),
+		UrlsCount:        int32(stats.URLs),
+		UsersCount:       int32(stats.Users),
+		ExpiredUrlsCount: int32(stats.ExpiredURLs),
 	}, nil
 }
+
+// GetLinkStats implements the GetLinkStats RPC method for links of the
+// authenticated user
+func (s *Server) GetLinkStats(ctx context.Context, req *pb.GetLinkStatsRequest) (*pb.GetLinkStatsResponse, error) {
+	userID, err := authenticatedUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := s.service.GetLinkStats(ctx, req.ShortUrl, userID, services.LinkStatsOptions{
+		Granularity: req.Granularity,
+		Top:         int(req.Top),
+	})
+	switch {
+	case errors.Is(err, services.ErrInvalidGranularity):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storeerr.ErrURLNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case err != nil:
+		return nil, fmt.Errorf("failed to get link stats: %w", err)
+	}
+
+	response := &pb.GetLinkStatsResponse{
+		ShortUrl:       stats.ShortURL,
+		Clicks:         stats.Clicks,
+		UniqueVisitors: stats.UniqueVisitors,
+		TimeSeries:     make([]*pb.ClickBucket, len(stats.TimeSeries)),
+		TopReferrers:   clickCounts(stats.TopReferrers),
+		TopUserAgents:  clickCounts(stats.TopUserAgents),
+	}
+	for i, bucket := range stats.TimeSeries {
+		response.TimeSeries[i] = &pb.ClickBucket{
+			Time:   bucket.Time.Format(time.RFC3339),
+			Clicks: bucket.Clicks,
+		}
+	}
+
+	return response, nil
+}
+
+// UpdateShortURL changes the original URL of a link owned by the
+// authenticated user
+func (s *Server) UpdateShortURL(ctx context.Context, req *pb.UpdateShortURLRequest) (*pb.UpdateShortURLResponse, error) {
+	userID, err := authenticatedUserID(ctx, req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := s.service.UpdateShortURL(ctx, req.ShortUrl, req.OriginalUrl, userID)
+	var conflict *storeerr.ErrConflict
+	var violation *services.PolicyViolation
+	switch {
+	case errors.As(err, &violation):
+		return nil, policyError(violation)
+	case services.IsInvalidURL(err), errors.Is(err, services.ErrURLUnchanged):
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storeerr.ErrURLNotFound):
+		return nil, status.Error(codes.NotFound, err.Error())
+	case errors.As(err, &conflict):
+		return nil, status.Errorf(codes.AlreadyExists, "URL is already shortened as %s", conflict.ShortURL)
+	case err != nil:
+		return nil, fmt.Errorf("failed to update short URL: %w", err)
+	}
+
+	return &pb.UpdateShortURLResponse{
+		Version:     int32(version.Version),
+		OriginalUrl: version.OriginalURL,
+		PreviousUrl: version.PreviousURL,
+		ChangedAt:   version.ChangedAt.Format(time.RFC3339),
+	}, nil
+}
+
+// policyError converts a URL rejected by the URL policy to a PermissionDenied
+// status with the reason of the rejection in its ErrorInfo details
+func policyError(violation *services.PolicyViolation) error {
+	st := status.New(codes.PermissionDenied, violation.Error())
+	metadata := map[string]string{"host": violation.Host}
+	if violation.Rule != "" {
+		metadata["rule"] = violation.Rule
+	}
+	if violation.CorrelationID != "" {
+		metadata["correlation_id"] = violation.CorrelationID
+	}
+
+	detailed, err := st.WithDetails(&errdetails.ErrorInfo{
+		Reason:   strings.ToUpper(violation.Reason),
+		Domain:   "goshortener",
+		Metadata: metadata,
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// clickCounts converts click counters to their protobuf representation
+func clickCounts(counts []models.ClickCount) []*pb.ClickCount {
+	result := make([]*pb.ClickCount, len(counts))
+	for i, count := range counts {
+		result[i] = &pb.ClickCount{Value: count.Value, Clicks: count.Clicks}
+	}
+	return result
+}
+
+// checkTrustedPeer rejects with PermissionDenied a call from a client outside
+// the trusted subnet; without a trusted subnet every call is rejected. The
+// client address is that of the connection, since metadata is set by the client
+func (s *Server) checkTrustedPeer(ctx context.Context) error {
+	if s.trustedSubnet == "" {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+
+	_, ipNet, err := net.ParseCIDR(s.trustedSubnet)
+	if err != nil {
+		logger.Log.Error("Failed to parse trusted subnet", "error", err)
+		return status.Error(codes.Internal, "invalid trusted subnet configuration")
+	}
+
+	ip := net.ParseIP(peerIP(ctx))
+	if ip == nil || !ipNet.Contains(ip) {
+		return status.Error(codes.PermissionDenied, "access denied")
+	}
+	return nil
+}
+
+// peerIP returns the IP address of the client of the RPC
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
@@ -0,0 +1,95 @@
+package grpc
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/store/memstore"
+	pb "github.com/learies/goShortener/proto"
+)
+
+func init() {
+	// Инициализация логгера для тестов
+	logger.Log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+func TestServerGetStats(t *testing.T) {
+	service := services.NewURLShortenerService(memstore.NewMemStore(), "http://localhost:8080", nil, nil, nil, nil, nil)
+
+	// fromPeer возвращает контекст вызова клиента с адресом ip
+	fromPeer := func(ip string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{
+			Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 50000},
+		})
+	}
+
+	tests := []struct {
+		name          string
+		trustedSubnet string
+		ctx           context.Context
+		expectedCode  codes.Code
+	}{
+		{
+			name:          "Client From Trusted Subnet",
+			trustedSubnet: "10.0.0.0/24",
+			ctx:           fromPeer("10.0.0.5"),
+			expectedCode:  codes.OK,
+		},
+		{
+			name:          "Client Outside Trusted Subnet",
+			trustedSubnet: "10.0.0.0/24",
+			ctx:           fromPeer("192.168.1.5"),
+			expectedCode:  codes.PermissionDenied,
+		},
+		{
+			name:          "Trusted Subnet Not Configured",
+			trustedSubnet: "",
+			ctx:           fromPeer("10.0.0.5"),
+			expectedCode:  codes.PermissionDenied,
+		},
+		{
+			// Адрес из метаданных задает сам клиент, ему не доверяем
+			name:          "Spoofed X-Real-IP Ignored",
+			trustedSubnet: "10.0.0.0/24",
+			ctx:           metadata.NewIncomingContext(fromPeer("192.168.1.5"), metadata.Pairs("x-real-ip", "10.0.0.5")),
+			expectedCode:  codes.PermissionDenied,
+		},
+		{
+			name:          "Client Without Address",
+			trustedSubnet: "10.0.0.0/24",
+			ctx:           context.Background(),
+			expectedCode:  codes.PermissionDenied,
+		},
+		{
+			name:          "Invalid Trusted Subnet",
+			trustedSubnet: "not-a-subnet",
+			ctx:           fromPeer("10.0.0.5"),
+			expectedCode:  codes.Internal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := &Server{service: service, trustedSubnet: tt.trustedSubnet}
+
+			resp, err := server.GetStats(tt.ctx, &pb.GetStatsRequest{})
+			assert.Equal(t, tt.expectedCode, status.Code(err))
+			if tt.expectedCode == codes.OK {
+				require.NotNil(t, resp)
+				assert.Zero(t, resp.UrlsCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+)
+
+// SessionStore is an interface that issues and clears the tokens of users.
+type SessionStore interface {
+	TokenIssuer
+	Clear(w http.ResponseWriter)
+}
+
+// SignUp is an HTTP handler that reads a JSON object with an email and a
+// password and registers an account. The links of the current anonymous user
+// stay with the account. It responds with 201 Created and the token of the
+// account, with 400 for an invalid email or password and with 409 Conflict
+// if the email is already registered.
+func (h *Handler) SignUp(accounts *services.AccountService, sessions SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var request models.AccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+
+		account, err := accounts.SignUp(r.Context(), request.Email, request.Password, userID)
+		switch {
+		case errors.Is(err, services.ErrInvalidEmail), errors.Is(err, services.ErrWeakPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, services.ErrEmailTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to register account", "error", err)
+			http.Error(w, "Failed to register account", http.StatusInternalServerError)
+			return
+		}
+
+		writeAccount(w, sessions, http.StatusCreated, models.AccountResponse{ID: account.ID, Email: account.Email})
+	}
+}
+
+// Login is an HTTP handler that reads a JSON object with an email and a
+// password and switches the session to the account. Links created by the
+// current anonymous user are merged into the account. It responds with the
+// token of the account and with 401 for a wrong email or password.
+func (h *Handler) Login(accounts *services.AccountService, sessions SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var request models.AccountRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+
+		account, merged, err := accounts.Login(r.Context(), request.Email, request.Password, userID)
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to log in", "error", err)
+			http.Error(w, "Failed to log in", http.StatusInternalServerError)
+			return
+		}
+
+		writeAccount(w, sessions, http.StatusOK, models.AccountResponse{ID: account.ID, Email: account.Email, MergedURLs: len(merged)})
+	}
+}
+
+// Logout is an HTTP handler that clears the session cookie, so the next
+// request starts a new anonymous session. It responds with 204 No Content.
+func (h *Handler) Logout(sessions SessionStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// A token issued to this request must not reach the client
+		w.Header().Del("Authorization")
+		sessions.Clear(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeAccount issues a token for the account and writes the response.
+func writeAccount(w http.ResponseWriter, sessions SessionStore, status int, response models.AccountResponse) {
+	token, err := sessions.Issue(w, response.ID.String())
+	if err != nil {
+		http.Error(w, "Could not create token", http.StatusInternalServerError)
+		return
+	}
+	response.TokenResponse = token
+
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "can't marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(responseBody)
+}
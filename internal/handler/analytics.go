@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// ClickTracker records redirects without blocking the request
+type ClickTracker interface {
+	Track(click models.Click) bool
+}
+
+// GetLinkStats is an HTTP handler that returns the click analytics of a short URL
+// owned by the user: total clicks, unique visitors, a time series and the top
+// referrers and user agents. The optional granularity query parameter selects
+// hourly or daily buckets, and top limits the referrers and user agents.
+func (h *Handler) GetLinkStats(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		opts := services.LinkStatsOptions{Granularity: r.URL.Query().Get("granularity")}
+		if top := r.URL.Query().Get("top"); top != "" {
+			n, err := strconv.Atoi(top)
+			if err != nil || n <= 0 {
+				http.Error(w, "top must be a positive number", http.StatusBadRequest)
+				return
+			}
+			opts.Top = n
+		}
+
+		stats, err := services.GetLinkStats(ctx, store, chi.URLParam(r, "shortURL"), userID, opts)
+		switch {
+		case errors.Is(err, services.ErrInvalidGranularity):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, storeerr.ErrURLNotFound):
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to get link stats", "error", err)
+			http.Error(w, "can't get link stats", http.StatusInternalServerError)
+			return
+		}
+
+		responseBody, err := json.Marshal(stats)
+		if err != nil {
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBody)
+	}
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// CreateAPIKey is an HTTP handler that reads a JSON object with a name and
+// scopes and creates an API key for the current user. It responds with 201
+// Created and the key, which is shown only once, and with 400 for unknown
+// scopes or a too long name.
+func (h *Handler) CreateAPIKey(apiKeys *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var request models.APIKeyRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+
+		key, plain, err := apiKeys.Create(r.Context(), userID, request.Name, request.Scopes)
+		switch {
+		case errors.Is(err, services.ErrInvalidScope), errors.Is(err, services.ErrInvalidAPIKeyName):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to create API key", "error", err)
+			http.Error(w, "Failed to create API key", http.StatusInternalServerError)
+			return
+		}
+
+		response := apiKeyResponse(key)
+		response.Key = plain
+		writeAPIKeys(w, http.StatusCreated, response)
+	}
+}
+
+// ListAPIKeys is an HTTP handler that lists the API keys of the current user,
+// including revoked ones, without the keys themselves.
+func (h *Handler) ListAPIKeys(apiKeys *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		keys, err := apiKeys.List(r.Context(), userID)
+		if err != nil {
+			logger.Log.Error("Failed to list API keys", "error", err)
+			http.Error(w, "Failed to list API keys", http.StatusInternalServerError)
+			return
+		}
+
+		response := make([]models.APIKeyResponse, len(keys))
+		for i, key := range keys {
+			response[i] = apiKeyResponse(key)
+		}
+		writeAPIKeys(w, http.StatusOK, response)
+	}
+}
+
+// RevokeAPIKey is an HTTP handler that revokes the API key with the ID of the
+// URL. It responds with the revoked key and with 404 for keys of other users
+// and keys that are already revoked.
+func (h *Handler) RevokeAPIKey(apiKeys *services.APIKeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		id, err := uuid.Parse(chi.URLParam(r, "keyID"))
+		if err != nil {
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		}
+
+		key, err := apiKeys.Revoke(r.Context(), userID, id)
+		switch {
+		case errors.Is(err, storeerr.ErrAPIKeyNotFound):
+			http.Error(w, "API key not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to revoke API key", "error", err)
+			http.Error(w, "Failed to revoke API key", http.StatusInternalServerError)
+			return
+		}
+
+		writeAPIKeys(w, http.StatusOK, apiKeyResponse(key))
+	}
+}
+
+// apiKeyResponse converts the API key to its response without the hash.
+func apiKeyResponse(key models.APIKey) models.APIKeyResponse {
+	return models.APIKeyResponse{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// writeAPIKeys writes the API keys of the response. Responses are not cached,
+// since a created key is shown only once.
+func writeAPIKeys(w http.ResponseWriter, status int, response any) {
+	responseBody, err := json.Marshal(response)
+	if err != nil {
+		http.Error(w, "can't marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	w.Write(responseBody)
+}
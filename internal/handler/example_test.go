@@ -12,6 +12,7 @@ import (
 	"github.com/learies/goShortener/internal/config/contextutils"
 	"github.com/learies/goShortener/internal/handler"
 	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
 	"github.com/learies/goShortener/internal/store/filestore"
 )
 
@@ -41,7 +42,7 @@ func ExampleHandler_CreateShortLink() {
 	ctx := contextutils.WithUserID(req.Context(), userID)
 	req = req.WithContext(ctx)
 
-	h.CreateShortLink(mockStore, baseURL, mockShortener)(rec, req)
+	h.CreateShortLink(mockStore, baseURL, mockShortener, nil, nil)(rec, req)
 
 	res := rec.Result()
 	defer res.Body.Close()
@@ -67,7 +68,7 @@ func ExampleHandler_CreateShortLink_badRequest() {
 	ctx := contextutils.WithUserID(req.Context(), userID)
 	req = req.WithContext(ctx)
 
-	h.CreateShortLink(mockStore, baseURL, mockShortener)(rec, req)
+	h.CreateShortLink(mockStore, baseURL, mockShortener, nil, nil)(rec, req)
 
 	res := rec.Result()
 	defer res.Body.Close()
@@ -86,7 +87,7 @@ func ExampleHandler_GetOriginalURL_notFound() {
 	req := httptest.NewRequest("GET", "/EwHXdJfB", nil)
 	rec := httptest.NewRecorder()
 
-	h.GetOriginalURL(mockStore)(rec, req)
+	h.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(rec, req)
 
 	res := rec.Result()
 	defer res.Body.Close()
@@ -17,6 +17,6 @@ func NewHandler() *Handler {
 }
 
 // GetStats is a method that returns statistics about the URL shortener service
-func (h *Handler) GetStats(store store.Store, trustedSubnet string) http.HandlerFunc {
-	return GetStats(store, trustedSubnet)
+func (h *Handler) GetStats(store store.Store, trustedSubnet string, deletions DeletionQueue) http.HandlerFunc {
+	return GetStats(store, trustedSubnet, deletions)
 }
@@ -3,6 +3,8 @@ package handler
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -11,14 +13,21 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/learies/goShortener/internal/config/contextutils"
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store"
 	"github.com/learies/goShortener/internal/store/filestore"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
 func init() {
@@ -33,18 +42,36 @@ func (m *MockShortener) GenerateShortURL(originalURL string) (string, error) {
 }
 
 type MockStore struct {
-	GetFunc            func(ctx context.Context, shortURL string) (models.ShortenStore, error)
-	AddFunc            func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error
-	AddBatchFunc       func(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error
-	GetUserURLsFunc    func(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error)
-	DeleteUserURLsFunc func(ctx context.Context, userShortURLs <-chan models.UserShortURL) error
-	PingFunc           func() error
-	GetStatsFunc       func(ctx context.Context) (int, int, error)
+	GetFunc               func(ctx context.Context, shortURL string) (models.ShortenStore, error)
+	AddFunc               func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error
+	AddBatchFunc          func(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error
+	GetUserURLsFunc       func(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error)
+	DeleteUserURLsFunc    func(ctx context.Context, userShortURLs <-chan models.UserShortURL) error
+	PingFunc              func() error
+	GetStatsFunc          func(ctx context.Context) (models.Stats, error)
+	PurgeExpiredFunc      func(ctx context.Context, before time.Time) (int, error)
+	RestoreUserURLsFunc   func(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error)
+	PurgeDeletedFunc      func(ctx context.Context, before time.Time) (int, error)
+	UpdateOriginalURLFunc func(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error)
+	GetURLHistoryFunc     func(ctx context.Context, shortURL string) ([]models.URLVersion, error)
+	ConsumeClickFunc      func(ctx context.Context, shortURL string) (int, error)
+	RecordClicksFunc      func(ctx context.Context, clicks []models.Click) error
+	GetLinkStatsFunc      func(ctx context.Context, shortURL string, top int) (models.LinkStats, error)
+	CreateAccountFunc     func(ctx context.Context, account models.Account) error
+	GetAccountFunc        func(ctx context.Context, id uuid.UUID) (models.Account, error)
+	GetAccountByEmailFunc func(ctx context.Context, email string) (models.Account, error)
+	MergeUserURLsFunc     func(ctx context.Context, from, to uuid.UUID) ([]string, error)
+	CreateAPIKeyFunc      func(ctx context.Context, key models.APIKey) error
+	GetAPIKeyByHashFunc   func(ctx context.Context, hash string) (models.APIKey, error)
+	ListAPIKeysFunc       func(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	RevokeAPIKeyFunc      func(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error)
+	CloseFunc             func() error
+	NextIDFunc            func(ctx context.Context) (int64, error)
 }
 
-func (m *MockStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
+func (m *MockStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
 	if m.AddFunc != nil {
-		return m.AddFunc(ctx, shortURL, originalURL, userID)
+		return m.AddFunc(ctx, shortURL, originalURL, userID, opts...)
 	}
 	return nil
 }
@@ -78,11 +105,137 @@ func (m *MockStore) Ping() error {
 	return nil
 }
 
-func (m *MockStore) GetStats(ctx context.Context) (int, int, error) {
+func (m *MockStore) Close() error {
+	if m.CloseFunc != nil {
+		return m.CloseFunc()
+	}
+	return nil
+}
+
+func (m *MockStore) GetStats(ctx context.Context) (models.Stats, error) {
 	if m.GetStatsFunc != nil {
 		return m.GetStatsFunc(ctx)
 	}
-	return 0, 0, nil
+	return models.Stats{}, nil
+}
+
+func (m *MockStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	if m.PurgeExpiredFunc != nil {
+		return m.PurgeExpiredFunc(ctx, before)
+	}
+	return 0, nil
+}
+
+func (m *MockStore) RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	if m.RestoreUserURLsFunc != nil {
+		return m.RestoreUserURLsFunc(ctx, userID, shortURLs, deletedAfter)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	if m.PurgeDeletedFunc != nil {
+		return m.PurgeDeletedFunc(ctx, before)
+	}
+	return 0, nil
+}
+
+func (m *MockStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	if m.UpdateOriginalURLFunc != nil {
+		return m.UpdateOriginalURLFunc(ctx, shortURL, userID, originalURL)
+	}
+	return models.URLVersion{}, nil
+}
+
+func (m *MockStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+	if m.GetURLHistoryFunc != nil {
+		return m.GetURLHistoryFunc(ctx, shortURL)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	if m.ConsumeClickFunc != nil {
+		return m.ConsumeClickFunc(ctx, shortURL)
+	}
+	return -1, nil
+}
+
+func (m *MockStore) RecordClicks(ctx context.Context, clicks []models.Click) error {
+	if m.RecordClicksFunc != nil {
+		return m.RecordClicksFunc(ctx, clicks)
+	}
+	return nil
+}
+
+func (m *MockStore) GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+	if m.GetLinkStatsFunc != nil {
+		return m.GetLinkStatsFunc(ctx, shortURL, top)
+	}
+	return models.LinkStats{}, nil
+}
+
+func (m *MockStore) NextID(ctx context.Context) (int64, error) {
+	if m.NextIDFunc != nil {
+		return m.NextIDFunc(ctx)
+	}
+	return 1, nil
+}
+
+func (m *MockStore) CreateAccount(ctx context.Context, account models.Account) error {
+	if m.CreateAccountFunc != nil {
+		return m.CreateAccountFunc(ctx, account)
+	}
+	return nil
+}
+
+func (m *MockStore) GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error) {
+	if m.GetAccountFunc != nil {
+		return m.GetAccountFunc(ctx, id)
+	}
+	return models.Account{}, storeerr.ErrAccountNotFound
+}
+
+func (m *MockStore) GetAccountByEmail(ctx context.Context, email string) (models.Account, error) {
+	if m.GetAccountByEmailFunc != nil {
+		return m.GetAccountByEmailFunc(ctx, email)
+	}
+	return models.Account{}, storeerr.ErrAccountNotFound
+}
+
+func (m *MockStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	if m.MergeUserURLsFunc != nil {
+		return m.MergeUserURLsFunc(ctx, from, to)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	if m.CreateAPIKeyFunc != nil {
+		return m.CreateAPIKeyFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *MockStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	if m.GetAPIKeyByHashFunc != nil {
+		return m.GetAPIKeyByHashFunc(ctx, hash)
+	}
+	return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+}
+
+func (m *MockStore) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	if m.ListAPIKeysFunc != nil {
+		return m.ListAPIKeysFunc(ctx, userID)
+	}
+	return nil, nil
+}
+
+func (m *MockStore) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	if m.RevokeAPIKeyFunc != nil {
+		return m.RevokeAPIKeyFunc(ctx, userID, id, at)
+	}
+	return models.APIKey{}, storeerr.ErrAPIKeyNotFound
 }
 
 func TestMainHandler(t *testing.T) {
@@ -100,7 +253,7 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -126,7 +279,7 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -144,16 +297,43 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
-			return fmt.Errorf("conflict error")
+		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+			return &storeerr.ErrConflict{ShortURL: "existing"}
 		}
 
-		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
 
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+
+		// Возвращается уже сохраненный короткий URL, а не новый
 		assert.Equal(t, http.StatusConflict, result.StatusCode)
+		assert.Equal(t, "http://localhost:8080/existing", string(body))
+	})
+
+	t.Run("CreateShortLinkStoreError", func(t *testing.T) {
+		reqBody := strings.NewReader("https://practicum.yandex.ru/")
+		req := httptest.NewRequest(http.MethodPost, "/", reqBody)
+		req.Header.Set("Content-Type", "text/plain")
+		recorder := httptest.NewRecorder()
+
+		userID := uuid.New()
+		ctx := contextutils.WithUserID(req.Context(), userID)
+		req = req.WithContext(ctx)
+
+		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+			return fmt.Errorf("storage error")
+		}
+
+		handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
 	})
 
 	t.Run("GetOriginalURL", func(t *testing.T) {
@@ -167,7 +347,7 @@ func TestMainHandler(t *testing.T) {
 			}, nil
 		}
 
-		handler.GetOriginalURL(mockStore)(recorder, req)
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -184,7 +364,165 @@ func TestMainHandler(t *testing.T) {
 			return models.ShortenStore{Deleted: true}, nil
 		}
 
-		handler.GetOriginalURL(mockStore)(recorder, req)
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusGone, result.StatusCode)
+	})
+
+	t.Run("GetExpiredOriginalURL", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
+		recorder := httptest.NewRecorder()
+
+		expiresAt := time.Now().Add(-time.Minute)
+		mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{
+				OriginalURL: "https://practicum.yandex.ru/",
+				LinkOptions: models.LinkOptions{ExpiresAt: &expiresAt},
+			}, nil
+		}
+
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusGone, result.StatusCode)
+		assert.Empty(t, result.Header.Get("Location"))
+	})
+
+	t.Run("GetOriginalURLTracksClick", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
+		req.Header.Set("Referer", "https://example.com/page")
+		req.Header.Set("User-Agent", "test-agent")
+		req.Header.Set("X-Real-IP", "10.0.0.1")
+		recorder := httptest.NewRecorder()
+
+		mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{ShortURL: shortURL, OriginalURL: "https://practicum.yandex.ru/"}, nil
+		}
+
+		tracker := &mockClickTracker{}
+		handler.GetOriginalURL(mockStore, tracker, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+		require.Len(t, tracker.clicks, 1)
+		assert.Equal(t, "EwHXdJfB", tracker.clicks[0].ShortURL)
+		assert.Equal(t, "https://example.com/page", tracker.clicks[0].Referrer)
+		assert.Equal(t, "test-agent", tracker.clicks[0].UserAgent)
+		assert.Equal(t, "10.0.0.1", tracker.clicks[0].IP)
+	})
+
+	t.Run("GetOriginalURLRedirectCode", func(t *testing.T) {
+		redirects := services.RedirectPolicy{DefaultCode: http.StatusFound, CacheMaxAge: time.Hour}
+		expiresAt := time.Now().Add(30 * time.Minute)
+		tests := []struct {
+			name                 string
+			link                 models.LinkOptions
+			expectedStatus       int
+			expectedCacheControl string
+		}{
+			{name: "Global default", expectedStatus: http.StatusFound, expectedCacheControl: "no-store"},
+			{name: "Permanent link", link: models.LinkOptions{RedirectCode: http.StatusMovedPermanently}, expectedStatus: http.StatusMovedPermanently, expectedCacheControl: "public, max-age=3600"},
+			{name: "Permanent link until expiry", link: models.LinkOptions{RedirectCode: http.StatusPermanentRedirect, ExpiresAt: &expiresAt}, expectedStatus: http.StatusPermanentRedirect, expectedCacheControl: "public, max-age=1799"},
+			{name: "Temporary link", link: models.LinkOptions{RedirectCode: http.StatusTemporaryRedirect}, expectedStatus: http.StatusTemporaryRedirect, expectedCacheControl: "no-store"},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
+				recorder := httptest.NewRecorder()
+
+				mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+					return models.ShortenStore{OriginalURL: "https://practicum.yandex.ru/", LinkOptions: tt.link}, nil
+				}
+
+				handler.GetOriginalURL(mockStore, nil, redirects, services.PreviewPolicy{}, nil)(recorder, req)
+
+				result := recorder.Result()
+				defer result.Body.Close()
+
+				assert.Equal(t, tt.expectedStatus, result.StatusCode)
+				assert.Equal(t, "https://practicum.yandex.ru/", result.Header.Get("Location"))
+				assert.Equal(t, tt.expectedCacheControl, result.Header.Get("Cache-Control"))
+			})
+		}
+	})
+
+	t.Run("GetOriginalURLPreview", func(t *testing.T) {
+		createdAt := time.Date(2024, time.March, 5, 10, 0, 0, 0, time.UTC)
+		previews := services.PreviewPolicy{FlaggedDomains: []string{"evil.com"}}
+		tests := []struct {
+			name            string
+			target          string
+			policy          services.PreviewPolicy
+			link            models.LinkOptions
+			originalURL     string
+			expectedPreview bool
+			expectedFlagged bool
+			expectedClicks  int
+			expectedBody    []string
+		}{
+			// Превью, запрошенное или принудительное, не считается переходом
+			{name: "Plus suffix", target: "/EwHXdJfB+", policy: previews, originalURL: "https://practicum.yandex.ru/", expectedPreview: true, expectedBody: []string{"https://practicum.yandex.ru/", "practicum.yandex.ru", "March 5, 2024"}},
+			{name: "Query parameter", target: "/EwHXdJfB?preview=1", policy: previews, originalURL: "https://practicum.yandex.ru/", link: models.LinkOptions{Title: "<b>Course</b>"}, expectedPreview: true, expectedBody: []string{"&lt;b&gt;Course&lt;/b&gt;"}},
+			{name: "Flagged domain", target: "/EwHXdJfB+", policy: previews, originalURL: "https://login.EVIL.com:8443/x", expectedPreview: true, expectedFlagged: true, expectedBody: []string{"login.evil.com"}},
+			{name: "Forced by link", target: "/EwHXdJfB", policy: previews, originalURL: "https://practicum.yandex.ru/", link: models.LinkOptions{Preview: true}, expectedPreview: true},
+			{name: "Forced globally", target: "/EwHXdJfB", policy: services.PreviewPolicy{Force: true}, originalURL: "https://practicum.yandex.ru/", expectedPreview: true},
+			{name: "Preview disabled", target: "/EwHXdJfB?preview=0", policy: previews, originalURL: "https://practicum.yandex.ru/", expectedClicks: 1},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+				recorder := httptest.NewRecorder()
+
+				var requested string
+				mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+					requested = shortURL
+					return models.ShortenStore{ShortURL: shortURL, OriginalURL: tt.originalURL, CreatedAt: &createdAt, LinkOptions: tt.link}, nil
+				}
+
+				tracker := &mockClickTracker{}
+				handler.GetOriginalURL(mockStore, tracker, services.RedirectPolicy{}, tt.policy, nil)(recorder, req)
+
+				result := recorder.Result()
+				defer result.Body.Close()
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, "EwHXdJfB", requested)
+				assert.Len(t, tracker.clicks, tt.expectedClicks)
+				if !tt.expectedPreview {
+					assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+					return
+				}
+				assert.Equal(t, http.StatusOK, result.StatusCode)
+				assert.Empty(t, result.Header.Get("Location"))
+				assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+				assert.Contains(t, result.Header.Get("Content-Type"), "text/html")
+				for _, expected := range tt.expectedBody {
+					assert.Contains(t, string(body), expected)
+				}
+				assert.Equal(t, tt.expectedFlagged, strings.Contains(string(body), "Warning:"))
+			})
+		}
+	})
+
+	t.Run("GetOriginalURLPreviewDeleted", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB+", nil)
+		recorder := httptest.NewRecorder()
+
+		mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{OriginalURL: "https://practicum.yandex.ru/", Deleted: true}, nil
+		}
+
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -192,6 +530,124 @@ func TestMainHandler(t *testing.T) {
 		assert.Equal(t, http.StatusGone, result.StatusCode)
 	})
 
+	t.Run("GetOriginalURLPassword", func(t *testing.T) {
+		opts, err := services.PasswordOptions("secret")
+		require.NoError(t, err)
+		mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{ShortURL: shortURL, OriginalURL: "https://practicum.yandex.ru/", LinkOptions: models.NewLinkOptions(opts...)}, nil
+		}
+		passwords, err := services.NewPasswordLimiter(2, time.Minute)
+		require.NoError(t, err)
+		tracker := &mockClickTracker{}
+		redirect := handler.GetOriginalURL(mockStore, tracker, services.RedirectPolicy{CacheMaxAge: time.Hour}, services.PreviewPolicy{}, passwords)
+
+		tests := []struct {
+			name           string
+			method         string
+			header         string
+			form           string
+			ip             string
+			expectedStatus int
+			expectedForm   bool
+		}{
+			{name: "Form", method: http.MethodGet, ip: "10.0.0.1", expectedStatus: http.StatusUnauthorized, expectedForm: true},
+			{name: "Wrong form password", method: http.MethodPost, form: "password=wrong", ip: "10.0.0.1", expectedStatus: http.StatusForbidden, expectedForm: true},
+			{name: "Form password", method: http.MethodPost, form: "password=secret", ip: "10.0.0.1", expectedStatus: http.StatusSeeOther},
+			{name: "Header password", method: http.MethodGet, header: "secret", ip: "10.0.0.2", expectedStatus: http.StatusTemporaryRedirect},
+			{name: "Wrong header password", method: http.MethodGet, header: "wrong", ip: "10.0.0.2", expectedStatus: http.StatusForbidden},
+			{name: "Wrong header password again", method: http.MethodGet, header: "wrong", ip: "10.0.0.2", expectedStatus: http.StatusForbidden},
+			{name: "Too many attempts", method: http.MethodGet, header: "secret", ip: "10.0.0.2", expectedStatus: http.StatusTooManyRequests},
+			{name: "Other address", method: http.MethodGet, header: "secret", ip: "10.0.0.3", expectedStatus: http.StatusTemporaryRedirect},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				req := httptest.NewRequest(tt.method, "/EwHXdJfB", strings.NewReader(tt.form))
+				if tt.form != "" {
+					req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				}
+				if tt.header != "" {
+					req.Header.Set(PasswordHeader, tt.header)
+				}
+				req.RemoteAddr = tt.ip + ":1234"
+				// Заголовок прокси не влияет на счетчик попыток адреса
+				req.Header.Set("X-Real-IP", uuid.NewString())
+				recorder := httptest.NewRecorder()
+
+				redirect(recorder, req)
+
+				result := recorder.Result()
+				defer result.Body.Close()
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+
+				assert.Equal(t, tt.expectedStatus, result.StatusCode)
+				assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+				assert.Equal(t, tt.expectedForm, strings.Contains(string(body), "<form"))
+				if tt.expectedStatus == http.StatusTooManyRequests {
+					assert.NotEmpty(t, result.Header.Get("Retry-After"))
+				}
+				if tt.expectedStatus < http.StatusBadRequest {
+					assert.Equal(t, "https://practicum.yandex.ru/", result.Header.Get("Location"))
+				} else {
+					assert.Empty(t, result.Header.Get("Location"))
+				}
+			})
+		}
+
+		// Клики учитываются только после проверки пароля
+		assert.Len(t, tracker.clicks, 3)
+	})
+
+	t.Run("GetOriginalURLClickLimit", func(t *testing.T) {
+		left := 1
+		mockStore.GetFunc = func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{
+				ShortURL:    shortURL,
+				OriginalURL: "https://practicum.yandex.ru/",
+				UsedClicks:  1 - left,
+				LinkOptions: models.LinkOptions{MaxClicks: 1, RedirectCode: http.StatusMovedPermanently},
+			}, nil
+		}
+		mockStore.ConsumeClickFunc = func(ctx context.Context, shortURL string) (int, error) {
+			if left == 0 {
+				return -1, storeerr.ErrLinkExhausted
+			}
+			left--
+			return left, nil
+		}
+		defer func() { mockStore.ConsumeClickFunc = nil }()
+		redirect := handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{CacheMaxAge: time.Hour}, services.PreviewPolicy{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
+		recorder := httptest.NewRecorder()
+		redirect(recorder, req)
+		assert.Equal(t, http.StatusMovedPermanently, recorder.Code)
+		// Ограниченные ссылки не кэшируются даже при постоянном перенаправлении
+		assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+		req = httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
+		recorder = httptest.NewRecorder()
+		redirect(recorder, req)
+		assert.Equal(t, http.StatusGone, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Location"))
+
+		// Предпросмотр раскрывает адрес назначения, поэтому тоже расходует переход
+		left = 1
+		req = httptest.NewRequest(http.MethodGet, "/EwHXdJfB+", nil)
+		recorder = httptest.NewRecorder()
+		redirect(recorder, req)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "https://practicum.yandex.ru/")
+		assert.Equal(t, 0, left)
+
+		req = httptest.NewRequest(http.MethodGet, "/EwHXdJfB?preview=1", nil)
+		recorder = httptest.NewRecorder()
+		redirect(recorder, req)
+		assert.Equal(t, http.StatusGone, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "https://practicum.yandex.ru/")
+	})
+
 	t.Run("GetOriginalURLNotFound", func(t *testing.T) {
 		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
 		recorder := httptest.NewRecorder()
@@ -200,7 +656,7 @@ func TestMainHandler(t *testing.T) {
 			return models.ShortenStore{}, filestore.ErrURLNotFound
 		}
 
-		handler.GetOriginalURL(mockStore)(recorder, req)
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -224,11 +680,11 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
+		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
 			return nil
 		}
 
-		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -255,16 +711,99 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
-			return fmt.Errorf("conflict error")
+		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+			return &storeerr.ErrConflict{ShortURL: "existing"}
 		}
 
-		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
 
+		var response models.ShortenResponse
+		require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+
 		assert.Equal(t, http.StatusConflict, result.StatusCode)
+		assert.Equal(t, "http://localhost:8080/existing", response.Result)
+	})
+
+	t.Run("ShortenLinkStoreError", func(t *testing.T) {
+		reqBody := `{"url":"https://practicum.yandex.ru/"}`
+		req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+
+		userID := uuid.New()
+		ctx := contextutils.WithUserID(req.Context(), userID)
+		req = req.WithContext(ctx)
+
+		mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+			return fmt.Errorf("storage error")
+		}
+
+		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+	})
+
+	t.Run("ShortenLinkAlias", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			alias          string
+			addErr         error
+			expectedStatus int
+			expectedResult string
+		}{
+			{
+				name:           "Created",
+				alias:          "spring-sale",
+				expectedStatus: http.StatusCreated,
+				expectedResult: "http://localhost:8080/spring-sale",
+			},
+			{
+				name:           "Invalid",
+				alias:          "api",
+				expectedStatus: http.StatusBadRequest,
+			},
+			{
+				name:           "Taken",
+				alias:          "spring-sale",
+				addErr:         storeerr.ErrURLExists,
+				expectedStatus: http.StatusConflict,
+			},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				reqBody := `{"url":"https://practicum.yandex.ru/","alias":"` + tt.alias + `"}`
+				req := httptest.NewRequest(http.MethodPost, "/api/shorten", strings.NewReader(reqBody))
+				req.Header.Set("Content-Type", "application/json")
+				req = req.WithContext(contextutils.WithUserID(req.Context(), uuid.New()))
+				recorder := httptest.NewRecorder()
+
+				var storedShortURL string
+				mockStore.AddFunc = func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+					storedShortURL = shortURL
+					return tt.addErr
+				}
+
+				handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+
+				result := recorder.Result()
+				defer result.Body.Close()
+
+				assert.Equal(t, tt.expectedStatus, result.StatusCode)
+				if tt.expectedResult != "" {
+					var response models.ShortenResponse
+					require.NoError(t, json.NewDecoder(result.Body).Decode(&response))
+					assert.Equal(t, tt.expectedResult, response.Result)
+					assert.Equal(t, tt.alias, storedShortURL)
+				}
+			})
+		}
 	})
 
 	t.Run("ShortenLinkBadRequest", func(t *testing.T) {
@@ -273,7 +812,7 @@ func TestMainHandler(t *testing.T) {
 		req.Header.Set("Content-Type", "application/json")
 		recorder := httptest.NewRecorder()
 
-		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -298,7 +837,7 @@ func TestMainHandler(t *testing.T) {
 			return nil
 		}
 
-		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -328,7 +867,7 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener)(recorder, req)
+		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -402,16 +941,34 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		mockStore.DeleteUserURLsFunc = func(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
-			return nil
-		}
-
-		handler.DeleteUserURLs(mockStore)(recorder, req)
+		deletions := &mockDeletionQueue{}
+		handler.DeleteUserURLs(deletions)(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
 
 		assert.Equal(t, http.StatusAccepted, result.StatusCode)
+		assert.Equal(t, []models.UserShortURL{
+			{UserID: userID, ShortURL: "EwHXdJfB"},
+			{UserID: userID, ShortURL: "AbCdEfGh"},
+		}, deletions.urls)
+	})
+
+	t.Run("DeleteUserURLsQueueUnavailable", func(t *testing.T) {
+		reqBody := `["EwHXdJfB"]`
+		req := httptest.NewRequest(http.MethodDelete, "/user/urls", strings.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+
+		ctx := contextutils.WithUserID(req.Context(), uuid.New())
+		req = req.WithContext(ctx)
+
+		handler.DeleteUserURLs(&mockDeletionQueue{err: worker.ErrDeleterClosed})(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
 	})
 
 	t.Run("DeleteUserURLsBadRequest", func(t *testing.T) {
@@ -424,7 +981,7 @@ func TestMainHandler(t *testing.T) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.DeleteUserURLs(mockStore)(recorder, req)
+		handler.DeleteUserURLs(&mockDeletionQueue{})(recorder, req)
 
 		result := recorder.Result()
 		defer result.Body.Close()
@@ -486,7 +1043,7 @@ func BenchmarkCreateShortLink(b *testing.B) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.CreateShortLink(mockStore, baseURL, mockShortener)(recorder, req)
+		handler.CreateShortLink(mockStore, baseURL, mockShortener, nil, nil)(recorder, req)
 	}
 }
 
@@ -505,7 +1062,7 @@ func BenchmarkGetOriginalURL(b *testing.B) {
 		req := httptest.NewRequest(http.MethodGet, "/EwHXdJfB", nil)
 		recorder := httptest.NewRecorder()
 
-		handler.GetOriginalURL(mockStore)(recorder, req)
+		handler.GetOriginalURL(mockStore, nil, services.RedirectPolicy{}, services.PreviewPolicy{}, nil)(recorder, req)
 	}
 }
 
@@ -524,7 +1081,372 @@ func BenchmarkShortenLink(b *testing.B) {
 		ctx := contextutils.WithUserID(req.Context(), userID)
 		req = req.WithContext(ctx)
 
-		handler.ShortenLink(mockStore, baseURL, mockShortener)(recorder, req)
+		handler.ShortenLink(mockStore, baseURL, mockShortener, nil, nil)(recorder, req)
+	}
+}
+
+func TestShortenWithExpiry(t *testing.T) {
+	handler := NewHandler()
+	mockShortener := &MockShortener{}
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		json           bool
+		expectedStatus int
+		// expectedTTL — ожидаемый срок действия; 0 означает бессрочную ссылку
+		expectedTTL time.Duration
+	}{
+		{
+			name:           "Plain with expires_in",
+			target:         "/?expires_in=1h",
+			body:           "https://practicum.yandex.ru/",
+			expectedStatus: http.StatusCreated,
+			expectedTTL:    time.Hour,
+		},
+		{
+			name:           "Plain with invalid expires_in",
+			target:         "/?expires_in=soon",
+			body:           "https://practicum.yandex.ru/",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "JSON with expires_in in seconds",
+			target:         "/api/shorten",
+			body:           `{"url":"https://practicum.yandex.ru/","expires_in":"120"}`,
+			json:           true,
+			expectedStatus: http.StatusCreated,
+			expectedTTL:    2 * time.Minute,
+		},
+		{
+			name:           "JSON with expires_at",
+			target:         "/api/shorten",
+			body:           `{"url":"https://practicum.yandex.ru/","expires_at":"` + time.Now().Add(24*time.Hour).Format(time.RFC3339) + `"}`,
+			json:           true,
+			expectedStatus: http.StatusCreated,
+			expectedTTL:    24 * time.Hour,
+		},
+		{
+			name:           "JSON with expires_at in the past",
+			target:         "/api/shorten",
+			body:           `{"url":"https://practicum.yandex.ru/","expires_at":"2000-01-01T00:00:00Z"}`,
+			json:           true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "JSON with both fields",
+			target:         "/api/shorten",
+			body:           `{"url":"https://practicum.yandex.ru/","expires_in":"1h","expires_at":"2100-01-01T00:00:00Z"}`,
+			json:           true,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "JSON without expiry",
+			target:         "/api/shorten",
+			body:           `{"url":"https://practicum.yandex.ru/"}`,
+			json:           true,
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored models.LinkOptions
+			mockStore := &MockStore{
+				AddFunc: func(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+					stored = models.NewLinkOptions(opts...)
+					return nil
+				},
+			}
+
+			req := httptest.NewRequest(http.MethodPost, tt.target, strings.NewReader(tt.body))
+			req = req.WithContext(contextutils.WithUserID(req.Context(), uuid.New()))
+			recorder := httptest.NewRecorder()
+
+			if tt.json {
+				handler.ShortenLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+			} else {
+				handler.CreateShortLink(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+			}
+
+			result := recorder.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, result.StatusCode)
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			if tt.expectedTTL == 0 {
+				assert.Nil(t, stored.ExpiresAt)
+				return
+			}
+			require.NotNil(t, stored.ExpiresAt)
+			assert.WithinDuration(t, time.Now().Add(tt.expectedTTL), *stored.ExpiresAt, 2*time.Second)
+		})
+	}
+
+	t.Run("Batch with invalid expiry", func(t *testing.T) {
+		mockStore := &MockStore{}
+		body := `[{"correlation_id":"1","original_url":"https://practicum.yandex.ru/","expires_in":"-1h"}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		req = req.WithContext(contextutils.WithUserID(req.Context(), uuid.New()))
+		recorder := httptest.NewRecorder()
+
+		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+
+	t.Run("Batch with expiry", func(t *testing.T) {
+		var stored []models.ShortenBatchStore
+		mockStore := &MockStore{
+			AddBatchFunc: func(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
+				stored = batchRequest
+				return nil
+			},
+		}
+		body := `[{"correlation_id":"1","original_url":"https://practicum.yandex.ru/","expires_in":"30m"},` +
+			`{"correlation_id":"2","original_url":"https://yandex.ru/"}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/shorten/batch", strings.NewReader(body))
+		req = req.WithContext(contextutils.WithUserID(req.Context(), uuid.New()))
+		recorder := httptest.NewRecorder()
+
+		handler.ShortenLinkBatch(mockStore, "http://localhost:8080", mockShortener, nil, nil)(recorder, req)
+
+		result := recorder.Result()
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusCreated, result.StatusCode)
+		require.Len(t, stored, 2)
+		require.NotNil(t, stored[0].ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(30*time.Minute), *stored[0].ExpiresAt, 2*time.Second)
+		assert.Nil(t, stored[1].ExpiresAt)
+	})
+}
+
+func TestUserURLsTrash(t *testing.T) {
+	handler := NewHandler()
+	userID := uuid.New()
+	deletedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var cutoff time.Time
+	mockStore := &MockStore{
+		GetUserURLsFunc: func(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error) {
+			return []models.UserURLResponse{
+				{ShortURL: "live", OriginalURL: "https://live.example"},
+				{ShortURL: "trash", OriginalURL: "https://trash.example", DeletedAt: &deletedAt},
+			}, nil
+		},
+		RestoreUserURLsFunc: func(ctx context.Context, id uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+			cutoff = deletedAfter
+			if id != userID {
+				return []string{}, nil
+			}
+			return shortURLs[:1], nil
+		},
+	}
+
+	serve := func(h http.HandlerFunc, method, target, body string) *http.Response {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req = req.WithContext(contextutils.WithUserID(req.Context(), userID))
+		recorder := httptest.NewRecorder()
+		h(recorder, req)
+		return recorder.Result()
+	}
+
+	t.Run("Live URLs by default", func(t *testing.T) {
+		result := serve(handler.GetUserURLs(mockStore, "http://localhost:8080"), http.MethodGet, "/api/user/urls", "")
+		defer result.Body.Close()
+
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.JSONEq(t, `[{"short_url":"http://localhost:8080/live","original_url":"https://live.example"}]`, string(body))
+	})
+
+	t.Run("Deleted URLs", func(t *testing.T) {
+		result := serve(handler.GetUserURLs(mockStore, "http://localhost:8080"), http.MethodGet, "/api/user/urls?deleted=true", "")
+		defer result.Body.Close()
+
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.JSONEq(t, `[{"short_url":"http://localhost:8080/trash","original_url":"https://trash.example",`+
+			`"deleted_at":"2024-01-01T12:00:00Z"}]`, string(body))
+	})
+
+	t.Run("Invalid deleted parameter", func(t *testing.T) {
+		result := serve(handler.GetUserURLs(mockStore, "http://localhost:8080"), http.MethodGet, "/api/user/urls?deleted=maybe", "")
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+
+	t.Run("Restore", func(t *testing.T) {
+		result := serve(handler.RestoreUserURLs(mockStore, 24*time.Hour), http.MethodPost, "/api/user/urls/restore", `["trash","live"]`)
+		defer result.Body.Close()
+
+		body, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.JSONEq(t, `["trash"]`, string(body))
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), cutoff, time.Second)
+	})
+
+	t.Run("Restore bad request", func(t *testing.T) {
+		result := serve(handler.RestoreUserURLs(mockStore, 24*time.Hour), http.MethodPost, "/api/user/urls/restore", `{ bad json }`)
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+
+	t.Run("Restore store error", func(t *testing.T) {
+		failing := &MockStore{
+			RestoreUserURLsFunc: func(ctx context.Context, id uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+				return nil, errors.New("db is down")
+			},
+		}
+		result := serve(handler.RestoreUserURLs(failing, 24*time.Hour), http.MethodPost, "/api/user/urls/restore", `["trash"]`)
+		defer result.Body.Close()
+
+		assert.Equal(t, http.StatusInternalServerError, result.StatusCode)
+	})
+}
+
+type mockDeletionQueue struct {
+	urls  []models.UserShortURL
+	stats worker.DeleterStats
+	err   error
+}
+
+func (m *mockDeletionQueue) Enqueue(_ context.Context, urls ...models.UserShortURL) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.urls = append(m.urls, urls...)
+	return nil
+}
+
+func (m *mockDeletionQueue) Stats() worker.DeleterStats {
+	return m.stats
+}
+
+type mockClickTracker struct {
+	clicks []models.Click
+}
+
+func (m *mockClickTracker) Track(click models.Click) bool {
+	m.clicks = append(m.clicks, click)
+	return true
+}
+
+func TestGetLinkStats(t *testing.T) {
+	handler := NewHandler()
+	userID := uuid.New()
+	hour := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	mockStore := &MockStore{
+		GetFunc: func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			if shortURL != "short1" {
+				return models.ShortenStore{}, storeerr.ErrURLNotFound
+			}
+			return models.ShortenStore{ShortURL: shortURL, UserID: userID}, nil
+		},
+		GetLinkStatsFunc: func(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+			return models.LinkStats{
+				ShortURL:       shortURL,
+				Clicks:         3,
+				UniqueVisitors: 2,
+				TimeSeries: []models.ClickBucket{
+					{Time: hour, Clicks: 1},
+					{Time: hour.Add(time.Hour), Clicks: 2},
+				},
+				TopReferrers:  []models.ClickCount{{Value: "https://example.com", Clicks: 3}},
+				TopUserAgents: []models.ClickCount{},
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		shortURL       string
+		query          string
+		userID         uuid.UUID
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Hourly stats",
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"short_url":"short1","clicks":3,"unique_visitors":2,` +
+				`"time_series":[{"time":"2024-01-01T10:00:00Z","clicks":1},{"time":"2024-01-01T11:00:00Z","clicks":2}],` +
+				`"top_referrers":[{"value":"https://example.com","clicks":3}],"top_user_agents":[]}`,
+		},
+		{
+			name:           "Daily stats",
+			shortURL:       "short1",
+			query:          "?granularity=day&top=5",
+			userID:         userID,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"short_url":"short1","clicks":3,"unique_visitors":2,` +
+				`"time_series":[{"time":"2024-01-01T00:00:00Z","clicks":3}],` +
+				`"top_referrers":[{"value":"https://example.com","clicks":3}],"top_user_agents":[]}`,
+		},
+		{
+			name:           "Invalid granularity",
+			shortURL:       "short1",
+			query:          "?granularity=week",
+			userID:         userID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid top",
+			shortURL:       "short1",
+			query:          "?top=0",
+			userID:         userID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Foreign URL",
+			shortURL:       "short1",
+			userID:         uuid.New(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Unknown URL",
+			shortURL:       "missing",
+			userID:         userID,
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/user/urls/"+tt.shortURL+"/stats"+tt.query, nil)
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("shortURL", tt.shortURL)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(contextutils.WithUserID(ctx, tt.userID))
+			recorder := httptest.NewRecorder()
+
+			handler.GetLinkStats(mockStore)(recorder, req)
+
+			result := recorder.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, result.StatusCode)
+			if tt.expectedBody != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.expectedBody, string(body))
+			}
+		})
 	}
 }
 
@@ -533,8 +1455,7 @@ func TestGetStats(t *testing.T) {
 		name           string
 		trustedSubnet  string
 		clientIP       string
-		urlsCount      int
-		usersCount     int
+		stats          models.Stats
 		storeError     error
 		expectedStatus int
 		expectedBody   string
@@ -543,10 +1464,9 @@ func TestGetStats(t *testing.T) {
 			name:           "successful request",
 			trustedSubnet:  "192.168.1.0/24",
 			clientIP:       "192.168.1.100",
-			urlsCount:      10,
-			usersCount:     5,
+			stats:          models.Stats{URLs: 10, ExpiredURLs: 2, Users: 5},
 			expectedStatus: http.StatusOK,
-			expectedBody:   `{"urls":10,"users":5}` + "\n",
+			expectedBody:   `{"urls":10,"expired_urls":2,"users":5}` + "\n",
 		},
 		{
 			name:           "empty trusted subnet",
@@ -589,8 +1509,8 @@ func TestGetStats(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			// Create mock store
 			mockStore := &MockStore{
-				GetStatsFunc: func(ctx context.Context) (int, int, error) {
-					return tt.urlsCount, tt.usersCount, tt.storeError
+				GetStatsFunc: func(ctx context.Context) (models.Stats, error) {
+					return tt.stats, tt.storeError
 				},
 			}
 
@@ -604,7 +1524,7 @@ func TestGetStats(t *testing.T) {
 			rr := httptest.NewRecorder()
 
 			// Create handler
-			handler := GetStats(mockStore, tt.trustedSubnet)
+			handler := GetStats(mockStore, tt.trustedSubnet, nil)
 
 			// Call handler
 			handler.ServeHTTP(rr, req)
@@ -617,3 +1537,203 @@ func TestGetStats(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStatsWithCache(t *testing.T) {
+	mockStore := &MockStore{
+		GetStatsFunc: func(ctx context.Context) (models.Stats, error) {
+			return models.Stats{URLs: 10, Users: 5}, nil
+		},
+		GetFunc: func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			return models.ShortenStore{ShortURL: shortURL, OriginalURL: "https://practicum.yandex.ru/"}, nil
+		},
+	}
+	cached := store.NewCachedStore(mockStore, store.CacheOptions{Size: 10})
+
+	// Один промах и одно попадание
+	for i := 0; i < 2; i++ {
+		_, err := cached.Get(context.Background(), "EwHXdJfB")
+		require.NoError(t, err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	req.Header.Set("X-Real-IP", "192.168.1.100")
+	rr := httptest.NewRecorder()
+
+	GetStats(cached, "192.168.1.0/24", nil).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"urls":10,"expired_urls":0,"users":5,"cache":{"hits":1,"misses":1,"size":1}}`, rr.Body.String())
+}
+
+func TestGetStatsWithDeletionQueue(t *testing.T) {
+	mockStore := &MockStore{
+		GetStatsFunc: func(ctx context.Context) (models.Stats, error) {
+			return models.Stats{URLs: 10, Users: 5}, nil
+		},
+	}
+	deletions := &mockDeletionQueue{stats: worker.DeleterStats{
+		QueueDepth: 3,
+		Lag:        250 * time.Millisecond,
+		Deleted:    42,
+		Failed:     1,
+	}}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/internal/stats", nil)
+	req.Header.Set("X-Real-IP", "192.168.1.100")
+	rr := httptest.NewRecorder()
+
+	GetStats(mockStore, "192.168.1.0/24", deletions).ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{"urls":10,"expired_urls":0,"users":5,`+
+		`"deletion":{"queue_depth":3,"lag_ms":250,"deleted":42,"failed":1}}`, rr.Body.String())
+}
+
+func TestUpdateUserURL(t *testing.T) {
+	handler := NewHandler()
+	userID := uuid.New()
+	changedAt := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	history := []models.URLVersion{
+		{ShortURL: "short1", Version: 1, OriginalURL: "https://example2.com", PreviousURL: "https://example1.com", ChangedAt: changedAt, ChangedBy: userID},
+	}
+
+	mockStore := &MockStore{
+		GetFunc: func(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+			if shortURL != "short1" {
+				return models.ShortenStore{}, storeerr.ErrURLNotFound
+			}
+			return models.ShortenStore{ShortURL: shortURL, OriginalURL: "https://example2.com", UserID: userID}, nil
+		},
+		GetURLHistoryFunc: func(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+			return history, nil
+		},
+		UpdateOriginalURLFunc: func(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+			if originalURL == "https://taken.com" {
+				return models.URLVersion{}, &storeerr.ErrConflict{ShortURL: "short2"}
+			}
+			return models.URLVersion{ShortURL: shortURL, Version: 2, OriginalURL: originalURL, PreviousURL: "https://example2.com", ChangedAt: changedAt, ChangedBy: userID}, nil
+		},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		shortURL       string
+		userID         uuid.UUID
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Update",
+			method:         http.MethodPatch,
+			body:           `{"url":"https://example3.com"}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"short_url":"short1","version":2,"original_url":"https://example3.com",` +
+				`"previous_url":"https://example2.com","changed_at":"2024-01-01T10:00:00Z","changed_by":"` + userID.String() + `"}`,
+		},
+		{
+			name:           "Invalid URL",
+			method:         http.MethodPatch,
+			body:           `{"url":"not a url"}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Unchanged URL",
+			method:         http.MethodPatch,
+			body:           `{"url":"https://example2.com"}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "URL shortened by another link",
+			method:         http.MethodPatch,
+			body:           `{"url":"https://taken.com"}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "Foreign URL",
+			method:         http.MethodPatch,
+			body:           `{"url":"https://example3.com"}`,
+			shortURL:       "short1",
+			userID:         uuid.New(),
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "History",
+			method:         http.MethodGet,
+			path:           "/history",
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusOK,
+			expectedBody: `[{"short_url":"short1","version":1,"original_url":"https://example2.com",` +
+				`"previous_url":"https://example1.com","changed_at":"2024-01-01T10:00:00Z","changed_by":"` + userID.String() + `"}]`,
+		},
+		{
+			name:           "History of unknown URL",
+			method:         http.MethodGet,
+			path:           "/history",
+			shortURL:       "missing",
+			userID:         userID,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "Rollback to the created URL",
+			method:         http.MethodPost,
+			path:           "/rollback",
+			body:           `{"version":0}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusOK,
+			expectedBody: `{"short_url":"short1","version":2,"original_url":"https://example1.com",` +
+				`"previous_url":"https://example2.com","changed_at":"2024-01-01T10:00:00Z","changed_by":"` + userID.String() + `"}`,
+		},
+		{
+			name:           "Rollback to unknown version",
+			method:         http.MethodPost,
+			path:           "/rollback",
+			body:           `{"version":7}`,
+			shortURL:       "short1",
+			userID:         userID,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/user/urls/"+tt.shortURL+tt.path, strings.NewReader(tt.body))
+			rctx := chi.NewRouteContext()
+			rctx.URLParams.Add("shortURL", tt.shortURL)
+			ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+			req = req.WithContext(contextutils.WithUserID(ctx, tt.userID))
+			recorder := httptest.NewRecorder()
+
+			switch tt.path {
+			case "/history":
+				handler.GetURLHistory(mockStore)(recorder, req)
+			case "/rollback":
+				handler.RollbackUserURL(mockStore, nil, nil)(recorder, req)
+			default:
+				handler.UpdateUserURL(mockStore, nil, nil)(recorder, req)
+			}
+
+			result := recorder.Result()
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.expectedStatus, result.StatusCode)
+			if tt.expectedBody != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.JSONEq(t, tt.expectedBody, string(body))
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// UpdateUserURL is an HTTP handler that reads a JSON object with a new original
+// URL and points the user's short URL to it. It responds with the created
+// version, with 409 Conflict if the URL is already shortened under another
+// link, and with 404 for links of other users and deleted links. The new URL
+// is stored in the canonical form produced by normalizer and must be allowed
+// by policy.
+func (h *Handler) UpdateUserURL(store store.Store, normalizer *services.URLNormalizer, policy *services.URLPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var updateRequest models.UpdateURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+		originalURL, err := normalizer.Normalize(updateRequest.URL)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := policy.Check(originalURL); err != nil {
+			writePolicyError(w, err)
+			return
+		}
+
+		version, err := services.UpdateOriginalURL(ctx, store, chi.URLParam(r, "shortURL"), userID, originalURL)
+		writeVersion(w, version, err)
+	}
+}
+
+// RollbackUserURL is an HTTP handler that reads a JSON object with a version
+// number and points the user's short URL back to the original URL of that
+// version. Version 0 is the URL the link was created with. The rollback is
+// recorded as a new version, which is returned in the response. The restored
+// URL is normalized and must still be allowed by policy.
+func (h *Handler) RollbackUserURL(store store.Store, normalizer *services.URLNormalizer, policy *services.URLPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var rollbackRequest models.RollbackURLRequest
+		if err := json.NewDecoder(r.Body).Decode(&rollbackRequest); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+
+		version, err := services.RollbackOriginalURL(ctx, store, chi.URLParam(r, "shortURL"), userID, rollbackRequest.Version, normalizer, policy)
+		writeVersion(w, version, err)
+	}
+}
+
+// GetURLHistory is an HTTP handler that returns the changes of the original
+// URL of a short URL owned by the user, ordered by version.
+func (h *Handler) GetURLHistory(store store.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		history, err := services.GetURLHistory(ctx, store, chi.URLParam(r, "shortURL"), userID)
+		switch {
+		case errors.Is(err, storeerr.ErrURLNotFound):
+			http.Error(w, "URL not found", http.StatusNotFound)
+			return
+		case err != nil:
+			logger.Log.Error("Failed to get URL history", "error", err)
+			http.Error(w, "can't get URL history", http.StatusInternalServerError)
+			return
+		}
+
+		responseBody, err := json.Marshal(history)
+		if err != nil {
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBody)
+	}
+}
+
+// writeVersion responds with the version created by a change of the original
+// URL or with the status matching the error of the change.
+func writeVersion(w http.ResponseWriter, version models.URLVersion, err error) {
+	var conflict *storeerr.ErrConflict
+	var violation *services.PolicyViolation
+	switch {
+	case errors.As(err, &violation):
+		writePolicyError(w, err)
+		return
+	case errors.Is(err, services.ErrURLUnchanged), errors.Is(err, services.ErrInvalidVersion), services.IsInvalidURL(err):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, storeerr.ErrURLNotFound):
+		http.Error(w, "URL not found", http.StatusNotFound)
+		return
+	case errors.As(err, &conflict):
+		http.Error(w, "URL is already shortened as "+conflict.ShortURL, http.StatusConflict)
+		return
+	case err != nil:
+		logger.Log.Error("Failed to update original URL", "error", err)
+		http.Error(w, "can't update URL", http.StatusInternalServerError)
+		return
+	}
+
+	responseBody, err := json.Marshal(version)
+	if err != nil {
+		http.Error(w, "can't marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(responseBody)
+}
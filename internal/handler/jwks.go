@@ -0,0 +1,32 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// KeySet is an interface that publishes the public keys verifying the tokens
+// issued by the service.
+type KeySet interface {
+	JWKS() models.JWKS
+}
+
+// GetJWKS is an HTTP handler that responds with the public keys of keys as a
+// JSON Web Key Set, so other services can verify the tokens of the users.
+// Shared HMAC secrets are never published, so the set may be empty.
+func (h *Handler) GetJWKS(keys KeySet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		responseBody, err := json.Marshal(keys.JWKS())
+		if err != nil {
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBody)
+	}
+}
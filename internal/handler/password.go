@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"html/template"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/services"
+)
+
+// PasswordHeader is the request header with the password of a protected link.
+// API clients send it to follow a protected link without the password form,
+// and owners send it to POST / to protect the created link.
+const PasswordHeader = "X-Link-Password"
+
+// passwordTemplate renders the password form of a protected link. The form
+// posts back to the same URL, so the preview parameters are kept.
+var passwordTemplate = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>Protected link</title>
+</head>
+<body>
+<main>
+<h1>This link is protected</h1>
+{{if .Message}}<p role="alert">{{.Message}}</p>
+{{end}}<form method="post">
+<label for="password">Password</label>
+<input id="password" name="password" type="password" autocomplete="current-password" required autofocus>
+<button type="submit">Continue</button>
+</form>
+</main>
+</body>
+</html>
+`))
+
+// passwordForm holds the data of the password form.
+type passwordForm struct {
+	Message string
+}
+
+// linkPassword returns the password of a protected link sent in the
+// PasswordHeader or, for a form submission, in the password form field.
+// fromForm reports whether the client uses the password form.
+func linkPassword(r *http.Request) (password string, fromForm bool) {
+	if password := r.Header.Get(PasswordHeader); password != "" {
+		return password, false
+	}
+	if r.Method == http.MethodPost {
+		return r.PostFormValue("password"), true
+	}
+	return "", true
+}
+
+// writePasswordError responds to a failed unlock of a protected link. Form
+// clients get the password form again, API clients get a plain text error.
+func writePasswordError(w http.ResponseWriter, err error, retryAfter time.Duration, fromForm bool) {
+	var status int
+	var message string
+	switch {
+	case errors.Is(err, services.ErrPasswordRequired):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrWrongPassword):
+		status, message = http.StatusForbidden, "Wrong password, try again."
+	case errors.Is(err, services.ErrTooManyAttempts):
+		status, message = http.StatusTooManyRequests, "Too many attempts, try again later."
+		seconds := int64(math.Ceil(retryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	default:
+		logger.Log.Error("Failed to check link password", "error", err)
+		http.Error(w, "can't check password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if !fromForm {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var page bytes.Buffer
+	if err := passwordTemplate.Execute(&page, passwordForm{Message: message}); err != nil {
+		logger.Log.Error("Failed to render password form", "error", err)
+		http.Error(w, "can't render password form", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
+
+// clientIP returns the IP address of the client from the address of the
+// connection. Behind a trusted proxy the RealIP middleware sets it from the
+// forwarded headers; the headers are never read here, since any client can send them.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
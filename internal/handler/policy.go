@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/learies/goShortener/internal/services"
+)
+
+// writePolicyError responds to an original URL rejected by the URL policy
+// with 403 Forbidden and a JSON body with the reason of the rejection.
+func writePolicyError(w http.ResponseWriter, err error) {
+	var violation *services.PolicyViolation
+	if !errors.As(err, &violation) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	responseBody, err := json.Marshal(violation.Response())
+	if err != nil {
+		http.Error(w, "can't marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	w.Write(responseBody)
+}
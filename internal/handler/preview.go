@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// previewTemplate renders the preview page of a short link. html/template
+// escapes the owner-provided title and the destination URL.
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="robots" content="noindex, nofollow">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<main>
+<h1>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</h1>
+{{if .Flagged}}<p role="alert"><strong>Warning:</strong> the domain {{.Domain}} is flagged as potentially unsafe. Continue only if you trust this link.</p>
+{{end}}<dl>
+<dt>Destination</dt>
+<dd>{{.OriginalURL}}</dd>
+<dt>Domain</dt>
+<dd>{{.Domain}}</dd>
+{{if .CreatedAt}}<dt>Created</dt>
+<dd><time datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "January 2, 2006"}}</time></dd>
+{{end}}</dl>
+<p><a href="{{.OriginalURL}}" rel="noopener noreferrer nofollow">Continue to {{.Domain}}</a></p>
+</main>
+</body>
+</html>
+`))
+
+// writePreview responds with the preview page of a short link.
+// The page is never cached, so that changes of the link are visible at once.
+func writePreview(w http.ResponseWriter, preview models.LinkPreview) {
+	var page bytes.Buffer
+	if err := previewTemplate.Execute(&page, preview); err != nil {
+		logger.Log.Error("Failed to render preview page", "error", err)
+		http.Error(w, "can't render preview", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(page.Bytes())
+}
@@ -3,8 +3,10 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -12,20 +14,22 @@ import (
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/models"
 	"github.com/learies/goShortener/internal/services"
-	"github.com/learies/goShortener/internal/services/worker"
 	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
-// checkOriginalURL verifies if the provided URL starts with the prefixes
-// "http://" or "https://". It returns true if the URL is valid, otherwise false.
-func checkOriginalURL(originalURL string) bool {
-	return strings.HasPrefix(originalURL, "http://") || strings.HasPrefix(originalURL, "https://")
-}
-
 // CreateShortLink is an HTTP handler that reads an original URL from the request
 // body, generates a short URL, and responds with the shortened URL.
+// The optional expires_in and expires_at query parameters limit the link lifetime,
+// redirect_code selects the HTTP status of its redirect, title sets the title
+// shown on the preview page, and preview=1 forces the preview page for every visitor.
+// A password in the PasswordHeader protects the link, and max_clicks limits
+// the number of its redirects, 1 making a one-time link.
+// The URL is stored in the canonical form produced by normalizer, so the same
+// destination written differently is reported as already shortened. URLs
+// rejected by policy get 403 Forbidden with a JSON body naming the reason.
 // It requires a store to persist the mapping and a shortener to generate the short URL.
-func (h *Handler) CreateShortLink(store store.Store, baseURL string, shortener services.Shortener) http.HandlerFunc {
+func (h *Handler) CreateShortLink(store store.Store, baseURL string, shortener services.Shortener, normalizer *services.URLNormalizer, policy *services.URLPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -36,19 +40,68 @@ func (h *Handler) CreateShortLink(store store.Store, baseURL string, shortener s
 			return
 		}
 
-		originalURL := string(body)
-		if !checkOriginalURL(originalURL) {
-			http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		originalURL, err := normalizer.Normalize(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := policy.Check(originalURL); err != nil {
+			writePolicyError(w, err)
 			return
 		}
 
-		shortURL, err := shortener.GenerateShortURL(originalURL)
+		query := r.URL.Query()
+		opts, err := services.ExpiryOptions(query.Get("expires_in"), query.Get("expires_at"))
 		if err != nil {
-			http.Error(w, "can't generate short URL", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		shortenedURL := baseURL + "/" + shortURL
+		if value := query.Get("redirect_code"); value != "" {
+			code, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "redirect_code must be a number", http.StatusBadRequest)
+				return
+			}
+			redirectOpts, err := services.RedirectOptions(code)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts = append(opts, redirectOpts...)
+		}
+		preview := false
+		if value := query.Get("preview"); value != "" {
+			preview, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "preview must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+		previewOpts, err := services.PreviewOptions(query.Get("title"), preview)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, previewOpts...)
+		passwordOpts, err := services.PasswordOptions(r.Header.Get(PasswordHeader))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, passwordOpts...)
+		if value := query.Get("max_clicks"); value != "" {
+			maxClicks, err := strconv.Atoi(value)
+			if err != nil {
+				http.Error(w, "max_clicks must be a number", http.StatusBadRequest)
+				return
+			}
+			limitOpts, err := services.MaxClicksOptions(maxClicks)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			opts = append(opts, limitOpts...)
+		}
 
 		userID, ok := contextutils.GetUserID(ctx)
 		if !ok {
@@ -56,29 +109,48 @@ func (h *Handler) CreateShortLink(store store.Store, baseURL string, shortener s
 			return
 		}
 
-		err = store.Add(ctx, shortURL, originalURL, userID)
-		if err != nil {
+		shortURL, err := services.Shorten(ctx, store, shortener, originalURL, userID, opts...)
+		var conflict *storeerr.ErrConflict
+		if errors.As(err, &conflict) {
 			w.Header().Set("Content-Type", "text/plain")
 			w.WriteHeader(http.StatusConflict)
-			w.Write([]byte(shortenedURL))
+			w.Write([]byte(baseURL + "/" + conflict.ShortURL))
+			return
+		}
+		if err != nil {
+			logger.Log.Error("Failed to save short URL", "error", err)
+			http.Error(w, "can't save short URL", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "text/plain")
 		w.WriteHeader(http.StatusCreated)
-		w.Write([]byte(shortenedURL))
+		w.Write([]byte(baseURL + "/" + shortURL))
 	}
 }
 
 // GetOriginalURL is an HTTP handler that retrieves the original URL for a given
 // short URL path and redirects the client.
-// It requires a store to fetch the mapping from the short URL.
-func (h *Handler) GetOriginalURL(store store.Store) http.HandlerFunc {
+// It requires a store to fetch the mapping from the short URL. Every redirect is
+// passed to clicks, which may be nil to disable click tracking. The redirect
+// status and its Cache-Control header are chosen by redirects.
+// A "+" suffix of the short URL or the preview query parameter renders the
+// preview page instead of redirecting; previews may force it for every visit.
+// Previews are not passed to clicks.
+// Protected links render a password form that posts back to the same URL, or
+// accept the password in the PasswordHeader. Failed attempts are limited by
+// passwords, which may be nil to disable the limit. Links with a click limit
+// respond with 410 Gone once all their redirects are used.
+func (h *Handler) GetOriginalURL(store store.Store, clicks ClickTracker, redirects services.RedirectPolicy, previews services.PreviewPolicy, passwords *services.PasswordLimiter) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
 
 		shortURL := strings.TrimPrefix(r.URL.Path, "/")
+		shortURL, preview := strings.CutSuffix(shortURL, "+")
+		if value := r.URL.Query().Get("preview"); value != "" {
+			preview, _ = strconv.ParseBool(value)
+		}
 
 		originalURL, err := store.Get(ctx, shortURL)
 		if err != nil {
@@ -91,15 +163,85 @@ func (h *Handler) GetOriginalURL(store store.Store) http.HandlerFunc {
 			return
 		}
 
+		now := time.Now()
+		if originalURL.Expired(now) {
+			http.Error(w, "URL has expired", http.StatusGone)
+			return
+		}
+
+		if originalURL.Exhausted() {
+			http.Error(w, "URL has no clicks left", http.StatusGone)
+			return
+		}
+
+		password, fromForm := linkPassword(r)
+		if retryAfter, err := passwords.Unlock(originalURL, password, clientIP(r), now); err != nil {
+			writePasswordError(w, err, retryAfter, fromForm)
+			return
+		}
+
+		if originalURL.MaxClicks > 0 {
+			// The stored limit is checked again, so concurrent redirects never exceed it.
+			// A preview reveals the destination too, so it uses up a click as well
+			_, err := store.ConsumeClick(ctx, shortURL)
+			switch {
+			case errors.Is(err, storeerr.ErrLinkExhausted):
+				http.Error(w, "URL has no clicks left", http.StatusGone)
+				return
+			case errors.Is(err, storeerr.ErrURLNotFound):
+				http.Error(w, "URL not found", http.StatusNotFound)
+				return
+			case err != nil:
+				logger.Log.Error("Failed to consume click", "error", err)
+				http.Error(w, "can't follow URL", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		// Previews, asked for or forced, are not tracked as clicks
+		if preview || previews.Forced(originalURL) {
+			writePreview(w, previews.Preview(originalURL))
+			return
+		}
+
+		if clicks != nil {
+			clicks.Track(models.Click{
+				ShortURL:  shortURL,
+				Time:      now,
+				Referrer:  r.Referer(),
+				UserAgent: r.UserAgent(),
+				IP:        r.Header.Get("X-Real-IP"),
+			})
+		}
+
+		code := redirects.Code(originalURL)
+		cacheControl := redirects.CacheControl(originalURL, code, now)
+		if originalURL.Protected() || originalURL.MaxClicks > 0 {
+			// A cached redirect would bypass the password or the click limit
+			cacheControl = "no-store"
+		}
+		if r.Method == http.MethodPost {
+			// The browser follows the link with GET instead of resubmitting the form
+			code = http.StatusSeeOther
+		}
+		w.Header().Set("Cache-Control", cacheControl)
 		w.Header().Set("Location", originalURL.OriginalURL)
-		w.WriteHeader(http.StatusTemporaryRedirect)
+		w.WriteHeader(code)
 	}
 }
 
 // ShortenLink is an HTTP handler that reads a JSON body with an original URL,
 // generates a short URL, and responds with a JSON containing the shortened URL.
+// An optional alias in the body is used as the short URL instead of a generated one,
+// the optional expires_in or expires_at limit the link lifetime, and the
+// optional redirect_code selects the HTTP status of its redirect. The optional
+// title is shown on the preview page, which preview forces for every visitor.
+// An optional password protects the link; only its hash is stored. The
+// optional max_clicks limits the number of its redirects.
+// The URL is stored in the canonical form produced by normalizer, and URLs
+// rejected by policy get 403 Forbidden with a JSON body naming the reason.
 // It requires a store to persist the mapping and a shortener to generate the short URL.
-func (h *Handler) ShortenLink(store store.Store, baseURL string, shortener services.Shortener) http.HandlerFunc {
+func (h *Handler) ShortenLink(store store.Store, baseURL string, shortener services.Shortener, normalizer *services.URLNormalizer, policy *services.URLPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -117,28 +259,45 @@ func (h *Handler) ShortenLink(store store.Store, baseURL string, shortener servi
 			return
 		}
 
-		originalURL := string(shortenRequest.URL)
-		if !checkOriginalURL(originalURL) {
-			http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		originalURL, err := normalizer.Normalize(string(shortenRequest.URL))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := policy.Check(originalURL); err != nil {
+			writePolicyError(w, err)
 			return
 		}
 
-		shortURL, err := shortener.GenerateShortURL(originalURL)
+		opts, err := services.ExpiryOptions(shortenRequest.ExpiresIn, shortenRequest.ExpiresAt)
 		if err != nil {
-			http.Error(w, "can't generate short URL", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-
-		shortenedURL := baseURL + "/" + shortURL
-
-		var shortenResponse models.ShortenResponse
-		shortenResponse.Result = shortenedURL
-
-		responseBody, err := json.Marshal(shortenResponse)
+		redirectOpts, err := services.RedirectOptions(shortenRequest.RedirectCode)
 		if err != nil {
-			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, redirectOpts...)
+		previewOpts, err := services.PreviewOptions(shortenRequest.Title, shortenRequest.Preview)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		opts = append(opts, previewOpts...)
+		passwordOpts, err := services.PasswordOptions(shortenRequest.Password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, passwordOpts...)
+		limitOpts, err := services.MaxClicksOptions(shortenRequest.MaxClicks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		opts = append(opts, limitOpts...)
 
 		userID, ok := contextutils.GetUserID(ctx)
 		if !ok {
@@ -146,24 +305,51 @@ func (h *Handler) ShortenLink(store store.Store, baseURL string, shortener servi
 			return
 		}
 
-		err = store.Add(ctx, shortURL, originalURL, userID)
+		status := http.StatusCreated
+		shortURL := shortenRequest.Alias
+		if shortURL != "" {
+			err = services.ShortenAlias(ctx, store, shortURL, originalURL, userID, opts...)
+		} else {
+			shortURL, err = services.Shorten(ctx, store, shortener, originalURL, userID, opts...)
+		}
+		var conflict *storeerr.ErrConflict
+		switch {
+		case errors.Is(err, services.ErrInvalidAlias):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case errors.Is(err, services.ErrAliasTaken):
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		case errors.As(err, &conflict):
+			status = http.StatusConflict
+			shortURL = conflict.ShortURL
+		case err != nil:
+			logger.Log.Error("Failed to save short URL", "error", err)
+			http.Error(w, "can't save short URL", http.StatusInternalServerError)
+			return
+		}
+
+		var shortenResponse models.ShortenResponse
+		shortenResponse.Result = baseURL + "/" + shortURL
+
+		responseBody, err := json.Marshal(shortenResponse)
 		if err != nil {
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(http.StatusConflict)
-			w.Write(responseBody)
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
 			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusCreated)
+		w.WriteHeader(status)
 		w.Write(responseBody)
 	}
 }
 
 // ShortenLinkBatch is an HTTP handler that reads a JSON array of URLs,
 // generates short URLs for each, and responds with a JSON array of shortened URLs.
+// The URLs are stored in the canonical form produced by normalizer. If policy
+// rejects any URL, nothing is stored and the response names the rejected item.
 // It requires a store to persist the batch and a shortener to generate short URLs.
-func (h *Handler) ShortenLinkBatch(store store.Store, baseURL string, shortener services.Shortener) http.HandlerFunc {
+func (h *Handler) ShortenLinkBatch(store store.Store, baseURL string, shortener services.Shortener, normalizer *services.URLNormalizer, policy *services.URLPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -187,35 +373,40 @@ func (h *Handler) ShortenLinkBatch(store store.Store, baseURL string, shortener
 			return
 		}
 
-		var batchResponse []models.ShortenBatchResponse
-		var batchShorten []models.ShortenBatchStore
-		for _, request := range batchRequest {
-			shortURL, errShortURL := shortener.GenerateShortURL(request.OriginalURL)
-			if errShortURL != nil {
-				http.Error(w, "can't generate short URL", http.StatusInternalServerError)
-				return
-			}
-
-			shortenedURL := baseURL + "/" + shortURL
-
-			batchResponse = append(batchResponse, models.ShortenBatchResponse{
-				CorrelationID: request.CorrelationID,
-				ShortURL:      shortenedURL,
-			})
-
-			batchShorten = append(batchShorten, models.ShortenBatchStore{
-				CorrelationID: request.CorrelationID,
-				ShortURL:      shortURL,
-				OriginalURL:   request.OriginalURL,
-			})
+		batchRequest, err = normalizer.NormalizeBatch(batchRequest)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := policy.CheckBatch(batchRequest); err != nil {
+			writePolicyError(w, err)
+			return
 		}
 
-		err = store.AddBatch(ctx, batchShorten, userID)
+		batchShorten, err := services.ShortenBatch(ctx, store, shortener, batchRequest, userID)
+		if services.IsInvalidLinkOption(err) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var conflict *storeerr.ErrConflict
+		if errors.As(err, &conflict) {
+			http.Error(w, "URL is already shortened as "+baseURL+"/"+conflict.ShortURL, http.StatusConflict)
+			return
+		}
 		if err != nil {
+			logger.Log.Error("Failed to save batch short URL", "error", err)
 			http.Error(w, "can't save batch short URL", http.StatusInternalServerError)
 			return
 		}
 
+		batchResponse := make([]models.ShortenBatchResponse, len(batchShorten))
+		for i, shorten := range batchShorten {
+			batchResponse[i] = models.ShortenBatchResponse{
+				CorrelationID: shorten.CorrelationID,
+				ShortURL:      baseURL + "/" + shorten.ShortURL,
+			}
+		}
+
 		responseBody, err := json.Marshal(batchResponse)
 		if err != nil {
 			http.Error(w, "can't marshal response", http.StatusInternalServerError)
@@ -245,7 +436,8 @@ func (h *Handler) PingHandler(store store.Store) http.HandlerFunc {
 
 // GetUserURLs is an HTTP handler that retrieves all URLs associated with the user
 // ID in the context. It responds with a JSON array of these URLs.
-// It requires a store to fetch the user's URLs.
+// It requires a store to fetch the user's URLs. Deleted URLs are listed
+// instead of the live ones when the deleted query parameter is true.
 func (h *Handler) GetUserURLs(store store.Store, baseURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
@@ -257,11 +449,22 @@ func (h *Handler) GetUserURLs(store store.Store, baseURL string) http.HandlerFun
 			return
 		}
 
+		var deleted bool
+		if value := r.URL.Query().Get("deleted"); value != "" {
+			var err error
+			deleted, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "deleted must be true or false", http.StatusBadRequest)
+				return
+			}
+		}
+
 		urls, err := store.GetUserURLs(ctx, userID)
 		if err != nil {
 			http.Error(w, "can't get user URLs", http.StatusNotFound)
 			return
 		}
+		urls = services.FilterDeleted(urls, deleted)
 
 		if len(urls) == 0 {
 			w.WriteHeader(http.StatusNoContent)
@@ -273,6 +476,10 @@ func (h *Handler) GetUserURLs(store store.Store, baseURL string) http.HandlerFun
 			modifiedUrls[i] = models.UserURLResponse{
 				ShortURL:    baseURL + "/" + url.ShortURL,
 				OriginalURL: url.OriginalURL,
+				ExpiresAt:   url.ExpiresAt,
+				DeletedAt:   url.DeletedAt,
+				Protected:   url.Protected,
+				ClicksLeft:  url.ClicksLeft,
 			}
 		}
 
@@ -288,10 +495,15 @@ func (h *Handler) GetUserURLs(store store.Store, baseURL string) http.HandlerFun
 	}
 }
 
+// DeletionQueue deletes user URLs in the background
+type DeletionQueue interface {
+	Enqueue(ctx context.Context, urls ...models.UserShortURL) error
+}
+
 // DeleteUserURLs is an HTTP handler that reads a JSON array of short URLs to be
-// deleted for the user. It performs logical deletion of these URLs.
-// It requires a store to delete the URLs and the user's ID in the context.
-func (h *Handler) DeleteUserURLs(store store.Store) http.HandlerFunc {
+// deleted for the user, queues them for background deletion and responds with
+// 202 Accepted. It responds with 503 if the queue does not accept the URLs in time.
+func (h *Handler) DeleteUserURLs(deletions DeletionQueue) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
 		defer cancel()
@@ -309,17 +521,60 @@ func (h *Handler) DeleteUserURLs(store store.Store) http.HandlerFunc {
 			return
 		}
 
-		for _, shortURL := range deleteRequest.ShortURLs {
-			err := store.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{
+		urls := make([]models.UserShortURL, len(deleteRequest.ShortURLs))
+		for i, shortURL := range deleteRequest.ShortURLs {
+			urls[i] = models.UserShortURL{
 				UserID:   userID,
 				ShortURL: shortURL,
-			}))
-			if err != nil {
-				http.Error(w, "can't delete URL", http.StatusInternalServerError)
-				return
 			}
 		}
 
+		if err := deletions.Enqueue(ctx, urls...); err != nil {
+			logger.Log.Error("Failed to queue URLs for deletion", "error", err)
+			http.Error(w, "can't delete URL", http.StatusServiceUnavailable)
+			return
+		}
+
 		w.WriteHeader(http.StatusAccepted)
 	}
 }
+
+// RestoreUserURLs is an HTTP handler that reads a JSON array of short URLs
+// deleted by the user and restores those deleted within the grace period.
+// It responds with a JSON array of the restored short URLs; URLs that are
+// not deleted, belong to other users or are past the grace period are skipped.
+func (h *Handler) RestoreUserURLs(store store.Store, gracePeriod time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), time.Second*10)
+		defer cancel()
+
+		userID, ok := contextutils.GetUserID(ctx)
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		var shortURLs []string
+		if err := json.NewDecoder(r.Body).Decode(&shortURLs); err != nil {
+			http.Error(w, "can't unmarshal body", http.StatusBadRequest)
+			return
+		}
+
+		restored, err := services.RestoreUserURLs(ctx, store, userID, shortURLs, gracePeriod)
+		if err != nil {
+			logger.Log.Error("Failed to restore user URLs", "error", err)
+			http.Error(w, "can't restore URLs", http.StatusInternalServerError)
+			return
+		}
+
+		responseBody, err := json.Marshal(restored)
+		if err != nil {
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(responseBody)
+	}
+}
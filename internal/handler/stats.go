@@ -6,17 +6,47 @@ import (
 	"net/http"
 
 	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/services/worker"
 	"github.com/learies/goShortener/internal/store"
 )
 
 // StatsResponse represents the response structure for the stats endpoint
 type StatsResponse struct {
-	URLs  int `json:"urls"`
-	Users int `json:"users"`
+	URLs        int                    `json:"urls"`
+	ExpiredURLs int                    `json:"expired_urls"`
+	Users       int                    `json:"users"`
+	Cache       *CacheStatsResponse    `json:"cache,omitempty"`
+	Deletion    *DeletionStatsResponse `json:"deletion,omitempty"`
 }
 
-// GetStats is a handler that returns statistics about the URL shortener service
-func GetStats(store store.Store, trustedSubnet string) http.HandlerFunc {
+// CacheStatsResponse represents the store cache counters
+type CacheStatsResponse struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// DeletionStatsResponse represents the background deletion queue metrics
+type DeletionStatsResponse struct {
+	QueueDepth int   `json:"queue_depth"`
+	LagMs      int64 `json:"lag_ms"`
+	Deleted    int64 `json:"deleted"`
+	Failed     int64 `json:"failed"`
+}
+
+// deleterStatser is implemented by deletion queues that report their metrics
+type deleterStatser interface {
+	Stats() worker.DeleterStats
+}
+
+// cacheStatser is implemented by stores with a read cache
+type cacheStatser interface {
+	CacheStats() store.CacheStats
+}
+
+// GetStats is a handler that returns statistics about the URL shortener service.
+// The deletion queue metrics are included when deletions reports them.
+func GetStats(store store.Store, trustedSubnet string, deletions DeletionQueue) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Check if trusted subnet is configured
 		if trustedSubnet == "" {
@@ -53,7 +83,7 @@ func GetStats(store store.Store, trustedSubnet string) http.HandlerFunc {
 		}
 
 		// Get stats from store
-		urlsCount, usersCount, err := store.GetStats(r.Context())
+		stats, err := store.GetStats(r.Context())
 		if err != nil {
 			logger.Log.Error("Failed to get stats", "error", err)
 			http.Error(w, "Failed to get stats", http.StatusInternalServerError)
@@ -62,8 +92,26 @@ func GetStats(store store.Store, trustedSubnet string) http.HandlerFunc {
 
 		// Prepare response
 		response := StatsResponse{
-			URLs:  urlsCount,
-			Users: usersCount,
+			URLs:        stats.URLs,
+			ExpiredURLs: stats.ExpiredURLs,
+			Users:       stats.Users,
+		}
+		if cached, ok := store.(cacheStatser); ok {
+			cacheStats := cached.CacheStats()
+			response.Cache = &CacheStatsResponse{
+				Hits:   cacheStats.Hits,
+				Misses: cacheStats.Misses,
+				Size:   cacheStats.Size,
+			}
+		}
+		if queue, ok := deletions.(deleterStatser); ok {
+			deleterStats := queue.Stats()
+			response.Deletion = &DeletionStatsResponse{
+				QueueDepth: deleterStats.QueueDepth,
+				LagMs:      deleterStats.Lag.Milliseconds(),
+				Deleted:    deleterStats.Deleted,
+				Failed:     deleterStats.Failed,
+			}
 		}
 
 		// Set response headers
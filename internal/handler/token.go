@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// TokenIssuer is an interface that issues a token for a user and sets it in the response.
+type TokenIssuer interface {
+	Issue(w http.ResponseWriter, userID string) (models.TokenResponse, error)
+}
+
+// IssueToken is an HTTP handler that issues a new token for the current user,
+// so API clients without cookies can keep their identity with the
+// Authorization header. The token is returned in the body and in the header.
+func (h *Handler) IssueToken(tokens TokenIssuer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := contextutils.GetUserID(r.Context())
+		if !ok {
+			http.Error(w, "UserID not found in context", http.StatusUnauthorized)
+			return
+		}
+
+		token, err := tokens.Issue(w, userID.String())
+		if err != nil {
+			http.Error(w, "Could not create token", http.StatusInternalServerError)
+			return
+		}
+
+		responseBody, err := json.Marshal(token)
+		if err != nil {
+			http.Error(w, "can't marshal response", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(responseBody)
+	}
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// stubVerifier accepts the keys of its map.
+type stubVerifier map[string]models.APIKey
+
+func (v stubVerifier) Verify(ctx context.Context, key string) (models.APIKey, error) {
+	if key == models.APIKeyPrefix+"broken" {
+		return models.APIKey{}, errors.New("store is down")
+	}
+	apiKey, ok := v[key]
+	if !ok {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+	return apiKey, nil
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	key, err := NewHMACKey([]byte("current-secret-0123456789"))
+	require.NoError(t, err)
+	keys, err := NewKeyring(key)
+	require.NoError(t, err)
+	sessions, err := NewSessions(keys, SessionOptions{TTL: time.Hour})
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	verifier := stubVerifier{
+		models.APIKeyPrefix + "reader": {ID: uuid.New(), UserID: userID, Scopes: []string{models.ScopeLinksRead}},
+	}
+
+	// The handler requires links:read and reports the user and the scopes of the request
+	next := RequireScope(models.ScopeLinksRead)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := contextutils.GetUserID(r.Context())
+		scopes, ok := contextutils.GetScopes(r.Context())
+		if !ok {
+			w.Write([]byte(id.String() + " session"))
+			return
+		}
+		w.Write([]byte(id.String() + " " + strings.Join(scopes, ",")))
+	}))
+	handler := JWTMiddleware(sessions, verifier)(next)
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	t.Run("Valid API Key", func(t *testing.T) {
+		rec := serve("Bearer " + models.APIKeyPrefix + "reader")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, userID.String()+" links:read", rec.Body.String())
+		assert.Empty(t, rec.Result().Cookies(), "API keys do not start sessions")
+		assert.Empty(t, rec.Header().Get("Authorization"))
+	})
+
+	t.Run("Unknown API Key", func(t *testing.T) {
+		rec := serve("Bearer " + models.APIKeyPrefix + "unknown")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, `Bearer error="invalid_token", error_description="API key is invalid or revoked"`, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Verifier Failure", func(t *testing.T) {
+		rec := serve("Bearer " + models.APIKeyPrefix + "broken")
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+
+	t.Run("Session Has All Scopes", func(t *testing.T) {
+		rec := serve("")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, strings.HasSuffix(rec.Body.String(), " session"))
+	})
+
+	t.Run("API Keys Disabled", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+models.APIKeyPrefix+"reader")
+		rec := httptest.NewRecorder()
+		JWTMiddleware(sessions, nil)(next).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+}
+
+func TestRequireScope(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	t.Run("Missing Scope", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req = req.WithContext(contextutils.WithScopes(req.Context(), []string{models.ScopeLinksRead}))
+		rec := httptest.NewRecorder()
+		RequireScope(models.ScopeLinksWrite)(next).ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Equal(t, `Bearer error="insufficient_scope", scope="links:write"`, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("Session Required", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		rec := httptest.NewRecorder()
+		RequireSession(next).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		req = req.WithContext(contextutils.WithScopes(req.Context(), []string{models.ScopeLinksRead, models.ScopeLinksWrite, models.ScopeLinksDelete, models.ScopeStatsRead}))
+		rec = httptest.NewRecorder()
+		RequireSession(next).ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	})
+}
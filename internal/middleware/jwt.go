@@ -2,15 +2,26 @@
 package middleware
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 
 	"github.com/learies/goShortener/internal/config/contextutils"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
 )
 
+// DefaultSessionTTL is the lifetime of a session when none is configured.
+const DefaultSessionTTL = 30 * 24 * time.Hour
+
+// errInvalidUserID marks a verified token whose user ID is missing or not a UUID.
+var errInvalidUserID = errors.New("token has no valid user id")
+
 // Claims represents the claims in a JWT token.
 type Claims struct {
 	jwt.RegisteredClaims
@@ -23,64 +34,235 @@ func CreateUserID() string {
 	return userID
 }
 
+// SessionOptions configures the lifetime of user tokens and the cookie that carries them.
+type SessionOptions struct {
+	// TTL is the lifetime of a token, DefaultSessionTTL when zero.
+	TTL time.Duration
+	// RenewBefore is how long before expiry a valid token is replaced
+	// by a new one for the same user; zero disables renewal.
+	RenewBefore time.Duration
+	// CookieDomain, CookieSecure and CookieSameSite (lax, strict or none)
+	// set the attributes of the token cookie.
+	CookieDomain   string
+	CookieSecure   bool
+	CookieSameSite string
+}
+
+// Sessions issues and verifies the tokens that identify users.
+type Sessions struct {
+	keys        *Keyring
+	ttl         time.Duration
+	renewBefore time.Duration
+	domain      string
+	secure      bool
+	sameSite    http.SameSite
+}
+
+// NewSessions creates sessions whose tokens are signed with keys.
+func NewSessions(keys *Keyring, opts SessionOptions) (*Sessions, error) {
+	if opts.TTL < 0 {
+		return nil, fmt.Errorf("session TTL must not be negative, got %s", opts.TTL)
+	}
+	if opts.TTL == 0 {
+		opts.TTL = DefaultSessionTTL
+	}
+	if opts.RenewBefore < 0 || opts.RenewBefore >= opts.TTL {
+		return nil, fmt.Errorf("session renewal must be between 0 and the session TTL %s, got %s", opts.TTL, opts.RenewBefore)
+	}
+
+	sameSite, err := parseSameSite(opts.CookieSameSite)
+	if err != nil {
+		return nil, err
+	}
+	if sameSite == http.SameSiteNoneMode && !opts.CookieSecure {
+		return nil, errors.New("SameSite=None cookies must be secure")
+	}
+
+	return &Sessions{
+		keys:        keys,
+		ttl:         opts.TTL,
+		renewBefore: opts.RenewBefore,
+		domain:      opts.CookieDomain,
+		secure:      opts.CookieSecure,
+		sameSite:    sameSite,
+	}, nil
+}
+
+// parseSameSite parses the SameSite attribute of the cookie, lax by default.
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("invalid cookie SameSite %q: must be lax, strict or none", value)
+	}
+}
+
+// Issue signs a new token for the user and sets it in the response cookie
+// and, for clients without cookies, in the Authorization header.
+func (s *Sessions) Issue(w http.ResponseWriter, userID string) (models.TokenResponse, error) {
+	now := time.Now()
+	expirationTime := now.Add(s.ttl)
+
+	claims := &Claims{
+		UserID: userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+
+	tokenString, err := s.keys.Sign(claims)
+	if err != nil {
+		return models.TokenResponse{}, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    tokenString,
+		Expires:  expirationTime,
+		MaxAge:   int(s.ttl / time.Second),
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   s.domain,
+		Secure:   s.secure,
+		SameSite: s.sameSite,
+	})
+	w.Header().Set("Authorization", "Bearer "+tokenString)
+
+	return models.TokenResponse{Token: tokenString, TokenType: "Bearer", ExpiresAt: expirationTime}, nil
+}
+
+// Clear removes the token cookie, so the next request starts a new anonymous session.
+// Tokens already handed out stay valid until they expire.
+func (s *Sessions) Clear(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    "",
+		Expires:  time.Unix(0, 0),
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+		Domain:   s.domain,
+		Secure:   s.secure,
+		SameSite: s.sameSite,
+	})
+}
+
+// needsRenewal reports whether a token with the claims is close enough to expiry to be renewed.
+func (s *Sessions) needsRenewal(claims *Claims) bool {
+	if s.renewBefore == 0 || claims.ExpiresAt == nil {
+		return false
+	}
+	return time.Until(claims.ExpiresAt.Time) < s.renewBefore
+}
+
+// bearerToken returns the token of the Authorization header and whether the
+// request uses the Bearer scheme at all.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// unauthorizedBearer rejects a request with an invalid Bearer token.
+func unauthorizedBearer(w http.ResponseWriter, description string) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description=%q`, description))
+	http.Error(w, "Invalid token", http.StatusUnauthorized)
+}
+
 // JWTMiddleware is an HTTP middleware that handles JWT authentication.
-// It checks for a JWT token in the request cookies and creates a new token if none is found.
+// It reads a JWT token from the Authorization header as "Bearer <token>" or from
+// the request cookies. Without a token, or with a cookie that is expired, cannot
+// be verified or has no valid user ID, a new user gets a new token, so the cookie
+// never locks a visitor out; an invalid or expired Bearer token is rejected, since
+// API clients have to keep their identity. A valid token close to expiry is
+// renewed for the same user.
+// A Bearer token with models.APIKeyPrefix is an API key checked by apiKeys, which
+// may be nil to disable API keys; the request then has only the scopes of the key.
 // It sets the user ID in the request context and passes the request to the next handler.
-func JWTMiddleware(next http.Handler) http.Handler {
+func JWTMiddleware(sessions *Sessions, apiKeys APIKeyVerifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return jwtHandler(sessions, apiKeys, next)
+	}
+}
+
+// jwtHandler authenticates the requests to next with the tokens of sessions
+// and the API keys of apiKeys.
+func jwtHandler(sessions *Sessions, apiKeys APIKeyVerifier, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		var userID string
-		var tokenString string
 
-		// Чтение токена из куки
-		cookie, err := r.Cookie("token")
-		if err == nil {
-			tokenString = cookie.Value
+		// Токен из заголовка Authorization имеет приоритет над кукой
+		tokenString, bearer := bearerToken(r)
+		if !bearer {
+			cookie, err := r.Cookie("token")
+			if err == nil {
+				tokenString = cookie.Value
+			}
+		}
+		if bearer && apiKeys != nil && strings.HasPrefix(tokenString, models.APIKeyPrefix) {
+			authenticateAPIKey(apiKeys, tokenString, w, r, next)
+			return
 		}
 
-		// Если токен не передан нужно создать userID и создать для него токен
-		if tokenString == "" {
-			userID = CreateUserID()
-
-			// Время жизни токена 1 минута
-			expirationTime := time.Now().Add(1 * time.Minute)
-
-			claims := &Claims{
-				UserID: userID,
-				RegisteredClaims: jwt.RegisteredClaims{
-					ExpiresAt: jwt.NewNumericDate(expirationTime),
-				},
+		issue := tokenString == "" && !bearer
+		if !issue {
+			claims := &Claims{}
+			err := sessions.keys.Parse(tokenString, claims)
+			if err == nil {
+				// Подписанный токен без корректного userID так же непригоден
+				if _, parseErr := uuid.Parse(claims.UserID); parseErr != nil {
+					err = errInvalidUserID
+				}
 			}
-
-			token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-			newTokenString, err := token.SignedString([]byte("qwerty"))
-			if err != nil {
-				http.Error(w, "Could not create token", http.StatusInternalServerError)
+			switch {
+			case bearer && errors.Is(err, jwt.ErrTokenExpired):
+				unauthorizedBearer(w, "token expired")
 				return
+			case bearer && err != nil:
+				unauthorizedBearer(w, "token is invalid")
+				return
+			case err == nil:
+				userID = claims.UserID
+				issue = sessions.needsRenewal(claims)
+			case errors.Is(err, jwt.ErrTokenExpired):
+				// Просроченный токен заменяется новым пользователем, а не ошибкой
+				logger.Log.Debug("Session token expired, issuing a new one")
+				issue = true
+			default:
+				// Кука, подписанная выведенным из ротации ключом, поддельная или
+				// без пользователя тоже заменяется новым пользователем, иначе
+				// посетитель получал бы 401 на каждой ссылке до истечения куки
+				logger.Log.Debug("Session token not verified, issuing a new one", "error", err)
+				issue = true
 			}
-			tokenString = newTokenString
-
-			// Устанавливаем токен в куки
-			http.SetCookie(w, &http.Cookie{
-				Name:     "token",
-				Value:    tokenString,
-				Expires:  expirationTime,
-				HttpOnly: true,
-				Path:     "/",
-			})
-		} else {
-			claims := &Claims{}
-			token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
-				return []byte("qwerty"), nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, "Invalid token", http.StatusUnauthorized)
+		}
+
+		// Новый пользователь получает новый userID, при продлении userID сохраняется
+		if issue {
+			if userID == "" {
+				userID = CreateUserID()
+			}
+			if _, err := sessions.Issue(w, userID); err != nil {
+				http.Error(w, "Could not create token", http.StatusInternalServerError)
 				return
 			}
-			userID = claims.UserID
 		}
 
-		ctx := contextutils.WithUserID(r.Context(), uuid.MustParse(userID))
+		id, err := uuid.Parse(userID)
+		if err != nil {
+			http.Error(w, "Could not create token", http.StatusInternalServerError)
+			return
+		}
+
+		ctx := contextutils.WithUserID(r.Context(), id)
 		r = r.WithContext(ctx)
 
 		next.ServeHTTP(w, r)
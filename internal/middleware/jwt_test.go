@@ -11,9 +11,33 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/learies/goShortener/internal/config/contextutils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestJWTMiddleware(t *testing.T) {
+	previousKey, err := NewHMACKey([]byte("previous-secret-0123456789"))
+	require.NoError(t, err)
+	currentKey, err := NewHMACKey([]byte("current-secret-0123456789"))
+	require.NoError(t, err)
+	keys, err := NewKeyring(currentKey, previousKey)
+	require.NoError(t, err)
+	previousKeys, err := NewKeyring(previousKey)
+	require.NoError(t, err)
+	sessions, err := NewSessions(keys, SessionOptions{TTL: time.Hour, RenewBefore: 10 * time.Minute})
+	require.NoError(t, err)
+
+	claimsExpiringIn := func(d time.Duration) *Claims {
+		return &Claims{
+			UserID: "12345678-1234-1234-1234-123456789abc",
+			RegisteredClaims: jwt.RegisteredClaims{
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(d)),
+			},
+		}
+	}
+	validClaims := func() *Claims {
+		return claimsExpiringIn(time.Hour)
+	}
+
 	// Mock the next handler
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, ok := contextutils.GetUserID(r.Context())
@@ -29,23 +53,51 @@ func TestJWTMiddleware(t *testing.T) {
 		setupRequest   func(req *http.Request)
 		expectedStatus int
 		expectedBody   string
+		expectedCookie bool
+		// expectedAuthenticate is the WWW-Authenticate header of a rejected Bearer token
+		expectedAuthenticate string
 	}{
 		{
 			name: "Valid Token Provided",
 			setupRequest: func(req *http.Request) {
-				claims := &Claims{
-					UserID: "12345678-1234-1234-1234-123456789abc",
-					RegisteredClaims: jwt.RegisteredClaims{
-						ExpiresAt: jwt.NewNumericDate(time.Now().Add(1 * time.Minute)),
-					},
-				}
-				token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-				tokenString, _ := token.SignedString([]byte("qwerty"))
+				tokenString, _ := keys.Sign(validClaims())
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
+		},
+		{
+			name: "Token of Previous Key",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := previousKeys.Sign(validClaims())
 				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
 		},
+		{
+			name: "Token of Dropped Key Replaced With New Identity",
+			setupRequest: func(req *http.Request) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+				tokenString, _ := token.SignedString([]byte("qwerty"))
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
+		{
+			name: "Forged Token Replaced With New Identity",
+			setupRequest: func(req *http.Request) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+				token.Header["kid"] = currentKey.ID
+				tokenString, _ := token.SignedString([]byte("attacker-secret-0123456789"))
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
 		{
 			name: "No Token Provided",
 			setupRequest: func(req *http.Request) {
@@ -53,14 +105,140 @@ func TestJWTMiddleware(t *testing.T) {
 			},
 			expectedStatus: http.StatusOK,
 			expectedBody:   "UserID: ", // In reality, this will be a new UUID, verify format instead
+			expectedCookie: true,
 		},
 		{
-			name: "Invalid Token Provided",
+			name: "Expired Token Replaced With New Identity",
 			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(claimsExpiringIn(-time.Minute))
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
+		{
+			name: "Token Near Expiry Renewed",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(claimsExpiringIn(5 * time.Minute))
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
+			expectedCookie: true,
+		},
+		{
+			name: "Bearer Token Provided",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(validClaims())
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
+		},
+		{
+			name: "Bearer Token Takes Precedence Over Cookie",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(validClaims())
+				req.Header.Set("Authorization", "bearer "+tokenString)
 				req.AddCookie(&http.Cookie{Name: "token", Value: "invalid-token"})
 			},
-			expectedStatus: http.StatusUnauthorized,
-			expectedBody:   "Invalid token\n",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
+		},
+		{
+			name: "Bearer Token Near Expiry Renewed",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(claimsExpiringIn(5 * time.Minute))
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: 12345678-1234-1234-1234-123456789abc",
+			expectedCookie: true,
+		},
+		{
+			name: "Expired Bearer Token",
+			setupRequest: func(req *http.Request) {
+				tokenString, _ := keys.Sign(claimsExpiringIn(-time.Minute))
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+			},
+			expectedStatus:       http.StatusUnauthorized,
+			expectedBody:         "Invalid token\n",
+			expectedAuthenticate: `Bearer error="invalid_token", error_description="token expired"`,
+		},
+		{
+			name: "Bearer Token of Dropped Key",
+			setupRequest: func(req *http.Request) {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, validClaims())
+				tokenString, _ := token.SignedString([]byte("qwerty"))
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+			},
+			expectedStatus:       http.StatusUnauthorized,
+			expectedBody:         "Invalid token\n",
+			expectedAuthenticate: `Bearer error="invalid_token", error_description="token is invalid"`,
+		},
+		{
+			name: "Empty Bearer Token",
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer ")
+			},
+			expectedStatus:       http.StatusUnauthorized,
+			expectedBody:         "Invalid token\n",
+			expectedAuthenticate: `Bearer error="invalid_token", error_description="token is invalid"`,
+		},
+		{
+			name: "Other Authorization Scheme Ignored",
+			setupRequest: func(req *http.Request) {
+				req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
+		{
+			name: "Token Without User Replaced With New Identity",
+			setupRequest: func(req *http.Request) {
+				claims := validClaims()
+				claims.UserID = ""
+				tokenString, _ := keys.Sign(claims)
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
+		{
+			name: "Token With Malformed User Replaced With New Identity",
+			setupRequest: func(req *http.Request) {
+				claims := validClaims()
+				claims.UserID = "not-a-uuid"
+				tokenString, _ := keys.Sign(claims)
+				req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
+		},
+		{
+			name: "Bearer Token With Malformed User",
+			setupRequest: func(req *http.Request) {
+				claims := validClaims()
+				claims.UserID = "not-a-uuid"
+				tokenString, _ := keys.Sign(claims)
+				req.Header.Set("Authorization", "Bearer "+tokenString)
+			},
+			expectedStatus:       http.StatusUnauthorized,
+			expectedBody:         "Invalid token\n",
+			expectedAuthenticate: `Bearer error="invalid_token", error_description="token is invalid"`,
+		},
+		{
+			name: "Invalid Token Replaced With New Identity",
+			setupRequest: func(req *http.Request) {
+				req.AddCookie(&http.Cookie{Name: "token", Value: "invalid-token"})
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "UserID: ",
+			expectedCookie: true,
 		},
 	}
 
@@ -77,7 +255,7 @@ func TestJWTMiddleware(t *testing.T) {
 			rec := httptest.NewRecorder()
 
 			// Run the middleware with the mock handler
-			handler := JWTMiddleware(nextHandler)
+			handler := JWTMiddleware(sessions, nil)(nextHandler)
 			handler.ServeHTTP(rec, req)
 
 			// Check the status code
@@ -87,6 +265,76 @@ func TestJWTMiddleware(t *testing.T) {
 			responseBody, err := io.ReadAll(rec.Body)
 			assert.NoError(t, err)
 			assert.True(t, strings.HasPrefix(string(responseBody), tt.expectedBody))
+			assert.Equal(t, tt.expectedAuthenticate, rec.Header().Get("WWW-Authenticate"))
+
+			// Check that the token cookie is issued only when needed
+			var cookie *http.Cookie
+			for _, c := range rec.Result().Cookies() {
+				if c.Name == "token" {
+					cookie = c
+				}
+			}
+			if !tt.expectedCookie {
+				assert.Nil(t, cookie)
+				assert.Empty(t, rec.Header().Get("Authorization"))
+				return
+			}
+			require.NotNil(t, cookie)
+			assert.Equal(t, "Bearer "+cookie.Value, rec.Header().Get("Authorization"))
+			claims := &Claims{}
+			require.NoError(t, keys.Parse(cookie.Value, claims))
+			assert.Equal(t, "UserID: "+claims.UserID, string(responseBody))
+			assert.WithinDuration(t, time.Now().Add(time.Hour), claims.ExpiresAt.Time, time.Minute)
 		})
 	}
 }
+
+func TestSessions(t *testing.T) {
+	key, err := NewHMACKey([]byte("current-secret-0123456789"))
+	require.NoError(t, err)
+	keys, err := NewKeyring(key)
+	require.NoError(t, err)
+
+	t.Run("Cookie Attributes", func(t *testing.T) {
+		sessions, err := NewSessions(keys, SessionOptions{
+			CookieDomain:   "short.example",
+			CookieSecure:   true,
+			CookieSameSite: "Strict",
+		})
+		require.NoError(t, err)
+
+		rec := httptest.NewRecorder()
+		token, err := sessions.Issue(rec, "12345678-1234-1234-1234-123456789abc")
+		require.NoError(t, err)
+		assert.Equal(t, "Bearer", token.TokenType)
+		assert.Equal(t, "Bearer "+token.Token, rec.Header().Get("Authorization"))
+
+		cookies := rec.Result().Cookies()
+		require.Len(t, cookies, 1)
+		cookie := cookies[0]
+		assert.Equal(t, token.Token, cookie.Value)
+		assert.Equal(t, "short.example", cookie.Domain)
+		assert.Equal(t, "/", cookie.Path)
+		assert.True(t, cookie.Secure)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		assert.Equal(t, int(DefaultSessionTTL/time.Second), cookie.MaxAge)
+	})
+
+	t.Run("Invalid Options", func(t *testing.T) {
+		invalid := []SessionOptions{
+			{TTL: -time.Hour},
+			{TTL: time.Hour, RenewBefore: time.Hour},
+			{RenewBefore: -time.Minute},
+			{CookieSameSite: "sometimes"},
+			{CookieSameSite: "none"},
+		}
+		for _, opts := range invalid {
+			_, err := NewSessions(keys, opts)
+			assert.Error(t, err, "%+v", opts)
+		}
+
+		_, err := NewSessions(keys, SessionOptions{CookieSameSite: "none", CookieSecure: true})
+		assert.NoError(t, err)
+	})
+}
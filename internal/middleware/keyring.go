@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// MinSecretLength is the minimum length of an HMAC secret in bytes.
+const MinSecretLength = 16
+
+// ErrUnknownKey is returned for a token signed by a key that is not in the keyring.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// SigningKey is a key that signs and verifies tokens. Its ID is sent in
+// the kid header of the tokens and is derived from the key, so the same
+// key has the same ID on every instance of the service.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	// sign is nil for a public key that only verifies tokens
+	sign   any
+	verify any
+}
+
+// CanSign reports whether the key can sign tokens.
+func (k SigningKey) CanSign() bool {
+	return k.sign != nil
+}
+
+// NewHMACKey creates an HS256 key from a shared secret.
+func NewHMACKey(secret []byte) (SigningKey, error) {
+	if len(secret) < MinSecretLength {
+		return SigningKey{}, fmt.Errorf("JWT secret must be at least %d bytes, got %d", MinSecretLength, len(secret))
+	}
+
+	return SigningKey{
+		ID:     keyID(jwt.SigningMethodHS256, secret),
+		Method: jwt.SigningMethodHS256,
+		sign:   secret,
+		verify: secret,
+	}, nil
+}
+
+// ParseKey creates a key from PEM data or, if data is not PEM, from an HMAC
+// secret. RSA keys are used with RS256 and Ed25519 keys with EdDSA. A public
+// key only verifies tokens, which is enough for a previous key.
+func ParseKey(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return NewHMACKey(bytes.TrimSpace(data))
+	}
+
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to parse %s: %w", block.Type, err)
+	}
+
+	return newAsymmetricKey(key)
+}
+
+// LoadKeyFile reads a key from a file, see ParseKey.
+func LoadKeyFile(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to read JWT key file: %w", err)
+	}
+
+	key, err := ParseKey(data)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+// newAsymmetricKey creates an RS256 or EdDSA key from a parsed private or public key.
+func newAsymmetricKey(key any) (SigningKey, error) {
+	var k SigningKey
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k = SigningKey{Method: jwt.SigningMethodRS256, sign: key, verify: &key.PublicKey}
+	case *rsa.PublicKey:
+		k = SigningKey{Method: jwt.SigningMethodRS256, verify: key}
+	case ed25519.PrivateKey:
+		k = SigningKey{Method: jwt.SigningMethodEdDSA, sign: key, verify: key.Public()}
+	case ed25519.PublicKey:
+		k = SigningKey{Method: jwt.SigningMethodEdDSA, verify: key}
+	default:
+		return SigningKey{}, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(k.verify)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to marshal public key: %w", err)
+	}
+	k.ID = keyID(k.Method, public)
+
+	return k, nil
+}
+
+// keyID derives the ID of a key from its algorithm and its public part or secret.
+func keyID(method jwt.SigningMethod, material []byte) string {
+	sum := sha256.Sum256(append([]byte(method.Alg()+":"), material...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Keyring signs tokens with the current key and verifies them with the
+// current and previous keys, so keys can be rotated without invalidating
+// the tokens already issued.
+type Keyring struct {
+	current SigningKey
+	keys    map[string]SigningKey
+	// ids lists the keys in order, the current key first
+	ids     []string
+	methods []string
+}
+
+// NewKeyring creates a keyring that signs with current and also accepts
+// tokens signed by the previous keys.
+func NewKeyring(current SigningKey, previous ...SigningKey) (*Keyring, error) {
+	if !current.CanSign() {
+		return nil, errors.New("current JWT key must be a private key or a secret")
+	}
+
+	k := &Keyring{current: current, keys: make(map[string]SigningKey)}
+	for _, key := range append([]SigningKey{current}, previous...) {
+		if _, ok := k.keys[key.ID]; ok {
+			continue
+		}
+		k.keys[key.ID] = key
+		k.ids = append(k.ids, key.ID)
+		k.methods = append(k.methods, key.Method.Alg())
+	}
+
+	return k, nil
+}
+
+// KeyringOptions selects the keys of a keyring. The current key is either
+// a Secret or a KeyFile; previous keys only verify tokens.
+type KeyringOptions struct {
+	Secret           string
+	KeyFile          string
+	PreviousSecrets  []string
+	PreviousKeyFiles []string
+}
+
+// LoadKeyring creates a keyring from the configured keys. Without a current
+// key it signs with a random secret, so tokens do not survive a restart.
+func LoadKeyring(opts KeyringOptions) (*Keyring, error) {
+	var current SigningKey
+	var err error
+	switch {
+	case opts.Secret != "" && opts.KeyFile != "":
+		return nil, errors.New("set either a JWT secret or a JWT key file, not both")
+	case opts.Secret != "":
+		current, err = NewHMACKey([]byte(opts.Secret))
+	case opts.KeyFile != "":
+		current, err = LoadKeyFile(opts.KeyFile)
+	default:
+		logger.Log.Warn("No JWT signing key configured, using a random secret; tokens will not survive a restart")
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate JWT secret: %w", err)
+		}
+		current, err = NewHMACKey(secret)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []SigningKey
+	for _, secret := range opts.PreviousSecrets {
+		key, err := NewHMACKey([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("invalid previous JWT secret: %w", err)
+		}
+		previous = append(previous, key)
+	}
+	for _, path := range opts.PreviousKeyFiles {
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+
+	return NewKeyring(current, previous...)
+}
+
+// Sign signs the claims with the current key and sets its ID in the kid header.
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.current.Method, claims)
+	token.Header["kid"] = k.current.ID
+	return token.SignedString(k.current.sign)
+}
+
+// Parse verifies the token with the key named by its kid header and decodes
+// its claims. Tokens of unknown keys or with another algorithm are rejected.
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, ErrUnknownKey
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s for key %s", t.Method.Alg(), kid)
+		}
+		return key.verify, nil
+	}, jwt.WithValidMethods(k.methods))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return errors.New("invalid token")
+	}
+
+	return nil
+}
+
+// JWKS returns the public keys of the keyring as a JSON Web Key Set, so
+// other services can verify the tokens. HMAC secrets are never published.
+func (k *Keyring) JWKS() models.JWKS {
+	set := models.JWKS{Keys: []models.JWK{}}
+	for _, id := range k.ids {
+		key := k.keys[id]
+		jwk := models.JWK{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+		switch public := key.verify.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
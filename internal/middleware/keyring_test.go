@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring(t *testing.T) {
+	dir := t.TempDir()
+
+	// writePEM сохраняет ключ в PEM-файл и возвращает путь к нему
+	writePEM := func(t *testing.T, name, blockType string, der []byte) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600))
+		return path
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaPrivateFile := writePEM(t, "rsa.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	rsaPublicDER, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPublicFile := writePEM(t, "rsa.pub", "PUBLIC KEY", rsaPublicDER)
+
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	edPrivateDER, err := x509.MarshalPKCS8PrivateKey(edPrivate)
+	require.NoError(t, err)
+	edPrivateFile := writePEM(t, "ed25519.pem", "PRIVATE KEY", edPrivateDER)
+
+	claims := func() *Claims {
+		return &Claims{
+			UserID:           "12345678-1234-1234-1234-123456789abc",
+			RegisteredClaims: jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))},
+		}
+	}
+
+	t.Run("Алгоритмы ключей", func(t *testing.T) {
+		tests := []struct {
+			name           string
+			opts           KeyringOptions
+			expectedMethod string
+		}{
+			{name: "HS256", opts: KeyringOptions{Secret: "secret-0123456789abcdef"}, expectedMethod: "HS256"},
+			{name: "RS256", opts: KeyringOptions{KeyFile: rsaPrivateFile}, expectedMethod: "RS256"},
+			{name: "EdDSA", opts: KeyringOptions{KeyFile: edPrivateFile}, expectedMethod: "EdDSA"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				keys, err := LoadKeyring(tt.opts)
+				require.NoError(t, err)
+
+				tokenString, err := keys.Sign(claims())
+				require.NoError(t, err)
+
+				token, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
+				require.NoError(t, err)
+				assert.Equal(t, tt.expectedMethod, token.Method.Alg())
+				assert.Equal(t, keys.current.ID, token.Header["kid"])
+
+				parsed := &Claims{}
+				require.NoError(t, keys.Parse(tokenString, parsed))
+				assert.Equal(t, "12345678-1234-1234-1234-123456789abc", parsed.UserID)
+			})
+		}
+	})
+
+	t.Run("Ротация ключей", func(t *testing.T) {
+		oldKeys, err := LoadKeyring(KeyringOptions{KeyFile: rsaPrivateFile})
+		require.NoError(t, err)
+		oldToken, err := oldKeys.Sign(claims())
+		require.NoError(t, err)
+
+		// Старый ключ остается только открытым ключом для проверки
+		keys, err := LoadKeyring(KeyringOptions{
+			KeyFile:          edPrivateFile,
+			PreviousKeyFiles: []string{rsaPublicFile},
+			PreviousSecrets:  []string{"previous-secret-0123456789"},
+		})
+		require.NoError(t, err)
+		assert.NoError(t, keys.Parse(oldToken, &Claims{}))
+
+		newToken, err := keys.Sign(claims())
+		require.NoError(t, err)
+		assert.NoError(t, keys.Parse(newToken, &Claims{}))
+		assert.Error(t, oldKeys.Parse(newToken, &Claims{}))
+
+		other, err := LoadKeyring(KeyringOptions{Secret: "other-secret-0123456789"})
+		require.NoError(t, err)
+		otherToken, err := other.Sign(claims())
+		require.NoError(t, err)
+		assert.ErrorIs(t, keys.Parse(otherToken, &Claims{}), ErrUnknownKey)
+
+		// Идентификатор ключа не зависит от экземпляра сервиса
+		again, err := LoadKeyring(KeyringOptions{KeyFile: edPrivateFile})
+		require.NoError(t, err)
+		assert.NoError(t, again.Parse(newToken, &Claims{}))
+	})
+
+	t.Run("Подмена алгоритма", func(t *testing.T) {
+		keys, err := LoadKeyring(KeyringOptions{KeyFile: rsaPrivateFile})
+		require.NoError(t, err)
+
+		// Открытый ключ RSA как секрет HS256 не принимается
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims())
+		token.Header["kid"] = keys.current.ID
+		tokenString, err := token.SignedString(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: rsaPublicDER}))
+		require.NoError(t, err)
+		assert.Error(t, keys.Parse(tokenString, &Claims{}))
+
+		token = jwt.NewWithClaims(jwt.SigningMethodNone, claims())
+		token.Header["kid"] = keys.current.ID
+		tokenString, err = token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+		require.NoError(t, err)
+		assert.Error(t, keys.Parse(tokenString, &Claims{}))
+	})
+
+	t.Run("Недопустимые ключи", func(t *testing.T) {
+		_, err := LoadKeyring(KeyringOptions{Secret: "qwerty"})
+		assert.Error(t, err)
+
+		_, err = LoadKeyring(KeyringOptions{Secret: "secret-0123456789abcdef", KeyFile: rsaPrivateFile})
+		assert.Error(t, err)
+
+		_, err = LoadKeyring(KeyringOptions{KeyFile: rsaPublicFile})
+		assert.Error(t, err, "открытый ключ не может подписывать токены")
+
+		_, err = LoadKeyring(KeyringOptions{KeyFile: filepath.Join(dir, "missing.pem")})
+		assert.Error(t, err)
+
+		_, err = LoadKeyring(KeyringOptions{Secret: "secret-0123456789abcdef", PreviousSecrets: []string{"short"}})
+		assert.Error(t, err)
+
+		certFile := writePEM(t, "cert.pem", "CERTIFICATE", []byte("not a key"))
+		_, err = LoadKeyring(KeyringOptions{KeyFile: certFile})
+		assert.Error(t, err)
+
+		// Без ключа токены подписываются случайным секретом
+		first, err := LoadKeyring(KeyringOptions{})
+		require.NoError(t, err)
+		second, err := LoadKeyring(KeyringOptions{})
+		require.NoError(t, err)
+		tokenString, err := first.Sign(claims())
+		require.NoError(t, err)
+		assert.Error(t, second.Parse(tokenString, &Claims{}))
+	})
+
+	t.Run("Набор открытых ключей", func(t *testing.T) {
+		keys, err := LoadKeyring(KeyringOptions{
+			KeyFile:          edPrivateFile,
+			PreviousKeyFiles: []string{rsaPrivateFile},
+			PreviousSecrets:  []string{"previous-secret-0123456789"},
+		})
+		require.NoError(t, err)
+
+		set := keys.JWKS()
+		require.Len(t, set.Keys, 2, "секреты HMAC не публикуются")
+		assert.Equal(t, "OKP", set.Keys[0].Kty)
+		assert.Equal(t, "Ed25519", set.Keys[0].Crv)
+		assert.Equal(t, "EdDSA", set.Keys[0].Alg)
+		assert.Equal(t, keys.current.ID, set.Keys[0].Kid)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(edPublic), set.Keys[0].X)
+		assert.Equal(t, "RSA", set.Keys[1].Kty)
+		assert.Equal(t, "RS256", set.Keys[1].Alg)
+		assert.Equal(t, "AQAB", set.Keys[1].E)
+		assert.NotEmpty(t, set.Keys[1].N)
+
+		hmacOnly, err := LoadKeyring(KeyringOptions{Secret: "secret-0123456789abcdef"})
+		require.NoError(t, err)
+		assert.Empty(t, hmacOnly.JWKS().Keys)
+	})
+}
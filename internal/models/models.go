@@ -2,12 +2,31 @@
 package models
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 )
 
 // ShortenRequest is a struct that represents the request body for shortening a URL.
 type ShortenRequest struct {
 	URL string `json:"url"`
+	// Alias is an optional custom short URL chosen by the user.
+	Alias string `json:"alias,omitempty"`
+	// ExpiresIn is an optional lifetime of the link, such as "24h" or "3600" seconds.
+	ExpiresIn string `json:"expires_in,omitempty"`
+	// ExpiresAt is an optional RFC 3339 time after which the link expires.
+	ExpiresAt string `json:"expires_at,omitempty"`
+	// RedirectCode is an optional HTTP status of the redirect: 301, 302, 307 or 308.
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Title is an optional title shown on the preview page of the link.
+	Title string `json:"title,omitempty"`
+	// Preview forces the preview page for every visitor of the link.
+	Preview bool `json:"preview,omitempty"`
+	// Password is an optional password visitors must enter before the redirect.
+	Password string `json:"password,omitempty"`
+	// MaxClicks is an optional number of redirects after which the link is used up;
+	// 1 makes a one-time link.
+	MaxClicks int `json:"max_clicks,omitempty"`
 }
 
 // ShortenResponse is a struct that represents the response body for shortening a URL.
@@ -22,12 +41,41 @@ type ShortenStore struct {
 	OriginalURL string    `json:"original_url"`
 	UserID      uuid.UUID `json:"user_id"`
 	Deleted     bool      `json:"deleted"`
+	// DeletedAt is the time the URL was deleted, nil for live URLs
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// CreatedAt is the time the URL was shortened, nil for URLs stored before it was recorded
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	// UsedClicks is the number of redirects consumed from MaxClicks
+	UsedClicks int `json:"used_clicks,omitempty"`
+	LinkOptions
+}
+
+// ClicksLeft returns the number of redirects left for a link with MaxClicks
+// and nil for a link without a limit.
+func (s ShortenStore) ClicksLeft() *int {
+	if s.MaxClicks <= 0 {
+		return nil
+	}
+	left := max(s.MaxClicks-s.UsedClicks, 0)
+	return &left
+}
+
+// Exhausted reports whether all the redirects allowed by MaxClicks are used.
+func (s ShortenStore) Exhausted() bool {
+	return s.MaxClicks > 0 && s.UsedClicks >= s.MaxClicks
 }
 
 // ShortenBatchRequest is a struct that represents the request body for batch shortening URLs.
 type ShortenBatchRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	ExpiresIn     string `json:"expires_in,omitempty"`
+	ExpiresAt     string `json:"expires_at,omitempty"`
+	RedirectCode  int    `json:"redirect_code,omitempty"`
+	Title         string `json:"title,omitempty"`
+	Preview       bool   `json:"preview,omitempty"`
+	Password      string `json:"password,omitempty"`
+	MaxClicks     int    `json:"max_clicks,omitempty"`
 }
 
 // ShortenBatchResponse is a struct that represents the response body for batch shortening URLs.
@@ -38,8 +86,13 @@ type ShortenBatchResponse struct {
 
 // UserURLResponse is a struct that represents the response body for a user's URL.
 type UserURLResponse struct {
-	ShortURL    string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Protected   bool       `json:"protected,omitempty"`
+	// ClicksLeft is the number of redirects left, nil for links without a limit
+	ClicksLeft *int `json:"clicks_left,omitempty"`
 }
 
 // ShortenBatchStore is a struct that represents the data stored for a batch of shortened URLs.
@@ -47,6 +100,7 @@ type ShortenBatchStore struct {
 	CorrelationID string `json:"correlation_id"`
 	ShortURL      string `json:"short_url"`
 	OriginalURL   string `json:"original_url"`
+	LinkOptions
 }
 
 // ShortenDeleteRequest is a struct that represents the request body for deleting URLs.
@@ -59,3 +113,279 @@ type UserShortURL struct {
 	UserID   uuid.UUID `json:"user_id"`
 	ShortURL string    `json:"short_url"`
 }
+
+// LinkOptions is a struct that holds the optional settings of a short link.
+type LinkOptions struct {
+	// ExpiresAt is the time after which the link is no longer served; nil means never.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// RedirectCode is the HTTP status of the redirect; 0 means the service default.
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Title is shown on the preview page of the link.
+	Title string `json:"title,omitempty"`
+	// Preview forces the preview page instead of the redirect.
+	Preview bool `json:"preview,omitempty"`
+	// PasswordHash is the bcrypt hash of the link password; empty for public links.
+	PasswordHash string `json:"password_hash,omitempty"`
+	// MaxClicks is the number of redirects after which the link is used up; 0 means unlimited.
+	MaxClicks int `json:"max_clicks,omitempty"`
+}
+
+// Protected reports whether visitors must enter a password before the redirect.
+func (o LinkOptions) Protected() bool {
+	return o.PasswordHash != ""
+}
+
+// Expired reports whether the link has expired at the given time.
+func (o LinkOptions) Expired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+// LinkOption is a function that sets an optional setting of a short link.
+type LinkOption func(*LinkOptions)
+
+// WithExpiresAt sets the time after which the link expires.
+func WithExpiresAt(expiresAt time.Time) LinkOption {
+	return func(o *LinkOptions) {
+		t := expiresAt.UTC()
+		o.ExpiresAt = &t
+	}
+}
+
+// WithRedirectCode sets the HTTP status of the redirect.
+func WithRedirectCode(code int) LinkOption {
+	return func(o *LinkOptions) {
+		o.RedirectCode = code
+	}
+}
+
+// WithTitle sets the title shown on the preview page.
+func WithTitle(title string) LinkOption {
+	return func(o *LinkOptions) {
+		o.Title = title
+	}
+}
+
+// WithPreview forces the preview page for every visitor.
+func WithPreview() LinkOption {
+	return func(o *LinkOptions) {
+		o.Preview = true
+	}
+}
+
+// WithPasswordHash sets the hash of the password that protects the link.
+func WithPasswordHash(hash string) LinkOption {
+	return func(o *LinkOptions) {
+		o.PasswordHash = hash
+	}
+}
+
+// WithMaxClicks limits the number of redirects of the link.
+func WithMaxClicks(maxClicks int) LinkOption {
+	return func(o *LinkOptions) {
+		o.MaxClicks = maxClicks
+	}
+}
+
+// NewLinkOptions is a function that applies the options to empty link settings.
+func NewLinkOptions(opts ...LinkOption) LinkOptions {
+	var o LinkOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// UpdateURLRequest is a struct that represents the request body for changing
+// the original URL of a short link.
+type UpdateURLRequest struct {
+	URL string `json:"url"`
+}
+
+// RollbackURLRequest is a struct that represents the request body for rolling
+// a short link back to a previous version. Version 0 is the original URL the
+// link was created with.
+type RollbackURLRequest struct {
+	Version int `json:"version"`
+}
+
+// URLVersion is a struct that represents a change of the original URL of a short link.
+// Versions of a link are numbered from 1 in the order of the changes.
+type URLVersion struct {
+	ShortURL    string    `json:"short_url"`
+	Version     int       `json:"version"`
+	OriginalURL string    `json:"original_url"`
+	PreviousURL string    `json:"previous_url"`
+	ChangedAt   time.Time `json:"changed_at"`
+	ChangedBy   uuid.UUID `json:"changed_by"`
+}
+
+// BlockedURLResponse is a struct that represents the response body for an
+// original URL rejected by the URL policy. Reason is one of denylisted,
+// not_allowlisted, private_address and redirect_loop.
+type BlockedURLResponse struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason"`
+	Host   string `json:"host"`
+	// Rule is the denylist rule that matched the host.
+	Rule string `json:"rule,omitempty"`
+	// CorrelationID identifies the rejected item of a batch.
+	CorrelationID string `json:"correlation_id,omitempty"`
+	Message       string `json:"message"`
+}
+
+// JWKS is a struct that represents a JSON Web Key Set with the public keys
+// that verify the tokens issued by the service.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWK is a struct that represents a public key in a JSON Web Key Set.
+// N and E are set for RSA keys, Crv and X for Ed25519 keys.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// TokenResponse is a struct that represents a token issued for the current
+// user, to be sent back in the Authorization header as "Bearer <token>".
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Account is a struct that represents a registered user account. The ID of
+// the account is the user ID that owns its links. Emails are stored in
+// lower case and passwords only as bcrypt hashes.
+type Account struct {
+	ID           uuid.UUID `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AccountRequest is a struct that represents the request body for signing up and logging in.
+type AccountRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// AccountResponse is a struct that represents the response body for signing up
+// and logging in, with the token of the account. MergedURLs is the number of
+// anonymous links moved into the account.
+type AccountResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Email      string    `json:"email"`
+	MergedURLs int       `json:"merged_urls"`
+	TokenResponse
+}
+
+// Scopes of API keys. Requests authenticated with a session have all of them.
+const (
+	ScopeLinksRead   = "links:read"
+	ScopeLinksWrite  = "links:write"
+	ScopeLinksDelete = "links:delete"
+	ScopeStatsRead   = "stats:read"
+)
+
+// APIKeyPrefix starts every API key, which tells it apart from a JWT token.
+const APIKeyPrefix = "gsk_"
+
+// APIKey is a struct that represents a personal API key of a user. Only the
+// SHA-256 hash of the key is stored; Prefix keeps its first characters so
+// the user can recognize it.
+type APIKey struct {
+	ID        uuid.UUID  `json:"id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Hash      string     `json:"hash"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRequest is a struct that represents the request body for creating an API key.
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyResponse is a struct that represents an API key in responses. Key is
+// the secret itself and is only returned once, when the key is created.
+type APIKeyResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+// LinkPreview is a struct that represents the preview page of a short link.
+type LinkPreview struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	Domain      string     `json:"domain"`
+	Title       string     `json:"title,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	// Flagged reports whether the domain of the original URL is flagged as unsafe.
+	Flagged bool `json:"flagged"`
+}
+
+// Stats is a struct that represents the statistics of the store.
+type Stats struct {
+	// URLs is the number of links that are neither deleted nor expired.
+	URLs int
+	// ExpiredURLs is the number of expired links that are not purged yet.
+	ExpiredURLs int
+	// Users is the number of users that own at least one active link.
+	Users int
+}
+
+// Click is a struct that represents a single redirect through a short URL.
+type Click struct {
+	ShortURL  string    `json:"short_url"`
+	Time      time.Time `json:"time"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	IP        string    `json:"ip,omitempty"`
+}
+
+// ClickBucket is a struct that represents the number of clicks in a time bucket.
+type ClickBucket struct {
+	Time   time.Time `json:"time"`
+	Clicks int64     `json:"clicks"`
+}
+
+// ClickCount is a struct that represents the number of clicks with the same value,
+// such as a referrer or a user agent.
+type ClickCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
+
+// LinkStats is a struct that represents the click analytics of a short URL.
+type LinkStats struct {
+	ShortURL       string        `json:"short_url"`
+	Clicks         int64         `json:"clicks"`
+	UniqueVisitors int64         `json:"unique_visitors"`
+	TimeSeries     []ClickBucket `json:"time_series"`
+	TopReferrers   []ClickCount  `json:"top_referrers"`
+	TopUserAgents  []ClickCount  `json:"top_user_agents"`
+}
+
+// ClickBucketSize is the width of the time buckets that clicks are aggregated into.
+const ClickBucketSize = time.Hour
+
+// ClickBucketStart returns the start of the time bucket that contains t, in UTC.
+func ClickBucketStart(t time.Time) time.Time {
+	return t.UTC().Truncate(ClickBucketSize)
+}
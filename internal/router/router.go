@@ -2,6 +2,7 @@
 package router
 
 import (
+	"errors"
 	"net/http"
 	"net/http/pprof"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/handler"
 	internalMiddleware "github.com/learies/goShortener/internal/middleware"
+	"github.com/learies/goShortener/internal/models"
 	"github.com/learies/goShortener/internal/services"
 	"github.com/learies/goShortener/internal/store"
 )
@@ -28,24 +30,125 @@ func NewRouter() *Router {
 	}
 }
 
+// Policies holds the password limiter, the URL checks and the keys of session
+// tokens. They are built once and shared with the gRPC server, so both
+// protocols use the same attempt budget, the same allow and deny lists and
+// accept the same session tokens.
+type Policies struct {
+	Passwords  *services.PasswordLimiter
+	Normalizer *services.URLNormalizer
+	URLPolicy  *services.URLPolicy
+	Keys       *internalMiddleware.Keyring
+}
+
+// NewPolicies builds the policies configured by cfg.
+func NewPolicies(cfg *config.Config) (Policies, error) {
+	keys, err := internalMiddleware.LoadKeyring(internalMiddleware.KeyringOptions{
+		Secret:           cfg.JWTSecret,
+		KeyFile:          cfg.JWTKeyFile,
+		PreviousSecrets:  cfg.JWTPreviousSecrets,
+		PreviousKeyFiles: cfg.JWTPreviousKeyFiles,
+	})
+	if err != nil {
+		return Policies{}, err
+	}
+
+	passwords, err := services.NewPasswordLimiter(cfg.PasswordMaxAttempts, cfg.PasswordAttemptWindow)
+	if err != nil {
+		return Policies{}, err
+	}
+
+	normalizer, err := services.NewURLNormalizer(cfg.URLNormalization, cfg.TrackingParams)
+	if err != nil {
+		return Policies{}, err
+	}
+
+	policy, err := services.NewURLPolicy(services.URLPolicyOptions{
+		BaseURL:        cfg.BaseURL,
+		AllowlistFile:  cfg.URLAllowlistFile,
+		DenylistFile:   cfg.URLDenylistFile,
+		ReloadInterval: cfg.URLListReloadInterval,
+		AllowPrivate:   cfg.AllowPrivateURLs,
+	})
+	if err != nil {
+		return Policies{}, err
+	}
+
+	return Policies{Passwords: passwords, Normalizer: normalizer, URLPolicy: policy, Keys: keys}, nil
+}
+
 // Routes configures the routes for the router.
-func (r *Router) Routes(cfg *config.Config, store store.Store, urlShortener services.Shortener) error {
+// Redirects are reported to clicks, which may be nil to disable click tracking.
+// Deleted user URLs are queued to deletions.
+func (r *Router) Routes(cfg *config.Config, store store.Store, urlShortener services.Shortener, clicks handler.ClickTracker, deletions handler.DeletionQueue, policies Policies) error {
+	keys := policies.Keys
+	if keys == nil {
+		return errors.New("session signing keys are required")
+	}
+	sessions, err := internalMiddleware.NewSessions(keys, internalMiddleware.SessionOptions{
+		TTL:            cfg.SessionTTL,
+		RenewBefore:    cfg.SessionRenewBefore,
+		CookieDomain:   cfg.CookieDomain,
+		CookieSecure:   cfg.EnableHTTPS || cfg.CookieSecure,
+		CookieSameSite: cfg.CookieSameSite,
+	})
+	if err != nil {
+		return err
+	}
+
 	routes := r.Mux
+	if cfg.TrustProxyHeaders {
+		routes.Use(middleware.RealIP)
+	}
 	routes.Use(middleware.Recoverer)
 	routes.Use(internalMiddleware.WithLogging)
 	routes.Use(internalMiddleware.GzipMiddleware)
-	routes.Use(internalMiddleware.JWTMiddleware)
+	apiKeys := services.NewAPIKeyService(store)
+	routes.Use(internalMiddleware.JWTMiddleware(sessions, apiKeys))
+
+	redirects, err := services.NewRedirectPolicy(cfg.RedirectCode, cfg.RedirectCacheMaxAge)
+	if err != nil {
+		return err
+	}
+
+	previews := services.PreviewPolicy{Force: cfg.ForcePreview, FlaggedDomains: cfg.FlaggedDomains}
+	passwords, normalizer, policy := policies.Passwords, policies.Normalizer, policies.URLPolicy
+
+	accounts := services.NewAccountService(store)
 
 	handler := handler.NewHandler()
 
-	routes.Post("/", handler.CreateShortLink(store, cfg.BaseURL, urlShortener))
-	routes.Get("/{shortURL}", handler.GetOriginalURL(store))
-	routes.Post("/api/shorten", handler.ShortenLink(store, cfg.BaseURL, urlShortener))
+	// Requests with an API key are limited to the scopes of the key;
+	// tokens, accounts and keys themselves are managed only with a session
+	linksRead := routes.With(internalMiddleware.RequireScope(models.ScopeLinksRead))
+	linksWrite := routes.With(internalMiddleware.RequireScope(models.ScopeLinksWrite))
+	linksDelete := routes.With(internalMiddleware.RequireScope(models.ScopeLinksDelete))
+	statsRead := routes.With(internalMiddleware.RequireScope(models.ScopeStatsRead))
+	session := routes.With(internalMiddleware.RequireSession)
+
+	linksWrite.Post("/", handler.CreateShortLink(store, cfg.BaseURL, urlShortener, normalizer, policy))
+	redirect := handler.GetOriginalURL(store, clicks, redirects, previews, passwords)
+	routes.Get("/{shortURL}", redirect)
+	routes.Post("/{shortURL}", redirect)
+	linksWrite.Post("/api/shorten", handler.ShortenLink(store, cfg.BaseURL, urlShortener, normalizer, policy))
 	routes.Get("/ping", handler.PingHandler(store))
-	routes.Post("/api/shorten/batch", handler.ShortenLinkBatch(store, cfg.BaseURL, urlShortener))
-	routes.Get("/api/user/urls", handler.GetUserURLs(store, cfg.BaseURL))
-	routes.Delete("/api/user/urls", handler.DeleteUserURLs(store))
-	routes.Get("/api/internal/stats", handler.GetStats(store, cfg.TrustedSubnet))
+	routes.Get("/.well-known/jwks.json", handler.GetJWKS(keys))
+	linksWrite.Post("/api/shorten/batch", handler.ShortenLinkBatch(store, cfg.BaseURL, urlShortener, normalizer, policy))
+	session.Post("/api/user/token", handler.IssueToken(sessions))
+	session.Post("/api/user/signup", handler.SignUp(accounts, sessions))
+	session.Post("/api/user/login", handler.Login(accounts, sessions))
+	session.Post("/api/user/logout", handler.Logout(sessions))
+	session.Post("/api/user/keys", handler.CreateAPIKey(apiKeys))
+	session.Get("/api/user/keys", handler.ListAPIKeys(apiKeys))
+	session.Delete("/api/user/keys/{keyID}", handler.RevokeAPIKey(apiKeys))
+	linksRead.Get("/api/user/urls", handler.GetUserURLs(store, cfg.BaseURL))
+	linksDelete.Delete("/api/user/urls", handler.DeleteUserURLs(deletions))
+	linksDelete.Post("/api/user/urls/restore", handler.RestoreUserURLs(store, cfg.DeleteGracePeriod))
+	linksWrite.Patch("/api/user/urls/{shortURL}", handler.UpdateUserURL(store, normalizer, policy))
+	linksRead.Get("/api/user/urls/{shortURL}/history", handler.GetURLHistory(store))
+	linksWrite.Post("/api/user/urls/{shortURL}/rollback", handler.RollbackUserURL(store, normalizer, policy))
+	statsRead.Get("/api/user/urls/{shortURL}/stats", handler.GetLinkStats(store))
+	routes.Get("/api/internal/stats", handler.GetStats(store, cfg.TrustedSubnet, deletions))
 	routes.MethodNotAllowed(methodNotAllowedHandler)
 
 	routes.Handle("/debug/pprof/", http.HandlerFunc(pprof.Index))
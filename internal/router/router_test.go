@@ -9,6 +9,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -20,7 +23,11 @@ import (
 	"github.com/learies/goShortener/internal/config"
 	"github.com/learies/goShortener/internal/config/contextutils"
 	"github.com/learies/goShortener/internal/config/logger"
+	internalMiddleware "github.com/learies/goShortener/internal/middleware"
 	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
 func init() {
@@ -34,21 +41,31 @@ var (
 
 // MockStore реализует интерфейс store.Store для тестирования
 type MockStore struct {
-	urls map[string]models.ShortenStore
+	urls     map[string]models.ShortenStore
+	clicks   map[string][]models.Click
+	history  map[string][]models.URLVersion
+	accounts map[uuid.UUID]models.Account
+	apiKeys  map[uuid.UUID]models.APIKey
+	sequence int64
 }
 
 func NewMockStore() *MockStore {
 	return &MockStore{
-		urls: make(map[string]models.ShortenStore),
+		urls:     make(map[string]models.ShortenStore),
+		clicks:   make(map[string][]models.Click),
+		history:  make(map[string][]models.URLVersion),
+		accounts: make(map[uuid.UUID]models.Account),
+		apiKeys:  make(map[uuid.UUID]models.APIKey),
 	}
 }
 
-func (m *MockStore) Add(_ context.Context, shortURL, originalURL string, userID uuid.UUID) error {
+func (m *MockStore) Add(_ context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
 	m.urls[shortURL] = models.ShortenStore{
 		UUID:        uuid.New(),
 		ShortURL:    shortURL,
 		OriginalURL: originalURL,
 		UserID:      userID,
+		LinkOptions: models.NewLinkOptions(opts...),
 	}
 	return nil
 }
@@ -67,6 +84,7 @@ func (m *MockStore) AddBatch(_ context.Context, urls []models.ShortenBatchStore,
 			ShortURL:    url.ShortURL,
 			OriginalURL: url.OriginalURL,
 			UserID:      userID,
+			LinkOptions: url.LinkOptions,
 		}
 	}
 	return nil
@@ -79,6 +97,8 @@ func (m *MockStore) GetUserURLs(_ context.Context, userID uuid.UUID) ([]models.U
 			result = append(result, models.UserURLResponse{
 				ShortURL:    record.ShortURL,
 				OriginalURL: record.OriginalURL,
+				DeletedAt:   record.DeletedAt,
+				ClicksLeft:  record.ClicksLeft(),
 			})
 		}
 	}
@@ -87,8 +107,10 @@ func (m *MockStore) GetUserURLs(_ context.Context, userID uuid.UUID) ([]models.U
 
 func (m *MockStore) DeleteUserURLs(_ context.Context, urls <-chan models.UserShortURL) error {
 	for url := range urls {
-		if record, ok := m.urls[url.ShortURL]; ok && record.UserID == url.UserID {
+		if record, ok := m.urls[url.ShortURL]; ok && record.UserID == url.UserID && !record.Deleted {
+			deletedAt := time.Now()
 			record.Deleted = true
+			record.DeletedAt = &deletedAt
 			m.urls[url.ShortURL] = record
 		}
 	}
@@ -99,24 +121,205 @@ func (m *MockStore) Ping() error {
 	return nil
 }
 
-func (m *MockStore) GetStats(_ context.Context) (int, int, error) {
-	// Count unique users
+func (m *MockStore) Close() error {
+	return nil
+}
+
+func (m *MockStore) NextID(_ context.Context) (int64, error) {
+	m.sequence++
+	return m.sequence, nil
+}
+
+func (m *MockStore) CreateAccount(_ context.Context, account models.Account) error {
+	for _, existing := range m.accounts {
+		if existing.ID == account.ID || existing.Email == account.Email {
+			return storeerr.ErrAccountExists
+		}
+	}
+	m.accounts[account.ID] = account
+	return nil
+}
+
+func (m *MockStore) GetAccount(_ context.Context, id uuid.UUID) (models.Account, error) {
+	if account, ok := m.accounts[id]; ok {
+		return account, nil
+	}
+	return models.Account{}, storeerr.ErrAccountNotFound
+}
+
+func (m *MockStore) GetAccountByEmail(_ context.Context, email string) (models.Account, error) {
+	for _, account := range m.accounts {
+		if account.Email == email {
+			return account, nil
+		}
+	}
+	return models.Account{}, storeerr.ErrAccountNotFound
+}
+
+func (m *MockStore) MergeUserURLs(_ context.Context, from, to uuid.UUID) ([]string, error) {
+	var merged []string
+	for shortURL, record := range m.urls {
+		if record.UserID == from && from != to {
+			record.UserID = to
+			m.urls[shortURL] = record
+			merged = append(merged, shortURL)
+		}
+	}
+	return merged, nil
+}
+
+func (m *MockStore) CreateAPIKey(_ context.Context, key models.APIKey) error {
+	m.apiKeys[key.ID] = key
+	return nil
+}
+
+func (m *MockStore) GetAPIKeyByHash(_ context.Context, hash string) (models.APIKey, error) {
+	for _, key := range m.apiKeys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+}
+
+func (m *MockStore) ListAPIKeys(_ context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (m *MockStore) RevokeAPIKey(_ context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	key, ok := m.apiKeys[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+	key.RevokedAt = &at
+	m.apiKeys[id] = key
+	return key, nil
+}
+
+func (m *MockStore) GetStats(_ context.Context) (models.Stats, error) {
+	now := time.Now()
+	var stats models.Stats
 	users := make(map[uuid.UUID]struct{})
 	for _, record := range m.urls {
-		if !record.Deleted {
+		switch {
+		case record.Deleted:
+		case record.Expired(now):
+			stats.ExpiredURLs++
+		default:
+			stats.URLs++
 			users[record.UserID] = struct{}{}
 		}
 	}
+	stats.Users = len(users)
 
-	// Count non-deleted URLs
-	urlsCount := 0
-	for _, record := range m.urls {
-		if !record.Deleted {
-			urlsCount++
+	return stats, nil
+}
+
+func (m *MockStore) PurgeExpired(_ context.Context, before time.Time) (int, error) {
+	purged := 0
+	for shortURL, record := range m.urls {
+		if record.ExpiresAt != nil && record.ExpiresAt.Before(before) {
+			delete(m.urls, shortURL)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MockStore) RestoreUserURLs(_ context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	var restored []string
+	for _, shortURL := range shortURLs {
+		record, ok := m.urls[shortURL]
+		if !ok || record.UserID != userID || !record.Deleted {
+			continue
 		}
+		if record.DeletedAt != nil && record.DeletedAt.Before(deletedAfter) {
+			continue
+		}
+		record.Deleted = false
+		record.DeletedAt = nil
+		m.urls[shortURL] = record
+		restored = append(restored, shortURL)
+	}
+	return restored, nil
+}
+
+func (m *MockStore) PurgeDeleted(_ context.Context, before time.Time) (int, error) {
+	purged := 0
+	for shortURL, record := range m.urls {
+		if record.Deleted && record.DeletedAt != nil && record.DeletedAt.Before(before) {
+			delete(m.urls, shortURL)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *MockStore) UpdateOriginalURL(_ context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	record, ok := m.urls[shortURL]
+	if !ok || record.UserID != userID || record.Deleted {
+		return models.URLVersion{}, ErrURLNotFound
+	}
+	version := models.URLVersion{
+		ShortURL:    shortURL,
+		Version:     len(m.history[shortURL]) + 1,
+		OriginalURL: originalURL,
+		PreviousURL: record.OriginalURL,
+		ChangedAt:   time.Now(),
+		ChangedBy:   userID,
+	}
+	record.OriginalURL = originalURL
+	m.urls[shortURL] = record
+	m.history[shortURL] = append(m.history[shortURL], version)
+	return version, nil
+}
+
+func (m *MockStore) GetURLHistory(_ context.Context, shortURL string) ([]models.URLVersion, error) {
+	if _, ok := m.urls[shortURL]; !ok {
+		return nil, ErrURLNotFound
+	}
+	return append([]models.URLVersion{}, m.history[shortURL]...), nil
+}
+
+func (m *MockStore) ConsumeClick(_ context.Context, shortURL string) (int, error) {
+	record, ok := m.urls[shortURL]
+	switch {
+	case !ok:
+		return -1, ErrURLNotFound
+	case record.MaxClicks <= 0:
+		return -1, nil
+	case record.Exhausted():
+		return -1, storeerr.ErrLinkExhausted
+	}
+	record.UsedClicks++
+	m.urls[shortURL] = record
+	return *record.ClicksLeft(), nil
+}
+
+func (m *MockStore) RecordClicks(_ context.Context, clicks []models.Click) error {
+	for _, click := range clicks {
+		m.clicks[click.ShortURL] = append(m.clicks[click.ShortURL], click)
 	}
+	return nil
+}
 
-	return urlsCount, len(users), nil
+func (m *MockStore) GetLinkStats(_ context.Context, shortURL string, top int) (models.LinkStats, error) {
+	if _, ok := m.urls[shortURL]; !ok {
+		return models.LinkStats{}, ErrURLNotFound
+	}
+	stats := models.LinkStats{ShortURL: shortURL}
+	for _, click := range m.clicks[shortURL] {
+		stats.Clicks++
+		stats.TimeSeries = append(stats.TimeSeries, models.ClickBucket{Time: models.ClickBucketStart(click.Time), Clicks: 1})
+	}
+	return stats, nil
 }
 
 // MockShortener реализует интерфейс services.Shortener для тестирования
@@ -129,15 +332,31 @@ func (m *MockShortener) GenerateShortURL(url string) (string, error) {
 	return "test" + url[:5], nil
 }
 
-// addAuthCookie добавляет JWT токен в куки запроса
+// testJWTSecret — секрет, которым подписываются токены в тестах роутера
+const testJWTSecret = "router-test-secret-0123456789"
+
+// testPolicies создает ограничитель попыток и проверки URL по конфигурации теста
+func testPolicies(t *testing.T, cfg *config.Config) Policies {
+	t.Helper()
+	policies, err := NewPolicies(cfg)
+	require.NoError(t, err)
+	return policies
+}
+
+// addAuthCookie добавляет JWT токен нового пользователя в куки запроса
 func addAuthCookie(req *http.Request) {
-	userID := uuid.New()
+	addUserAuthCookie(req, uuid.New())
+}
+
+// addUserAuthCookie добавляет JWT токен заданного пользователя в куки запроса
+func addUserAuthCookie(req *http.Request, userID uuid.UUID) {
 	claims := jwt.MapClaims{
 		"user_id": userID.String(),
 		"exp":     jwt.NewNumericDate(time.Now().Add(time.Hour * 24)),
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString([]byte("qwerty"))
+	key, _ := internalMiddleware.NewHMACKey([]byte(testJWTSecret))
+	keys, _ := internalMiddleware.NewKeyring(key)
+	tokenString, _ := keys.Sign(claims)
 
 	req.AddCookie(&http.Cookie{
 		Name:     "token",
@@ -155,9 +374,9 @@ func TestRouter_CreateShortLink(t *testing.T) {
 	router := NewRouter()
 	store := NewMockStore()
 	shortener := &MockShortener{}
-	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
 
-	err := router.Routes(cfg, store, shortener)
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -196,9 +415,9 @@ func TestRouter_ShortenLink(t *testing.T) {
 	router := NewRouter()
 	store := NewMockStore()
 	shortener := &MockShortener{}
-	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
 
-	err := router.Routes(cfg, store, shortener)
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
 	require.NoError(t, err)
 
 	tests := []struct {
@@ -248,9 +467,9 @@ func TestRouter_GetOriginalURL(t *testing.T) {
 	router := NewRouter()
 	store := NewMockStore()
 	shortener := &MockShortener{}
-	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
 
-	err := router.Routes(cfg, store, shortener)
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
 	require.NoError(t, err)
 
 	// Добавляем тестовый URL в хранилище
@@ -292,13 +511,799 @@ func TestRouter_GetOriginalURL(t *testing.T) {
 	}
 }
 
+func TestRouter_DroppedKeyCookie(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	testOriginalURL := "https://example.com"
+	err = store.Add(context.Background(), "testurl", testOriginalURL, uuid.New())
+	require.NoError(t, err)
+
+	// Куки подписана ключом, который убран из конфигурации
+	key, err := internalMiddleware.NewHMACKey([]byte("dropped-secret-0123456789"))
+	require.NoError(t, err)
+	droppedKeys, err := internalMiddleware.NewKeyring(key)
+	require.NoError(t, err)
+	oldUserID := uuid.New()
+	tokenString, err := droppedKeys.Sign(jwt.MapClaims{
+		"user_id": oldUserID.String(),
+		"exp":     jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/testurl", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: tokenString})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Посетитель доходит до редиректа и получает новую личность
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, testOriginalURL, w.Header().Get("Location"))
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "token" {
+			cookie = c
+		}
+	}
+	require.NotNil(t, cookie)
+	assert.NotEqual(t, tokenString, cookie.Value)
+}
+
+func TestRouter_RedirectCode(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080", RedirectCode: http.StatusFound, RedirectCacheMaxAge: time.Hour}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	body, err := json.Marshal(models.ShortenRequest{URL: "https://example.com", RedirectCode: http.StatusPermanentRedirect})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBuffer(body))
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response models.ShortenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+
+	req = httptest.NewRequest(http.MethodGet, strings.TrimPrefix(response.Result, cfg.BaseURL), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusPermanentRedirect, w.Code)
+	assert.Equal(t, "public, max-age=3600", w.Header().Get("Cache-Control"))
+
+	// Ссылки без своего кода используют код из конфигурации
+	require.NoError(t, store.Add(context.Background(), "default", "https://example.org", uuid.New()))
+	req = httptest.NewRequest(http.MethodGet, "/default", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.net","redirect_code":303}`))
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Недопустимый код по умолчанию не дает настроить маршруты
+	cfg.RedirectCode = http.StatusOK
+	assert.ErrorIs(t, NewRouter().Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg)), services.ErrInvalidRedirectCode)
+}
+
+func TestRouter_Preview(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080", FlaggedDomains: []string{"evil.com"}}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/?title=Docs&preview=1", bytes.NewBufferString("https://docs.evil.com/page"))
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	path := strings.TrimPrefix(w.Body.String(), cfg.BaseURL)
+
+	// Ссылка с preview всегда показывает страницу предпросмотра
+	for _, target := range []string{path, path + "+", path + "?preview=1"} {
+		req = httptest.NewRequest(http.MethodGet, target, nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, target)
+		assert.Contains(t, w.Body.String(), "Docs")
+		assert.Contains(t, w.Body.String(), "Warning:")
+	}
+
+	require.NoError(t, store.Add(context.Background(), "plain", "https://example.org", uuid.New()))
+	req = httptest.NewRequest(http.MethodGet, "/plain+", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "Warning:")
+
+	req = httptest.NewRequest(http.MethodGet, "/plain", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/?preview=maybe", bytes.NewBufferString("https://example.net"))
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_PasswordProtected(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080", PasswordMaxAttempts: 1, PasswordAttemptWindow: time.Minute}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://docs.example.com","password":"secret"}`))
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response models.ShortenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	path := strings.TrimPrefix(response.Result, cfg.BaseURL)
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `name="password"`)
+
+	// Форма отправляется на тот же адрес
+	req = httptest.NewRequest(http.MethodPost, path, strings.NewReader("password=secret"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "https://docs.example.com", w.Header().Get("Location"))
+
+	// Текстовый эндпоинт принимает пароль в заголовке
+	req = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://other.example.com"))
+	req.Header.Set("X-Link-Password", "other")
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	otherPath := strings.TrimPrefix(w.Body.String(), cfg.BaseURL)
+
+	req = httptest.NewRequest(http.MethodGet, otherPath, nil)
+	req.Header.Set("X-Link-Password", "wrong")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, otherPath, nil)
+	req.Header.Set("X-Link-Password", "other")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+}
+
+func TestRouter_NormalizedURL(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{
+		JWTSecret:        testJWTSecret,
+		BaseURL:          "http://localhost:8080",
+		URLNormalization: []string{"lowercase", "default-port", "clean-path", "sort-query", "strip-tracking"},
+		TrackingParams:   []string{"utm_*"},
+	}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("HTTP://Example.com:80/a/../b?z=1&utm_source=mail&a=2"))
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	path := strings.TrimPrefix(w.Body.String(), cfg.BaseURL)
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "http://example.com/b?a=2&z=1", w.Header().Get("Location"))
+
+	for _, body := range []string{"ftp://example.com", "https://exa mple.com", "https://"} {
+		req = httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(body))
+		addAuthCookie(req)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten/batch", bytes.NewBufferString(`[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"2","original_url":"garbage"}]`))
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_URLPolicy(t *testing.T) {
+	denylist := filepath.Join(t.TempDir(), "deny.txt")
+	require.NoError(t, os.WriteFile(denylist, []byte("*.phish.example\n"), 0644))
+
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://short.example", URLDenylistFile: denylist}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		path           string
+		body           string
+		expectedReason string
+		expectedID     string
+	}{
+		{name: "Denylisted", path: "/", body: "https://login.phish.example/", expectedReason: services.ReasonDenylisted},
+		{name: "Private", path: "/api/shorten", body: `{"url":"http://169.254.169.254/"}`, expectedReason: services.ReasonPrivateAddress},
+		{name: "Loop", path: "/api/shorten", body: `{"url":"http://short.example/abc"}`, expectedReason: services.ReasonRedirectLoop},
+		{
+			name:           "Batch",
+			path:           "/api/shorten/batch",
+			body:           `[{"correlation_id":"1","original_url":"https://example.com"},{"correlation_id":"2","original_url":"http://localhost"}]`,
+			expectedReason: services.ReasonPrivateAddress,
+			expectedID:     "2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			addAuthCookie(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusForbidden, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+			var response models.BlockedURLResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Equal(t, "url_blocked", response.Error)
+			assert.Equal(t, tt.expectedReason, response.Reason)
+			assert.Equal(t, tt.expectedID, response.CorrelationID)
+		})
+	}
+	assert.Empty(t, store.urls, "rejected URLs are not stored")
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString("https://example.com/"))
+	addAuthCookie(req)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestRouter_JWTKeyRotation(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{
+		JWTSecret:          "rotated-secret-0123456789",
+		JWTPreviousSecrets: []string{testJWTSecret},
+		BaseURL:            "http://localhost:8080",
+	}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	// Токен, подписанный предыдущим секретом, остается действительным
+	userID := uuid.New()
+	store.urls["kept"] = models.ShortenStore{ShortURL: "kept", OriginalURL: "https://example.com", UserID: userID}
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	addUserAuthCookie(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Новые токены подписываются текущим секретом
+	req = httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"keys":[]}`, w.Body.String())
+
+	var token string
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "token" {
+			token = cookie.Value
+		}
+	}
+	require.NotEmpty(t, token)
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+	require.NoError(t, err)
+	current, err := internalMiddleware.NewHMACKey([]byte(cfg.JWTSecret))
+	require.NoError(t, err)
+	assert.Equal(t, current.ID, parsed.Header["kid"])
+
+	_, err = NewPolicies(&config.Config{JWTSecret: "short"})
+	assert.Error(t, err)
+
+	router = NewRouter()
+	err = router.Routes(cfg, store, shortener, nil, nil, Policies{})
+	assert.Error(t, err, "без ключей сессий маршруты не настраиваются")
+}
+
+func TestRouter_SessionCookie(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{
+		JWTSecret:          testJWTSecret,
+		BaseURL:            "https://localhost:8080",
+		EnableHTTPS:        true,
+		SessionTTL:         24 * time.Hour,
+		SessionRenewBefore: time.Hour,
+		CookieDomain:       "localhost",
+		CookieSameSite:     "strict",
+	}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	// Новая сессия получает защищенную куку на весь срок жизни
+	req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, cookies[0].Secure)
+	assert.Equal(t, http.SameSiteStrictMode, cookies[0].SameSite)
+	assert.Equal(t, "localhost", cookies[0].Domain)
+	assert.Equal(t, int((24 * time.Hour).Seconds()), cookies[0].MaxAge)
+
+	// Действующая сессия не переиздается на каждый запрос
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Result().Cookies())
+
+	router = NewRouter()
+	err = router.Routes(&config.Config{JWTSecret: testJWTSecret, CookieSameSite: "none"}, store, shortener, nil, nil, Policies{})
+	assert.Error(t, err, "SameSite=None без Secure недопустим")
+}
+
+func TestRouter_BearerToken(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	// Клиент без куки получает токен для текущего пользователя
+	req := httptest.NewRequest(http.MethodPost, "/api/user/token", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var token models.TokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &token))
+	assert.Equal(t, "Bearer", token.TokenType)
+	assert.NotEmpty(t, token.Token)
+	assert.True(t, token.ExpiresAt.After(time.Now()))
+	assert.Equal(t, "Bearer "+token.Token, w.Header().Get("Authorization"))
+
+	// Ссылки, созданные с токеном в заголовке, принадлежат одному пользователю
+	req = httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://example.com/bearer"}`))
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://example.com/bearer")
+
+	// Токен для того же пользователя выдается повторно
+	req = httptest.NewRequest(http.MethodPost, "/api/user/token", nil)
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var renewed models.TokenResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &renewed))
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer "+renewed.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Contains(t, w.Body.String(), "https://example.com/bearer")
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.Header.Set("Authorization", "Bearer invalid-token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+func TestRouter_Accounts(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	// tokenCookie возвращает куку с токеном из ответа
+	tokenCookie := func(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+		t.Helper()
+		for _, cookie := range w.Result().Cookies() {
+			if cookie.Name == "token" {
+				return cookie
+			}
+		}
+		t.Fatal("token cookie not set")
+		return nil
+	}
+
+	// Регистрация сохраняет ссылки анонимного пользователя
+	registered := uuid.New()
+	store.urls["first"] = models.ShortenStore{ShortURL: "first", OriginalURL: "https://example.com/first", UserID: registered}
+	req := httptest.NewRequest(http.MethodPost, "/api/user/signup", bytes.NewBufferString(`{"email":"User@example.com","password":"secret-password"}`))
+	addUserAuthCookie(req, registered)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var account models.AccountResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &account))
+	assert.Equal(t, registered, account.ID)
+	assert.Equal(t, "user@example.com", account.Email)
+	assert.NotEmpty(t, account.Token)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/signup", bytes.NewBufferString(`{"email":"user@example.com","password":"secret-password"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/signup", bytes.NewBufferString(`{"email":"new@example.com","password":"short"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Выход очищает куку
+	req = httptest.NewRequest(http.MethodPost, "/api/user/logout", nil)
+	req.Header.Set("Authorization", "Bearer "+account.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, -1, tokenCookie(t, w).MaxAge)
+	assert.Empty(t, w.Header().Get("Authorization"))
+
+	// Новый анонимный пользователь создает ссылку и входит в учетную запись
+	anonymous := uuid.New()
+	store.urls["second"] = models.ShortenStore{ShortURL: "second", OriginalURL: "https://example.com/second", UserID: anonymous}
+	req = httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewBufferString(`{"email":"user@example.com","password":"wrong-password"}`))
+	addUserAuthCookie(req, anonymous)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/login", bytes.NewBufferString(`{"email":"user@example.com","password":"secret-password"}`))
+	addUserAuthCookie(req, anonymous)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var loggedIn models.AccountResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &loggedIn))
+	assert.Equal(t, registered, loggedIn.ID)
+	assert.Equal(t, 1, loggedIn.MergedURLs)
+
+	// Обе ссылки доступны с куки учетной записи
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	req.AddCookie(tokenCookie(t, w))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://example.com/first")
+	assert.Contains(t, w.Body.String(), "https://example.com/second")
+}
+
+func TestRouter_APIKeys(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	store.urls["first"] = models.ShortenStore{ShortURL: "first", OriginalURL: "https://example.com/first", UserID: userID}
+
+	// serve выполняет запрос с API-ключом или, если ключ пуст, с кукой пользователя
+	serve := func(method, path, body, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		} else {
+			addUserAuthCookie(req, userID)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(http.MethodPost, "/api/user/keys", `{"name":"ci","scopes":["links:admin"]}`, "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Ключ выдается с сессией и показывается только при создании
+	w = serve(http.MethodPost, "/api/user/keys", `{"name":"ci","scopes":["links:read"]}`, "")
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	var created models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.True(t, strings.HasPrefix(created.Key, models.APIKeyPrefix))
+	assert.Equal(t, []string{models.ScopeLinksRead}, created.Scopes)
+	assert.NotContains(t, w.Body.String(), "hash")
+
+	w = serve(http.MethodGet, "/api/user/keys", "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed []models.APIKeyResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed, 1)
+	assert.Equal(t, created.ID, listed[0].ID)
+	assert.Empty(t, listed[0].Key)
+	assert.NotContains(t, w.Body.String(), created.Key)
+
+	// Ключ действует от имени владельца в пределах своих областей доступа
+	w = serve(http.MethodGet, "/api/user/urls", "", created.Key)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "https://example.com/first")
+	assert.Empty(t, w.Result().Cookies())
+
+	w = serve(http.MethodPost, "/api/shorten", `{"url":"https://example.com/new"}`, created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, `Bearer error="insufficient_scope", scope="links:write"`, w.Header().Get("WWW-Authenticate"))
+	w = serve(http.MethodDelete, "/api/user/urls", `["first"]`, created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = serve(http.MethodGet, "/api/user/urls/first/stats", "", created.Key)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Ключом нельзя управлять ключами и выпускать токены
+	for _, path := range []string{"/api/user/keys", "/api/user/token"} {
+		w = serve(http.MethodPost, path, `{"name":"escalate","scopes":["links:write"]}`, created.Key)
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+
+	// Отозванный ключ больше не принимается
+	w = serve(http.MethodDelete, "/api/user/keys/"+created.ID.String(), "", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = serve(http.MethodDelete, "/api/user/keys/"+created.ID.String(), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = serve(http.MethodGet, "/api/user/urls", "", created.Key)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRouter_OneTimeLink(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(`{"url":"https://secret.example.com","max_clicks":1}`))
+	addUserAuthCookie(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var response models.ShortenResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	path := strings.TrimPrefix(response.Result, cfg.BaseURL)
+
+	clicksLeft := func() *int {
+		req := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+		addUserAuthCookie(req, userID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var urls []models.UserURLResponse
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&urls))
+		require.Len(t, urls, 1)
+		return urls[0].ClicksLeft
+	}
+	require.NotNil(t, clicksLeft())
+	assert.Equal(t, 1, *clicksLeft())
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, path, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Equal(t, 0, *clicksLeft())
+
+	req = httptest.NewRequest(http.MethodPost, "/?max_clicks=-1", bytes.NewBufferString("https://other.example.com"))
+	addAuthCookie(req)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestRouter_DeleteUserURLs(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+	deleter := worker.NewDeleter(store, worker.DeleterOptions{FlushInterval: time.Hour})
+
+	err := router.Routes(cfg, store, shortener, nil, deleter, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	require.NoError(t, store.Add(context.Background(), "short1", "https://example1.com", userID))
+	require.NoError(t, store.Add(context.Background(), "short2", "https://example2.com", uuid.New()))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString(`["short1","short2"]`))
+	addUserAuthCookie(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+
+	// Закрытие дожидается удаления URL, оставшихся в очереди
+	deleter.Close()
+	assert.Equal(t, int64(2), deleter.Stats().Deleted)
+
+	req = httptest.NewRequest(http.MethodGet, "/short1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGone, w.Code)
+
+	// Чужие ссылки не удаляются
+	req = httptest.NewRequest(http.MethodGet, "/short2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	// После остановки очереди запросы на удаление отклоняются
+	req = httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString(`["short2"]`))
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
+func TestRouter_RestoreUserURLs(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080", DeleteGracePeriod: time.Hour}
+	deleter := worker.NewDeleter(store, worker.DeleterOptions{})
+
+	err := router.Routes(cfg, store, shortener, nil, deleter, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	require.NoError(t, store.Add(context.Background(), "short1", "https://example1.com", userID))
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/user/urls", bytes.NewBufferString(`["short1"]`))
+	addUserAuthCookie(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusAccepted, w.Code)
+	deleter.Close()
+
+	// Удаленная ссылка видна только в корзине
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls?deleted=true", nil)
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var trash []models.UserURLResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &trash))
+	require.Len(t, trash, 1)
+	assert.Equal(t, "http://localhost:8080/short1", trash[0].ShortURL)
+	assert.NotNil(t, trash[0].DeletedAt)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", bytes.NewBufferString(`["short1"]`))
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["short1"]`, w.Body.String())
+
+	req = httptest.NewRequest(http.MethodGet, "/short1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+}
+
+func TestRouter_UpdateUserURL(t *testing.T) {
+	router := NewRouter()
+	store := NewMockStore()
+	shortener := &MockShortener{}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
+
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	require.NoError(t, store.Add(context.Background(), "short1", "https://example1.com", userID))
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/user/urls/short1", bytes.NewBufferString(`{"url":"https://example2.com"}`))
+	addUserAuthCookie(req, userID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// Переход ведет на новый адрес
+	req = httptest.NewRequest(http.MethodGet, "/short1", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	assert.Equal(t, "https://example2.com", w.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodPost, "/api/user/urls/short1/rollback", bytes.NewBufferString(`{"version":0}`))
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls/short1/history", nil)
+	addUserAuthCookie(req, userID)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var history []models.URLVersion
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	require.Len(t, history, 2)
+	assert.Equal(t, "https://example1.com", history[1].OriginalURL)
+	assert.Equal(t, userID, history[1].ChangedBy)
+
+	// Чужой пользователь не видит историю
+	req = httptest.NewRequest(http.MethodGet, "/api/user/urls/short1/history", nil)
+	addUserAuthCookie(req, uuid.New())
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func TestRouter_MethodNotAllowed(t *testing.T) {
 	router := NewRouter()
 	store := NewMockStore()
 	shortener := &MockShortener{}
-	cfg := &config.Config{BaseURL: "http://localhost:8080"}
+	cfg := &config.Config{JWTSecret: testJWTSecret, BaseURL: "http://localhost:8080"}
 
-	err := router.Routes(cfg, store, shortener)
+	err := router.Routes(cfg, store, shortener, nil, nil, testPolicies(t, cfg))
 	require.NoError(t, err)
 
 	req := httptest.NewRequest(http.MethodPut, "/", nil)
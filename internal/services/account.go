@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// MinAccountPasswordLength — минимальная длина пароля учетной записи в байтах
+const MinAccountPasswordLength = 8
+
+var (
+	// ErrInvalidEmail ошибка, возникающая при неверном адресе электронной почты
+	ErrInvalidEmail = errors.New("invalid email")
+	// ErrWeakPassword ошибка, возникающая при слишком коротком или длинном пароле учетной записи
+	ErrWeakPassword = errors.New("invalid account password")
+	// ErrEmailTaken ошибка, возникающая при регистрации с уже занятым адресом
+	ErrEmailTaken = errors.New("email is already registered")
+	// ErrInvalidCredentials ошибка, возникающая при входе с неверным адресом или паролем
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// dummyPasswordHash сравнивается с паролем при входе с неизвестным адресом,
+// чтобы время ответа не выдавало зарегистрированные адреса
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), passwordCost)
+
+// AccountService регистрирует учетные записи и выполняет вход в них.
+// ID учетной записи — это ID пользователя, которому принадлежат ее ссылки.
+type AccountService struct {
+	store store.Store
+}
+
+// NewAccountService создает сервис учетных записей поверх хранилища
+func NewAccountService(store store.Store) *AccountService {
+	return &AccountService{store: store}
+}
+
+// SignUp регистрирует учетную запись. Анонимный пользователь userID становится
+// владельцем учетной записи вместе со своими ссылками; если userID уже
+// принадлежит другой учетной записи, создается новый пользователь.
+func (s *AccountService) SignUp(ctx context.Context, email, password string, userID uuid.UUID) (models.Account, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return models.Account{}, err
+	}
+	if len(password) < MinAccountPasswordLength || len(password) > MaxPasswordLength {
+		return models.Account{}, fmt.Errorf("%w: expected %d to %d bytes, got %d", ErrWeakPassword, MinAccountPasswordLength, MaxPasswordLength, len(password))
+	}
+
+	anonymous, err := s.isAnonymous(ctx, userID)
+	if err != nil {
+		return models.Account{}, err
+	}
+	id := userID
+	if !anonymous {
+		id = uuid.New()
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return models.Account{}, err
+	}
+
+	account := models.Account{ID: id, Email: email, PasswordHash: hash, CreatedAt: time.Now().UTC()}
+	if err := s.store.CreateAccount(ctx, account); err != nil {
+		if errors.Is(err, storeerr.ErrAccountExists) {
+			return models.Account{}, ErrEmailTaken
+		}
+		return models.Account{}, err
+	}
+
+	logger.Log.Info("Account registered", "userID", account.ID)
+
+	return account, nil
+}
+
+// Login проверяет адрес и пароль и возвращает учетную запись. Ссылки
+// анонимного пользователя userID переходят в учетную запись, их короткие URL
+// возвращаются вторым значением.
+func (s *AccountService) Login(ctx context.Context, email, password string, userID uuid.UUID) (models.Account, []string, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return models.Account{}, nil, ErrInvalidCredentials
+	}
+
+	account, err := s.store.GetAccountByEmail(ctx, email)
+	if errors.Is(err, storeerr.ErrAccountNotFound) {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return models.Account{}, nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.Account{}, nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(account.PasswordHash), []byte(password)); err != nil {
+		return models.Account{}, nil, ErrInvalidCredentials
+	}
+
+	if userID == account.ID {
+		return account, nil, nil
+	}
+	anonymous, err := s.isAnonymous(ctx, userID)
+	if err != nil || !anonymous {
+		return account, nil, err
+	}
+
+	merged, err := s.store.MergeUserURLs(ctx, userID, account.ID)
+	if err != nil {
+		return models.Account{}, nil, fmt.Errorf("failed to merge anonymous links: %w", err)
+	}
+	if len(merged) > 0 {
+		logger.Log.Info("Merged anonymous links into account", "userID", account.ID, "count", len(merged))
+	}
+
+	return account, merged, nil
+}
+
+// isAnonymous сообщает, что пользователь не владеет учетной записью
+func (s *AccountService) isAnonymous(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := s.store.GetAccount(ctx, userID)
+	if errors.Is(err, storeerr.ErrAccountNotFound) {
+		return true, nil
+	}
+	return false, err
+}
+
+// normalizeEmail проверяет адрес электронной почты и приводит его к нижнему регистру
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+	}
+	return email, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/store/memstore"
+)
+
+func TestAccountService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Регистрация", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		accounts := NewAccountService(s)
+		anonymous := uuid.New()
+		require.NoError(t, s.Add(ctx, "short1", "https://example.com/1", anonymous))
+
+		account, err := accounts.SignUp(ctx, "  User@Example.COM ", "secret-password", anonymous)
+		require.NoError(t, err)
+		assert.Equal(t, "user@example.com", account.Email)
+		assert.Equal(t, anonymous, account.ID, "анонимный пользователь становится владельцем учетной записи")
+		assert.NotEqual(t, "secret-password", account.PasswordHash)
+
+		urls, err := s.GetUserURLs(ctx, account.ID)
+		require.NoError(t, err)
+		assert.Len(t, urls, 1)
+
+		// Пользователь с учетной записью регистрирует новую под новым ID
+		second, err := accounts.SignUp(ctx, "second@example.com", "secret-password", account.ID)
+		require.NoError(t, err)
+		assert.NotEqual(t, account.ID, second.ID)
+
+		_, err = accounts.SignUp(ctx, "USER@example.com", "other-password", uuid.New())
+		assert.ErrorIs(t, err, ErrEmailTaken)
+	})
+
+	t.Run("Недопустимые данные", func(t *testing.T) {
+		accounts := NewAccountService(memstore.NewMemStore())
+
+		for _, email := range []string{"", "user", "user@", "User <user@example.com>", "a@b@c"} {
+			_, err := accounts.SignUp(ctx, email, "secret-password", uuid.New())
+			assert.ErrorIs(t, err, ErrInvalidEmail, email)
+		}
+		for _, password := range []string{"", "short", string(make([]byte, MaxPasswordLength+1))} {
+			_, err := accounts.SignUp(ctx, "user@example.com", password, uuid.New())
+			assert.ErrorIs(t, err, ErrWeakPassword)
+		}
+	})
+
+	t.Run("Вход с переносом ссылок", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		accounts := NewAccountService(s)
+		account, err := accounts.SignUp(ctx, "user@example.com", "secret-password", uuid.New())
+		require.NoError(t, err)
+
+		anonymous := uuid.New()
+		require.NoError(t, s.Add(ctx, "short1", "https://example.com/1", anonymous))
+		require.NoError(t, s.Add(ctx, "short2", "https://example.com/2", anonymous))
+
+		_, _, err = accounts.Login(ctx, "user@example.com", "wrong-password", anonymous)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = accounts.Login(ctx, "nobody@example.com", "secret-password", anonymous)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+		_, _, err = accounts.Login(ctx, "not an email", "secret-password", anonymous)
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+
+		loggedIn, merged, err := accounts.Login(ctx, "User@example.com", "secret-password", anonymous)
+		require.NoError(t, err)
+		assert.Equal(t, account.ID, loggedIn.ID)
+		assert.ElementsMatch(t, []string{"short1", "short2"}, merged)
+
+		urls, err := s.GetUserURLs(ctx, account.ID)
+		require.NoError(t, err)
+		assert.Len(t, urls, 2)
+
+		// Ссылки другой учетной записи не переносятся
+		other, err := accounts.SignUp(ctx, "other@example.com", "secret-password", uuid.New())
+		require.NoError(t, err)
+		require.NoError(t, s.Add(ctx, "short3", "https://example.com/3", other.ID))
+		_, merged, err = accounts.Login(ctx, "user@example.com", "secret-password", other.ID)
+		require.NoError(t, err)
+		assert.Empty(t, merged)
+		record, err := s.Get(ctx, "short3")
+		require.NoError(t, err)
+		assert.Equal(t, other.ID, record.UserID)
+	})
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+const (
+	// MinAliasLength и MaxAliasLength ограничивают длину пользовательского алиаса.
+	// Максимум совпадает с размером колонки short_url в базе данных.
+	MinAliasLength = 3
+	MaxAliasLength = 64
+)
+
+// ErrInvalidAlias ошибка, возникающая при недопустимом алиасе
+var ErrInvalidAlias = errors.New("invalid alias")
+
+// ErrAliasTaken ошибка, возникающая, если алиас уже занят другой ссылкой
+var ErrAliasTaken = errors.New("alias is already taken")
+
+// aliasPattern допускает латинские буквы, цифры, дефис и подчеркивание.
+// Алиас начинается и заканчивается буквой или цифрой.
+var aliasPattern = regexp.MustCompile(`^[a-zA-Z0-9](?:[a-zA-Z0-9_-]*[a-zA-Z0-9])?$`)
+
+// reservedAliases совпадают с путями, которые обслуживает роутер,
+// поэтому алиас не может перекрыть существующий маршрут
+var reservedAliases = map[string]struct{}{
+	"api":     {},
+	"ping":    {},
+	"debug":   {},
+	"admin":   {},
+	"health":  {},
+	"metrics": {},
+	"static":  {},
+}
+
+// ValidateAlias проверяет набор символов, длину и зарезервированные слова
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return fmt.Errorf("%w: length must be between %d and %d", ErrInvalidAlias, MinAliasLength, MaxAliasLength)
+	}
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("%w: only letters, digits, '-' and '_' are allowed", ErrInvalidAlias)
+	}
+	if _, ok := reservedAliases[strings.ToLower(alias)]; ok {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAlias, alias)
+	}
+
+	return nil
+}
+
+// ShortenAlias сохраняет ссылку под выбранным пользователем алиасом.
+// Возвращает ErrInvalidAlias для недопустимого алиаса и ErrAliasTaken для занятого.
+// Если исходный URL уже сокращен, возвращается *storeerr.ErrConflict.
+func ShortenAlias(ctx context.Context, s store.Store, alias, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+	if err := ValidateAlias(alias); err != nil {
+		return err
+	}
+
+	err := s.Add(ctx, alias, originalURL, userID, opts...)
+	if errors.Is(err, storeerr.ErrURLExists) {
+		return ErrAliasTaken
+	}
+
+	return err
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestValidateAlias(t *testing.T) {
+	tests := []struct {
+		name    string
+		alias   string
+		wantErr bool
+	}{
+		{name: "Simple", alias: "spring-sale"},
+		{name: "Digits and underscore", alias: "sale_2024"},
+		{name: "Max length", alias: strings.Repeat("a", MaxAliasLength)},
+		{name: "Too short", alias: "ab", wantErr: true},
+		{name: "Too long", alias: strings.Repeat("a", MaxAliasLength+1), wantErr: true},
+		{name: "Slash", alias: "api/shorten", wantErr: true},
+		{name: "Space", alias: "spring sale", wantErr: true},
+		{name: "Non-latin", alias: "распродажа", wantErr: true},
+		{name: "Leading dash", alias: "-sale", wantErr: true},
+		{name: "Trailing underscore", alias: "sale_", wantErr: true},
+		{name: "Reserved", alias: "api", wantErr: true},
+		{name: "Reserved in other case", alias: "PING", wantErr: true},
+		{name: "Reserved debug", alias: "debug", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAlias(tt.alias)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidAlias)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestShortenAlias(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	s := memstore.NewMemStore()
+
+	require.NoError(t, ShortenAlias(ctx, s, "spring-sale", "https://example.com/sale", userID))
+
+	record, err := s.Get(ctx, "spring-sale")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/sale", record.OriginalURL)
+
+	// Занятый алиас
+	err = ShortenAlias(ctx, s, "spring-sale", "https://example.com/other", userID)
+	assert.ErrorIs(t, err, ErrAliasTaken)
+
+	// Уже сокращенный URL возвращает существующую ссылку
+	err = ShortenAlias(ctx, s, "summer-sale", "https://example.com/sale", userID)
+	var conflict *storeerr.ErrConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "spring-sale", conflict.ShortURL)
+
+	err = ShortenAlias(ctx, s, "ping", "https://example.com/ping", userID)
+	assert.ErrorIs(t, err, ErrInvalidAlias)
+}
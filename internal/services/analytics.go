@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// Шаг временного ряда аналитики переходов
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// DefaultTopClickValues — число источников и user agent в аналитике по умолчанию
+const DefaultTopClickValues = 10
+
+// ErrInvalidGranularity ошибка, возникающая при неизвестном шаге временного ряда
+var ErrInvalidGranularity = errors.New("invalid granularity")
+
+// LinkStatsOptions задает параметры аналитики переходов
+type LinkStatsOptions struct {
+	// Granularity — шаг временного ряда; пустое значение означает GranularityHour
+	Granularity string
+	// Top — число источников и user agent; 0 означает DefaultTopClickValues
+	Top int
+}
+
+// GetLinkStats возвращает аналитику переходов по ссылке пользователя.
+// Для чужой или несуществующей ссылки возвращается storeerr.ErrURLNotFound,
+// чтобы не раскрывать существование чужих ссылок.
+func GetLinkStats(ctx context.Context, s store.Store, shortURL string, userID uuid.UUID, opts LinkStatsOptions) (models.LinkStats, error) {
+	var step time.Duration
+	switch opts.Granularity {
+	case "", GranularityHour:
+		step = time.Hour
+	case GranularityDay:
+		step = 24 * time.Hour
+	default:
+		return models.LinkStats{}, fmt.Errorf("%w: %q, expected %s or %s", ErrInvalidGranularity, opts.Granularity, GranularityHour, GranularityDay)
+	}
+	if opts.Top <= 0 {
+		opts.Top = DefaultTopClickValues
+	}
+
+	record, err := s.Get(ctx, shortURL)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	if record.UserID != userID {
+		return models.LinkStats{}, storeerr.ErrURLNotFound
+	}
+
+	stats, err := s.GetLinkStats(ctx, shortURL, opts.Top)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	stats.TimeSeries = regroupBuckets(stats.TimeSeries, step)
+
+	return stats, nil
+}
+
+// regroupBuckets объединяет отсортированные по времени интервалы в интервалы длины step
+func regroupBuckets(series []models.ClickBucket, step time.Duration) []models.ClickBucket {
+	if step <= models.ClickBucketSize {
+		return series
+	}
+
+	grouped := make([]models.ClickBucket, 0, len(series))
+	for _, bucket := range series {
+		start := bucket.Time.UTC().Truncate(step)
+		if n := len(grouped); n > 0 && grouped[n-1].Time.Equal(start) {
+			grouped[n-1].Clicks += bucket.Clicks
+			continue
+		}
+		grouped = append(grouped, models.ClickBucket{Time: start, Clicks: bucket.Clicks})
+	}
+
+	return grouped
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestGetLinkStats(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	s := memstore.NewMemStore()
+
+	require.NoError(t, s.Add(ctx, "short1", "https://example.com", userID))
+
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, s.RecordClicks(ctx, []models.Click{
+		{ShortURL: "short1", Time: day.Add(time.Hour), Referrer: "https://a.example", IP: "10.0.0.1"},
+		{ShortURL: "short1", Time: day.Add(5 * time.Hour), Referrer: "https://b.example", IP: "10.0.0.2"},
+		{ShortURL: "short1", Time: day.Add(5 * time.Hour), Referrer: "https://b.example", IP: "10.0.0.2"},
+		{ShortURL: "short1", Time: day.Add(25 * time.Hour), Referrer: "https://c.example", IP: "10.0.0.1"},
+	}))
+
+	t.Run("По часам", func(t *testing.T) {
+		stats, err := GetLinkStats(ctx, s, "short1", userID, LinkStatsOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), stats.Clicks)
+		assert.Equal(t, int64(2), stats.UniqueVisitors)
+		assert.Equal(t, []models.ClickBucket{
+			{Time: day.Add(time.Hour), Clicks: 1},
+			{Time: day.Add(5 * time.Hour), Clicks: 2},
+			{Time: day.Add(25 * time.Hour), Clicks: 1},
+		}, stats.TimeSeries)
+		assert.Len(t, stats.TopReferrers, 3)
+	})
+
+	t.Run("По дням", func(t *testing.T) {
+		stats, err := GetLinkStats(ctx, s, "short1", userID, LinkStatsOptions{Granularity: GranularityDay, Top: 1})
+		require.NoError(t, err)
+		assert.Equal(t, []models.ClickBucket{
+			{Time: day, Clicks: 3},
+			{Time: day.Add(24 * time.Hour), Clicks: 1},
+		}, stats.TimeSeries)
+		assert.Equal(t, []models.ClickCount{{Value: "https://b.example", Clicks: 2}}, stats.TopReferrers)
+	})
+
+	t.Run("Неизвестный шаг", func(t *testing.T) {
+		_, err := GetLinkStats(ctx, s, "short1", userID, LinkStatsOptions{Granularity: "week"})
+		assert.ErrorIs(t, err, ErrInvalidGranularity)
+	})
+
+	t.Run("Чужая ссылка", func(t *testing.T) {
+		_, err := GetLinkStats(ctx, s, "short1", uuid.New(), LinkStatsOptions{})
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+
+	t.Run("Несуществующая ссылка", func(t *testing.T) {
+		_, err := GetLinkStats(ctx, s, "missing", userID, LinkStatsOptions{})
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+}
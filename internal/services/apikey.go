@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+const (
+	// MaxAPIKeyNameLength — максимальная длина имени API-ключа в символах
+	MaxAPIKeyNameLength = 100
+	// apiKeySecretBytes — число случайных байт API-ключа
+	apiKeySecretBytes = 32
+	// apiKeyPrefixLength — длина начала ключа, которое хранится открыто для списка ключей
+	apiKeyPrefixLength = len(models.APIKeyPrefix) + 8
+)
+
+// APIKeyScopes — области доступа, которые можно выдать API-ключу
+var APIKeyScopes = []string{
+	models.ScopeLinksRead,
+	models.ScopeLinksWrite,
+	models.ScopeLinksDelete,
+	models.ScopeStatsRead,
+}
+
+var (
+	// ErrInvalidScope ошибка, возникающая при неизвестной или отсутствующей области доступа ключа
+	ErrInvalidScope = errors.New("invalid API key scope")
+	// ErrInvalidAPIKeyName ошибка, возникающая при слишком длинном имени ключа
+	ErrInvalidAPIKeyName = errors.New("invalid API key name")
+)
+
+// APIKeyService выдает, перечисляет, отзывает и проверяет персональные
+// API-ключи. Хранилище содержит только SHA-256 хэши ключей: ключи состоят из
+// 256 случайных бит, поэтому медленный хэш, как у паролей, не нужен.
+type APIKeyService struct {
+	store store.Store
+}
+
+// NewAPIKeyService создает сервис API-ключей поверх хранилища
+func NewAPIKeyService(store store.Store) *APIKeyService {
+	return &APIKeyService{store: store}
+}
+
+// Create выдает пользователю ключ с областями доступа scopes и возвращает его
+// вместе с самим ключом, который больше нигде не сохраняется
+func (s *APIKeyService) Create(ctx context.Context, userID uuid.UUID, name string, scopes []string) (models.APIKey, string, error) {
+	name = strings.TrimSpace(name)
+	if utf8.RuneCountInString(name) > MaxAPIKeyNameLength {
+		return models.APIKey{}, "", fmt.Errorf("%w: at most %d characters", ErrInvalidAPIKeyName, MaxAPIKeyNameLength)
+	}
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, "", fmt.Errorf("failed to generate API key: %w", err)
+	}
+	plain := models.APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	key := models.APIKey{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    plain[:apiKeyPrefixLength],
+		Hash:      HashAPIKey(plain),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.store.CreateAPIKey(ctx, key); err != nil {
+		return models.APIKey{}, "", err
+	}
+
+	logger.Log.Info("API key created", "userID", userID, "keyID", key.ID, "scopes", scopes)
+
+	return key, plain, nil
+}
+
+// List возвращает ключи пользователя, включая отозванные
+func (s *APIKeyService) List(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	return s.store.ListAPIKeys(ctx, userID)
+}
+
+// Revoke отзывает ключ пользователя. Для чужого, неизвестного или уже
+// отозванного ключа возвращает storeerr.ErrAPIKeyNotFound
+func (s *APIKeyService) Revoke(ctx context.Context, userID, id uuid.UUID) (models.APIKey, error) {
+	key, err := s.store.RevokeAPIKey(ctx, userID, id, time.Now().UTC())
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	logger.Log.Info("API key revoked", "userID", userID, "keyID", id)
+
+	return key, nil
+}
+
+// Verify возвращает действующий ключ. Для неверного, неизвестного или
+// отозванного ключа возвращает storeerr.ErrAPIKeyNotFound
+func (s *APIKeyService) Verify(ctx context.Context, plain string) (models.APIKey, error) {
+	if !strings.HasPrefix(plain, models.APIKeyPrefix) {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+
+	key, err := s.store.GetAPIKeyByHash(ctx, HashAPIKey(plain))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	if key.RevokedAt != nil {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// HashAPIKey возвращает хэш ключа, под которым он хранится
+func HashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// normalizeScopes проверяет области доступа и упорядочивает их без повторов
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one of %s is required", ErrInvalidScope, strings.Join(APIKeyScopes, ", "))
+	}
+
+	result := make([]string, 0, len(APIKeyScopes))
+	for _, scope := range scopes {
+		if !slices.Contains(APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w %q: must be one of %s", ErrInvalidScope, scope, strings.Join(APIKeyScopes, ", "))
+		}
+	}
+	for _, scope := range APIKeyScopes {
+		if slices.Contains(scopes, scope) {
+			result = append(result, scope)
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestAPIKeyService(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Выдача и проверка ключа", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		keys := NewAPIKeyService(s)
+		userID := uuid.New()
+
+		key, plain, err := keys.Create(ctx, userID, " ci ", []string{models.ScopeStatsRead, models.ScopeLinksRead, models.ScopeLinksRead})
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(plain, models.APIKeyPrefix))
+		assert.True(t, strings.HasPrefix(plain, key.Prefix))
+		assert.Equal(t, "ci", key.Name)
+		assert.Equal(t, []string{models.ScopeLinksRead, models.ScopeStatsRead}, key.Scopes, "области доступа упорядочены без повторов")
+
+		// Хранилище содержит только хэш ключа
+		stored, err := s.ListAPIKeys(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, stored, 1)
+		assert.NotContains(t, stored[0].Hash, plain)
+		assert.Equal(t, HashAPIKey(plain), stored[0].Hash)
+
+		verified, err := keys.Verify(ctx, plain)
+		require.NoError(t, err)
+		assert.Equal(t, key.ID, verified.ID)
+		assert.Equal(t, userID, verified.UserID)
+
+		for _, wrong := range []string{"", plain + "x", strings.TrimPrefix(plain, models.APIKeyPrefix)} {
+			_, err := keys.Verify(ctx, wrong)
+			assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+		}
+	})
+
+	t.Run("Отзыв ключа", func(t *testing.T) {
+		keys := NewAPIKeyService(memstore.NewMemStore())
+		userID := uuid.New()
+		key, plain, err := keys.Create(ctx, userID, "ci", []string{models.ScopeLinksWrite})
+		require.NoError(t, err)
+
+		// Чужой ключ отозвать нельзя
+		_, err = keys.Revoke(ctx, uuid.New(), key.ID)
+		assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+
+		revoked, err := keys.Revoke(ctx, userID, key.ID)
+		require.NoError(t, err)
+		assert.NotNil(t, revoked.RevokedAt)
+
+		_, err = keys.Verify(ctx, plain)
+		assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+		list, err := keys.List(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, list, 1)
+		assert.NotNil(t, list[0].RevokedAt)
+	})
+
+	t.Run("Недопустимые параметры", func(t *testing.T) {
+		keys := NewAPIKeyService(memstore.NewMemStore())
+
+		for _, scopes := range [][]string{nil, {}, {"links:admin"}, {models.ScopeLinksRead, "LINKS:WRITE"}} {
+			_, _, err := keys.Create(ctx, uuid.New(), "ci", scopes)
+			assert.ErrorIs(t, err, ErrInvalidScope, scopes)
+		}
+		_, _, err := keys.Create(ctx, uuid.New(), strings.Repeat("я", MaxAPIKeyNameLength+1), []string{models.ScopeLinksRead})
+		assert.ErrorIs(t, err, ErrInvalidAPIKeyName)
+	})
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// ErrInvalidMaxClicks ошибка, возникающая при отрицательном ограничении числа переходов
+var ErrInvalidMaxClicks = errors.New("invalid max clicks")
+
+// ValidateMaxClicks проверяет ограничение числа переходов; 0 означает отсутствие ограничения
+func ValidateMaxClicks(maxClicks int) error {
+	if maxClicks < 0 {
+		return fmt.Errorf("%w: %d, expected a positive number or 0 for no limit", ErrInvalidMaxClicks, maxClicks)
+	}
+
+	return nil
+}
+
+// MaxClicksOptions проверяет ограничение числа переходов и возвращает
+// параметры ссылки для хранилища. Ограничение 1 создает одноразовую ссылку.
+func MaxClicksOptions(maxClicks int) ([]models.LinkOption, error) {
+	if err := ValidateMaxClicks(maxClicks); err != nil {
+		return nil, err
+	}
+	if maxClicks == 0 {
+		return nil, nil
+	}
+
+	return []models.LinkOption{models.WithMaxClicks(maxClicks)}, nil
+}
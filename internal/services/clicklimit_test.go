@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestClickLimit(t *testing.T) {
+	t.Run("Параметры ссылки", func(t *testing.T) {
+		opts, err := MaxClicksOptions(0)
+		require.NoError(t, err)
+		assert.Empty(t, opts)
+
+		opts, err = MaxClicksOptions(1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, models.NewLinkOptions(opts...).MaxClicks)
+
+		_, err = MaxClicksOptions(-1)
+		assert.ErrorIs(t, err, ErrInvalidMaxClicks)
+	})
+
+	t.Run("Одноразовая ссылка через сервис", func(t *testing.T) {
+		ctx := context.Background()
+		s := memstore.NewMemStore()
+		service := NewURLShortenerService(s, "http://localhost:8080", NewURLShortener(), nil, nil, nil, nil)
+
+		result, err := service.CreateShortURL(ctx, "https://example.com", "once", uuid.New(), models.WithMaxClicks(1))
+		require.NoError(t, err)
+		assert.Equal(t, "http://localhost:8080/once", result)
+
+		originalURL, err := service.GetOriginalURL(ctx, "once", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", originalURL)
+
+		_, err = service.GetOriginalURL(ctx, "once", "", "")
+		assert.ErrorIs(t, err, storeerr.ErrLinkExhausted)
+	})
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// ErrInvalidExpiry ошибка, возникающая при недопустимом сроке действия ссылки
+var ErrInvalidExpiry = errors.New("invalid expiry")
+
+// ParseExpiry вычисляет момент истечения ссылки.
+// expiresIn задает срок действия длительностью ("90m", "24h") или числом секунд,
+// expiresAt — момент истечения в формате RFC 3339. Можно указать только одно из них.
+// Если оба пусты, возвращается nil: ссылка бессрочная.
+func ParseExpiry(expiresIn, expiresAt string, now time.Time) (*time.Time, error) {
+	switch {
+	case expiresIn != "" && expiresAt != "":
+		return nil, fmt.Errorf("%w: expires_in and expires_at are mutually exclusive", ErrInvalidExpiry)
+	case expiresIn != "":
+		ttl, err := parseTTL(expiresIn)
+		if err != nil {
+			return nil, err
+		}
+		t := now.Add(ttl)
+		return &t, nil
+	case expiresAt != "":
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return nil, fmt.Errorf("%w: expires_at must be an RFC 3339 time", ErrInvalidExpiry)
+		}
+		if !t.After(now) {
+			return nil, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidExpiry)
+		}
+		return &t, nil
+	}
+
+	return nil, nil
+}
+
+// ExpiryOptions разбирает срок действия и возвращает параметры ссылки для хранилища
+func ExpiryOptions(expiresIn, expiresAt string) ([]models.LinkOption, error) {
+	t, err := ParseExpiry(expiresIn, expiresAt, time.Now())
+	if err != nil || t == nil {
+		return nil, err
+	}
+
+	return []models.LinkOption{models.WithExpiresAt(*t)}, nil
+}
+
+// parseTTL разбирает длительность или число секунд
+func parseTTL(value string) (time.Duration, error) {
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		seconds, convErr := strconv.ParseInt(value, 10, 64)
+		if convErr != nil {
+			return 0, fmt.Errorf("%w: expires_in must be a duration or a number of seconds", ErrInvalidExpiry)
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("%w: expires_in must be positive", ErrInvalidExpiry)
+	}
+
+	return ttl, nil
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		expiresIn string
+		expiresAt string
+		expected  *time.Time
+		wantErr   bool
+	}{
+		{
+			name: "Без срока действия",
+		},
+		{
+			name:      "Длительность",
+			expiresIn: "90m",
+			expected:  timeRef(now.Add(90 * time.Minute)),
+		},
+		{
+			name:      "Число секунд",
+			expiresIn: "3600",
+			expected:  timeRef(now.Add(time.Hour)),
+		},
+		{
+			name:      "Момент истечения",
+			expiresAt: "2024-01-02T12:00:00+03:00",
+			expected:  timeRef(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)),
+		},
+		{
+			name:      "Отрицательная длительность",
+			expiresIn: "-5m",
+			wantErr:   true,
+		},
+		{
+			name:      "Нулевое число секунд",
+			expiresIn: "0",
+			wantErr:   true,
+		},
+		{
+			name:      "Неверная длительность",
+			expiresIn: "tomorrow",
+			wantErr:   true,
+		},
+		{
+			name:      "Момент в прошлом",
+			expiresAt: "2023-12-31T00:00:00Z",
+			wantErr:   true,
+		},
+		{
+			name:      "Неверный формат времени",
+			expiresAt: "2024-01-02",
+			wantErr:   true,
+		},
+		{
+			name:      "Оба параметра",
+			expiresIn: "1h",
+			expiresAt: "2024-01-02T12:00:00Z",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expiresAt, err := ParseExpiry(tt.expiresIn, tt.expiresAt, now)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidExpiry)
+				return
+			}
+			require.NoError(t, err)
+			if tt.expected == nil {
+				assert.Nil(t, expiresAt)
+				return
+			}
+			require.NotNil(t, expiresAt)
+			assert.True(t, tt.expected.Equal(*expiresAt), "expected %v, got %v", tt.expected, expiresAt)
+		})
+	}
+}
+
+func timeRef(t time.Time) *time.Time {
+	return &t
+}
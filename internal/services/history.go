@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// ErrURLUnchanged ошибка, возникающая, если новый URL совпадает с текущим
+var ErrURLUnchanged = errors.New("URL is unchanged")
+
+// ErrInvalidVersion ошибка, возникающая при откате к несуществующей версии
+var ErrInvalidVersion = errors.New("invalid version")
+
+// UpdateOriginalURL меняет оригинальный URL ссылки пользователя и возвращает
+// созданную версию. Для чужой, удаленной или несуществующей ссылки возвращается
+// storeerr.ErrURLNotFound, а если новый URL уже сокращен — *storeerr.ErrConflict.
+func UpdateOriginalURL(ctx context.Context, s store.Store, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	if err := validateURL(originalURL); err != nil {
+		return models.URLVersion{}, err
+	}
+
+	record, err := s.Get(ctx, shortURL)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+	if record.UserID != userID || record.Deleted {
+		return models.URLVersion{}, storeerr.ErrURLNotFound
+	}
+	if record.OriginalURL == originalURL {
+		return models.URLVersion{}, ErrURLUnchanged
+	}
+
+	return s.UpdateOriginalURL(ctx, shortURL, userID, originalURL)
+}
+
+// GetURLHistory возвращает историю изменений ссылки пользователя.
+// Для чужой или несуществующей ссылки возвращается storeerr.ErrURLNotFound.
+func GetURLHistory(ctx context.Context, s store.Store, shortURL string, userID uuid.UUID) ([]models.URLVersion, error) {
+	record, err := s.Get(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	if record.UserID != userID {
+		return nil, storeerr.ErrURLNotFound
+	}
+
+	return s.GetURLHistory(ctx, shortURL)
+}
+
+// RollbackOriginalURL возвращает ссылке оригинальный URL указанной версии,
+// записывая откат как новую версию. Версия 0 — URL, с которым ссылка была создана.
+// URL версии приводится к каноническому виду и проверяется политикой так же,
+// как новый URL при изменении ссылки.
+func RollbackOriginalURL(ctx context.Context, s store.Store, shortURL string, userID uuid.UUID, version int, normalizer *URLNormalizer, policy *URLPolicy) (models.URLVersion, error) {
+	history, err := GetURLHistory(ctx, s, shortURL, userID)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+
+	var originalURL string
+	switch {
+	case version == 0 && len(history) > 0:
+		originalURL = history[0].PreviousURL
+	case version > 0 && version <= len(history):
+		originalURL = history[version-1].OriginalURL
+	default:
+		return models.URLVersion{}, fmt.Errorf("%w: %d, the link has %d versions", ErrInvalidVersion, version, len(history))
+	}
+
+	originalURL, err = normalizer.Normalize(originalURL)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+	if err := policy.Check(originalURL); err != nil {
+		return models.URLVersion{}, err
+	}
+
+	return UpdateOriginalURL(ctx, s, shortURL, userID, originalURL)
+}
+
+// UpdateShortURL changes the original URL of a link owned by the user.
+// The short URL may be given either as the bare key or as the full short link.
+// The new original URL is stored in its canonical form and must be allowed
+// by the policy.
+func (s *URLShortenerService) UpdateShortURL(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) (models.URLVersion, error) {
+	originalURL, err := s.normalizer.Normalize(originalURL)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+	if err := s.policy.Check(originalURL); err != nil {
+		return models.URLVersion{}, err
+	}
+
+	shortURL = strings.TrimPrefix(shortURL, s.baseURL+"/")
+	return UpdateOriginalURL(ctx, s.store, shortURL, userID, originalURL)
+}
+
+// RollbackShortURL points a link owned by the user back to the original URL
+// of the given version. The short URL may be given either as the bare key or
+// as the full short link. The restored URL must still be allowed by the policy.
+func (s *URLShortenerService) RollbackShortURL(ctx context.Context, shortURL string, userID uuid.UUID, version int) (models.URLVersion, error) {
+	shortURL = strings.TrimPrefix(shortURL, s.baseURL+"/")
+	return RollbackOriginalURL(ctx, s.store, shortURL, userID, version, s.normalizer, s.policy)
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestURLHistory(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+	s := memstore.NewMemStore()
+
+	require.NoError(t, s.Add(ctx, "short1", "https://example1.com", userID))
+
+	t.Run("Изменение и история", func(t *testing.T) {
+		version, err := UpdateOriginalURL(ctx, s, "short1", userID, "https://example2.com")
+		require.NoError(t, err)
+		assert.Equal(t, 1, version.Version)
+		assert.Equal(t, "https://example1.com", version.PreviousURL)
+
+		_, err = UpdateOriginalURL(ctx, s, "short1", userID, "https://example3.com")
+		require.NoError(t, err)
+
+		history, err := GetURLHistory(ctx, s, "short1", userID)
+		require.NoError(t, err)
+		assert.Len(t, history, 2)
+	})
+
+	t.Run("Тот же URL", func(t *testing.T) {
+		_, err := UpdateOriginalURL(ctx, s, "short1", userID, "https://example3.com")
+		assert.ErrorIs(t, err, ErrURLUnchanged)
+	})
+
+	t.Run("Пустой URL", func(t *testing.T) {
+		_, err := UpdateOriginalURL(ctx, s, "short1", userID, "")
+		assert.ErrorIs(t, err, ErrEmptyURL)
+	})
+
+	t.Run("Чужая ссылка", func(t *testing.T) {
+		_, err := UpdateOriginalURL(ctx, s, "short1", uuid.New(), "https://other.com")
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+		_, err = GetURLHistory(ctx, s, "short1", uuid.New())
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+
+	t.Run("Откат к версии", func(t *testing.T) {
+		version, err := RollbackOriginalURL(ctx, s, "short1", userID, 1, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, 3, version.Version)
+		assert.Equal(t, "https://example2.com", version.OriginalURL)
+
+		version, err = RollbackOriginalURL(ctx, s, "short1", userID, 0, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example1.com", version.OriginalURL)
+
+		record, err := s.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example1.com", record.OriginalURL)
+	})
+
+	t.Run("Несуществующая версия", func(t *testing.T) {
+		for _, version := range []int{-1, 5} {
+			_, err := RollbackOriginalURL(ctx, s, "short1", userID, version, nil, nil)
+			assert.ErrorIs(t, err, ErrInvalidVersion)
+		}
+
+		require.NoError(t, s.Add(ctx, "short2", "https://fresh.com", userID))
+		_, err := RollbackOriginalURL(ctx, s, "short2", userID, 0, nil, nil)
+		assert.ErrorIs(t, err, ErrInvalidVersion)
+	})
+
+	t.Run("Откат к запрещенному хосту", func(t *testing.T) {
+		require.NoError(t, s.Add(ctx, "short3", "https://evil.com/login", userID))
+		_, err := UpdateOriginalURL(ctx, s, "short3", userID, "https://safe.com")
+		require.NoError(t, err)
+
+		// Хост первой версии попал в список запрещенных уже после изменения ссылки
+		denylist := filepath.Join(t.TempDir(), "deny.txt")
+		require.NoError(t, os.WriteFile(denylist, []byte("evil.com\n"), 0644))
+		policy, err := NewURLPolicy(URLPolicyOptions{DenylistFile: denylist})
+		require.NoError(t, err)
+		service := NewURLShortenerService(s, "http://localhost:8080", NewURLShortener(), nil, nil, nil, policy)
+
+		_, err = service.RollbackShortURL(ctx, "http://localhost:8080/short3", userID, 0)
+		var violation *PolicyViolation
+		require.ErrorAs(t, err, &violation)
+		assert.Equal(t, ReasonDenylisted, violation.Reason)
+
+		record, err := s.Get(ctx, "short3")
+		require.NoError(t, err)
+		assert.Equal(t, "https://safe.com", record.OriginalURL)
+	})
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// Правила нормализации URL
+const (
+	// NormalizeLowercase приводит схему и хост к нижнему регистру
+	NormalizeLowercase = "lowercase"
+	// NormalizeDefaultPort удаляет порт по умолчанию: 80 для http и 443 для https
+	NormalizeDefaultPort = "default-port"
+	// NormalizeCleanPath убирает из пути "." и ".." и повторные "/"
+	NormalizeCleanPath = "clean-path"
+	// NormalizeIDN переводит интернационализированный домен в punycode
+	NormalizeIDN = "idn"
+	// NormalizeSortQuery сортирует параметры запроса по имени
+	NormalizeSortQuery = "sort-query"
+	// NormalizeStripTracking удаляет из запроса параметры отслеживания
+	NormalizeStripTracking = "strip-tracking"
+	// NormalizeNone выключает все правила, URL только проверяется
+	NormalizeNone = "none"
+)
+
+// DefaultNormalizeRules — правила нормализации по умолчанию
+var DefaultNormalizeRules = []string{
+	NormalizeLowercase,
+	NormalizeDefaultPort,
+	NormalizeCleanPath,
+	NormalizeIDN,
+	NormalizeSortQuery,
+	NormalizeStripTracking,
+}
+
+// DefaultTrackingParams — параметры отслеживания, удаляемые по умолчанию.
+// Звездочка в конце имени совпадает с любым продолжением.
+var DefaultTrackingParams = []string{"utm_*", "fbclid", "gclid", "yclid", "msclkid", "mc_cid", "mc_eid", "igshid"}
+
+// ErrInvalidURL ошибка, возникающая при недопустимом оригинальном URL
+var ErrInvalidURL = errors.New("invalid URL")
+
+// defaultPorts — порты по умолчанию для допустимых схем
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// hostProfile проверяет доменные имена и переводит их в punycode
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// URLNormalizer проверяет оригинальные URL и приводит их к каноническому виду,
+// по которому одинаковые адреса распознаются как один URL.
+// Нулевой указатель только проверяет URL.
+type URLNormalizer struct {
+	rules          map[string]bool
+	trackingParams []string
+}
+
+// NewURLNormalizer создает нормализатор с правилами rules, удаляющий параметры
+// trackingParams. Правило NormalizeNone выключает нормализацию.
+func NewURLNormalizer(rules, trackingParams []string) (*URLNormalizer, error) {
+	n := &URLNormalizer{rules: make(map[string]bool)}
+	for _, rule := range rules {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch rule {
+		case NormalizeNone:
+			clear(n.rules)
+			return n, nil
+		case NormalizeLowercase, NormalizeDefaultPort, NormalizeCleanPath,
+			NormalizeIDN, NormalizeSortQuery, NormalizeStripTracking:
+			n.rules[rule] = true
+		default:
+			return nil, fmt.Errorf("unknown URL normalization rule %q", rule)
+		}
+	}
+
+	for _, param := range trackingParams {
+		if param = strings.ToLower(strings.TrimSpace(param)); param != "" {
+			n.trackingParams = append(n.trackingParams, param)
+		}
+	}
+
+	return n, nil
+}
+
+// Normalize проверяет, что URL абсолютный, со схемой http или https и
+// допустимым хостом, и возвращает его канонический вид.
+// Пустой URL возвращает ErrEmptyURL, недопустимый — ErrInvalidURL.
+func (n *URLNormalizer) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", ErrEmptyURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	if _, ok := defaultPorts[strings.ToLower(u.Scheme)]; !ok || u.Opaque != "" {
+		return "", fmt.Errorf("%w: expected an absolute http or https URL", ErrInvalidURL)
+	}
+
+	host, err := checkHost(u.Hostname())
+	if err != nil {
+		return "", err
+	}
+	port := u.Port()
+	if port != "" {
+		if number, err := strconv.Atoi(port); err != nil || number < 1 || number > 65535 {
+			return "", fmt.Errorf("%w: invalid port %q", ErrInvalidURL, port)
+		}
+	}
+
+	if n.enabled(NormalizeLowercase) {
+		u.Scheme = strings.ToLower(u.Scheme)
+		host = strings.ToLower(host)
+	}
+	if n.enabled(NormalizeIDN) && !isASCII(host) {
+		host, _ = hostProfile.ToASCII(host)
+	}
+	if n.enabled(NormalizeDefaultPort) && port == defaultPorts[strings.ToLower(u.Scheme)] {
+		port = ""
+	}
+	u.Host = joinHost(host, port)
+
+	if n.enabled(NormalizeCleanPath) {
+		cleanPath(u)
+	}
+	if n.enabled(NormalizeStripTracking) || n.enabled(NormalizeSortQuery) {
+		u.RawQuery = n.normalizeQuery(u.RawQuery)
+		u.ForceQuery = false
+	}
+
+	return u.String(), nil
+}
+
+// NormalizeBatch возвращает копию пакета с нормализованными оригинальными URL
+func (n *URLNormalizer) NormalizeBatch(batchRequest []models.ShortenBatchRequest) ([]models.ShortenBatchRequest, error) {
+	normalized := make([]models.ShortenBatchRequest, len(batchRequest))
+	for i, request := range batchRequest {
+		originalURL, err := n.Normalize(request.OriginalURL)
+		if err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+		}
+		request.OriginalURL = originalURL
+		normalized[i] = request
+	}
+
+	return normalized, nil
+}
+
+// IsInvalidURL сообщает, вызвана ли ошибка пустым или недопустимым URL
+func IsInvalidURL(err error) bool {
+	return errors.Is(err, ErrEmptyURL) || errors.Is(err, ErrInvalidURL)
+}
+
+// enabled сообщает, включено ли правило
+func (n *URLNormalizer) enabled(rule string) bool {
+	return n != nil && n.rules[rule]
+}
+
+// checkHost проверяет, что хост является IP-адресом или допустимым доменным именем
+func checkHost(host string) (string, error) {
+	if host == "" {
+		return "", fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+	if net.ParseIP(host) != nil {
+		return host, nil
+	}
+	if _, err := hostProfile.ToASCII(host); err != nil {
+		return "", fmt.Errorf("%w: invalid host %q", ErrInvalidURL, host)
+	}
+
+	return host, nil
+}
+
+// joinHost собирает хост и порт, заключая IPv6-адрес в квадратные скобки
+func joinHost(host, port string) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// cleanPath убирает из пути "." и ".." и повторные "/", сохраняя завершающий "/".
+// Пустой путь заменяется на "/".
+func cleanPath(u *url.URL) {
+	escaped := u.EscapedPath()
+	if escaped == "" {
+		u.Path, u.RawPath = "/", ""
+		return
+	}
+
+	cleaned := path.Clean(escaped)
+	if strings.HasSuffix(escaped, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+
+	unescaped, err := url.PathUnescape(cleaned)
+	if err != nil {
+		return
+	}
+	u.Path, u.RawPath = unescaped, cleaned
+}
+
+// normalizeQuery удаляет параметры отслеживания и сортирует параметры по имени,
+// не меняя их кодирование. Порядок значений одного параметра сохраняется.
+func (n *URLNormalizer) normalizeQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct {
+		name string
+		raw  string
+	}
+	var params []param
+	for _, raw := range strings.Split(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.enabled(NormalizeStripTracking) && n.tracking(name) {
+			continue
+		}
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	if n.enabled(NormalizeSortQuery) {
+		sort.SliceStable(params, func(i, j int) bool {
+			return params[i].name < params[j].name
+		})
+	}
+
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+	return strings.Join(raws, "&")
+}
+
+// tracking сообщает, является ли параметр параметром отслеживания
+func (n *URLNormalizer) tracking(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range n.trackingParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isASCII сообщает, состоит ли строка только из символов ASCII
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
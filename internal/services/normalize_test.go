@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestURLNormalizer(t *testing.T) {
+	normalizer, err := NewURLNormalizer(DefaultNormalizeRules, DefaultTrackingParams)
+	require.NoError(t, err)
+
+	t.Run("Канонический вид", func(t *testing.T) {
+		tests := map[string]string{
+			"HTTP://Example.com:80/a/../b":                     "http://example.com/b",
+			"http://example.com/b":                             "http://example.com/b",
+			"https://Example.com:443":                          "https://example.com/",
+			"https://example.com:8443/a//b/./c/":               "https://example.com:8443/a/b/c/",
+			"https://пример.рф/путь":                           "https://xn--e1afmkfd.xn--p1ai/%D0%BF%D1%83%D1%82%D1%8C",
+			"https://example.com/?b=2&a=1&a=0":                 "https://example.com/?a=1&a=0&b=2",
+			"https://example.com/?utm_source=x&id=1&fbclid=y":  "https://example.com/?id=1",
+			"https://example.com/?UTM_Medium=x":                "https://example.com/",
+			"https://example.com/a%2Fb?q=a%20b#frag":           "https://example.com/a%2Fb?q=a%20b#frag",
+			"  https://example.com/x  ":                        "https://example.com/x",
+			"http://[2001:DB8::1]:80/":                         "http://[2001:db8::1]/",
+			"https://user@example.com/?gclid=1&utm_campaign=2": "https://user@example.com/",
+		}
+		for rawURL, expected := range tests {
+			normalized, err := normalizer.Normalize(rawURL)
+			require.NoError(t, err, rawURL)
+			assert.Equal(t, expected, normalized, rawURL)
+		}
+	})
+
+	t.Run("Недопустимые URL", func(t *testing.T) {
+		_, err := normalizer.Normalize("")
+		assert.ErrorIs(t, err, ErrEmptyURL)
+
+		for _, rawURL := range []string{
+			"example.com",
+			"/relative/path",
+			"ftp://example.com/file",
+			"mailto:user@example.com",
+			"http://",
+			"http:example.com",
+			"https://exa mple.com/",
+			"https://a..b/",
+			"https://-bad-.com/",
+			"https://ex!ample.com/",
+			"https://example.com:99999/",
+		} {
+			_, err := normalizer.Normalize(rawURL)
+			assert.ErrorIs(t, err, ErrInvalidURL, rawURL)
+			assert.True(t, IsInvalidURL(err), rawURL)
+		}
+	})
+
+	t.Run("Выбор правил", func(t *testing.T) {
+		none, err := NewURLNormalizer([]string{NormalizeNone}, DefaultTrackingParams)
+		require.NoError(t, err)
+		normalized, err := none.Normalize("HTTP://Example.com:80/a/../b?utm_source=x")
+		require.NoError(t, err)
+		assert.Equal(t, "http://Example.com:80/a/../b?utm_source=x", normalized)
+
+		// Без нормализатора URL только проверяется
+		var validator *URLNormalizer
+		normalized, err = validator.Normalize("https://Example.com/b?z=1&a=2")
+		require.NoError(t, err)
+		assert.Equal(t, "https://Example.com/b?z=1&a=2", normalized)
+		_, err = validator.Normalize("ftp://example.com")
+		assert.ErrorIs(t, err, ErrInvalidURL)
+
+		tracking, err := NewURLNormalizer([]string{" Strip-Tracking "}, []string{"ref", "src_*"})
+		require.NoError(t, err)
+		normalized, err = tracking.Normalize("https://example.com/?z=1&ref=a&src_id=2&utm_source=x")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/?z=1&utm_source=x", normalized)
+
+		_, err = NewURLNormalizer([]string{"lowercase", "unknown"}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("Пакет", func(t *testing.T) {
+		batch := []models.ShortenBatchRequest{
+			{CorrelationID: "1", OriginalURL: "HTTPS://Example.com"},
+			{CorrelationID: "2", OriginalURL: "https://example.com/?utm_source=x"},
+		}
+		normalized, err := normalizer.NormalizeBatch(batch)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/", normalized[0].OriginalURL)
+		assert.Equal(t, "https://example.com/", normalized[1].OriginalURL)
+		assert.Equal(t, "HTTPS://Example.com", batch[0].OriginalURL, "исходный пакет не меняется")
+
+		batch = append(batch, models.ShortenBatchRequest{CorrelationID: "3", OriginalURL: "not a url"})
+		_, err = normalizer.NormalizeBatch(batch)
+		assert.ErrorIs(t, err, ErrInvalidURL)
+		assert.Contains(t, err.Error(), `correlation_id "3"`)
+	})
+
+	t.Run("Дедупликация по каноническому виду", func(t *testing.T) {
+		ctx := context.Background()
+		s := memstore.NewMemStore()
+		service := NewURLShortenerService(s, "http://localhost:8080", NewURLShortener(), nil, nil, normalizer, nil)
+		userID := uuid.New()
+
+		first, err := service.CreateShortURL(ctx, "http://example.com/b", "", userID)
+		require.NoError(t, err)
+
+		second, err := service.CreateShortURL(ctx, "HTTP://Example.com:80/a/../b?utm_source=mail", "", userID)
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, first, second)
+
+		_, err = service.CreateShortURL(ctx, "javascript:alert(1)", "", userID)
+		assert.ErrorIs(t, err, ErrInvalidURL)
+	})
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// MaxPasswordLength — максимальная длина пароля ссылки в байтах, больше bcrypt не учитывает
+const MaxPasswordLength = 72
+
+// passwordCost — стоимость хэширования паролей ссылок
+const passwordCost = bcrypt.DefaultCost
+
+// pruneThreshold — число счетчиков неудачных попыток, после которого
+// при очередной неудаче удаляются счетчики с истекшим окном
+const pruneThreshold = 10000
+
+// linkAttemptsFactor — во сколько раз лимит неудачных попыток для ссылки
+// больше лимита одного клиента. Один клиент не может исчерпать его в одиночку
+// и закрыть ссылку для посетителей с верным паролем.
+const linkAttemptsFactor = 10
+
+var (
+	// ErrInvalidPassword ошибка, возникающая при слишком длинном пароле ссылки
+	ErrInvalidPassword = errors.New("invalid password")
+	// ErrPasswordRequired ошибка, возникающая при переходе по защищенной ссылке без пароля
+	ErrPasswordRequired = errors.New("password required")
+	// ErrWrongPassword ошибка, возникающая при неверном пароле ссылки
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrTooManyAttempts ошибка, возникающая после слишком большого числа неудачных попыток
+	ErrTooManyAttempts = errors.New("too many password attempts")
+)
+
+// HashPassword проверяет длину пароля ссылки и возвращает его bcrypt-хэш
+func HashPassword(password string) (string, error) {
+	if len(password) > MaxPasswordLength {
+		return "", fmt.Errorf("%w: %d bytes, expected at most %d", ErrInvalidPassword, len(password), MaxPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	return string(hash), nil
+}
+
+// PasswordOptions хэширует пароль ссылки и возвращает параметры ссылки
+// для хранилища. Пустой пароль означает открытую ссылку.
+func PasswordOptions(password string) ([]models.LinkOption, error) {
+	if password == "" {
+		return nil, nil
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return []models.LinkOption{models.WithPasswordHash(hash)}, nil
+}
+
+// PasswordLimiter ограничивает число неудачных попыток ввода пароля ссылки
+// для каждого клиента, а также общее число попыток для ссылки с более мягким
+// лимитом, чтобы перебор с множества адресов тоже упирался в ограничение.
+// Адреса IPv6 из одной сети /64 считаются одним клиентом.
+// Нулевой указатель не ограничивает попытки.
+type PasswordLimiter struct {
+	maxAttempts int
+	window      time.Duration
+
+	mu       sync.Mutex
+	failures map[string]*attempts
+}
+
+// attempts — число неудачных попыток с начала окна
+type attempts struct {
+	count int
+	start time.Time
+}
+
+// counter — счетчик неудачных попыток и допустимое число попыток для него
+type counter struct {
+	key         string
+	maxAttempts int
+}
+
+// NewPasswordLimiter создает ограничитель, допускающий maxAttempts неудачных
+// попыток за window. При maxAttempts <= 0 попытки не ограничиваются.
+func NewPasswordLimiter(maxAttempts int, window time.Duration) (*PasswordLimiter, error) {
+	if maxAttempts <= 0 {
+		return nil, nil
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("password attempt window must be positive, got %s", window)
+	}
+
+	return &PasswordLimiter{
+		maxAttempts: maxAttempts,
+		window:      window,
+		failures:    make(map[string]*attempts),
+	}, nil
+}
+
+// Unlock проверяет пароль для перехода по ссылке с IP-адреса ip.
+// Для открытых ссылок всегда возвращает nil. Если исчерпаны попытки клиента
+// для этой ссылки или общие попытки ссылки, возвращает ErrTooManyAttempts
+// и время до следующей попытки.
+func (l *PasswordLimiter) Unlock(record models.ShortenStore, password, ip string, now time.Time) (time.Duration, error) {
+	if !record.Protected() {
+		return 0, nil
+	}
+	if password == "" {
+		return 0, ErrPasswordRequired
+	}
+
+	counters := l.counters(record.ShortURL, ip)
+	if retryAfter := l.blocked(counters, now); retryAfter > 0 {
+		return retryAfter, ErrTooManyAttempts
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(record.PasswordHash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		l.fail(counters, now)
+		return 0, ErrWrongPassword
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to check password: %w", err)
+	}
+
+	return 0, nil
+}
+
+// counters возвращает счетчики попыток клиента с адресом ip для ссылки
+// и общий счетчик ссылки
+func (l *PasswordLimiter) counters(shortURL, ip string) []counter {
+	if l == nil {
+		return nil
+	}
+
+	link := "link:" + shortURL
+	return []counter{
+		{key: link + " client:" + clientKey(ip), maxAttempts: l.maxAttempts},
+		{key: link, maxAttempts: l.maxAttempts * linkAttemptsFactor},
+	}
+}
+
+// clientKey возвращает ключ клиента по IP-адресу. Адреса IPv6 сводятся
+// к сети /64, которую обычно получает один абонент.
+func clientKey(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil || !addr.Unmap().Is6() {
+		return ip
+	}
+
+	prefix, err := addr.Prefix(64)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
+
+// blocked возвращает время до снятия ограничения с самого долгого
+// из исчерпанных счетчиков или 0, если попытка разрешена
+func (l *PasswordLimiter) blocked(counters []counter, now time.Time) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var retryAfter time.Duration
+	for _, c := range counters {
+		a, ok := l.failures[c.key]
+		if !ok || a.count < c.maxAttempts {
+			continue
+		}
+		if remaining := a.start.Add(l.window).Sub(now); remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+
+	return retryAfter
+}
+
+// fail учитывает неудачную попытку в каждом счетчике
+func (l *PasswordLimiter) fail(counters []counter, now time.Time) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.failures) >= pruneThreshold {
+		for key, a := range l.failures {
+			if !now.Before(a.start.Add(l.window)) {
+				delete(l.failures, key)
+			}
+		}
+	}
+
+	for _, c := range counters {
+		a, ok := l.failures[c.key]
+		if !ok || !now.Before(a.start.Add(l.window)) {
+			a = &attempts{start: now}
+			l.failures[c.key] = a
+		}
+		a.count++
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+func TestLinkPassword(t *testing.T) {
+	now := time.Now()
+
+	opts, err := PasswordOptions("secret")
+	require.NoError(t, err)
+	record := models.ShortenStore{ShortURL: "short1", LinkOptions: models.NewLinkOptions(opts...)}
+	require.True(t, record.Protected())
+	// Пароль хранится только в виде хэша
+	assert.NotContains(t, record.PasswordHash, "secret")
+
+	t.Run("Параметры ссылки", func(t *testing.T) {
+		opts, err := PasswordOptions("")
+		require.NoError(t, err)
+		assert.Empty(t, opts)
+
+		_, err = PasswordOptions(strings.Repeat("a", MaxPasswordLength+1))
+		assert.ErrorIs(t, err, ErrInvalidPassword)
+	})
+
+	t.Run("Открытая ссылка", func(t *testing.T) {
+		_, err := (*PasswordLimiter)(nil).Unlock(models.ShortenStore{}, "", "10.0.0.1", now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Проверка пароля без ограничения", func(t *testing.T) {
+		var limiter *PasswordLimiter
+		_, err := limiter.Unlock(record, "", "10.0.0.1", now)
+		assert.ErrorIs(t, err, ErrPasswordRequired)
+		for i := 0; i < 3; i++ {
+			_, err = limiter.Unlock(record, "wrong", "10.0.0.1", now)
+			assert.ErrorIs(t, err, ErrWrongPassword)
+		}
+		_, err = limiter.Unlock(record, "secret", "10.0.0.1", now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Перебор не закрывает ссылку для верного пароля", func(t *testing.T) {
+		limiter, err := NewPasswordLimiter(2, time.Minute)
+		require.NoError(t, err)
+
+		// Адрес, исчерпавший попытки на ссылке, ограничивается даже с верным паролем
+		for i := 0; i < 2; i++ {
+			_, err = limiter.Unlock(record, "wrong", "10.0.0.1", now)
+			assert.ErrorIs(t, err, ErrWrongPassword)
+		}
+		retryAfter, err := limiter.Unlock(record, "secret", "10.0.0.1", now.Add(10*time.Second))
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+		assert.Equal(t, 50*time.Second, retryAfter)
+
+		// Владелец с другого адреса проходит с верным паролем
+		_, err = limiter.Unlock(record, "secret", "10.0.0.2", now.Add(10*time.Second))
+		assert.NoError(t, err)
+
+		// После окна попытки снова разрешены
+		_, err = limiter.Unlock(record, "secret", "10.0.0.1", now.Add(time.Minute))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Попытки адреса считаются для каждой ссылки", func(t *testing.T) {
+		limiter, err := NewPasswordLimiter(2, time.Minute)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err = limiter.Unlock(record, "wrong", "10.0.0.1", now)
+			assert.ErrorIs(t, err, ErrWrongPassword)
+		}
+		_, err = limiter.Unlock(record, "secret", "10.0.0.1", now)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+		// Посетители за одним NAT не теряют доступ к другим ссылкам
+		other := record
+		other.ShortURL = "short2"
+		_, err = limiter.Unlock(other, "secret", "10.0.0.1", now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Адреса одной сети IPv6 считаются одним клиентом", func(t *testing.T) {
+		limiter, err := NewPasswordLimiter(2, time.Minute)
+		require.NoError(t, err)
+
+		_, err = limiter.Unlock(record, "wrong", "2001:db8:1:1::1", now)
+		assert.ErrorIs(t, err, ErrWrongPassword)
+		_, err = limiter.Unlock(record, "wrong", "2001:db8:1:1::2", now)
+		assert.ErrorIs(t, err, ErrWrongPassword)
+		_, err = limiter.Unlock(record, "wrong", "2001:db8:1:1:ffff::3", now)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+		_, err = limiter.Unlock(record, "secret", "2001:db8:1:2::1", now)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Общее ограничение для ссылки", func(t *testing.T) {
+		limiter, err := NewPasswordLimiter(2, time.Minute)
+		require.NoError(t, err)
+
+		// Перебор с множества адресов упирается в общий лимит ссылки
+		for i := 0; i < 2*linkAttemptsFactor; i++ {
+			_, err = limiter.Unlock(record, "wrong", fmt.Sprintf("10.0.1.%d", i), now)
+			assert.ErrorIs(t, err, ErrWrongPassword)
+		}
+		_, err = limiter.Unlock(record, "wrong", "10.0.2.1", now)
+		assert.ErrorIs(t, err, ErrTooManyAttempts)
+
+		// Другие ссылки не затронуты, после окна ссылка снова открывается
+		other := record
+		other.ShortURL = "short2"
+		_, err = limiter.Unlock(other, "secret", "10.0.2.1", now)
+		assert.NoError(t, err)
+		_, err = limiter.Unlock(record, "secret", "10.0.2.1", now.Add(time.Minute))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Недопустимые параметры ограничения", func(t *testing.T) {
+		limiter, err := NewPasswordLimiter(0, time.Minute)
+		require.NoError(t, err)
+		assert.Nil(t, limiter)
+
+		_, err = NewPasswordLimiter(5, 0)
+		assert.Error(t, err)
+	})
+}
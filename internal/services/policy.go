@@ -0,0 +1,432 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// Причины отклонения URL политикой
+const (
+	// ReasonDenylisted — хост совпал с правилом списка запрещенных
+	ReasonDenylisted = "denylisted"
+	// ReasonNotAllowlisted — хост не совпал ни с одним правилом списка разрешенных
+	ReasonNotAllowlisted = "not_allowlisted"
+	// ReasonPrivateAddress — URL ведет на локальный или внутренний адрес
+	ReasonPrivateAddress = "private_address"
+	// ReasonRedirectLoop — URL ведет обратно на сам сервис
+	ReasonRedirectLoop = "redirect_loop"
+)
+
+// ErrURLBlocked ошибка, возникающая при URL, запрещенном политикой
+var ErrURLBlocked = errors.New("URL blocked by policy")
+
+// PolicyViolation описывает причину, по которой политика отклонила URL
+type PolicyViolation struct {
+	Reason string
+	Host   string
+	// Rule — совпавшее правило списка запрещенных
+	Rule string
+	// CorrelationID — идентификатор элемента пакета с отклоненным URL
+	CorrelationID string
+}
+
+// Error возвращает описание нарушения
+func (v *PolicyViolation) Error() string {
+	message := fmt.Sprintf("%s: %s host %q", ErrURLBlocked, v.Reason, v.Host)
+	if v.Rule != "" {
+		message += fmt.Sprintf(" matches %q", v.Rule)
+	}
+	if v.CorrelationID != "" {
+		message = fmt.Sprintf("correlation_id %q: %s", v.CorrelationID, message)
+	}
+	return message
+}
+
+// Unwrap позволяет сравнивать нарушение с ErrURLBlocked
+func (v *PolicyViolation) Unwrap() error {
+	return ErrURLBlocked
+}
+
+// Response возвращает нарушение в виде тела ответа
+func (v *PolicyViolation) Response() models.BlockedURLResponse {
+	return models.BlockedURLResponse{
+		Error:         "url_blocked",
+		Reason:        v.Reason,
+		Host:          v.Host,
+		Rule:          v.Rule,
+		CorrelationID: v.CorrelationID,
+		Message:       v.Error(),
+	}
+}
+
+// privatePrefixes — локальные, внутренние и зарезервированные сети
+var privatePrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("255.255.255.255/32"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// privateDomains — зарезервированные и общепринятые имена локальных и внутренних сетей,
+// вместе с поддоменами
+var privateDomains = []string{"localhost", "localdomain", "local", "internal", "intranet", "lan", "home.arpa"}
+
+// URLPolicyOptions — параметры политики оригинальных URL
+type URLPolicyOptions struct {
+	// BaseURL — адрес сервиса, ссылки на который запрещены
+	BaseURL string
+	// AllowlistFile и DenylistFile — файлы с правилами хостов, по одному на строку.
+	// Пустой путь выключает список.
+	AllowlistFile string
+	DenylistFile  string
+	// ReloadInterval — как часто проверяется изменение файлов, 0 выключает перезагрузку
+	ReloadInterval time.Duration
+	// AllowPrivate разрешает локальные и внутренние адреса. Проверяются только
+	// IP-адреса в URL и зарезервированные имена, имена хостов не разрешаются через DNS.
+	AllowPrivate bool
+}
+
+// URLPolicy решает, можно ли сократить оригинальный URL.
+// Нулевой указатель разрешает любой URL.
+type URLPolicy struct {
+	baseHost     string
+	allowPrivate bool
+	allow        *domainList
+	deny         *domainList
+}
+
+// NewURLPolicy создает политику и загружает списки хостов
+func NewURLPolicy(opts URLPolicyOptions) (*URLPolicy, error) {
+	p := &URLPolicy{allowPrivate: opts.AllowPrivate}
+
+	if opts.BaseURL != "" {
+		base, err := url.Parse(opts.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %w", err)
+		}
+		p.baseHost = hostKey(base)
+	}
+
+	var err error
+	if p.allow, err = newDomainList(opts.AllowlistFile, opts.ReloadInterval); err != nil {
+		return nil, fmt.Errorf("failed to load URL allowlist: %w", err)
+	}
+	if p.deny, err = newDomainList(opts.DenylistFile, opts.ReloadInterval); err != nil {
+		return nil, fmt.Errorf("failed to load URL denylist: %w", err)
+	}
+
+	return p, nil
+}
+
+// Check проверяет нормализованный оригинальный URL и возвращает
+// *PolicyViolation, если политика его запрещает
+func (p *URLPolicy) Check(originalURL string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(originalURL)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+	host := asciiHost(u.Hostname())
+
+	if p.baseHost != "" && hostKey(u) == p.baseHost {
+		return &PolicyViolation{Reason: ReasonRedirectLoop, Host: host}
+	}
+	if !p.allowPrivate && privateHost(host) {
+		return &PolicyViolation{Reason: ReasonPrivateAddress, Host: host}
+	}
+
+	now := time.Now()
+	if rule, ok := p.deny.match(host, now); ok {
+		return &PolicyViolation{Reason: ReasonDenylisted, Host: host, Rule: rule}
+	}
+	if p.allow != nil {
+		if _, ok := p.allow.match(host, now); !ok {
+			return &PolicyViolation{Reason: ReasonNotAllowlisted, Host: host}
+		}
+	}
+
+	return nil
+}
+
+// CheckBatch проверяет оригинальные URL пакета. В нарушении указывается
+// идентификатор первого отклоненного элемента.
+func (p *URLPolicy) CheckBatch(batchRequest []models.ShortenBatchRequest) error {
+	for _, request := range batchRequest {
+		err := p.Check(request.OriginalURL)
+		var violation *PolicyViolation
+		if errors.As(err, &violation) {
+			violation.CorrelationID = request.CorrelationID
+			return violation
+		}
+		if err != nil {
+			return fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+		}
+	}
+
+	return nil
+}
+
+// hostKey возвращает хост URL в нижнем регистре вместе с портом,
+// порт по умолчанию подставляется явно
+func hostKey(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPorts[strings.ToLower(u.Scheme)]
+	}
+	return asciiHost(u.Hostname()) + ":" + port
+}
+
+// asciiHost приводит хост к нижнему регистру и punycode и убирает завершающую точку
+func asciiHost(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := hostProfile.ToASCII(host); err == nil {
+		return ascii
+	}
+	return host
+}
+
+// privateHost сообщает, ведет ли хост на локальный или внутренний адрес.
+// Числовые записи IPv4 вида 2130706433 или 0x7f.1 разбираются так же, как в браузерах.
+// Проверяются только сами адреса и зарезервированные имена: имена не
+// разрешаются через DNS, поэтому публичное имя с внутренним адресом не
+// отклоняется. Защиту от обращений во внутреннюю сеть по такому имени
+// должен обеспечивать тот, кто переходит по ссылке.
+func privateHost(host string) bool {
+	for _, domain := range privateDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		var ok bool
+		if addr, ok = parseLegacyIPv4(host); !ok {
+			return false
+		}
+	}
+	addr = addr.Unmap()
+	for _, prefix := range privatePrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseLegacyIPv4 разбирает IPv4-адрес из 1–4 десятичных, восьмеричных
+// или шестнадцатеричных частей, как inet_aton
+func parseLegacyIPv4(host string) (netip.Addr, bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+
+	values := make([]uint64, len(parts))
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 0, 32)
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		values[i] = value
+	}
+
+	// Последняя часть занимает все оставшиеся байты адреса
+	last := len(values) - 1
+	if values[last] >= 1<<(8*(4-last)) {
+		return netip.Addr{}, false
+	}
+	ip := values[last]
+	for i := 0; i < last; i++ {
+		if values[i] > 255 {
+			return netip.Addr{}, false
+		}
+		ip |= values[i] << (8 * (3 - i))
+	}
+
+	return netip.AddrFrom4([4]byte{byte(ip >> 24), byte(ip >> 16), byte(ip >> 8), byte(ip)}), true
+}
+
+// domainRules — правила списка хостов
+type domainRules struct {
+	exact    map[string]string
+	suffixes []string
+	prefixes []netip.Prefix
+	any      bool
+}
+
+// domainList — список хостов из файла, перечитываемый при его изменении
+type domainList struct {
+	path     string
+	interval time.Duration
+
+	mu      sync.Mutex
+	rules   *domainRules
+	checked time.Time
+	modTime time.Time
+	size    int64
+}
+
+// newDomainList загружает список из файла. Для пустого пути возвращает nil.
+func newDomainList(path string, interval time.Duration) (*domainList, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	l := &domainList{path: path, interval: interval}
+	if err := l.load(time.Now()); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// match возвращает совпавшее с хостом правило. Если файл изменился,
+// список перечитывается не чаще одного раза за interval.
+func (l *domainList) match(host string, now time.Time) (string, bool) {
+	if l == nil {
+		return "", false
+	}
+
+	l.mu.Lock()
+	if l.interval > 0 && now.Sub(l.checked) >= l.interval {
+		if err := l.load(now); err != nil {
+			logger.Log.Error("Failed to reload URL policy list, keeping the previous rules", "path", l.path, "error", err)
+		}
+	}
+	rules := l.rules
+	l.mu.Unlock()
+
+	return rules.match(host)
+}
+
+// load перечитывает файл, если изменились время его изменения или размер
+func (l *domainList) load(now time.Time) error {
+	l.checked = now
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	if l.rules != nil && info.ModTime().Equal(l.modTime) && info.Size() == l.size {
+		return nil
+	}
+
+	rules, err := readDomainRules(l.path)
+	if err != nil {
+		return err
+	}
+
+	if l.rules != nil {
+		logger.Log.Info("URL policy list reloaded", "path", l.path)
+	}
+	l.rules, l.modTime, l.size = rules, info.ModTime(), info.Size()
+	return nil
+}
+
+// readDomainRules читает правила из файла. Каждая строка содержит хост,
+// шаблон "*.example.com" для его поддоменов, "*" для любого хоста,
+// IP-адрес или подсеть в формате CIDR. Текст после "#" игнорируется.
+func readDomainRules(path string) (*domainRules, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := &domainRules{exact: make(map[string]string)}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		rule, _, _ := strings.Cut(scanner.Text(), "#")
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		if err := rules.add(rule); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// add добавляет правило в список
+func (r *domainRules) add(rule string) error {
+	if rule == "*" {
+		r.any = true
+		return nil
+	}
+	if prefix, err := netip.ParsePrefix(rule); err == nil {
+		r.prefixes = append(r.prefixes, prefix.Masked())
+		return nil
+	}
+	if addr, err := netip.ParseAddr(strings.Trim(rule, "[]")); err == nil {
+		r.prefixes = append(r.prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+		return nil
+	}
+
+	domain, wildcard := strings.CutPrefix(rule, "*.")
+	ascii, err := hostProfile.ToASCII(strings.TrimSuffix(strings.ToLower(domain), "."))
+	if err != nil || strings.Contains(ascii, "*") {
+		return fmt.Errorf("invalid rule %q", rule)
+	}
+	if wildcard {
+		r.suffixes = append(r.suffixes, "."+ascii)
+	} else {
+		r.exact[ascii] = rule
+	}
+
+	return nil
+}
+
+// match возвращает правило, совпавшее с хостом
+func (r *domainRules) match(host string) (string, bool) {
+	if r.any {
+		return "*", true
+	}
+	if rule, ok := r.exact[host]; ok {
+		return rule, true
+	}
+	for _, suffix := range r.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return "*" + suffix, true
+		}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		addr = addr.Unmap()
+		for _, prefix := range r.prefixes {
+			if prefix.Contains(addr) {
+				return prefix.String(), true
+			}
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+func TestURLPolicy(t *testing.T) {
+	writeList := func(t *testing.T, path, content string) {
+		t.Helper()
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	// violation возвращает нарушение политики для URL или nil
+	violation := func(t *testing.T, policy *URLPolicy, originalURL string) *PolicyViolation {
+		t.Helper()
+		err := policy.Check(originalURL)
+		if err == nil {
+			return nil
+		}
+		var v *PolicyViolation
+		require.ErrorAs(t, err, &v, originalURL)
+		assert.ErrorIs(t, err, ErrURLBlocked)
+		return v
+	}
+
+	t.Run("Без политики", func(t *testing.T) {
+		var policy *URLPolicy
+		assert.NoError(t, policy.Check("http://127.0.0.1/"))
+		assert.NoError(t, policy.CheckBatch([]models.ShortenBatchRequest{{OriginalURL: "http://localhost/"}}))
+	})
+
+	t.Run("Локальные адреса и петли", func(t *testing.T) {
+		policy, err := NewURLPolicy(URLPolicyOptions{BaseURL: "https://Short.example"})
+		require.NoError(t, err)
+
+		private := []string{
+			"http://localhost/admin",
+			"http://api.localhost/",
+			"http://127.0.0.1:8080/",
+			"http://10.1.2.3/",
+			"http://172.20.0.1/",
+			"http://192.168.1.1/",
+			"http://169.254.169.254/latest/meta-data/",
+			"http://100.64.0.1/",
+			"http://0.0.0.0/",
+			"http://[::1]/",
+			"http://[fd00::1]/",
+			"http://[fe80::1]/",
+			"http://[::ffff:127.0.0.1]/",
+			"http://2130706433/",
+			"http://0x7f.1/",
+			"http://0177.0.0.1/",
+			"http://224.0.0.1/",
+			"http://239.255.255.250:1900/",
+			"http://255.255.255.255/",
+			"http://[ff02::1]/",
+			"http://metadata.google.internal/",
+			"http://printer.local/",
+			"http://router.home.arpa/",
+		}
+		for _, originalURL := range private {
+			v := violation(t, policy, originalURL)
+			if assert.NotNil(t, v, originalURL) {
+				assert.Equal(t, ReasonPrivateAddress, v.Reason, originalURL)
+			}
+		}
+
+		for _, originalURL := range []string{"https://short.example/abc", "https://short.example:443/", "https://SHORT.example./x"} {
+			v := violation(t, policy, originalURL)
+			if assert.NotNil(t, v, originalURL) {
+				assert.Equal(t, ReasonRedirectLoop, v.Reason, originalURL)
+				assert.Equal(t, "short.example", v.Host)
+			}
+		}
+
+		for _, originalURL := range []string{"https://example.com/", "http://short.example/", "https://short.example:8443/", "http://8.8.8.8/", "http://1.2.3/", "https://internal.example.com/", "https://local.dev/"} {
+			assert.Nil(t, violation(t, policy, originalURL), originalURL)
+		}
+
+		allowed, err := NewURLPolicy(URLPolicyOptions{AllowPrivate: true})
+		require.NoError(t, err)
+		assert.NoError(t, allowed.Check("http://localhost:3000/"))
+	})
+
+	t.Run("Списки хостов", func(t *testing.T) {
+		dir := t.TempDir()
+		denylist := filepath.Join(dir, "deny.txt")
+		allowlist := filepath.Join(dir, "allow.txt")
+		writeList(t, denylist, "# фишинг\nevil.com\n*.phish.example # поддомены\nпример.рф\n203.0.113.0/24\n\n")
+		writeList(t, allowlist, "*.com\nexample.org\n203.0.113.7\n")
+
+		policy, err := NewURLPolicy(URLPolicyOptions{AllowlistFile: allowlist, DenylistFile: denylist})
+		require.NoError(t, err)
+
+		tests := []struct {
+			originalURL    string
+			expectedReason string
+			expectedRule   string
+		}{
+			{originalURL: "https://evil.com/login", expectedReason: ReasonDenylisted, expectedRule: "evil.com"},
+			{originalURL: "https://login.phish.example/", expectedReason: ReasonDenylisted, expectedRule: "*.phish.example"},
+			{originalURL: "https://xn--e1afmkfd.xn--p1ai/", expectedReason: ReasonDenylisted, expectedRule: "пример.рф"},
+			{originalURL: "http://203.0.113.7/", expectedReason: ReasonDenylisted, expectedRule: "203.0.113.0/24"},
+			{originalURL: "https://phish.example/", expectedReason: ReasonNotAllowlisted},
+			{originalURL: "https://sub.example.org/", expectedReason: ReasonNotAllowlisted},
+			{originalURL: "https://good.com/"},
+			{originalURL: "https://www.notevil.com/"},
+			{originalURL: "https://example.org/"},
+		}
+		for _, tt := range tests {
+			v := violation(t, policy, tt.originalURL)
+			if tt.expectedReason == "" {
+				assert.Nil(t, v, tt.originalURL)
+				continue
+			}
+			if assert.NotNil(t, v, tt.originalURL) {
+				assert.Equal(t, tt.expectedReason, v.Reason, tt.originalURL)
+				assert.Equal(t, tt.expectedRule, v.Rule, tt.originalURL)
+			}
+		}
+
+		writeList(t, denylist, "evil..com\n")
+		_, err = NewURLPolicy(URLPolicyOptions{DenylistFile: denylist})
+		assert.ErrorContains(t, err, "deny.txt:1")
+
+		_, err = NewURLPolicy(URLPolicyOptions{AllowlistFile: filepath.Join(dir, "missing.txt")})
+		assert.Error(t, err)
+	})
+
+	t.Run("Перезагрузка списков", func(t *testing.T) {
+		denylist := filepath.Join(t.TempDir(), "deny.txt")
+		writeList(t, denylist, "evil.com\n")
+
+		policy, err := NewURLPolicy(URLPolicyOptions{DenylistFile: denylist, ReloadInterval: time.Millisecond})
+		require.NoError(t, err)
+		assert.Error(t, policy.Check("https://evil.com/"))
+		assert.NoError(t, policy.Check("https://bad.example/"))
+
+		writeList(t, denylist, "bad.example\nanother.example\n")
+		time.Sleep(5 * time.Millisecond)
+		assert.NoError(t, policy.Check("https://evil.com/"))
+		assert.Error(t, policy.Check("https://bad.example/"))
+
+		// Ошибка чтения не сбрасывает загруженные правила
+		require.NoError(t, os.Remove(denylist))
+		time.Sleep(5 * time.Millisecond)
+		assert.Error(t, policy.Check("https://bad.example/"))
+	})
+
+	t.Run("Пакет", func(t *testing.T) {
+		policy, err := NewURLPolicy(URLPolicyOptions{})
+		require.NoError(t, err)
+
+		err = policy.CheckBatch([]models.ShortenBatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://example.com/"},
+			{CorrelationID: "2", OriginalURL: "http://localhost/"},
+		})
+		var v *PolicyViolation
+		require.ErrorAs(t, err, &v)
+		assert.Equal(t, "2", v.CorrelationID)
+
+		response := v.Response()
+		assert.Equal(t, models.BlockedURLResponse{
+			Error:         "url_blocked",
+			Reason:        ReasonPrivateAddress,
+			Host:          "localhost",
+			CorrelationID: "2",
+			Message:       `correlation_id "2": URL blocked by policy: private_address host "localhost"`,
+		}, response)
+	})
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// MaxTitleLength — максимальная длина заголовка ссылки в символах
+const MaxTitleLength = 200
+
+// ErrInvalidTitle ошибка, возникающая при слишком длинном заголовке ссылки
+var ErrInvalidTitle = errors.New("invalid title")
+
+// ValidateTitle проверяет длину заголовка ссылки
+func ValidateTitle(title string) error {
+	if length := utf8.RuneCountInString(title); length > MaxTitleLength {
+		return fmt.Errorf("%w: %d characters, expected at most %d", ErrInvalidTitle, length, MaxTitleLength)
+	}
+
+	return nil
+}
+
+// PreviewOptions проверяет заголовок ссылки и возвращает параметры ссылки
+// для хранилища. Пустой заголовок и preview=false не сохраняются.
+func PreviewOptions(title string, preview bool) ([]models.LinkOption, error) {
+	if err := ValidateTitle(title); err != nil {
+		return nil, err
+	}
+
+	var opts []models.LinkOption
+	if title != "" {
+		opts = append(opts, models.WithTitle(title))
+	}
+	if preview {
+		opts = append(opts, models.WithPreview())
+	}
+
+	return opts, nil
+}
+
+// PreviewPolicy задает, когда вместо перенаправления показывается
+// страница предпросмотра, и какие домены помечаются как опасные
+type PreviewPolicy struct {
+	// Force показывает страницу предпросмотра для всех ссылок
+	Force bool
+	// FlaggedDomains — домены, для которых страница предпросмотра
+	// показывает предупреждение; поддомены помечаются тоже
+	FlaggedDomains []string
+}
+
+// Forced сообщает, показывается ли страница предпросмотра вместо перенаправления
+func (p PreviewPolicy) Forced(record models.ShortenStore) bool {
+	return p.Force || record.Preview
+}
+
+// Flagged сообщает, помечен ли домен оригинального URL как опасный
+func (p PreviewPolicy) Flagged(originalURL string) bool {
+	host := domain(originalURL)
+	if host == "" {
+		return false
+	}
+
+	for _, flagged := range p.FlaggedDomains {
+		flagged = strings.ToLower(strings.Trim(strings.TrimSpace(flagged), "."))
+		if flagged == "" {
+			continue
+		}
+		if host == flagged || strings.HasSuffix(host, "."+flagged) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Preview возвращает данные страницы предпросмотра ссылки
+func (p PreviewPolicy) Preview(record models.ShortenStore) models.LinkPreview {
+	return models.LinkPreview{
+		ShortURL:    record.ShortURL,
+		OriginalURL: record.OriginalURL,
+		Domain:      domain(record.OriginalURL),
+		Title:       record.Title,
+		CreatedAt:   record.CreatedAt,
+		Flagged:     p.Flagged(record.OriginalURL),
+	}
+}
+
+// domain возвращает хост URL в нижнем регистре без порта
+func domain(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSuffix(parsed.Hostname(), "."))
+}
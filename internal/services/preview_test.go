@@ -0,0 +1,78 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+func TestPreviewPolicy(t *testing.T) {
+	t.Run("Параметры ссылки", func(t *testing.T) {
+		opts, err := PreviewOptions("", false)
+		require.NoError(t, err)
+		assert.Empty(t, opts)
+
+		opts, err = PreviewOptions("Заголовок", true)
+		require.NoError(t, err)
+		link := models.NewLinkOptions(opts...)
+		assert.Equal(t, "Заголовок", link.Title)
+		assert.True(t, link.Preview)
+
+		// Длина считается в символах, а не в байтах
+		_, err = PreviewOptions(strings.Repeat("я", MaxTitleLength), false)
+		assert.NoError(t, err)
+		_, err = PreviewOptions(strings.Repeat("я", MaxTitleLength+1), false)
+		assert.ErrorIs(t, err, ErrInvalidTitle)
+	})
+
+	t.Run("Принудительный предпросмотр", func(t *testing.T) {
+		record := models.ShortenStore{}
+		assert.False(t, PreviewPolicy{}.Forced(record))
+		assert.True(t, PreviewPolicy{Force: true}.Forced(record))
+
+		record.Preview = true
+		assert.True(t, PreviewPolicy{}.Forced(record))
+	})
+
+	t.Run("Помеченные домены", func(t *testing.T) {
+		policy := PreviewPolicy{FlaggedDomains: []string{"Evil.com", " .phish.example. ", ""}}
+		tests := map[string]bool{
+			"https://evil.com/login":           true,
+			"https://login.EVIL.com:8443/":     true,
+			"http://phish.example./":           true,
+			"https://notevil.com/":             false,
+			"https://evil.com.example.org/":    false,
+			"https://practicum.yandex.ru/":     false,
+			"://broken":                        false,
+			"https://user@evil.com@safe.org/x": false,
+		}
+		for originalURL, flagged := range tests {
+			assert.Equal(t, flagged, policy.Flagged(originalURL), originalURL)
+		}
+	})
+
+	t.Run("Данные страницы", func(t *testing.T) {
+		createdAt := time.Now()
+		record := models.ShortenStore{
+			ShortURL:    "short1",
+			OriginalURL: "https://WWW.Example.com:8080/path",
+			CreatedAt:   &createdAt,
+			LinkOptions: models.LinkOptions{Title: "Пример"},
+		}
+
+		preview := PreviewPolicy{FlaggedDomains: []string{"example.com"}}.Preview(record)
+		assert.Equal(t, models.LinkPreview{
+			ShortURL:    "short1",
+			OriginalURL: "https://WWW.Example.com:8080/path",
+			Domain:      "www.example.com",
+			Title:       "Пример",
+			CreatedAt:   &createdAt,
+			Flagged:     true,
+		}, preview)
+	})
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+// DefaultRedirectCode — код перенаправления, если он не задан ни для ссылки, ни в конфигурации
+const DefaultRedirectCode = http.StatusTemporaryRedirect
+
+// ErrInvalidRedirectCode ошибка, возникающая при неподдерживаемом коде перенаправления
+var ErrInvalidRedirectCode = errors.New("invalid redirect code")
+
+// ValidateRedirectCode проверяет, что код перенаправления один из 301, 302, 307 и 308
+func ValidateRedirectCode(code int) error {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d, expected 301, 302, 307 or 308", ErrInvalidRedirectCode, code)
+}
+
+// RedirectOptions проверяет код перенаправления ссылки и возвращает параметры
+// ссылки для хранилища. Код 0 означает код по умолчанию и не сохраняется.
+func RedirectOptions(code int) ([]models.LinkOption, error) {
+	if code == 0 {
+		return nil, nil
+	}
+	if err := ValidateRedirectCode(code); err != nil {
+		return nil, err
+	}
+
+	return []models.LinkOption{models.WithRedirectCode(code)}, nil
+}
+
+// RedirectPolicy задает код перенаправления по умолчанию и время,
+// на которое браузеры и CDN могут закэшировать постоянное перенаправление
+type RedirectPolicy struct {
+	// DefaultCode используется для ссылок без своего кода; 0 означает DefaultRedirectCode
+	DefaultCode int
+	// CacheMaxAge — время кэширования постоянных перенаправлений;
+	// 0 запрещает кэшировать любые перенаправления
+	CacheMaxAge time.Duration
+}
+
+// NewRedirectPolicy проверяет код по умолчанию и создает политику перенаправлений
+func NewRedirectPolicy(defaultCode int, cacheMaxAge time.Duration) (RedirectPolicy, error) {
+	if defaultCode != 0 {
+		if err := ValidateRedirectCode(defaultCode); err != nil {
+			return RedirectPolicy{}, err
+		}
+	}
+	if cacheMaxAge < 0 {
+		return RedirectPolicy{}, fmt.Errorf("redirect cache max age must not be negative, got %s", cacheMaxAge)
+	}
+
+	return RedirectPolicy{DefaultCode: defaultCode, CacheMaxAge: cacheMaxAge}, nil
+}
+
+// Code возвращает код перенаправления для ссылки
+func (p RedirectPolicy) Code(record models.ShortenStore) int {
+	switch {
+	case record.RedirectCode != 0:
+		return record.RedirectCode
+	case p.DefaultCode != 0:
+		return p.DefaultCode
+	}
+
+	return DefaultRedirectCode
+}
+
+// CacheControl возвращает значение заголовка Cache-Control для перенаправления
+// по ссылке с кодом code. Временные перенаправления не кэшируются никогда,
+// постоянные — не дольше CacheMaxAge и не дольше срока действия ссылки.
+func (p RedirectPolicy) CacheControl(record models.ShortenStore, code int, now time.Time) string {
+	if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+		return "no-store"
+	}
+
+	maxAge := p.CacheMaxAge
+	if record.ExpiresAt != nil {
+		maxAge = min(maxAge, record.ExpiresAt.Sub(now))
+	}
+	seconds := int64(maxAge / time.Second)
+	if seconds <= 0 {
+		return "no-store"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", seconds)
+}
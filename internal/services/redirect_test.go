@@ -0,0 +1,68 @@
+package services
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+)
+
+func TestRedirectPolicy(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Допустимые коды", func(t *testing.T) {
+		for _, code := range []int{301, 302, 307, 308} {
+			assert.NoError(t, ValidateRedirectCode(code))
+		}
+		for _, code := range []int{200, 303, 404} {
+			assert.ErrorIs(t, ValidateRedirectCode(code), ErrInvalidRedirectCode)
+		}
+	})
+
+	t.Run("Параметры ссылки", func(t *testing.T) {
+		opts, err := RedirectOptions(0)
+		require.NoError(t, err)
+		assert.Empty(t, opts)
+
+		opts, err = RedirectOptions(http.StatusMovedPermanently)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMovedPermanently, models.NewLinkOptions(opts...).RedirectCode)
+
+		_, err = RedirectOptions(303)
+		assert.ErrorIs(t, err, ErrInvalidRedirectCode)
+	})
+
+	t.Run("Код ссылки важнее кода по умолчанию", func(t *testing.T) {
+		assert.Equal(t, http.StatusTemporaryRedirect, RedirectPolicy{}.Code(models.ShortenStore{}))
+
+		policy, err := NewRedirectPolicy(http.StatusFound, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusFound, policy.Code(models.ShortenStore{}))
+		record := models.ShortenStore{LinkOptions: models.LinkOptions{RedirectCode: http.StatusMovedPermanently}}
+		assert.Equal(t, http.StatusMovedPermanently, policy.Code(record))
+	})
+
+	t.Run("Недопустимая политика", func(t *testing.T) {
+		_, err := NewRedirectPolicy(200, time.Hour)
+		assert.ErrorIs(t, err, ErrInvalidRedirectCode)
+		_, err = NewRedirectPolicy(0, -time.Hour)
+		assert.Error(t, err)
+	})
+
+	t.Run("Кэширование", func(t *testing.T) {
+		policy := RedirectPolicy{CacheMaxAge: time.Hour}
+		record := models.ShortenStore{}
+		assert.Equal(t, "public, max-age=3600", policy.CacheControl(record, http.StatusMovedPermanently, now))
+		assert.Equal(t, "no-store", policy.CacheControl(record, http.StatusFound, now))
+		assert.Equal(t, "no-store", RedirectPolicy{}.CacheControl(record, http.StatusPermanentRedirect, now))
+
+		// Постоянное перенаправление не кэшируется дольше срока действия ссылки
+		expiresAt := now.Add(10 * time.Minute)
+		record.ExpiresAt = &expiresAt
+		assert.Equal(t, "public, max-age=600", policy.CacheControl(record, http.StatusPermanentRedirect, now))
+	})
+}
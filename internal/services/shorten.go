@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// MaxGenerateAttempts ограничивает число попыток найти свободный короткий URL
+const MaxGenerateAttempts = 5
+
+// Shorten генерирует короткий URL и сохраняет его в хранилище.
+// Если код уже занят, генерируется новый, но не более MaxGenerateAttempts раз.
+// Параметры opts сохраняются вместе со ссылкой.
+func Shorten(ctx context.Context, s store.Store, shortener Shortener, originalURL string, userID uuid.UUID, opts ...models.LinkOption) (string, error) {
+	for attempt := 0; ; attempt++ {
+		shortURL, err := generate(shortener, originalURL, attempt)
+		if err != nil {
+			return "", err
+		}
+
+		err = s.Add(ctx, shortURL, originalURL, userID, opts...)
+		if errors.Is(err, storeerr.ErrURLExists) && attempt+1 < MaxGenerateAttempts {
+			logger.Log.Warn("Short URL collision, retrying", "short_url", shortURL, "attempt", attempt+1)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return shortURL, nil
+	}
+}
+
+// ShortenBatch генерирует короткие URL для пакета и сохраняет их одной операцией.
+// Пакет сохраняется атомарно, поэтому при занятом коде генерируется весь пакет заново.
+// Недопустимый срок действия любого элемента возвращает ErrInvalidExpiry,
+// недопустимый код перенаправления — ErrInvalidRedirectCode,
+// слишком длинный заголовок — ErrInvalidTitle, слишком длинный пароль — ErrInvalidPassword,
+// а отрицательное ограничение переходов — ErrInvalidMaxClicks.
+// Пароли элементов сохраняются только в виде хэшей.
+func ShortenBatch(ctx context.Context, s store.Store, shortener Shortener, batchRequest []models.ShortenBatchRequest, userID uuid.UUID) ([]models.ShortenBatchStore, error) {
+	now := time.Now()
+	expiries := make([]*time.Time, len(batchRequest))
+	passwordHashes := make([]string, len(batchRequest))
+	for i, request := range batchRequest {
+		expiresAt, err := ParseExpiry(request.ExpiresIn, request.ExpiresAt, now)
+		if err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+		}
+		expiries[i] = expiresAt
+		if request.RedirectCode != 0 {
+			if err := ValidateRedirectCode(request.RedirectCode); err != nil {
+				return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+			}
+		}
+		if err := ValidateTitle(request.Title); err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+		}
+		if err := ValidateMaxClicks(request.MaxClicks); err != nil {
+			return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+		}
+		if request.Password != "" {
+			hash, err := HashPassword(request.Password)
+			if err != nil {
+				return nil, fmt.Errorf("correlation_id %q: %w", request.CorrelationID, err)
+			}
+			passwordHashes[i] = hash
+		}
+	}
+
+	batchStore := make([]models.ShortenBatchStore, len(batchRequest))
+	for attempt := 0; ; attempt++ {
+		for i, request := range batchRequest {
+			shortURL, err := generate(shortener, request.OriginalURL, attempt)
+			if err != nil {
+				return nil, err
+			}
+			batchStore[i] = models.ShortenBatchStore{
+				CorrelationID: request.CorrelationID,
+				ShortURL:      shortURL,
+				OriginalURL:   request.OriginalURL,
+				LinkOptions: models.LinkOptions{
+					ExpiresAt:    expiries[i],
+					RedirectCode: request.RedirectCode,
+					Title:        request.Title,
+					Preview:      request.Preview,
+					PasswordHash: passwordHashes[i],
+					MaxClicks:    request.MaxClicks,
+				},
+			}
+		}
+
+		err := s.AddBatch(ctx, batchStore, userID)
+		if errors.Is(err, storeerr.ErrURLExists) && attempt+1 < MaxGenerateAttempts {
+			logger.Log.Warn("Short URL collision in batch, retrying", "attempt", attempt+1)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return batchStore, nil
+	}
+}
+
+// IsInvalidLinkOption сообщает, вызвана ли ошибка недопустимым параметром ссылки в запросе
+func IsInvalidLinkOption(err error) bool {
+	for _, target := range []error{ErrInvalidExpiry, ErrInvalidRedirectCode, ErrInvalidTitle, ErrInvalidPassword, ErrInvalidMaxClicks} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// generate вызывает генератор, добавляя номер попытки к URL при повторах,
+// чтобы детерминированные стратегии выдали другой код
+func generate(shortener Shortener, originalURL string, attempt int) (string, error) {
+	if attempt == 0 {
+		return shortener.GenerateShortURL(originalURL)
+	}
+	return shortener.GenerateShortURL(fmt.Sprintf("%s#retry-%d", originalURL, attempt))
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func init() {
+	// Инициализация логгера для тестов
+	logger.Log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+// sequenceShortener возвращает коды по порядку, повторяя последний
+type sequenceShortener struct {
+	codes []string
+	calls int
+}
+
+func (s *sequenceShortener) GenerateShortURL(url string) (string, error) {
+	code := s.codes[min(s.calls, len(s.codes)-1)]
+	s.calls++
+	return code, nil
+}
+
+func TestShorten(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("Retries on collision", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		require.NoError(t, s.Add(ctx, "taken", "https://example1.com", userID))
+
+		shortener := &sequenceShortener{codes: []string{"taken", "taken", "free"}}
+		shortURL, err := Shorten(ctx, s, shortener, "https://example2.com", userID)
+		require.NoError(t, err)
+		assert.Equal(t, "free", shortURL)
+		assert.Equal(t, 3, shortener.calls)
+	})
+
+	t.Run("Gives up after max attempts", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		require.NoError(t, s.Add(ctx, "taken", "https://example1.com", userID))
+
+		shortener := &sequenceShortener{codes: []string{"taken"}}
+		_, err := Shorten(ctx, s, shortener, "https://example2.com", userID)
+		assert.ErrorIs(t, err, storeerr.ErrURLExists)
+		assert.Equal(t, MaxGenerateAttempts, shortener.calls)
+	})
+
+	t.Run("Conflict is not retried", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		require.NoError(t, s.Add(ctx, "existing", "https://example1.com", userID))
+
+		shortener := &sequenceShortener{codes: []string{"other"}}
+		_, err := Shorten(ctx, s, shortener, "https://example1.com", userID)
+
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "existing", conflict.ShortURL)
+		assert.Equal(t, 1, shortener.calls)
+	})
+
+	t.Run("Hash strategy retries with a different code", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		shortener := &HashShortener{Length: DefaultShortURLLength}
+
+		code, err := shortener.GenerateShortURL("https://example2.com")
+		require.NoError(t, err)
+		require.NoError(t, s.Add(ctx, code, "https://example1.com", userID))
+
+		shortURL, err := Shorten(ctx, s, shortener, "https://example2.com", userID)
+		require.NoError(t, err)
+		assert.NotEqual(t, code, shortURL)
+	})
+
+	t.Run("Batch retries as a whole", func(t *testing.T) {
+		s := memstore.NewMemStore()
+		require.NoError(t, s.Add(ctx, "taken", "https://example1.com", userID))
+
+		shortener := &sequenceShortener{codes: []string{"a1", "taken", "b1", "b2"}}
+		batch, err := ShortenBatch(ctx, s, shortener, []models.ShortenBatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://example2.com"},
+			{CorrelationID: "2", OriginalURL: "https://example3.com"},
+		}, userID)
+		require.NoError(t, err)
+		require.Len(t, batch, 2)
+		assert.Equal(t, "b1", batch[0].ShortURL)
+		assert.Equal(t, "b2", batch[1].ShortURL)
+
+		_, err = s.Get(ctx, "a1")
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+}
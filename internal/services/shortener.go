@@ -2,8 +2,6 @@
 package services
 
 import (
-	"crypto/sha256"
-	"encoding/base64"
 	"errors"
 )
 
@@ -15,24 +13,7 @@ type Shortener interface {
 	GenerateShortURL(url string) (string, error)
 }
 
-// URLShortener структура
-type URLShortener struct{}
-
-// NewURLShortener создаёт новую структуру URLShortener
-func NewURLShortener() *URLShortener {
-	return &URLShortener{}
-}
-
-// GenerateShortURL генерирует сокращённый URL, используя SHA256 и base64
-func (us *URLShortener) GenerateShortURL(url string) (string, error) {
-	if url == "" {
-		return "", ErrEmptyURL
-	}
-
-	hasher := sha256.New()
-	hasher.Write([]byte(url))
-	hash := hasher.Sum(nil)
-
-	shortURL := base64.URLEncoding.EncodeToString(hash)[:8]
-	return shortURL, nil
+// NewURLShortener создаёт детерминированный генератор на основе SHA-256
+func NewURLShortener() *HashShortener {
+	return &HashShortener{Length: DefaultShortURLLength}
 }
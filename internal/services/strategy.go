@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Стратегии генерации коротких URL
+const (
+	StrategyRandom  = "random"
+	StrategyCounter = "counter"
+	StrategyHash    = "hash"
+	StrategyWords   = "words"
+)
+
+const (
+	// DefaultShortURLLength длина короткого URL по умолчанию
+	DefaultShortURLLength = 8
+	// MinShortURLLength и MaxShortURLLength ограничивают длину, заданную в конфигурации.
+	// Максимум совпадает с размером колонки short_url в базе данных.
+	MinShortURLLength = 4
+	MaxShortURLLength = 32
+)
+
+// base62Alphabet содержит только символы, безопасные в пути URL
+const base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// sequenceTimeout ограничивает ожидание следующего значения последовательности
+const sequenceTimeout = 5 * time.Second
+
+// Sequence выдает уникальные возрастающие числа для счетчика
+type Sequence interface {
+	NextID(ctx context.Context) (int64, error)
+}
+
+// StrategyOptions задает параметры стратегии генерации
+type StrategyOptions struct {
+	// Length — длина короткого URL; 0 означает DefaultShortURLLength
+	Length int
+	// Sequence нужна стратегии StrategyCounter
+	Sequence Sequence
+}
+
+// StrategyFactory создает генератор коротких URL
+type StrategyFactory func(opts StrategyOptions) (Shortener, error)
+
+// strategies — реестр стратегий по имени
+var strategies = map[string]StrategyFactory{
+	StrategyRandom: func(opts StrategyOptions) (Shortener, error) {
+		return &RandomShortener{Length: opts.Length}, nil
+	},
+	StrategyCounter: func(opts StrategyOptions) (Shortener, error) {
+		if opts.Sequence == nil {
+			return nil, errors.New("counter strategy requires a sequence")
+		}
+		return &CounterShortener{Length: opts.Length, Sequence: opts.Sequence}, nil
+	},
+	StrategyHash: func(opts StrategyOptions) (Shortener, error) {
+		return &HashShortener{Length: opts.Length}, nil
+	},
+	StrategyWords: func(opts StrategyOptions) (Shortener, error) {
+		return NewWordsShortener(), nil
+	},
+}
+
+// RegisterStrategy добавляет стратегию в реестр.
+// Функция должна вызываться при инициализации пакета.
+func RegisterStrategy(name string, factory StrategyFactory) {
+	strategies[name] = factory
+}
+
+// Strategies возвращает имена зарегистрированных стратегий
+func Strategies() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewShortenerStrategy создает генератор по имени стратегии.
+// Пустое имя выбирает StrategyRandom.
+func NewShortenerStrategy(name string, opts StrategyOptions) (Shortener, error) {
+	if name == "" {
+		name = StrategyRandom
+	}
+
+	factory, ok := strategies[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown short URL strategy %q, available: %s", name, strings.Join(Strategies(), ", "))
+	}
+
+	if opts.Length == 0 {
+		opts.Length = DefaultShortURLLength
+	}
+	if opts.Length < MinShortURLLength || opts.Length > MaxShortURLLength {
+		return nil, fmt.Errorf("short URL length must be between %d and %d", MinShortURLLength, MaxShortURLLength)
+	}
+
+	return factory(opts)
+}
+
+// RandomShortener генерирует случайный короткий URL из символов base62
+type RandomShortener struct {
+	Length int
+}
+
+// GenerateShortURL возвращает случайную строку длины Length
+func (rs *RandomShortener) GenerateShortURL(url string) (string, error) {
+	if url == "" {
+		return "", ErrEmptyURL
+	}
+
+	length := rs.Length
+	if length <= 0 {
+		length = DefaultShortURLLength
+	}
+
+	// Отбрасываем байты за пределами кратного 62 диапазона, чтобы символы были равновероятны
+	const limit = 256 - 256%len(base62Alphabet)
+	code := make([]byte, 0, length)
+	buf := make([]byte, length*2)
+	for len(code) < length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for _, b := range buf {
+			if int(b) >= limit {
+				continue
+			}
+			code = append(code, base62Alphabet[int(b)%len(base62Alphabet)])
+			if len(code) == length {
+				break
+			}
+		}
+	}
+
+	return string(code), nil
+}
+
+// HashShortener строит короткий URL из SHA-256 исходного URL.
+// Один и тот же URL всегда дает один и тот же код.
+type HashShortener struct {
+	Length int
+}
+
+// GenerateShortURL возвращает последние Length символов хэша в base62.
+// Младшие разряды распределены равномернее старших.
+func (hs *HashShortener) GenerateShortURL(url string) (string, error) {
+	if url == "" {
+		return "", ErrEmptyURL
+	}
+
+	length := hs.Length
+	if length <= 0 {
+		length = DefaultShortURLLength
+	}
+
+	hash := sha256.Sum256([]byte(url))
+	encoded := new(big.Int).SetBytes(hash[:]).Text(62)
+	// Text(62) использует алфавит 0-9a-zA-Z, как и base62Alphabet
+	if len(encoded) < length {
+		encoded = strings.Repeat("0", length-len(encoded)) + encoded
+	}
+
+	return encoded[len(encoded)-length:], nil
+}
+
+// counterMultiplier перемешивает значения счетчика, чтобы соседние коды
+// не были похожи. Он взаимно прост с 62, поэтому отображение обратимо.
+const counterMultiplier = 0x9E3779B97F4A7C15
+
+// CounterShortener кодирует следующее значение последовательности хранилища.
+// Коды уникальны без повторных попыток, пока значения помещаются в Length символов.
+type CounterShortener struct {
+	Length   int
+	Sequence Sequence
+}
+
+// GenerateShortURL возвращает закодированное значение последовательности
+func (cs *CounterShortener) GenerateShortURL(url string) (string, error) {
+	if url == "" {
+		return "", ErrEmptyURL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sequenceTimeout)
+	defer cancel()
+
+	id, err := cs.Sequence.NextID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get next id: %w", err)
+	}
+
+	length := cs.Length
+	if length <= 0 {
+		length = DefaultShortURLLength
+	}
+
+	return encodeCounter(uint64(id), length), nil
+}
+
+// encodeCounter перемешивает id в пределах 62^length и кодирует его в base62.
+// Значения, не помещающиеся в length символов, кодируются без перемешивания
+// и дают более длинный код.
+func encodeCounter(id uint64, length int) string {
+	space, ok := pow62(length)
+	if !ok || id >= space {
+		return new(big.Int).SetUint64(id).Text(62)
+	}
+
+	hi, lo := bits.Mul64(id, counterMultiplier%space)
+	scrambled := bits.Rem64(hi, lo, space)
+
+	code := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		code[i] = base62Alphabet[scrambled%62]
+		scrambled /= 62
+	}
+
+	return string(code)
+}
+
+// pow62 возвращает 62^n и false, если значение не помещается в uint64
+func pow62(n int) (uint64, bool) {
+	result := uint64(1)
+	for i := 0; i < n; i++ {
+		hi, lo := bits.Mul64(result, 62)
+		if hi != 0 {
+			return 0, false
+		}
+		result = lo
+	}
+	return result, true
+}
+
+//go:embed words/adjectives.txt
+var adjectivesList string
+
+//go:embed words/nouns.txt
+var nounsList string
+
+// WordsShortener генерирует читаемый код вида "brave-otter-427"
+type WordsShortener struct {
+	adjectives []string
+	nouns      []string
+}
+
+// NewWordsShortener создает генератор на встроенных списках слов
+func NewWordsShortener() *WordsShortener {
+	return &WordsShortener{
+		adjectives: strings.Fields(adjectivesList),
+		nouns:      strings.Fields(nounsList),
+	}
+}
+
+// GenerateShortURL возвращает случайные прилагательное, существительное и число
+func (ws *WordsShortener) GenerateShortURL(url string) (string, error) {
+	if url == "" {
+		return "", ErrEmptyURL
+	}
+
+	adjective, err := randomIndex(len(ws.adjectives))
+	if err != nil {
+		return "", err
+	}
+	noun, err := randomIndex(len(ws.nouns))
+	if err != nil {
+		return "", err
+	}
+	number, err := randomIndex(900)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s-%d", ws.adjectives[adjective], ws.nouns[noun], number+100), nil
+}
+
+// randomIndex возвращает случайное число в [0, n)
+func randomIndex(n int) (int, error) {
+	value, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(value.Int64()), nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counterSequence — последовательность в памяти для тестов
+type counterSequence struct {
+	value atomic.Int64
+}
+
+func (c *counterSequence) NextID(ctx context.Context) (int64, error) {
+	return c.value.Add(1), nil
+}
+
+var base62Pattern = regexp.MustCompile(`^[0-9a-zA-Z]+$`)
+
+func TestNewShortenerStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy string
+		opts     StrategyOptions
+		want     Shortener
+		wantErr  bool
+	}{
+		{name: "Default", strategy: "", want: &RandomShortener{}},
+		{name: "Random", strategy: StrategyRandom, want: &RandomShortener{}},
+		{name: "Hash", strategy: StrategyHash, want: &HashShortener{}},
+		{name: "Words", strategy: StrategyWords, want: &WordsShortener{}},
+		{name: "Counter", strategy: StrategyCounter, opts: StrategyOptions{Sequence: &counterSequence{}}, want: &CounterShortener{}},
+		{name: "Counter without sequence", strategy: StrategyCounter, wantErr: true},
+		{name: "Unknown", strategy: "uuid", wantErr: true},
+		{name: "Too short", strategy: StrategyRandom, opts: StrategyOptions{Length: 2}, wantErr: true},
+		{name: "Too long", strategy: StrategyRandom, opts: StrategyOptions{Length: 64}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shortener, err := NewShortenerStrategy(tt.strategy, tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.IsType(t, tt.want, shortener)
+		})
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	const url = "https://example.com"
+
+	t.Run("Random", func(t *testing.T) {
+		shortener := &RandomShortener{Length: 12}
+
+		seen := make(map[string]struct{})
+		for i := 0; i < 100; i++ {
+			code, err := shortener.GenerateShortURL(url)
+			require.NoError(t, err)
+			assert.Len(t, code, 12)
+			assert.Regexp(t, base62Pattern, code)
+			seen[code] = struct{}{}
+		}
+		assert.Len(t, seen, 100)
+	})
+
+	t.Run("Hash", func(t *testing.T) {
+		shortener := &HashShortener{Length: 10}
+
+		code1, err := shortener.GenerateShortURL(url)
+		require.NoError(t, err)
+		code2, err := shortener.GenerateShortURL(url)
+		require.NoError(t, err)
+		other, err := shortener.GenerateShortURL(url + "/other")
+		require.NoError(t, err)
+
+		assert.Len(t, code1, 10)
+		assert.Regexp(t, base62Pattern, code1)
+		assert.Equal(t, code1, code2)
+		assert.NotEqual(t, code1, other)
+	})
+
+	t.Run("Counter", func(t *testing.T) {
+		shortener := &CounterShortener{Length: 6, Sequence: &counterSequence{}}
+
+		seen := make(map[string]struct{})
+		for i := 0; i < 1000; i++ {
+			code, err := shortener.GenerateShortURL(url)
+			require.NoError(t, err)
+			assert.Len(t, code, 6)
+			assert.Regexp(t, base62Pattern, code)
+			seen[code] = struct{}{}
+		}
+		assert.Len(t, seen, 1000)
+	})
+
+	t.Run("Counter overflow", func(t *testing.T) {
+		// 62^4 значений помещаются в 4 символа, следующие дают более длинный код
+		space, ok := pow62(4)
+		require.True(t, ok)
+
+		assert.Len(t, encodeCounter(space-1, 4), 4)
+		assert.Len(t, encodeCounter(space, 4), 5)
+		assert.NotEqual(t, encodeCounter(1, 4), encodeCounter(2, 4))
+	})
+
+	t.Run("Words", func(t *testing.T) {
+		shortener := NewWordsShortener()
+
+		code, err := shortener.GenerateShortURL(url)
+		require.NoError(t, err)
+		assert.Regexp(t, `^[a-z]+-[a-z]+-[1-9][0-9]{2}$`, code)
+		assert.LessOrEqual(t, len(code), MaxShortURLLength)
+	})
+
+	t.Run("Empty URL", func(t *testing.T) {
+		for _, shortener := range []Shortener{
+			&RandomShortener{},
+			&HashShortener{},
+			&CounterShortener{Sequence: &counterSequence{}},
+			NewWordsShortener(),
+		} {
+			_, err := shortener.GenerateShortURL("")
+			assert.ErrorIs(t, err, ErrEmptyURL)
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store"
+)
+
+// FilterDeleted возвращает действующие ссылки пользователя,
+// а при deleted — только удаленные, то есть корзину
+func FilterDeleted(urls []models.UserURLResponse, deleted bool) []models.UserURLResponse {
+	filtered := make([]models.UserURLResponse, 0, len(urls))
+	for _, url := range urls {
+		if (url.DeletedAt != nil) == deleted {
+			filtered = append(filtered, url)
+		}
+	}
+
+	return filtered
+}
+
+// RestoreUserURLs восстанавливает ссылки пользователя, удаленные не раньше
+// gracePeriod назад, и возвращает восстановленные короткие URL.
+// Чужие, действующие и неизвестные ссылки пропускаются.
+func RestoreUserURLs(ctx context.Context, s store.Store, userID uuid.UUID, shortURLs []string, gracePeriod time.Duration) ([]string, error) {
+	if len(shortURLs) == 0 {
+		return []string{}, nil
+	}
+
+	return s.RestoreUserURLs(ctx, userID, shortURLs, time.Now().Add(-gracePeriod))
+}
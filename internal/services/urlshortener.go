@@ -4,58 +4,106 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"net/url"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/learies/goShortener/internal/models"
 	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
+// ErrURLExpired is returned for a short URL whose expiry time has passed
+var ErrURLExpired = errors.New("URL has expired")
+
+// DeletionQueue deletes user URLs in the background
+type DeletionQueue interface {
+	Enqueue(ctx context.Context, urls ...models.UserShortURL) error
+}
+
 // URLShortenerService provides business logic for URL shortening operations
 type URLShortenerService struct {
-	store   store.Store
-	baseURL string
+	store      store.Store
+	baseURL    string
+	shortener  Shortener
+	deletions  DeletionQueue
+	passwords  *PasswordLimiter
+	normalizer *URLNormalizer
+	policy     *URLPolicy
 }
 
-// NewURLShortenerService creates a new URLShortenerService instance
-func NewURLShortenerService(store store.Store, baseURL string) *URLShortenerService {
+// NewURLShortenerService creates a new URLShortenerService instance.
+// Short URLs are generated by the given strategy. Deleted URLs are queued to
+// deletions, or deleted synchronously if deletions is nil. Failed password
+// attempts on protected links are limited by passwords, which may be nil.
+// Original URLs are brought to their canonical form by normalizer; a nil
+// normalizer only validates them. URLs rejected by policy are not shortened,
+// a nil policy allows any URL.
+func NewURLShortenerService(store store.Store, baseURL string, shortener Shortener, deletions DeletionQueue, passwords *PasswordLimiter, normalizer *URLNormalizer, policy *URLPolicy) *URLShortenerService {
 	return &URLShortenerService{
-		store:   store,
-		baseURL: baseURL,
+		store:      store,
+		baseURL:    baseURL,
+		shortener:  shortener,
+		deletions:  deletions,
+		passwords:  passwords,
+		normalizer: normalizer,
+		policy:     policy,
 	}
 }
 
 // GenerateShortURL implements the Shortener interface
 func (s *URLShortenerService) GenerateShortURL(urlStr string) (string, error) {
-	if urlStr == "" {
-		return "", ErrEmptyURL
+	if err := validateURL(urlStr); err != nil {
+		return "", err
 	}
 
-	if _, err := url.Parse(urlStr); err != nil {
-		return "", fmt.Errorf("invalid URL: %w", err)
-	}
+	return s.shortener.GenerateShortURL(urlStr)
+}
 
-	shortURL := uuid.New().String()[:8]
-	return shortURL, nil
+// validateURL checks that the URL is an absolute http or https URL with a valid host
+func validateURL(urlStr string) error {
+	_, err := (*URLNormalizer)(nil).Normalize(urlStr)
+	return err
 }
 
-// CreateShortURL creates a short URL for the given original URL
-func (s *URLShortenerService) CreateShortURL(ctx context.Context, originalURL string, userID uuid.UUID) (string, error) {
-	shortURL, err := s.GenerateShortURL(originalURL)
+// CreateShortURL creates a short URL for the given original URL.
+// A non-empty alias is used as the short URL instead of a generated one.
+// The original URL is stored in its canonical form; a URL rejected by the
+// policy returns a *PolicyViolation. If the URL is already
+// shortened, it returns the existing short URL together with an error
+// wrapping *storeerr.ErrConflict.
+func (s *URLShortenerService) CreateShortURL(ctx context.Context, originalURL, alias string, userID uuid.UUID, opts ...models.LinkOption) (string, error) {
+	originalURL, err := s.normalizer.Normalize(originalURL)
 	if err != nil {
 		return "", err
 	}
+	if err := s.policy.Check(originalURL); err != nil {
+		return "", err
+	}
 
-	if err := s.store.Add(ctx, shortURL, originalURL, userID); err != nil {
+	shortURL := alias
+	if alias != "" {
+		err = ShortenAlias(ctx, s.store, alias, originalURL, userID, opts...)
+	} else {
+		shortURL, err = Shorten(ctx, s.store, s, originalURL, userID, opts...)
+	}
+	if err != nil {
+		var conflict *storeerr.ErrConflict
+		if errors.As(err, &conflict) {
+			return fmt.Sprintf("%s/%s", s.baseURL, conflict.ShortURL), err
+		}
 		return "", fmt.Errorf("failed to store URL: %w", err)
 	}
 
 	return fmt.Sprintf("%s/%s", s.baseURL, shortURL), nil
 }
 
-// GetOriginalURL retrieves the original URL for a given short URL
-func (s *URLShortenerService) GetOriginalURL(ctx context.Context, shortURL string) (string, error) {
+// GetOriginalURL retrieves the original URL for a given short URL.
+// Protected links require their password; failed attempts are limited per
+// client IP on each link and, by a looser bound, per link. Every call uses one redirect of a link with a click
+// limit and fails with storeerr.ErrLinkExhausted once they are used up.
+func (s *URLShortenerService) GetOriginalURL(ctx context.Context, shortURL, password, ip string) (string, error) {
 	store, err := s.store.Get(ctx, shortURL)
 	if err != nil {
 		return "", fmt.Errorf("failed to get URL: %w", err)
@@ -65,25 +113,38 @@ func (s *URLShortenerService) GetOriginalURL(ctx context.Context, shortURL strin
 		return "", errors.New("URL has been deleted")
 	}
 
+	now := time.Now()
+	if store.Expired(now) {
+		return "", ErrURLExpired
+	}
+
+	if _, err := s.passwords.Unlock(store, password, ip, now); err != nil {
+		return "", err
+	}
+
+	if store.MaxClicks > 0 {
+		if _, err := s.store.ConsumeClick(ctx, shortURL); err != nil {
+			return "", err
+		}
+	}
+
 	return store.OriginalURL, nil
 }
 
-// CreateBatchShortURL creates multiple short URLs in batch
+// CreateBatchShortURL creates multiple short URLs in batch.
+// The original URLs are stored in their canonical form; if the policy
+// rejects any of them, nothing is stored.
 func (s *URLShortenerService) CreateBatchShortURL(ctx context.Context, batchRequest []models.ShortenBatchRequest, userID uuid.UUID) ([]models.ShortenBatchResponse, error) {
-	batchStore := make([]models.ShortenBatchStore, len(batchRequest))
-	for i, req := range batchRequest {
-		shortURL, err := s.GenerateShortURL(req.OriginalURL)
-		if err != nil {
-			return nil, err
-		}
-		batchStore[i] = models.ShortenBatchStore{
-			CorrelationID: req.CorrelationID,
-			OriginalURL:   req.OriginalURL,
-			ShortURL:      shortURL,
-		}
+	batchRequest, err := s.normalizer.NormalizeBatch(batchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.policy.CheckBatch(batchRequest); err != nil {
+		return nil, err
 	}
 
-	if err := s.store.AddBatch(ctx, batchStore, userID); err != nil {
+	batchStore, err := ShortenBatch(ctx, s.store, s, batchRequest, userID)
+	if err != nil {
 		return nil, fmt.Errorf("failed to store batch URLs: %w", err)
 	}
 
@@ -104,12 +165,16 @@ func (s *URLShortenerService) GetUserURLs(ctx context.Context, userID uuid.UUID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user URLs: %w", err)
 	}
+	urls = FilterDeleted(urls, false)
 
 	response := make([]models.UserURLResponse, len(urls))
 	for i, url := range urls {
 		response[i] = models.UserURLResponse{
 			ShortURL:    fmt.Sprintf("%s/%s", s.baseURL, url.ShortURL),
 			OriginalURL: url.OriginalURL,
+			ExpiresAt:   url.ExpiresAt,
+			Protected:   url.Protected,
+			ClicksLeft:  url.ClicksLeft,
 		}
 	}
 
@@ -118,13 +183,25 @@ func (s *URLShortenerService) GetUserURLs(ctx context.Context, userID uuid.UUID)
 
 // DeleteUserURLs deletes URLs created by a user
 func (s *URLShortenerService) DeleteUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string) error {
-	urlChan := make(chan models.UserShortURL, len(shortURLs))
-	for _, shortURL := range shortURLs {
-		urlChan <- models.UserShortURL{
+	urls := make([]models.UserShortURL, len(shortURLs))
+	for i, shortURL := range shortURLs {
+		urls[i] = models.UserShortURL{
 			UserID:   userID,
 			ShortURL: shortURL,
 		}
 	}
+
+	if s.deletions != nil {
+		if err := s.deletions.Enqueue(ctx, urls...); err != nil {
+			return fmt.Errorf("failed to queue user URLs for deletion: %w", err)
+		}
+		return nil
+	}
+
+	urlChan := make(chan models.UserShortURL, len(urls))
+	for _, userShortURL := range urls {
+		urlChan <- userShortURL
+	}
 	close(urlChan)
 
 	if err := s.store.DeleteUserURLs(ctx, urlChan); err != nil {
@@ -135,6 +212,13 @@ func (s *URLShortenerService) DeleteUserURLs(ctx context.Context, userID uuid.UU
 }
 
 // GetStats retrieves service statistics
-func (s *URLShortenerService) GetStats(ctx context.Context) (int, int, error) {
+func (s *URLShortenerService) GetStats(ctx context.Context) (models.Stats, error) {
 	return s.store.GetStats(ctx)
 }
+
+// GetLinkStats retrieves click analytics for a link owned by the user.
+// The short URL may be given either as the bare key or as the full short link.
+func (s *URLShortenerService) GetLinkStats(ctx context.Context, shortURL string, userID uuid.UUID, opts LinkStatsOptions) (models.LinkStats, error) {
+	shortURL = strings.TrimPrefix(shortURL, s.baseURL+"/")
+	return GetLinkStats(ctx, s.store, shortURL, userID, opts)
+}
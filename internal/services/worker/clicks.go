@@ -0,0 +1,151 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// Default click tracker settings.
+const (
+	DefaultClickQueueSize     = 10000
+	DefaultClickBatchSize     = 500
+	DefaultClickFlushInterval = time.Second
+)
+
+// ClickRecorder is implemented by stores that keep click analytics.
+type ClickRecorder interface {
+	RecordClicks(ctx context.Context, clicks []models.Click) error
+}
+
+// ClickTrackerOptions configures a ClickTracker. Zero values select the defaults.
+type ClickTrackerOptions struct {
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ClickTracker records clicks in the background, so redirects do not wait
+// for the store. Clicks are written in batches when BatchSize clicks are
+// collected or FlushInterval passes. When the queue is full new clicks are
+// dropped and counted.
+type ClickTracker struct {
+	recorder ClickRecorder
+	opts     ClickTrackerOptions
+	queue    chan models.Click
+	dropped  atomic.Int64
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewClickTracker creates a tracker and starts its background writer.
+func NewClickTracker(recorder ClickRecorder, opts ClickTrackerOptions) *ClickTracker {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultClickQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultClickBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultClickFlushInterval
+	}
+
+	t := &ClickTracker{
+		recorder: recorder,
+		opts:     opts,
+		queue:    make(chan models.Click, opts.QueueSize),
+		done:     make(chan struct{}),
+	}
+	t.wg.Add(1)
+	go t.run()
+
+	return t
+}
+
+// Track queues the click without blocking. It reports false if the click was dropped.
+func (t *ClickTracker) Track(click models.Click) bool {
+	select {
+	case <-t.done:
+		t.dropped.Add(1)
+		return false
+	default:
+	}
+
+	select {
+	case t.queue <- click:
+		return true
+	default:
+		t.dropped.Add(1)
+		return false
+	}
+}
+
+// Dropped returns the number of clicks dropped because the queue was full.
+func (t *ClickTracker) Dropped() int64 {
+	return t.dropped.Load()
+}
+
+// Close stops accepting clicks, writes the queued ones and waits for the writer.
+// It is safe to call Close more than once.
+func (t *ClickTracker) Close() {
+	t.closeOnce.Do(func() {
+		close(t.done)
+		t.wg.Wait()
+	})
+}
+
+// run collects clicks into batches and writes them to the recorder.
+func (t *ClickTracker) run() {
+	defer t.wg.Done()
+
+	ticker := time.NewTicker(t.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]models.Click, 0, t.opts.BatchSize)
+	for {
+		select {
+		case click := <-t.queue:
+			batch = append(batch, click)
+			if len(batch) >= t.opts.BatchSize {
+				batch = t.flush(batch)
+			}
+		case <-ticker.C:
+			batch = t.flush(batch)
+		case <-t.done:
+			// Дописываем клики, оставшиеся в очереди
+			for {
+				select {
+				case click := <-t.queue:
+					batch = append(batch, click)
+					if len(batch) >= t.opts.BatchSize {
+						batch = t.flush(batch)
+					}
+				default:
+					t.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush writes the batch and returns a new empty batch.
+// Failed batches are logged and dropped, so a broken store does not block redirects.
+func (t *ClickTracker) flush(batch []models.Click) []models.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := t.recorder.RecordClicks(context.Background(), batch); err != nil {
+		logger.Log.Error("Failed to record clicks", "count", len(batch), "error", err)
+		t.dropped.Add(int64(len(batch)))
+	}
+
+	return make([]models.Click, 0, t.opts.BatchSize)
+}
@@ -0,0 +1,218 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/models"
+)
+
+// Default deleter settings.
+const (
+	DefaultDeleteQueueSize     = 10000
+	DefaultDeleteBatchSize     = 100
+	DefaultDeleteWorkers       = 2
+	DefaultDeleteFlushInterval = 500 * time.Millisecond
+	DefaultDeleteFlushTimeout  = 10 * time.Second
+)
+
+// ErrDeleterClosed is returned when URLs are queued after the deleter was closed.
+var ErrDeleterClosed = errors.New("deleter is closed")
+
+// URLDeleter is implemented by stores that mark user URLs as deleted.
+type URLDeleter interface {
+	DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error
+}
+
+// DeleterOptions configures a Deleter. Zero values select the defaults.
+type DeleterOptions struct {
+	QueueSize     int
+	BatchSize     int
+	Workers       int
+	FlushInterval time.Duration
+	// FlushTimeout limits the write of one batch, so a stuck store
+	// cannot block Close
+	FlushTimeout time.Duration
+}
+
+// DeleterStats describes the state of the deletion queue.
+type DeleterStats struct {
+	// QueueDepth is the number of URLs waiting for a worker
+	QueueDepth int
+	// Lag is how long the oldest URL of the last written batch waited in the queue
+	Lag time.Duration
+	// Deleted and Failed count the URLs passed to the store and the URLs of failed batches
+	Deleted int64
+	Failed  int64
+}
+
+// deleteTask is a queued URL with the time it was queued.
+type deleteTask struct {
+	url      models.UserShortURL
+	enqueued time.Time
+}
+
+// Deleter deletes user URLs in the background. URLs from all requests are
+// collected in a bounded queue and written by workers in batches of BatchSize
+// URLs or every FlushInterval, whichever comes first.
+type Deleter struct {
+	store URLDeleter
+	opts  DeleterOptions
+	queue chan deleteTask
+
+	lag     atomic.Int64
+	deleted atomic.Int64
+	failed  atomic.Int64
+
+	// mu keeps Enqueue from adding URLs after the workers started draining
+	mu     sync.RWMutex
+	closed bool
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewDeleter creates a deleter and starts its workers.
+func NewDeleter(store URLDeleter, opts DeleterOptions) *Deleter {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = DefaultDeleteQueueSize
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultDeleteBatchSize
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = DefaultDeleteWorkers
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = DefaultDeleteFlushInterval
+	}
+	if opts.FlushTimeout <= 0 {
+		opts.FlushTimeout = DefaultDeleteFlushTimeout
+	}
+
+	d := &Deleter{
+		store: store,
+		opts:  opts,
+		queue: make(chan deleteTask, opts.QueueSize),
+		done:  make(chan struct{}),
+	}
+	d.wg.Add(opts.Workers)
+	for i := 0; i < opts.Workers; i++ {
+		go d.run()
+	}
+
+	return d
+}
+
+// Enqueue queues the URLs for deletion. It blocks while the queue is full
+// and returns the context error if the context is done first, in which case
+// only part of the URLs may have been queued.
+func (d *Deleter) Enqueue(ctx context.Context, urls ...models.UserShortURL) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return ErrDeleterClosed
+	}
+
+	now := time.Now()
+	for _, url := range urls {
+		select {
+		case d.queue <- deleteTask{url: url, enqueued: now}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the current queue metrics.
+func (d *Deleter) Stats() DeleterStats {
+	return DeleterStats{
+		QueueDepth: len(d.queue),
+		Lag:        time.Duration(d.lag.Load()),
+		Deleted:    d.deleted.Load(),
+		Failed:     d.failed.Load(),
+	}
+}
+
+// Close stops accepting URLs, deletes the queued ones and waits for the workers.
+// It is safe to call Close more than once.
+func (d *Deleter) Close() {
+	d.mu.Lock()
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// run collects URLs into batches and deletes them.
+func (d *Deleter) run() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(d.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]deleteTask, 0, d.opts.BatchSize)
+	for {
+		select {
+		case task := <-d.queue:
+			batch = append(batch, task)
+			if len(batch) >= d.opts.BatchSize {
+				batch = d.flush(batch)
+			}
+		case <-ticker.C:
+			batch = d.flush(batch)
+		case <-d.done:
+			// Новые URL больше не поступают, дочищаем очередь
+			for {
+				select {
+				case task := <-d.queue:
+					batch = append(batch, task)
+					if len(batch) >= d.opts.BatchSize {
+						batch = d.flush(batch)
+					}
+				default:
+					d.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+// flush deletes the batch with a single store call and returns a new empty batch.
+// Failed batches are logged and counted; the user may repeat the request.
+func (d *Deleter) flush(batch []deleteTask) []deleteTask {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	urls := make([]models.UserShortURL, len(batch))
+	oldest := batch[0].enqueued
+	for i, task := range batch {
+		urls[i] = task.url
+		if task.enqueued.Before(oldest) {
+			oldest = task.enqueued
+		}
+	}
+	d.lag.Store(int64(time.Since(oldest)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.FlushTimeout)
+	defer cancel()
+	if err := d.store.DeleteUserURLs(ctx, DeleteUserURLs(urls...)); err != nil {
+		logger.Log.Error("Failed to delete user URLs", "count", len(urls), "error", err)
+		d.failed.Add(int64(len(urls)))
+	} else {
+		d.deleted.Add(int64(len(urls)))
+	}
+
+	return make([]deleteTask, 0, d.opts.BatchSize)
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"context"
+	"time"
+
+	"github.com/learies/goShortener/internal/config/logger"
+)
+
+// Purger is implemented by stores that can remove expired and deleted URLs.
+type Purger interface {
+	PurgeExpired(ctx context.Context, before time.Time) (int, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+}
+
+// Reaper periodically removes URLs that expired more than Retention ago and
+// URLs deleted more than DeleteGracePeriod ago. Until then expired URLs stay
+// in the store and are reported as expired, and deleted URLs can be restored.
+type Reaper struct {
+	Purger            Purger
+	Interval          time.Duration
+	Retention         time.Duration
+	DeleteGracePeriod time.Duration
+}
+
+// Run purges expired URLs every Interval until the context is canceled.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Purge(ctx)
+		}
+	}
+}
+
+// Purge removes the URLs that expired before the retention period or were
+// deleted before the grace period and returns their number. Errors are
+// logged, so the next run retries.
+func (r *Reaper) Purge(ctx context.Context) int {
+	now := time.Now()
+
+	expired, err := r.Purger.PurgeExpired(ctx, now.Add(-r.Retention))
+	if err != nil {
+		logger.Log.Error("Failed to purge expired URLs", "error", err)
+		expired = 0
+	} else if expired > 0 {
+		logger.Log.Info("Purged expired URLs", "count", expired)
+	}
+
+	deleted, err := r.Purger.PurgeDeleted(ctx, now.Add(-r.DeleteGracePeriod))
+	if err != nil {
+		logger.Log.Error("Failed to purge deleted URLs", "error", err)
+		deleted = 0
+	} else if deleted > 0 {
+		logger.Log.Info("Purged deleted URLs", "count", deleted)
+	}
+
+	return expired + deleted
+}
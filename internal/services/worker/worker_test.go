@@ -1,14 +1,29 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config/logger"
 
 	"github.com/learies/goShortener/internal/models"
 )
 
+func init() {
+	// Инициализация логгера для тестов
+	logger.Log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
 func TestDeleteUserURLs(t *testing.T) {
 	testData := []models.UserShortURL{
 		{UserID: uuid.New(), ShortURL: "short1"},
@@ -25,3 +40,326 @@ func TestDeleteUserURLs(t *testing.T) {
 
 	assert.ElementsMatch(t, testData, result, "The input and output of DeleteUserURLs should match")
 }
+
+type fakePurger struct {
+	mu             sync.Mutex
+	befores        []time.Time
+	deletedBefores []time.Time
+	err            error
+}
+
+func (p *fakePurger) PurgeExpired(_ context.Context, before time.Time) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.befores = append(p.befores, before)
+	return 2, p.err
+}
+
+func (p *fakePurger) PurgeDeleted(_ context.Context, before time.Time) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deletedBefores = append(p.deletedBefores, before)
+	return 3, p.err
+}
+
+func (p *fakePurger) calls() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.befores)
+}
+
+func TestReaper(t *testing.T) {
+	t.Run("Purge uses retention and grace period", func(t *testing.T) {
+		purger := &fakePurger{}
+		reaper := &Reaper{Purger: purger, Interval: time.Hour, Retention: time.Hour, DeleteGracePeriod: 24 * time.Hour}
+
+		assert.Equal(t, 5, reaper.Purge(context.Background()))
+		require.Len(t, purger.befores, 1)
+		assert.WithinDuration(t, time.Now().Add(-time.Hour), purger.befores[0], time.Second)
+		require.Len(t, purger.deletedBefores, 1)
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), purger.deletedBefores[0], time.Second)
+	})
+
+	t.Run("Purge error", func(t *testing.T) {
+		purger := &fakePurger{err: errors.New("db is down")}
+		reaper := &Reaper{Purger: purger, Interval: time.Hour}
+
+		assert.Zero(t, reaper.Purge(context.Background()))
+	})
+
+	t.Run("Run stops with context", func(t *testing.T) {
+		purger := &fakePurger{}
+		reaper := &Reaper{Purger: purger, Interval: time.Millisecond}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			reaper.Run(ctx)
+			close(done)
+		}()
+
+		assert.Eventually(t, func() bool { return purger.calls() > 0 }, time.Second, time.Millisecond)
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("reaper did not stop")
+		}
+	})
+}
+
+type fakeRecorder struct {
+	mu      sync.Mutex
+	batches [][]models.Click
+	block   chan struct{}
+	err     error
+}
+
+func (r *fakeRecorder) RecordClicks(_ context.Context, clicks []models.Click) error {
+	if r.block != nil {
+		<-r.block
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, clicks)
+	return r.err
+}
+
+func (r *fakeRecorder) recorded() (batches, clicks int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, batch := range r.batches {
+		clicks += len(batch)
+	}
+	return len(r.batches), clicks
+}
+
+func TestClickTracker(t *testing.T) {
+	click := models.Click{ShortURL: "short1", Time: time.Now()}
+
+	t.Run("Flush by batch size", func(t *testing.T) {
+		recorder := &fakeRecorder{}
+		tracker := NewClickTracker(recorder, ClickTrackerOptions{BatchSize: 2, FlushInterval: time.Hour})
+		defer tracker.Close()
+
+		for i := 0; i < 4; i++ {
+			require.True(t, tracker.Track(click))
+		}
+		assert.Eventually(t, func() bool {
+			batches, clicks := recorder.recorded()
+			return batches == 2 && clicks == 4
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Flush by interval", func(t *testing.T) {
+		recorder := &fakeRecorder{}
+		tracker := NewClickTracker(recorder, ClickTrackerOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+		defer tracker.Close()
+
+		require.True(t, tracker.Track(click))
+		assert.Eventually(t, func() bool {
+			_, clicks := recorder.recorded()
+			return clicks == 1
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Close drains the queue", func(t *testing.T) {
+		recorder := &fakeRecorder{}
+		tracker := NewClickTracker(recorder, ClickTrackerOptions{BatchSize: 100, FlushInterval: time.Hour})
+
+		for i := 0; i < 10; i++ {
+			require.True(t, tracker.Track(click))
+		}
+		tracker.Close()
+		tracker.Close()
+
+		_, clicks := recorder.recorded()
+		assert.Equal(t, 10, clicks)
+
+		// После закрытия клики не принимаются
+		assert.False(t, tracker.Track(click))
+		assert.Equal(t, int64(1), tracker.Dropped())
+	})
+
+	t.Run("Drop when the queue is full", func(t *testing.T) {
+		recorder := &fakeRecorder{block: make(chan struct{})}
+		tracker := NewClickTracker(recorder, ClickTrackerOptions{QueueSize: 1, BatchSize: 1, FlushInterval: time.Hour})
+
+		// Первый клик забирает писатель и блокируется на записи, второй занимает очередь
+		require.True(t, tracker.Track(click))
+		require.Eventually(t, func() bool { return len(tracker.queue) == 0 }, time.Second, time.Millisecond)
+		require.True(t, tracker.Track(click))
+		assert.False(t, tracker.Track(click))
+		assert.Equal(t, int64(1), tracker.Dropped())
+
+		close(recorder.block)
+		tracker.Close()
+		_, clicks := recorder.recorded()
+		assert.Equal(t, 2, clicks)
+	})
+
+	t.Run("Failed batches are counted as dropped", func(t *testing.T) {
+		recorder := &fakeRecorder{err: errors.New("store is down")}
+		tracker := NewClickTracker(recorder, ClickTrackerOptions{})
+
+		require.True(t, tracker.Track(click))
+		tracker.Close()
+		assert.Equal(t, int64(1), tracker.Dropped())
+	})
+}
+
+type fakeURLDeleter struct {
+	mu      sync.Mutex
+	batches [][]models.UserShortURL
+	block   chan struct{}
+	err     error
+}
+
+func (d *fakeURLDeleter) DeleteUserURLs(_ context.Context, userShortURLs <-chan models.UserShortURL) error {
+	if d.block != nil {
+		<-d.block
+	}
+	var batch []models.UserShortURL
+	for userShortURL := range userShortURLs {
+		batch = append(batch, userShortURL)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.batches = append(d.batches, batch)
+	return d.err
+}
+
+func (d *fakeURLDeleter) deleted() (batches, urls int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, batch := range d.batches {
+		urls += len(batch)
+	}
+	return len(d.batches), urls
+}
+
+// stuckURLDeleter never finishes a deletion before its context ends
+type stuckURLDeleter struct{}
+
+func (stuckURLDeleter) DeleteUserURLs(ctx context.Context, _ <-chan models.UserShortURL) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDeleter(t *testing.T) {
+	ctx := context.Background()
+	userURL := func(i int) models.UserShortURL {
+		return models.UserShortURL{UserID: uuid.New(), ShortURL: fmt.Sprintf("short%d", i)}
+	}
+
+	t.Run("Flush by batch size", func(t *testing.T) {
+		store := &fakeURLDeleter{}
+		deleter := NewDeleter(store, DeleterOptions{BatchSize: 3, Workers: 1, FlushInterval: time.Hour})
+		defer deleter.Close()
+
+		require.NoError(t, deleter.Enqueue(ctx, userURL(1), userURL(2), userURL(3)))
+		assert.Eventually(t, func() bool {
+			batches, urls := store.deleted()
+			return batches == 1 && urls == 3
+		}, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Flush by interval", func(t *testing.T) {
+		store := &fakeURLDeleter{}
+		deleter := NewDeleter(store, DeleterOptions{BatchSize: 100, FlushInterval: 10 * time.Millisecond})
+		defer deleter.Close()
+
+		require.NoError(t, deleter.Enqueue(ctx, userURL(1)))
+		assert.Eventually(t, func() bool {
+			_, urls := store.deleted()
+			return urls == 1
+		}, time.Second, 5*time.Millisecond)
+		assert.Greater(t, deleter.Stats().Lag, time.Duration(0))
+	})
+
+	t.Run("Fan-in from concurrent requests", func(t *testing.T) {
+		store := &fakeURLDeleter{}
+		deleter := NewDeleter(store, DeleterOptions{QueueSize: 10, BatchSize: 7, Workers: 3})
+
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, deleter.Enqueue(ctx, userURL(i), userURL(i+100)))
+			}(i)
+		}
+		wg.Wait()
+		deleter.Close()
+
+		_, urls := store.deleted()
+		assert.Equal(t, 40, urls)
+		stats := deleter.Stats()
+		assert.Equal(t, int64(40), stats.Deleted)
+		assert.Zero(t, stats.QueueDepth)
+	})
+
+	t.Run("Close drains the queue", func(t *testing.T) {
+		store := &fakeURLDeleter{}
+		deleter := NewDeleter(store, DeleterOptions{BatchSize: 100, FlushInterval: time.Hour})
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, deleter.Enqueue(ctx, userURL(i)))
+		}
+		deleter.Close()
+		deleter.Close()
+
+		_, urls := store.deleted()
+		assert.Equal(t, 10, urls)
+		assert.ErrorIs(t, deleter.Enqueue(ctx, userURL(11)), ErrDeleterClosed)
+	})
+
+	t.Run("Enqueue waits for a free slot", func(t *testing.T) {
+		store := &fakeURLDeleter{block: make(chan struct{})}
+		deleter := NewDeleter(store, DeleterOptions{QueueSize: 1, BatchSize: 1, Workers: 1})
+
+		// Первый URL забирает заблокированный воркер, второй занимает очередь
+		require.NoError(t, deleter.Enqueue(ctx, userURL(1)))
+		require.Eventually(t, func() bool { return deleter.Stats().QueueDepth == 0 }, time.Second, time.Millisecond)
+		require.NoError(t, deleter.Enqueue(ctx, userURL(2)))
+		assert.Equal(t, 1, deleter.Stats().QueueDepth)
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, deleter.Enqueue(timeoutCtx, userURL(3)), context.DeadlineExceeded)
+
+		close(store.block)
+		deleter.Close()
+		_, urls := store.deleted()
+		assert.Equal(t, 2, urls)
+	})
+
+	t.Run("Failed batches are counted", func(t *testing.T) {
+		store := &fakeURLDeleter{err: errors.New("store is down")}
+		deleter := NewDeleter(store, DeleterOptions{})
+
+		require.NoError(t, deleter.Enqueue(ctx, userURL(1), userURL(2)))
+		deleter.Close()
+
+		stats := deleter.Stats()
+		assert.Equal(t, int64(2), stats.Failed)
+		assert.Zero(t, stats.Deleted)
+	})
+
+	t.Run("Close does not hang on a stuck store", func(t *testing.T) {
+		deleter := NewDeleter(stuckURLDeleter{}, DeleterOptions{FlushTimeout: 20 * time.Millisecond})
+		require.NoError(t, deleter.Enqueue(ctx, userURL(1)))
+
+		closed := make(chan struct{})
+		go func() {
+			deleter.Close()
+			close(closed)
+		}()
+		select {
+		case <-closed:
+		case <-time.After(time.Second):
+			t.Fatal("Close is blocked by the store")
+		}
+		assert.Equal(t, int64(1), deleter.Stats().Failed)
+	})
+}
@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRU(t *testing.T) {
+	t.Run("Get and Set", func(t *testing.T) {
+		c := NewLRU[string, int](2)
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+
+		c.Set("a", 1, 0)
+		value, ok := c.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+
+		// Повторная запись заменяет значение
+		c.Set("a", 2, 0)
+		value, _ = c.Get("a")
+		assert.Equal(t, 2, value)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		c := NewLRU[string, int](2)
+		c.Set("a", 1, 0)
+		c.Set("b", 2, 0)
+
+		// Обращение к "a" делает ее недавно использованной, поэтому вытесняется "b"
+		c.Get("a")
+		c.Set("c", 3, 0)
+
+		_, ok := c.Get("b")
+		assert.False(t, ok)
+		_, ok = c.Get("a")
+		assert.True(t, ok)
+		_, ok = c.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("Expires entries", func(t *testing.T) {
+		now := time.Now()
+		c := NewLRU[string, int](2)
+		c.now = func() time.Time { return now }
+
+		c.Set("a", 1, time.Minute)
+		c.Set("b", 2, 0)
+
+		now = now.Add(time.Minute)
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		_, ok = c.Get("b")
+		assert.True(t, ok)
+		assert.Equal(t, 1, c.Len())
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		c := NewLRU[string, int](2)
+		c.Set("a", 1, 0)
+		c.Remove("a")
+		c.Remove("missing")
+
+		_, ok := c.Get("a")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("Concurrent access", func(t *testing.T) {
+		c := NewLRU[string, int](16)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					key := fmt.Sprintf("key%d", j%32)
+					c.Set(key, j, time.Minute)
+					c.Get(key)
+					if j%10 == 0 {
+						c.Remove(key)
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.LessOrEqual(t, c.Len(), 16)
+	})
+}
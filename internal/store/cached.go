@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/cache"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// CacheOptions настраивает кэш перед хранилищем
+type CacheOptions struct {
+	// Size — максимальное число коротких URL в кэше
+	Size int
+	// TTL — время жизни найденной записи; 0 означает без ограничения
+	TTL time.Duration
+	// NegativeTTL — время жизни отсутствующей записи; 0 отключает негативное кэширование
+	NegativeTTL time.Duration
+}
+
+// CacheStats содержит счетчики кэша
+type CacheStats struct {
+	Hits   int64
+	Misses int64
+	Size   int
+}
+
+// cacheEntry хранит запись или признак ее отсутствия
+type cacheEntry struct {
+	record   models.ShortenStore
+	notFound bool
+}
+
+// CachedStore — декоратор, кэширующий Get в LRU.
+// Остальные методы передаются обернутому хранилищу, а изменяющие
+// методы сбрасывают затронутые записи кэша.
+type CachedStore struct {
+	Store
+	cache  *cache.LRU[string, cacheEntry]
+	opts   CacheOptions
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// NewCachedStore оборачивает хранилище кэшем
+func NewCachedStore(s Store, opts CacheOptions) *CachedStore {
+	return &CachedStore{
+		Store: s,
+		cache: cache.NewLRU[string, cacheEntry](opts.Size),
+		opts:  opts,
+	}
+}
+
+// Get возвращает запись из кэша, а при промахе читает ее из хранилища
+func (c *CachedStore) Get(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+	if entry, ok := c.cache.Get(shortURL); ok {
+		c.hits.Add(1)
+		if entry.notFound {
+			return models.ShortenStore{}, storeerr.ErrURLNotFound
+		}
+		return entry.record, nil
+	}
+	c.misses.Add(1)
+
+	record, err := c.Store.Get(ctx, shortURL)
+	switch {
+	case errors.Is(err, storeerr.ErrURLNotFound):
+		if c.opts.NegativeTTL > 0 {
+			c.cache.Set(shortURL, cacheEntry{notFound: true}, c.opts.NegativeTTL)
+		}
+	case err == nil:
+		if ttl, ok := c.recordTTL(record); ok {
+			c.cache.Set(shortURL, cacheEntry{record: record}, ttl)
+		}
+	}
+
+	return record, err
+}
+
+// recordTTL ограничивает время жизни записи в кэше сроком действия ссылки.
+// Истекшие и удаленные ссылки не кэшируются, поэтому их восстановление
+// и окончательное удаление сразу видны читателям.
+func (c *CachedStore) recordTTL(record models.ShortenStore) (time.Duration, bool) {
+	if record.Deleted {
+		return 0, false
+	}
+	if record.ExpiresAt == nil {
+		return c.opts.TTL, true
+	}
+
+	untilExpiry := time.Until(*record.ExpiresAt)
+	if untilExpiry <= 0 {
+		return 0, false
+	}
+	if c.opts.TTL > 0 && c.opts.TTL < untilExpiry {
+		return c.opts.TTL, true
+	}
+
+	return untilExpiry, true
+}
+
+// Add сохраняет URL и сбрасывает негативную запись для него
+func (c *CachedStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+	err := c.Store.Add(ctx, shortURL, originalURL, userID, opts...)
+	c.cache.Remove(shortURL)
+	return err
+}
+
+// AddBatch сохраняет пакет URL и сбрасывает негативные записи для них
+func (c *CachedStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
+	err := c.Store.AddBatch(ctx, batchRequest, userID)
+	for _, request := range batchRequest {
+		c.cache.Remove(request.ShortURL)
+	}
+	return err
+}
+
+// DeleteUserURLs удаляет URL и сбрасывает их записи в кэше.
+// Записи сбрасываются после удаления, чтобы параллельный Get
+// не вернул в кэш состояние до удаления.
+func (c *CachedStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
+	var shortURLs []string
+	forwarded := make(chan models.UserShortURL)
+	go func() {
+		defer close(forwarded)
+		for userShortURL := range userShortURLs {
+			shortURLs = append(shortURLs, userShortURL.ShortURL)
+			select {
+			case forwarded <- userShortURL:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	err := c.Store.DeleteUserURLs(ctx, forwarded)
+
+	// Дочитываем канал, если хранилище прервало чтение раньше
+	for range forwarded {
+	}
+	for _, shortURL := range shortURLs {
+		c.cache.Remove(shortURL)
+	}
+
+	return err
+}
+
+// UpdateOriginalURL меняет оригинальный URL и сбрасывает запись в кэше,
+// чтобы переходы сразу вели на новый адрес
+func (c *CachedStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	version, err := c.Store.UpdateOriginalURL(ctx, shortURL, userID, originalURL)
+	c.cache.Remove(shortURL)
+	return version, err
+}
+
+// ConsumeClick расходует переход ссылки и сбрасывает ее запись в кэше,
+// чтобы следующее чтение увидело оставшееся число переходов
+func (c *CachedStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	left, err := c.Store.ConsumeClick(ctx, shortURL)
+	c.cache.Remove(shortURL)
+	return left, err
+}
+
+// MergeUserURLs передает ссылки другому пользователю и сбрасывает их записи
+// в кэше, чтобы проверки владельца видели нового пользователя
+func (c *CachedStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	shortURLs, err := c.Store.MergeUserURLs(ctx, from, to)
+	for _, shortURL := range shortURLs {
+		c.cache.Remove(shortURL)
+	}
+	return shortURLs, err
+}
+
+// CacheStats возвращает счетчики попаданий и промахов
+func (c *CachedStore) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Size:   c.cache.Len(),
+	}
+}
@@ -0,0 +1,184 @@
+package store_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
+	"github.com/learies/goShortener/internal/store/storetest"
+)
+
+// countingStore считает обращения к Get обернутого хранилища
+type countingStore struct {
+	store.Store
+	gets atomic.Int64
+}
+
+func (c *countingStore) Get(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+	c.gets.Add(1)
+	return c.Store.Get(ctx, shortURL)
+}
+
+func TestCachedStoreConformance(t *testing.T) {
+	storetest.Run(t, config.Config{}, func(cfg config.Config) (store.Store, error) {
+		return store.NewCachedStore(memstore.NewMemStore(), store.CacheOptions{
+			Size:        100,
+			TTL:         time.Minute,
+			NegativeTTL: time.Minute,
+		}), nil
+	})
+}
+
+func TestCachedStore(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	newStore := func(opts store.CacheOptions) (*store.CachedStore, *countingStore) {
+		backend := &countingStore{Store: memstore.NewMemStore()}
+		return store.NewCachedStore(backend, opts), backend
+	}
+
+	t.Run("Read through", func(t *testing.T) {
+		cached, backend := newStore(store.CacheOptions{Size: 10, TTL: time.Minute})
+		require.NoError(t, cached.Add(ctx, "short1", "https://example1.com", userID))
+
+		for i := 0; i < 3; i++ {
+			record, err := cached.Get(ctx, "short1")
+			require.NoError(t, err)
+			assert.Equal(t, "https://example1.com", record.OriginalURL)
+		}
+
+		assert.Equal(t, int64(1), backend.gets.Load())
+		assert.Equal(t, store.CacheStats{Hits: 2, Misses: 1, Size: 1}, cached.CacheStats())
+	})
+
+	t.Run("Negative caching", func(t *testing.T) {
+		cached, backend := newStore(store.CacheOptions{Size: 10, NegativeTTL: time.Minute})
+
+		for i := 0; i < 2; i++ {
+			_, err := cached.Get(ctx, "missing")
+			assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+		}
+		assert.Equal(t, int64(1), backend.gets.Load())
+
+		// Добавление сбрасывает негативную запись
+		require.NoError(t, cached.Add(ctx, "missing", "https://example2.com", userID))
+		record, err := cached.Get(ctx, "missing")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example2.com", record.OriginalURL)
+	})
+
+	t.Run("Negative caching disabled", func(t *testing.T) {
+		cached, backend := newStore(store.CacheOptions{Size: 10})
+
+		for i := 0; i < 2; i++ {
+			_, err := cached.Get(ctx, "missing")
+			assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+		}
+		assert.Equal(t, int64(2), backend.gets.Load())
+	})
+
+	t.Run("Batch invalidates negative entries", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 10, NegativeTTL: time.Minute})
+
+		_, err := cached.Get(ctx, "batch1")
+		require.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+		err = cached.AddBatch(ctx, []models.ShortenBatchStore{
+			{CorrelationID: "1", ShortURL: "batch1", OriginalURL: "https://batch1.com"},
+		}, userID)
+		require.NoError(t, err)
+
+		_, err = cached.Get(ctx, "batch1")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Delete invalidates entries", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 10, TTL: time.Minute})
+		require.NoError(t, cached.Add(ctx, "short3", "https://example3.com", userID))
+
+		record, err := cached.Get(ctx, "short3")
+		require.NoError(t, err)
+		require.False(t, record.Deleted)
+
+		err = cached.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: "short3"}))
+		require.NoError(t, err)
+
+		record, err = cached.Get(ctx, "short3")
+		require.NoError(t, err)
+		assert.True(t, record.Deleted)
+	})
+
+	t.Run("Restore is visible immediately", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 10, TTL: time.Minute})
+		require.NoError(t, cached.Add(ctx, "short4", "https://example4.com", userID))
+
+		err := cached.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: "short4"}))
+		require.NoError(t, err)
+		record, err := cached.Get(ctx, "short4")
+		require.NoError(t, err)
+		require.True(t, record.Deleted)
+
+		// Удаленные записи не кэшируются, поэтому восстановление видно сразу
+		restored, err := cached.RestoreUserURLs(ctx, userID, []string{"short4"}, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"short4"}, restored)
+
+		record, err = cached.Get(ctx, "short4")
+		require.NoError(t, err)
+		assert.False(t, record.Deleted)
+	})
+
+	t.Run("Update invalidates entries", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 10, TTL: time.Minute})
+		require.NoError(t, cached.Add(ctx, "short5", "https://example5.com", userID))
+
+		_, err := cached.Get(ctx, "short5")
+		require.NoError(t, err)
+
+		_, err = cached.UpdateOriginalURL(ctx, "short5", userID, "https://example6.com")
+		require.NoError(t, err)
+
+		record, err := cached.Get(ctx, "short5")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example6.com", record.OriginalURL)
+	})
+
+	t.Run("Consume invalidates entries", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 10, TTL: time.Minute})
+		require.NoError(t, cached.Add(ctx, "short6", "https://example7.com", userID, models.WithMaxClicks(1)))
+
+		record, err := cached.Get(ctx, "short6")
+		require.NoError(t, err)
+		require.False(t, record.Exhausted())
+
+		_, err = cached.ConsumeClick(ctx, "short6")
+		require.NoError(t, err)
+
+		record, err = cached.Get(ctx, "short6")
+		require.NoError(t, err)
+		assert.True(t, record.Exhausted())
+	})
+
+	t.Run("Bounded size", func(t *testing.T) {
+		cached, _ := newStore(store.CacheOptions{Size: 2, TTL: time.Minute})
+		for _, shortURL := range []string{"a", "b", "c"} {
+			require.NoError(t, cached.Add(ctx, shortURL, "https://"+shortURL+".com", userID))
+			_, err := cached.Get(ctx, shortURL)
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 2, cached.CacheStats().Size)
+	})
+}
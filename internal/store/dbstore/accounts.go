@@ -0,0 +1,79 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// CreateAccount stores the account in the accounts table. It fails with
+// storeerr.ErrAccountExists if the email or the ID is already registered.
+func (d *DBStore) CreateAccount(ctx context.Context, account models.Account) error {
+	_, err := d.DB.ExecContext(ctx, `
+		INSERT INTO accounts (id, email, password_hash, created_at)
+		VALUES ($1, $2, $3, $4)`,
+		account.ID, account.Email, account.PasswordHash, account.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return storeerr.ErrAccountExists
+	}
+
+	return err
+}
+
+// GetAccount returns the account with the ID.
+func (d *DBStore) GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error) {
+	return d.getAccount(ctx, `SELECT id, email, password_hash, created_at FROM accounts WHERE id = $1`, id)
+}
+
+// GetAccountByEmail returns the account registered with the email.
+func (d *DBStore) GetAccountByEmail(ctx context.Context, email string) (models.Account, error) {
+	return d.getAccount(ctx, `SELECT id, email, password_hash, created_at FROM accounts WHERE email = $1`, email)
+}
+
+// getAccount reads a single account with the query.
+func (d *DBStore) getAccount(ctx context.Context, query string, arg any) (models.Account, error) {
+	var account models.Account
+	err := d.DB.QueryRowContext(ctx, query, arg).Scan(&account.ID, &account.Email, &account.PasswordHash, &account.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Account{}, storeerr.ErrAccountNotFound
+	}
+	if err != nil {
+		return models.Account{}, err
+	}
+	account.CreatedAt = account.CreatedAt.UTC()
+
+	return account, nil
+}
+
+// MergeUserURLs moves all the URLs of the user from, including deleted ones,
+// to the user to and returns their short URLs.
+func (d *DBStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	rows, err := d.DB.QueryContext(ctx, `UPDATE urls SET user_id = $2 WHERE user_id = $1 RETURNING short_url`, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shortURLs []string
+	for rows.Next() {
+		var shortURL string
+		if err := rows.Scan(&shortURL); err != nil {
+			return nil, err
+		}
+		shortURLs = append(shortURLs, shortURL)
+	}
+
+	return shortURLs, rows.Err()
+}
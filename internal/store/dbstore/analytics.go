@@ -0,0 +1,179 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// clickKey identifies a counter of a short URL, such as a time bucket or a referrer.
+type clickKey[T comparable] struct {
+	shortURL string
+	value    T
+}
+
+// RecordClicks aggregates the clicks and adds them to the analytics tables in
+// one transaction. Clicks on unknown short URLs are ignored.
+func (d *DBStore) RecordClicks(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	buckets := make(map[clickKey[time.Time]]int64)
+	referrers := make(map[clickKey[string]]int64)
+	userAgents := make(map[clickKey[string]]int64)
+	visitors := make(map[clickKey[string]]struct{})
+	for _, click := range clicks {
+		buckets[clickKey[time.Time]{click.ShortURL, models.ClickBucketStart(click.Time)}]++
+		if click.Referrer != "" {
+			referrers[clickKey[string]{click.ShortURL, click.Referrer}]++
+		}
+		if click.UserAgent != "" {
+			userAgents[clickKey[string]{click.ShortURL, click.UserAgent}]++
+		}
+		if click.IP != "" {
+			visitors[clickKey[string]{click.ShortURL, click.IP}] = struct{}{}
+		}
+	}
+
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Ссылка могла быть удалена после перехода, поэтому строки вставляются
+	// только для существующих коротких URL
+	for key, count := range buckets {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO link_clicks (short_url, bucket, clicks)
+			SELECT $1::varchar, $2::timestamptz, $3::bigint
+			WHERE EXISTS (SELECT 1 FROM urls WHERE short_url = $1)
+			ON CONFLICT (short_url, bucket) DO UPDATE SET clicks = link_clicks.clicks + EXCLUDED.clicks`,
+			key.shortURL, key.value, count)
+		if err != nil {
+			return err
+		}
+	}
+	for key, count := range referrers {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO link_referrers (short_url, referrer, clicks)
+			SELECT $1::varchar, $2::text, $3::bigint
+			WHERE EXISTS (SELECT 1 FROM urls WHERE short_url = $1)
+			ON CONFLICT (short_url, referrer) DO UPDATE SET clicks = link_referrers.clicks + EXCLUDED.clicks`,
+			key.shortURL, key.value, count)
+		if err != nil {
+			return err
+		}
+	}
+	for key, count := range userAgents {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO link_user_agents (short_url, user_agent, clicks)
+			SELECT $1::varchar, $2::text, $3::bigint
+			WHERE EXISTS (SELECT 1 FROM urls WHERE short_url = $1)
+			ON CONFLICT (short_url, user_agent) DO UPDATE SET clicks = link_user_agents.clicks + EXCLUDED.clicks`,
+			key.shortURL, key.value, count)
+		if err != nil {
+			return err
+		}
+	}
+	for key := range visitors {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO link_visitors (short_url, ip)
+			SELECT $1::varchar, $2::text
+			WHERE EXISTS (SELECT 1 FROM urls WHERE short_url = $1)
+			ON CONFLICT (short_url, ip) DO NOTHING`,
+			key.shortURL, key.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLinkStats returns the click analytics of the short URL with at most top
+// referrers and user agents. A non-positive top returns all of them.
+func (d *DBStore) GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+	var exists int
+	err := d.DB.QueryRowContext(ctx, `SELECT 1 FROM urls WHERE short_url = $1`, shortURL).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.LinkStats{}, storeerr.ErrURLNotFound
+	}
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+
+	stats := models.LinkStats{ShortURL: shortURL}
+
+	rows, err := d.DB.QueryContext(ctx, `SELECT bucket, clicks FROM link_clicks WHERE short_url = $1 ORDER BY bucket`, shortURL)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+	defer rows.Close()
+
+	stats.TimeSeries = []models.ClickBucket{}
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err := rows.Scan(&bucket.Time, &bucket.Clicks); err != nil {
+			return models.LinkStats{}, err
+		}
+		bucket.Time = bucket.Time.UTC()
+		stats.Clicks += bucket.Clicks
+		stats.TimeSeries = append(stats.TimeSeries, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		return models.LinkStats{}, err
+	}
+
+	err = d.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM link_visitors WHERE short_url = $1`, shortURL).Scan(&stats.UniqueVisitors)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+
+	stats.TopReferrers, err = d.topCounts(ctx, `
+		SELECT referrer, clicks FROM link_referrers WHERE short_url = $1
+		ORDER BY clicks DESC, referrer LIMIT $2`, shortURL, top)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+
+	stats.TopUserAgents, err = d.topCounts(ctx, `
+		SELECT user_agent, clicks FROM link_user_agents WHERE short_url = $1
+		ORDER BY clicks DESC, user_agent LIMIT $2`, shortURL, top)
+	if err != nil {
+		return models.LinkStats{}, err
+	}
+
+	return stats, nil
+}
+
+// topCounts runs a query that returns values with their clicks.
+func (d *DBStore) topCounts(ctx context.Context, query, shortURL string, top int) ([]models.ClickCount, error) {
+	// LIMIT NULL возвращает все строки
+	var limit any
+	if top > 0 {
+		limit = top
+	}
+
+	rows, err := d.DB.QueryContext(ctx, query, shortURL, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := []models.ClickCount{}
+	for rows.Next() {
+		var count models.ClickCount
+		if err := rows.Scan(&count.Value, &count.Clicks); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
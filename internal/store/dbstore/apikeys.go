@@ -0,0 +1,89 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// apiKeyColumns are the columns read by scanAPIKey. Scopes are stored as a
+// space-separated list, like the scope of an OAuth token.
+const apiKeyColumns = `id, user_id, name, prefix, hash, scopes, created_at, revoked_at`
+
+// CreateAPIKey stores the API key in the api_keys table.
+func (d *DBStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	_, err := d.DB.ExecContext(ctx, `
+		INSERT INTO api_keys (id, user_id, name, prefix, hash, scopes, created_at, revoked_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, " "), key.CreatedAt, key.RevokedAt)
+	return err
+}
+
+// GetAPIKeyByHash returns the API key with the hash, including a revoked one.
+func (d *DBStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	key, err := scanAPIKey(d.DB.QueryRowContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE hash = $1`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// ListAPIKeys returns the API keys of the user in the order of creation.
+func (d *DBStore) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	rows, err := d.DB.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE user_id = $1 ORDER BY created_at, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey revokes the active API key of the user and returns it.
+func (d *DBStore) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	key, err := scanAPIKey(d.DB.QueryRowContext(ctx, `
+		UPDATE api_keys SET revoked_at = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		RETURNING `+apiKeyColumns,
+		id, userID, at))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// scanAPIKey reads an API key selected with apiKeyColumns.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (models.APIKey, error) {
+	var key models.APIKey
+	var scopes string
+	var revokedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &revokedAt)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.CreatedAt = key.CreatedAt.UTC()
+	if revokedAt.Valid {
+		at := revokedAt.Time.UTC()
+		key.RevokedAt = &at
+	}
+
+	return key, nil
+}
@@ -0,0 +1,20 @@
+package dbstore_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storetest"
+)
+
+// TestConformance runs against a real database when TEST_DATABASE_DSN is set.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	storetest.Run(t, config.Config{DatabaseDSN: dsn}, store.DefaultStoreConstructor)
+}
@@ -4,50 +4,99 @@ package dbstore
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/models"
-	"github.com/learies/goShortener/internal/store/filestore"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
+// uniqueViolation is the Postgres error code of a unique constraint violation.
+const uniqueViolation = "23505"
+
 // DBStore is a struct that represents the database store.
 type DBStore struct {
 	DB *sql.DB
 }
 
 // Add is a method that adds a new URL to the database.
-func (d *DBStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
+func (d *DBStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
 	record := models.ShortenStore{
 		UUID:        uuid.New(),
 		ShortURL:    shortURL,
 		OriginalURL: originalURL,
 		UserID:      userID,
+		LinkOptions: models.NewLinkOptions(opts...),
 	}
 
-	query := `INSERT INTO urls (uuid, short_url, original_url, user_id) VALUES ($1, $2, $3, $4)`
-	_, err := d.DB.ExecContext(ctx, query, record.UUID, record.ShortURL, record.OriginalURL, record.UserID)
+	query := `INSERT INTO urls (uuid, short_url, original_url, user_id, expires_at, redirect_code, title, preview, password_hash, max_clicks) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+	_, err := d.DB.ExecContext(ctx, query, record.UUID, record.ShortURL, record.OriginalURL, record.UserID, record.ExpiresAt, record.RedirectCode, record.Title, record.Preview, record.PasswordHash, record.MaxClicks)
 	if err != nil {
-		return err
+		return d.conflictError(ctx, err, originalURL)
 	}
 
 	return nil
 }
 
+// conflictError converts a unique violation into a store error. If the original
+// URL is already stored, it returns *storeerr.ErrConflict with its short URL,
+// otherwise the short URL is taken and storeerr.ErrURLExists is returned.
+// Other errors are returned unchanged.
+func (d *DBStore) conflictError(ctx context.Context, err error, originalURL string) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolation {
+		return err
+	}
+
+	var shortURL string
+	query := `SELECT short_url FROM urls WHERE original_url = $1`
+	lookupErr := d.DB.QueryRowContext(ctx, query, originalURL).Scan(&shortURL)
+	if errors.Is(lookupErr, sql.ErrNoRows) {
+		return storeerr.ErrURLExists
+	}
+	if lookupErr != nil {
+		return lookupErr
+	}
+
+	return &storeerr.ErrConflict{ShortURL: shortURL}
+}
+
 // Get is a method that retrieves the original URL from the database.
 func (d *DBStore) Get(ctx context.Context, shortURL string) (models.ShortenStore, error) {
-	query := `SELECT original_url, is_deleted FROM urls WHERE short_url = $1`
+	query := `SELECT uuid, short_url, original_url, user_id, is_deleted, deleted_at, expires_at, redirect_code, created_at, title, preview, password_hash, max_clicks, used_clicks FROM urls WHERE short_url = $1`
 
 	shortenStore := models.ShortenStore{}
-
-	err := d.DB.QueryRowContext(ctx, query, shortURL).Scan(&shortenStore.OriginalURL, &shortenStore.Deleted)
+	var deletedAt, expiresAt, createdAt sql.NullTime
+
+	err := d.DB.QueryRowContext(ctx, query, shortURL).Scan(
+		&shortenStore.UUID,
+		&shortenStore.ShortURL,
+		&shortenStore.OriginalURL,
+		&shortenStore.UserID,
+		&shortenStore.Deleted,
+		&deletedAt,
+		&expiresAt,
+		&shortenStore.RedirectCode,
+		&createdAt,
+		&shortenStore.Title,
+		&shortenStore.Preview,
+		&shortenStore.PasswordHash,
+		&shortenStore.MaxClicks,
+		&shortenStore.UsedClicks,
+	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return models.ShortenStore{}, filestore.ErrURLNotFound
+			return models.ShortenStore{}, storeerr.ErrURLNotFound
 		}
 		return models.ShortenStore{}, err
 	}
+	shortenStore.DeletedAt = timePtr(deletedAt)
+	shortenStore.ExpiresAt = timePtr(expiresAt)
+	shortenStore.CreatedAt = timePtr(createdAt)
 
 	return shortenStore, nil
 }
@@ -57,6 +106,22 @@ func (d *DBStore) Ping() error {
 	return d.DB.Ping()
 }
 
+// NextID returns the next value of the short_url_seq sequence.
+func (d *DBStore) NextID(ctx context.Context) (int64, error) {
+	var id int64
+	err := d.DB.QueryRowContext(ctx, `SELECT nextval('short_url_seq')`).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// Close is a method that closes the database connection.
+func (d *DBStore) Close() error {
+	return d.DB.Close()
+}
+
 // AddBatch is a method that adds a batch of URLs to the database.
 func (d *DBStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
 	tx, err := d.DB.BeginTx(ctx, nil)
@@ -65,17 +130,19 @@ func (d *DBStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBat
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `INSERT INTO urls (uuid, short_url, original_url, user_id) VALUES ($1, $2, $3, $4)`)
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO urls (uuid, short_url, original_url, user_id, expires_at, redirect_code, title, preview, password_hash, max_clicks) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
 	for _, request := range batchRequest {
-		_, err = stmt.ExecContext(ctx, request.CorrelationID, request.ShortURL, request.OriginalURL, userID)
+		_, err = stmt.ExecContext(ctx, uuid.New(), request.ShortURL, request.OriginalURL, userID, request.ExpiresAt, request.RedirectCode, request.Title, request.Preview, request.PasswordHash, request.MaxClicks)
 		if err != nil {
 			logger.Log.Error("Error adding batch request", "error", err)
-			return err
+			// Откатываем транзакцию до поиска, чтобы не держать блокировки
+			tx.Rollback()
+			return d.conflictError(ctx, err, request.OriginalURL)
 		}
 	}
 
@@ -90,7 +157,7 @@ func (d *DBStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBat
 
 // GetUserURLs is a method that retrieves all URLs associated with the user ID.
 func (d *DBStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error) {
-	query := `SELECT short_url, original_url FROM urls WHERE user_id = $1`
+	query := `SELECT short_url, original_url, expires_at, deleted_at, password_hash <> '', max_clicks, used_clicks FROM urls WHERE user_id = $1`
 
 	rows, err := d.DB.QueryContext(ctx, query, userID)
 	if err != nil {
@@ -102,9 +169,14 @@ func (d *DBStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.U
 
 	for rows.Next() {
 		var url models.UserURLResponse
-		if err := rows.Scan(&url.ShortURL, &url.OriginalURL); err != nil {
+		var expiresAt, deletedAt sql.NullTime
+		var limit models.ShortenStore
+		if err := rows.Scan(&url.ShortURL, &url.OriginalURL, &expiresAt, &deletedAt, &url.Protected, &limit.MaxClicks, &limit.UsedClicks); err != nil {
 			return nil, err
 		}
+		url.ClicksLeft = limit.ClicksLeft()
+		url.ExpiresAt = timePtr(expiresAt)
+		url.DeletedAt = timePtr(deletedAt)
 		urls = append(urls, url)
 	}
 
@@ -117,21 +189,36 @@ func (d *DBStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.U
 }
 
 // DeleteUserURLs is a method that deletes URLs associated with the user ID.
+// The URLs are grouped by user and each group is marked deleted with a single
+// statement, all in one transaction. Already deleted URLs keep their deletion time.
 func (d *DBStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
+	var users []uuid.UUID
+	shortURLs := make(map[uuid.UUID][]string)
+	for userShortURL := range userShortURLs {
+		if _, ok := shortURLs[userShortURL.UserID]; !ok {
+			users = append(users, userShortURL.UserID)
+		}
+		shortURLs[userShortURL.UserID] = append(shortURLs[userShortURL.UserID], userShortURL.ShortURL)
+	}
+
+	if len(users) == 0 {
+		return nil
+	}
+
 	tx, err := d.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
-	stmt, err := tx.PrepareContext(ctx, `UPDATE urls SET is_deleted = true WHERE user_id = $1 AND short_url = $2`)
+	stmt, err := tx.PrepareContext(ctx, `UPDATE urls SET is_deleted = true, deleted_at = now() WHERE user_id = $1 AND short_url = ANY($2) AND NOT is_deleted`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	for userShortURL := range userShortURLs {
-		_, err = stmt.ExecContext(ctx, userShortURL.UserID, userShortURL.ShortURL)
+	for _, userID := range users {
+		_, err = stmt.ExecContext(ctx, userID, shortURLs[userID])
 		if err != nil {
 			return err
 		}
@@ -145,21 +232,92 @@ func (d *DBStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan model
 	return nil
 }
 
-// GetStats returns the number of URLs and unique users in the database
-func (d *DBStore) GetStats(ctx context.Context) (int, int, error) {
-	var urlsCount, usersCount int
+// RestoreUserURLs restores the user's URLs deleted at or after deletedAfter
+// and returns the restored short URLs.
+func (d *DBStore) RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	query := `
+		UPDATE urls SET is_deleted = false, deleted_at = NULL
+		WHERE user_id = $1 AND short_url = ANY($2) AND is_deleted
+			AND (deleted_at IS NULL OR deleted_at >= $3)
+		RETURNING short_url`
+	rows, err := d.DB.QueryContext(ctx, query, userID, shortURLs, deletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	restored := make([]string, 0, len(shortURLs))
+	for rows.Next() {
+		var shortURL string
+		if err := rows.Scan(&shortURL); err != nil {
+			return nil, err
+		}
+		restored = append(restored, shortURL)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return restored, nil
+}
+
+// PurgeDeleted removes the URLs deleted before the given time together with
+// their click analytics and returns their number.
+func (d *DBStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	result, err := d.DB.ExecContext(ctx, `DELETE FROM urls WHERE is_deleted AND deleted_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+
+	purged, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(purged), nil
+}
+
+// GetStats returns the number of active and expired URLs and of users with
+// active URLs in the database.
+func (d *DBStore) GetStats(ctx context.Context) (models.Stats, error) {
+	var stats models.Stats
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE expires_at IS NULL OR expires_at > now()),
+			COUNT(*) FILTER (WHERE expires_at <= now()),
+			COUNT(DISTINCT user_id) FILTER (WHERE expires_at IS NULL OR expires_at > now())
+		FROM urls
+		WHERE is_deleted = false`
+	err := d.DB.QueryRowContext(ctx, query).Scan(&stats.URLs, &stats.ExpiredURLs, &stats.Users)
+	if err != nil {
+		return models.Stats{}, err
+	}
+
+	return stats, nil
+}
 
-	// Get total number of URLs
-	err := d.DB.QueryRowContext(ctx, `SELECT COUNT(*) FROM urls WHERE is_deleted = false`).Scan(&urlsCount)
+// PurgeExpired removes the URLs that expired before the given time and
+// returns their number.
+func (d *DBStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	result, err := d.DB.ExecContext(ctx, `DELETE FROM urls WHERE expires_at < $1`, before)
 	if err != nil {
-		return 0, 0, err
+		return 0, err
 	}
 
-	// Get number of unique users
-	err = d.DB.QueryRowContext(ctx, `SELECT COUNT(DISTINCT user_id) FROM urls WHERE is_deleted = false`).Scan(&usersCount)
+	purged, err := result.RowsAffected()
 	if err != nil {
-		return 0, 0, err
+		return 0, err
 	}
 
-	return urlsCount, usersCount, nil
+	return int(purged), nil
+}
+
+// timePtr converts a nullable timestamp into a pointer in UTC.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
 }
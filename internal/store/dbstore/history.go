@@ -0,0 +1,99 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// UpdateOriginalURL changes the original URL of the user's short URL and
+// records the change in the url_history table in one transaction.
+func (d *DBStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	tx, err := d.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки упорядочивает конкурентные изменения одной ссылки
+	var previousURL string
+	err = tx.QueryRowContext(ctx, `
+		SELECT original_url FROM urls
+		WHERE short_url = $1 AND user_id = $2 AND NOT is_deleted
+		FOR UPDATE`, shortURL, userID).Scan(&previousURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.URLVersion{}, storeerr.ErrURLNotFound
+	}
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE urls SET original_url = $2 WHERE short_url = $1`, shortURL, originalURL)
+	if err != nil {
+		// Откатываем транзакцию до поиска, чтобы не держать блокировки
+		tx.Rollback()
+		return models.URLVersion{}, d.conflictError(ctx, err, originalURL)
+	}
+
+	version := models.URLVersion{
+		ShortURL:    shortURL,
+		OriginalURL: originalURL,
+		PreviousURL: previousURL,
+		ChangedBy:   userID,
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO url_history (short_url, version, original_url, previous_url, changed_at, changed_by)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, now(), $4
+		FROM url_history WHERE short_url = $1
+		RETURNING version, changed_at`,
+		shortURL, originalURL, previousURL, userID).Scan(&version.Version, &version.ChangedAt)
+	if err != nil {
+		return models.URLVersion{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.URLVersion{}, err
+	}
+	version.ChangedAt = version.ChangedAt.UTC()
+
+	return version, nil
+}
+
+// GetURLHistory returns the changes of the original URL of the short URL
+// ordered by version.
+func (d *DBStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+	var exists bool
+	err := d.DB.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM urls WHERE short_url = $1)`, shortURL).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, storeerr.ErrURLNotFound
+	}
+
+	rows, err := d.DB.QueryContext(ctx, `
+		SELECT version, original_url, previous_url, changed_at, changed_by
+		FROM url_history WHERE short_url = $1
+		ORDER BY version`, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []models.URLVersion{}
+	for rows.Next() {
+		version := models.URLVersion{ShortURL: shortURL}
+		if err := rows.Scan(&version.Version, &version.OriginalURL, &version.PreviousURL, &version.ChangedAt, &version.ChangedBy); err != nil {
+			return nil, err
+		}
+		version.ChangedAt = version.ChangedAt.UTC()
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
@@ -0,0 +1,41 @@
+package dbstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// ConsumeClick uses one redirect of a link with a click limit and returns the
+// number of redirects left. The check and the increment are a single UPDATE,
+// so concurrent redirects never exceed the limit. It returns -1 for links
+// without a limit and storeerr.ErrLinkExhausted once all the redirects are used.
+func (d *DBStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	var left int
+	err := d.DB.QueryRowContext(ctx, `
+		UPDATE urls SET used_clicks = used_clicks + 1
+		WHERE short_url = $1 AND max_clicks > 0 AND used_clicks < max_clicks
+		RETURNING max_clicks - used_clicks`, shortURL).Scan(&left)
+	if err == nil {
+		return left, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return -1, err
+	}
+
+	// Ни одна строка не изменилась: ссылки нет, у нее нет ограничения или оно исчерпано
+	var maxClicks int
+	err = d.DB.QueryRowContext(ctx, `SELECT max_clicks FROM urls WHERE short_url = $1`, shortURL).Scan(&maxClicks)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return -1, storeerr.ErrURLNotFound
+	case err != nil:
+		return -1, err
+	case maxClicks > 0:
+		return -1, storeerr.ErrLinkExhausted
+	}
+
+	return -1, nil
+}
@@ -0,0 +1,18 @@
+package filestore_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, config.Config{
+		StorageBackend: store.BackendFile,
+		FilePath:       filepath.Join(t.TempDir(), "urls.log"),
+		FileSyncPolicy: "always",
+	}, store.DefaultStoreConstructor)
+}
@@ -1,39 +1,211 @@
 // Package filestore provides file-based storage implementation for the URL shortener service.
+//
+// Records are kept in a memstore.MemStore and every change is appended to a write-ahead log
+// as a single JSON line. On startup the last snapshot is loaded and the log is
+// replayed on top of it. A background compaction periodically writes the current
+// state into the snapshot file and truncates the log.
 package filestore
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/memstore"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
 // ErrURLNotFound is an error that indicates the URL was not found.
-var ErrURLNotFound = errors.New("URL not found")
+var ErrURLNotFound = storeerr.ErrURLNotFound
+
+// ErrURLExists is an error that indicates the short or original URL is already stored.
+var ErrURLExists = storeerr.ErrURLExists
+
+// SyncPolicy defines when appended log entries are flushed to disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways flushes the log after every write.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval flushes the log periodically in the background.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	defaultSyncInterval    = time.Second
+	defaultCompactInterval = 10 * time.Minute
+	snapshotSuffix         = ".snapshot"
+	// sequenceBlock is the number of IDs reserved by a single log event.
+	sequenceBlock = 100
+)
+
+// Log operations.
+const (
+	opAdd      = "add"
+	opDelete   = "delete"
+	opRestore  = "restore"
+	opPurge    = "purge"
+	opSequence = "sequence"
+	opClicks   = "clicks"
+	opUpdate   = "update"
+	opConsume  = "consume"
+	opAccount  = "account"
+	opMerge    = "merge"
+	opAPIKey   = "api_key"
+	// opClickStats holds aggregated click analytics in the snapshot.
+	opClickStats = "click_stats"
+	// opHistory holds the changes of original URLs in the snapshot.
+	opHistory = "history"
+)
+
+// event is a single line of the write-ahead log.
+// A whole batch is written as one event so that it is replayed atomically.
+type event struct {
+	Op        string                `json:"op"`
+	Records   []models.ShortenStore `json:"records,omitempty"`
+	ShortURLs []string              `json:"short_urls,omitempty"`
+	Sequence  int64                 `json:"sequence,omitempty"`
+	// Time is the time of a deletion
+	Time *time.Time `json:"time,omitempty"`
+	// ID numbers click events. Counting clicks is not idempotent, so events
+	// already included in the snapshot are skipped on replay.
+	ID         int64                     `json:"id,omitempty"`
+	Clicks     []models.Click            `json:"clicks,omitempty"`
+	ClickStats []memstore.ClickAggregate `json:"click_stats,omitempty"`
+	// Versions are changes of original URLs. A version is applied once,
+	// so replaying them is idempotent.
+	Versions []models.URLVersion `json:"versions,omitempty"`
+	// Used is the number of used redirects of a link with a click limit
+	// after a consume event. It only grows, so replaying it is idempotent.
+	Used int `json:"used,omitempty"`
+	// Accounts are registered accounts, stored by ID, so replaying them is idempotent.
+	Accounts []models.Account `json:"accounts,omitempty"`
+	// UserID is the new owner of the links of a merge event.
+	UserID *uuid.UUID `json:"user_id,omitempty"`
+	// APIKeys are created or revoked API keys, stored by ID, so replaying them is idempotent.
+	APIKeys []models.APIKey `json:"api_keys,omitempty"`
+}
+
+// Options configures durability and compaction of the file store.
+type Options struct {
+	SyncPolicy      SyncPolicy
+	SyncInterval    time.Duration
+	CompactInterval time.Duration
+}
 
 // FileStore is a struct that represents the file store.
+// Reads are served by the in-memory state without touching the file.
 type FileStore struct {
-	URLMapping map[string]string
-	mu         sync.RWMutex
-	FilePath   string
+	// mu serializes writes to the log and compactions.
+	mu sync.Mutex
+	// mem holds the current state.
+	mem *memstore.MemStore
+
+	filePath     string
+	snapshotPath string
+	file         *os.File
+	opts         Options
+	// pending counts log events written since the last compaction.
+	pending int
+	// nextID is the last ID handed out by NextID, reservedID is the highest
+	// ID reserved in the log. IDs up to reservedID are not reused after a restart.
+	nextID     int64
+	reservedID int64
+	// clickEventID is the ID of the last applied click event.
+	clickEventID int64
+	// broken is set when a failed append could not be rolled back; the log
+	// then ends in a partial entry and further appends are rejected.
+	broken error
+
+	done      chan struct{}
+	wg        sync.WaitGroup
+	closeOnce sync.Once
 }
 
-// Add is a method that adds a new URL to the file store.
-func (fs *FileStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error {
-	fs.mu.Lock()
-	defer fs.mu.Unlock()
+// NewFileStore is a function that creates a new file store, restores its
+// state from the snapshot and the log, and starts background maintenance.
+// An empty file path creates a store that is kept in memory only.
+func NewFileStore(filePath string, opts Options) (*FileStore, error) {
+	switch opts.SyncPolicy {
+	case "":
+		opts.SyncPolicy = SyncInterval
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown sync policy %q", opts.SyncPolicy)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultSyncInterval
+	}
+	if opts.CompactInterval <= 0 {
+		opts.CompactInterval = defaultCompactInterval
+	}
 
-	fs.URLMapping[shortURL] = originalURL
+	fs := &FileStore{
+		mem:      memstore.NewMemStore(),
+		filePath: filePath,
+		opts:     opts,
+		done:     make(chan struct{}),
+	}
 
-	if fs.FilePath != "" {
-		fs.SaveToFile()
+	if fs.filePath == "" {
+		return fs, nil
+	}
+
+	fs.snapshotPath = fs.filePath + snapshotSuffix
+	if err := fs.loadSnapshot(); err != nil {
+		return nil, fmt.Errorf("load snapshot: %w", err)
+	}
+	if err := fs.replayLog(); err != nil {
+		return nil, fmt.Errorf("replay log: %w", err)
+	}
+	fs.nextID = fs.reservedID
+
+	file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	fs.file = file
+
+	if fs.opts.SyncPolicy == SyncInterval {
+		fs.wg.Add(1)
+		go fs.syncLoop()
+	}
+	fs.wg.Add(1)
+	go fs.compactLoop()
+
+	logger.Log.Info("File store opened", "path", fs.filePath, "records", fs.mem.Len())
+
+	return fs, nil
+}
+
+// Add is a method that adds a new URL to the file store.
+func (fs *FileStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+	now := time.Now().UTC()
+	record := models.ShortenStore{
+		UUID:        uuid.New(),
+		CreatedAt:   &now,
+		ShortURL:    shortURL,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		LinkOptions: models.NewLinkOptions(opts...),
+	}
+
+	if err := fs.insert(record); err != nil {
+		return err
 	}
 
 	logger.Log.Info("Added to store", "shortURL", shortURL, "originalURL", originalURL)
@@ -43,76 +215,482 @@ func (fs *FileStore) Add(ctx context.Context, shortURL, originalURL string, user
 
 // Get is a method that retrieves the original URL from the file store.
 func (fs *FileStore) Get(ctx context.Context, shortURL string) (models.ShortenStore, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+	return fs.mem.Get(ctx, shortURL)
+}
+
+// AddBatch is a method that adds a batch of URLs to the file store.
+// The batch is stored atomically: if any URL conflicts, nothing is added.
+func (fs *FileStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
+	if len(batchRequest) == 0 {
+		return nil
+	}
 
-	if fs.FilePath != "" {
-		if err := fs.LoadFromFile(); err != nil {
-			logger.Log.Error("Failed to load from file", "error", err)
-			return models.ShortenStore{}, err
+	now := time.Now().UTC()
+	records := make([]models.ShortenStore, len(batchRequest))
+	for i, request := range batchRequest {
+		records[i] = models.ShortenStore{
+			UUID:        uuid.New(),
+			CreatedAt:   &now,
+			ShortURL:    request.ShortURL,
+			OriginalURL: request.OriginalURL,
+			UserID:      userID,
+			LinkOptions: request.LinkOptions,
 		}
 	}
 
-	originalURL, ok := fs.URLMapping[shortURL]
-	if !ok {
-		return models.ShortenStore{}, ErrURLNotFound
+	if err := fs.insert(records...); err != nil {
+		return err
 	}
 
-	logger.Log.Info("Retrieved from store", "shortURL", shortURL, "originalURL", originalURL)
+	logger.Log.Info("Added batch to store", "count", len(records))
 
-	return models.ShortenStore{
-		OriginalURL: originalURL,
-		Deleted:     false,
-	}, nil
+	return nil
 }
 
-// AddBatch is a method that adds a batch of URLs to the file store.
-func (fs *FileStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
+// GetUserURLs is a method that retrieves all URLs associated with the user ID.
+func (fs *FileStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error) {
+	return fs.mem.GetUserURLs(ctx, userID)
+}
+
+// DeleteUserURLs is a method that deletes URLs associated with the user ID.
+// URLs owned by other users are left untouched.
+func (fs *FileStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
+	var items []models.UserShortURL
+	for userShortURL := range userShortURLs {
+		items = append(items, userShortURL)
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	for _, request := range batchRequest {
-		fs.URLMapping[request.ShortURL] = request.OriginalURL
+	var shortURLs []string
+	for _, item := range items {
+		record, err := fs.mem.Get(ctx, item.ShortURL)
+		if err != nil || record.UserID != item.UserID || record.Deleted {
+			continue
+		}
+		shortURLs = append(shortURLs, record.ShortURL)
 	}
 
-	if fs.FilePath != "" {
-		fs.SaveToFile()
+	if len(shortURLs) == 0 {
+		return nil
 	}
 
-	logger.Log.Info("Added batch to store", "batchRequest", batchRequest)
+	now := time.Now().UTC()
+	return fs.commit(event{Op: opDelete, ShortURLs: shortURLs, Time: &now})
+}
 
-	return nil
+// RestoreUserURLs restores the user's URLs deleted at or after deletedAfter
+// and returns the restored short URLs.
+func (fs *FileStore) RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	restored := fs.mem.RestorableUserURLs(userID, shortURLs, deletedAfter)
+	if len(restored) == 0 {
+		return restored, nil
+	}
+
+	if err := fs.commit(event{Op: opRestore, ShortURLs: restored}); err != nil {
+		return nil, err
+	}
+
+	return restored, nil
 }
 
-// SaveToFile is a method that saves the URL mapping to a file.
-func (fs *FileStore) SaveToFile() error {
-	file, err := os.OpenFile(fs.FilePath, os.O_WRONLY|os.O_CREATE, 0644)
+// PurgeDeleted removes the URLs deleted before the given time and returns
+// their number.
+func (fs *FileStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	shortURLs := fs.mem.DeletedBefore(before)
+	if len(shortURLs) == 0 {
+		return 0, nil
+	}
+
+	if err := fs.commit(event{Op: opPurge, ShortURLs: shortURLs}); err != nil {
+		return 0, err
+	}
+
+	return len(shortURLs), nil
+}
+
+// UpdateOriginalURL changes the original URL of the user's short URL and
+// records the change as a new version.
+func (fs *FileStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	version, err := fs.mem.CheckUpdate(shortURL, userID, originalURL)
 	if err != nil {
+		return models.URLVersion{}, err
+	}
+
+	if err := fs.commit(event{Op: opUpdate, Versions: []models.URLVersion{version}}); err != nil {
+		return models.URLVersion{}, err
+	}
+
+	logger.Log.Info("Updated original URL", "shortURL", shortURL, "originalURL", originalURL, "version", version.Version)
+
+	return version, nil
+}
+
+// ConsumeClick uses one redirect of a link with a click limit and returns the
+// number of redirects left. It returns -1 for links without a limit and
+// storeerr.ErrLinkExhausted once all the redirects are used.
+func (fs *FileStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	record, err := fs.mem.CheckConsume(shortURL)
+	if err != nil || record.MaxClicks <= 0 {
+		return -1, err
+	}
+
+	if err := fs.commit(event{Op: opConsume, ShortURLs: []string{shortURL}, Used: record.UsedClicks}); err != nil {
+		return -1, err
+	}
+
+	return *record.ClicksLeft(), nil
+}
+
+// CreateAccount stores the account. It fails with storeerr.ErrAccountExists
+// if the email or the ID is already registered.
+func (fs *FileStore) CreateAccount(ctx context.Context, account models.Account) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.mem.CheckAccount(account); err != nil {
 		return err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	for shortURL, originalURL := range fs.URLMapping {
-		record := models.ShortenStore{
-			UUID:        uuid.New(),
-			ShortURL:    shortURL,
-			OriginalURL: originalURL,
+	return fs.commit(event{Op: opAccount, Accounts: []models.Account{account}})
+}
+
+// GetAccount returns the account with the ID.
+func (fs *FileStore) GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error) {
+	return fs.mem.GetAccount(ctx, id)
+}
+
+// GetAccountByEmail returns the account registered with the email.
+func (fs *FileStore) GetAccountByEmail(ctx context.Context, email string) (models.Account, error) {
+	return fs.mem.GetAccountByEmail(ctx, email)
+}
+
+// MergeUserURLs moves all the URLs of the user from, including deleted ones,
+// to the user to and returns their short URLs.
+func (fs *FileStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	shortURLs := fs.mem.UserURLs(from)
+	if len(shortURLs) == 0 || from == to {
+		return nil, nil
+	}
+
+	if err := fs.commit(event{Op: opMerge, ShortURLs: shortURLs, UserID: &to}); err != nil {
+		return nil, err
+	}
+
+	logger.Log.Info("Merged user URLs", "from", from, "to", to, "count", len(shortURLs))
+
+	return shortURLs, nil
+}
+
+// CreateAPIKey stores the API key.
+func (fs *FileStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.commit(event{Op: opAPIKey, APIKeys: []models.APIKey{key}})
+}
+
+// GetAPIKeyByHash returns the API key with the hash, including a revoked one.
+func (fs *FileStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	return fs.mem.GetAPIKeyByHash(ctx, hash)
+}
+
+// ListAPIKeys returns the API keys of the user in the order of creation.
+func (fs *FileStore) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	return fs.mem.ListAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey revokes the active API key of the user and returns it.
+func (fs *FileStore) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	key, err := fs.mem.CheckRevokeAPIKey(userID, id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	key.RevokedAt = &at
+
+	if err := fs.commit(event{Op: opAPIKey, APIKeys: []models.APIKey{key}}); err != nil {
+		return models.APIKey{}, err
+	}
+
+	return key, nil
+}
+
+// GetURLHistory returns the changes of the original URL of the short URL.
+func (fs *FileStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+	return fs.mem.GetURLHistory(ctx, shortURL)
+}
+
+// Ping is a method that checks the file store connection.
+func (fs *FileStore) Ping() error {
+	err := errors.New("unable to access the store")
+	return err
+}
+
+// GetStats returns the number of active and expired URLs and of users with
+// active URLs in the file store.
+func (fs *FileStore) GetStats(ctx context.Context) (models.Stats, error) {
+	return fs.mem.GetStats(ctx)
+}
+
+// PurgeExpired removes the URLs that expired before the given time and
+// returns their number.
+func (fs *FileStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	shortURLs := fs.mem.ExpiredBefore(before)
+	if len(shortURLs) == 0 {
+		return 0, nil
+	}
+
+	if err := fs.commit(event{Op: opPurge, ShortURLs: shortURLs}); err != nil {
+		return 0, err
+	}
+
+	return len(shortURLs), nil
+}
+
+// RecordClicks logs the clicks and adds them to the analytics of their short URLs.
+// Clicks on unknown short URLs are ignored.
+func (fs *FileStore) RecordClicks(ctx context.Context, clicks []models.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.commit(event{Op: opClicks, ID: fs.clickEventID + 1, Clicks: clicks})
+}
+
+// GetLinkStats returns the click analytics of the short URL.
+func (fs *FileStore) GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+	return fs.mem.GetLinkStats(ctx, shortURL, top)
+}
+
+// NextID returns the next value of the store sequence, starting from 1.
+// IDs are reserved in the log in blocks, so a restart may skip some values.
+func (fs *FileStore) NextID(ctx context.Context) (int64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.nextID >= fs.reservedID {
+		if err := fs.commit(event{Op: opSequence, Sequence: fs.nextID + sequenceBlock}); err != nil {
+			return 0, err
 		}
+	}
+	fs.nextID++
 
-		if err := encoder.Encode(&record); err != nil {
+	return fs.nextID, nil
+}
+
+// Close stops background maintenance, flushes the log and closes the file.
+// It is safe to call Close more than once.
+func (fs *FileStore) Close() error {
+	if fs.file == nil {
+		return nil
+	}
+
+	var err error
+	fs.closeOnce.Do(func() {
+		close(fs.done)
+		fs.wg.Wait()
+
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+
+		err = fs.file.Sync()
+		if closeErr := fs.file.Close(); err == nil {
+			err = closeErr
+		}
+	})
+
+	return err
+}
+
+// Compact writes the current state into the snapshot file and truncates the log.
+// Reads are served while the snapshot is written; writes wait until it is done.
+func (fs *FileStore) Compact() error {
+	if fs.file == nil {
+		return nil
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.pending == 0 {
+		return nil
+	}
+
+	var header []event
+	if fs.reservedID > 0 {
+		header = append(header, event{Op: opSequence, Sequence: fs.reservedID})
+	}
+	if fs.clickEventID > 0 {
+		header = append(header, event{Op: opClickStats, ID: fs.clickEventID, ClickStats: fs.mem.ClickAggregates()})
+	}
+	if versions := fs.mem.History(); len(versions) > 0 {
+		header = append(header, event{Op: opHistory, Versions: versions})
+	}
+	if accounts := fs.mem.Accounts(); len(accounts) > 0 {
+		header = append(header, event{Op: opAccount, Accounts: accounts})
+	}
+	if apiKeys := fs.mem.APIKeys(); len(apiKeys) > 0 {
+		header = append(header, event{Op: opAPIKey, APIKeys: apiKeys})
+	}
+
+	tmpPath := fs.snapshotPath + ".tmp"
+	records := fs.mem.Records()
+	if err := writeSnapshot(tmpPath, header, records); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, fs.snapshotPath); err != nil {
+		return err
+	}
+	if err := syncDir(filepath.Dir(fs.snapshotPath)); err != nil {
+		return err
+	}
+
+	// Replaying the old log over the new snapshot yields the same state,
+	// so a crash before the truncation below loses nothing.
+	if err := fs.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := fs.file.Sync(); err != nil {
+		return err
+	}
+	// The partial entry left by a failed append is gone with the old log
+	fs.broken = nil
+
+	logger.Log.Info("Compacted file store", "records", len(records), "events", fs.pending)
+	fs.pending = 0
+
+	return nil
+}
+
+// insert checks the records for conflicts, logs them and adds them to the state.
+func (fs *FileStore) insert(records ...models.ShortenStore) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.mem.CheckInsert(records...); err != nil {
+		return err
+	}
+
+	return fs.commit(event{Op: opAdd, Records: records})
+}
+
+// commit appends the event to the log and then applies it to the in-memory state.
+// The caller must hold the lock.
+func (fs *FileStore) commit(e event) error {
+	if fs.file != nil {
+		line, err := json.Marshal(e)
+		if err != nil {
 			return err
 		}
+		line = append(line, '\n')
 
-		logger.Log.Info("Saving to file", "uuid", record.UUID, "short_url", shortURL, "original_url", originalURL)
+		if fs.broken != nil {
+			return fmt.Errorf("log is unusable after a failed append: %w", fs.broken)
+		}
+		info, err := fs.file.Stat()
+		if err != nil {
+			return err
+		}
+		if _, err := fs.file.Write(line); err != nil {
+			logger.Log.Error("Failed to append to log", "error", err)
+			// A partial entry in the middle of the log would fail the next start,
+			// so the log is cut back to the last complete entry
+			if truncErr := fs.file.Truncate(info.Size()); truncErr != nil {
+				logger.Log.Error("Failed to roll back partial log entry", "error", truncErr)
+				fs.broken = err
+			}
+			return err
+		}
+		if fs.opts.SyncPolicy == SyncAlways {
+			if err := fs.file.Sync(); err != nil {
+				logger.Log.Error("Failed to sync log", "error", err)
+				return err
+			}
+		}
+		fs.pending++
 	}
 
+	fs.apply(e)
+
 	return nil
 }
 
-// LoadFromFile is a method that loads the URL mapping from a file.
-func (fs *FileStore) LoadFromFile() error {
-	file, err := os.Open(fs.FilePath)
+// apply changes the in-memory state according to the event.
+// Applying an event is idempotent, which makes replay after compaction safe.
+func (fs *FileStore) apply(e event) {
+	switch e.Op {
+	case opAdd:
+		fs.mem.Put(dateDeletions(e.Records)...)
+	case opDelete:
+		// Deletions logged before their time was recorded are dated at load time
+		at := time.Now()
+		if e.Time != nil {
+			at = *e.Time
+		}
+		fs.mem.SetDeleted(true, at, e.ShortURLs...)
+	case opRestore:
+		fs.mem.SetDeleted(false, time.Time{}, e.ShortURLs...)
+	case opPurge:
+		fs.mem.Remove(e.ShortURLs...)
+	case opSequence:
+		fs.reservedID = max(fs.reservedID, e.Sequence)
+	case opClicks:
+		if e.ID <= fs.clickEventID {
+			return
+		}
+		fs.mem.RecordClicks(context.Background(), e.Clicks)
+		fs.clickEventID = e.ID
+	case opClickStats:
+		fs.mem.PutClickAggregates(e.ClickStats...)
+		fs.clickEventID = max(fs.clickEventID, e.ID)
+	case opUpdate, opHistory:
+		fs.mem.PutVersions(e.Versions...)
+	case opConsume:
+		for _, shortURL := range e.ShortURLs {
+			fs.mem.PutUsedClicks(shortURL, e.Used)
+		}
+	case opAccount:
+		fs.mem.PutAccounts(e.Accounts...)
+	case opMerge:
+		if e.UserID != nil {
+			fs.mem.SetOwner(*e.UserID, e.ShortURLs...)
+		}
+	case opAPIKey:
+		fs.mem.PutAPIKeys(e.APIKeys...)
+	}
+}
+
+// loadSnapshot reads the state saved by the last compaction.
+// The snapshot holds one record per line, optionally preceded by the sequence,
+// click analytics, history, account and API key events.
+func (fs *FileStore) loadSnapshot() error {
+	file, err := os.Open(fs.snapshotPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil
@@ -121,54 +699,194 @@ func (fs *FileStore) LoadFromFile() error {
 	}
 	defer file.Close()
 
+	var records []models.ShortenStore
 	decoder := json.NewDecoder(file)
-
 	for {
-		var record models.ShortenStore
-		if err := decoder.Decode(&record); err != nil {
+		var line json.RawMessage
+		if err := decoder.Decode(&line); err != nil {
 			if err == io.EOF {
 				break
 			}
 			return err
 		}
-		fs.URLMapping[record.ShortURL] = record.OriginalURL
+
+		e, err := decodeEvent(line)
+		if err != nil {
+			return err
+		}
+		if e.Op == opAdd {
+			records = append(records, e.Records...)
+			continue
+		}
+		fs.apply(e)
 	}
 
-	logger.Log.Info("Loaded from file", "URLMapping", fs.URLMapping)
+	fs.mem.Put(dateDeletions(records)...)
+
 	return nil
 }
 
-// GetUserURLs is a method that retrieves all URLs associated with the user ID.
-func (fs *FileStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error) {
-	return nil, nil
+// dateDeletions sets the deletion time of deleted records saved before the
+// time was recorded, so that they are eventually purged. Such records are
+// treated as deleted at load time.
+func dateDeletions(records []models.ShortenStore) []models.ShortenStore {
+	now := time.Now().UTC()
+	for i := range records {
+		if records[i].Deleted && records[i].DeletedAt == nil {
+			records[i].DeletedAt = &now
+		}
+	}
+
+	return records
 }
 
-// DeleteUserURLs is a method that deletes URLs associated with the user ID.
-func (fs *FileStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
-	return nil
+// replayLog applies the events from the log. A damaged last line is the result
+// of an interrupted write, so it is cut off and the log is truncated to the last
+// complete event. Damage anywhere else is reported as an error.
+func (fs *FileStore) replayLog() error {
+	file, err := os.OpenFile(fs.filePath, os.O_RDWR, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var offset int64
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+		if len(line) == 0 {
+			return nil
+		}
+
+		e, err := decodeEvent(line)
+		if err != nil {
+			if _, peekErr := reader.Peek(1); peekErr != io.EOF {
+				return fmt.Errorf("corrupted log entry at offset %d: %w", offset, err)
+			}
+			logger.Log.Warn("Truncating incomplete log entry", "offset", offset)
+			if err := file.Truncate(offset); err != nil {
+				return err
+			}
+			return file.Sync()
+		}
+
+		fs.apply(e)
+		fs.pending++
+		offset += int64(len(line))
+
+		if readErr == io.EOF {
+			// The entry is complete but lost its line break, restore it so
+			// that the next append starts on a new line.
+			if _, err := file.WriteAt([]byte{'\n'}, offset); err != nil {
+				return err
+			}
+			return nil
+		}
+	}
 }
 
-// Ping is a method that checks the file store connection.
-func (fs *FileStore) Ping() error {
-	err := errors.New("unable to access the store")
-	return err
+// decodeEvent parses a log line. Lines written before the log format was
+// introduced hold a bare record and are treated as additions.
+func decodeEvent(line []byte) (event, error) {
+	line = bytes.TrimSpace(line)
+
+	var e event
+	if err := json.Unmarshal(line, &e); err != nil {
+		return event{}, err
+	}
+	if e.Op != "" {
+		return e, nil
+	}
+
+	var record models.ShortenStore
+	if err := json.Unmarshal(line, &record); err != nil {
+		return event{}, err
+	}
+	if record.ShortURL == "" {
+		return event{}, errors.New("unknown log entry")
+	}
+
+	return event{Op: opAdd, Records: []models.ShortenStore{record}}, nil
+}
+
+// writeSnapshot saves the header events and the records to the file and flushes it to disk.
+func writeSnapshot(path string, header []event, records []models.ShortenStore) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, e := range header {
+		if err := encoder.Encode(e); err != nil {
+			return err
+		}
+	}
+	for _, record := range records {
+		if err := encoder.Encode(&record); err != nil {
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// syncDir flushes directory metadata so that a rename survives a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
 }
 
-// GetStats returns the number of URLs and unique users in the file store
-func (fs *FileStore) GetStats(ctx context.Context) (int, int, error) {
-	fs.mu.RLock()
-	defer fs.mu.RUnlock()
+// syncLoop periodically flushes the log to disk.
+func (fs *FileStore) syncLoop() {
+	defer fs.wg.Done()
 
-	if fs.FilePath != "" {
-		if err := fs.LoadFromFile(); err != nil {
-			logger.Log.Error("Failed to load from file", "error", err)
-			return 0, 0, err
+	ticker := time.NewTicker(fs.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-fs.done:
+			return
+		case <-ticker.C:
+			if err := fs.file.Sync(); err != nil {
+				logger.Log.Error("Failed to sync log", "error", err)
+			}
 		}
 	}
+}
+
+// compactLoop periodically compacts the log into the snapshot.
+func (fs *FileStore) compactLoop() {
+	defer fs.wg.Done()
 
-	urlsCount := len(fs.URLMapping)
-	// Since file store doesn't track users, we'll return 0 for users count
-	usersCount := 0
+	ticker := time.NewTicker(fs.opts.CompactInterval)
+	defer ticker.Stop()
 
-	return urlsCount, usersCount, nil
+	for {
+		select {
+		case <-fs.done:
+			return
+		case <-ticker.C:
+			if err := fs.Compact(); err != nil {
+				logger.Log.Error("Failed to compact file store", "error", err)
+			}
+		}
+	}
 }
@@ -6,7 +6,9 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -14,6 +16,8 @@ import (
 
 	"github.com/learies/goShortener/internal/config/logger"
 	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store/storeerr"
 )
 
 func init() {
@@ -27,10 +31,9 @@ func TestFileStore(t *testing.T) {
 	filePath := filepath.Join(tmpDir, "urls.json")
 
 	// Создаем новый экземпляр FileStore
-	fs := &FileStore{
-		URLMapping: make(map[string]string),
-		FilePath:   filePath,
-	}
+	fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+	require.NoError(t, err)
+	t.Cleanup(func() { fs.Close() })
 
 	// Тестовые данные
 	shortURL := "abc123"
@@ -53,6 +56,18 @@ func TestFileStore(t *testing.T) {
 		assert.ErrorIs(t, err, ErrURLNotFound)
 	})
 
+	t.Run("Add duplicate", func(t *testing.T) {
+		// Повторное сокращение того же URL должно вернуть ошибку
+		err := fs.Add(context.Background(), "other", originalURL, userID)
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, shortURL, conflict.ShortURL)
+
+		// Занятый короткий URL тоже нельзя переиспользовать
+		err = fs.Add(context.Background(), shortURL, "https://other.com", userID)
+		assert.ErrorIs(t, err, ErrURLExists)
+	})
+
 	t.Run("AddBatch", func(t *testing.T) {
 		batchRequest := []models.ShortenBatchStore{
 			{
@@ -75,67 +90,44 @@ func TestFileStore(t *testing.T) {
 			result, err := fs.Get(context.Background(), req.ShortURL)
 			require.NoError(t, err)
 			assert.Equal(t, req.OriginalURL, result.OriginalURL)
+			assert.Equal(t, userID, result.UserID)
 		}
 	})
 
-	t.Run("SaveToFile and LoadFromFile", func(t *testing.T) {
-		// Создаем новый FileStore для тестирования сохранения/загрузки
-		testFilePath := filepath.Join(tmpDir, "test_urls.json")
-		testFS := &FileStore{
-			URLMapping: make(map[string]string),
-			FilePath:   testFilePath,
-		}
-
-		// Добавляем тестовые данные
-		testData := map[string]string{
-			"test1": "https://test1.com",
-			"test2": "https://test2.com",
-		}
-		for short, original := range testData {
-			testFS.URLMapping[short] = original
-		}
-
-		// Сохраняем в файл
-		err := testFS.SaveToFile()
-		require.NoError(t, err)
-
-		// Проверяем, что файл существует
-		_, err = os.Stat(testFilePath)
-		require.NoError(t, err)
-
-		// Создаем новый FileStore для загрузки данных
-		loadFS := &FileStore{
-			URLMapping: make(map[string]string),
-			FilePath:   testFilePath,
+	t.Run("AddBatch with conflict", func(t *testing.T) {
+		batchRequest := []models.ShortenBatchStore{
+			{
+				CorrelationID: "1",
+				ShortURL:      "short3",
+				OriginalURL:   "https://example3.com",
+			},
+			{
+				CorrelationID: "2",
+				ShortURL:      "short4",
+				OriginalURL:   "https://example1.com",
+			},
 		}
 
-		// Загружаем данные из файла
-		err = loadFS.LoadFromFile()
-		require.NoError(t, err)
+		err := fs.AddBatch(context.Background(), batchRequest, userID)
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, "short1", conflict.ShortURL)
 
-		// Проверяем, что данные загружены корректно
-		for short, original := range testData {
-			loaded, ok := loadFS.URLMapping[short]
-			assert.True(t, ok)
-			assert.Equal(t, original, loaded)
-		}
+		// Пакет добавляется атомарно, поэтому первый URL тоже не сохранен
+		_, err = fs.Get(context.Background(), "short3")
+		assert.ErrorIs(t, err, ErrURLNotFound)
 	})
 
-	t.Run("LoadFromFile with non-existent file", func(t *testing.T) {
-		nonExistentFS := &FileStore{
-			URLMapping: make(map[string]string),
-			FilePath:   filepath.Join(tmpDir, "nonexistent.json"),
-		}
-
-		err := nonExistentFS.LoadFromFile()
+	t.Run("Open with non-existent file", func(t *testing.T) {
+		nonExistentFS, err := NewFileStore(filepath.Join(tmpDir, "nonexistent.json"), Options{})
 		require.NoError(t, err)
-		assert.Empty(t, nonExistentFS.URLMapping)
+		defer nonExistentFS.Close()
+		assert.Zero(t, nonExistentFS.mem.Len())
 	})
 
 	t.Run("Concurrent access", func(t *testing.T) {
-		concurrentFS := &FileStore{
-			URLMapping: make(map[string]string),
-		}
+		concurrentFS, err := NewFileStore("", Options{})
+		require.NoError(t, err)
 
 		// Запускаем несколько горутин для одновременного доступа
 		done := make(chan bool)
@@ -155,28 +147,71 @@ func TestFileStore(t *testing.T) {
 		}
 
 		// Проверяем, что все URL были добавлены
-		assert.Equal(t, 10, len(concurrentFS.URLMapping))
+		assert.Equal(t, 10, concurrentFS.mem.Len())
 	})
 
 	t.Run("GetUserURLs", func(t *testing.T) {
-		// Проверяем, что метод возвращает nil, nil
 		urls, err := fs.GetUserURLs(context.Background(), userID)
 		require.NoError(t, err)
-		assert.Nil(t, urls)
+		assert.ElementsMatch(t, []models.UserURLResponse{
+			{ShortURL: shortURL, OriginalURL: originalURL},
+			{ShortURL: "short1", OriginalURL: "https://example1.com"},
+			{ShortURL: "short2", OriginalURL: "https://example2.com"},
+		}, urls)
+
+		// У другого пользователя ссылок нет
+		urls, err = fs.GetUserURLs(context.Background(), uuid.New())
+		require.NoError(t, err)
+		assert.Empty(t, urls)
 	})
 
 	t.Run("DeleteUserURLs", func(t *testing.T) {
 		// Создаем канал с URL для удаления
-		urlsToDelete := make(chan models.UserShortURL, 1)
+		urlsToDelete := make(chan models.UserShortURL, 2)
 		urlsToDelete <- models.UserShortURL{
 			UserID:   userID,
 			ShortURL: shortURL,
 		}
+		// Чужой URL удалять нельзя
+		urlsToDelete <- models.UserShortURL{
+			UserID:   uuid.New(),
+			ShortURL: "short1",
+		}
 		close(urlsToDelete)
 
-		// Проверяем, что метод возвращает nil
 		err := fs.DeleteUserURLs(context.Background(), urlsToDelete)
 		require.NoError(t, err)
+
+		result, err := fs.Get(context.Background(), shortURL)
+		require.NoError(t, err)
+		assert.True(t, result.Deleted)
+
+		result, err = fs.Get(context.Background(), "short1")
+		require.NoError(t, err)
+		assert.False(t, result.Deleted)
+	})
+
+	t.Run("GetStats", func(t *testing.T) {
+		stats, err := fs.GetStats(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 2, stats.URLs)
+		assert.Equal(t, 1, stats.Users)
+	})
+
+	t.Run("Reload from file", func(t *testing.T) {
+		// Новый экземпляр должен увидеть владельцев и удаления
+		reloaded, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reloaded.Close()
+
+		result, err := reloaded.Get(context.Background(), shortURL)
+		require.NoError(t, err)
+		assert.True(t, result.Deleted)
+		assert.Equal(t, userID, result.UserID)
+
+		urls, err := reloaded.GetUserURLs(context.Background(), userID)
+		require.NoError(t, err)
+		assert.Len(t, urls, 3)
 	})
 
 	t.Run("Ping", func(t *testing.T) {
@@ -186,3 +221,465 @@ func TestFileStore(t *testing.T) {
 		assert.Equal(t, "unable to access the store", err.Error())
 	})
 }
+
+func TestFileStoreLog(t *testing.T) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	t.Run("Append only", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		defer fs.Close()
+
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID))
+		require.NoError(t, fs.AddBatch(ctx, []models.ShortenBatchStore{
+			{CorrelationID: "1", ShortURL: "short2", OriginalURL: "https://example2.com"},
+			{CorrelationID: "2", ShortURL: "short3", OriginalURL: "https://example3.com"},
+		}, userID))
+		require.NoError(t, fs.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: "short1"})))
+
+		// Каждая операция записывается одной строкой, пакет тоже
+		data, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		require.Len(t, lines, 3)
+		assert.Contains(t, lines[0], `"op":"add"`)
+		assert.Contains(t, lines[1], `"short2"`)
+		assert.Contains(t, lines[1], `"short3"`)
+		assert.Contains(t, lines[2], `"op":"delete"`)
+
+		// UUID записи не меняется при повторном открытии
+		first, err := fs.Get(ctx, "short1")
+		require.NoError(t, err)
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		second, err := reopened.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+		assert.True(t, second.Deleted)
+	})
+
+	t.Run("Truncated tail is recovered", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID))
+		require.NoError(t, fs.Close())
+
+		// Имитируем обрыв записи посреди строки
+		file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND, 0644)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"op":"add","records":[{"short_url":"sho`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		recovered, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		defer recovered.Close()
+
+		_, err = recovered.Get(ctx, "short1")
+		require.NoError(t, err)
+
+		// После восстановления запись продолжается с новой строки
+		require.NoError(t, recovered.Add(ctx, "short2", "https://example2.com", userID))
+		require.NoError(t, recovered.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		urls, err := reopened.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+		assert.Len(t, urls, 2)
+	})
+
+	t.Run("Corrupted entry in the middle", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		content := `{"op":"add","records":[{"short_url":"short1","original_url":"https://example1.com"}]}
+not json
+{"op":"delete","short_urls":["short1"]}
+`
+		require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+		_, err := NewFileStore(filePath, Options{})
+		assert.Error(t, err)
+	})
+
+	t.Run("Failed append", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		defer fs.Close()
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID))
+
+		// Файл только для чтения: запись и откат строки завершаются ошибкой
+		writable := fs.file
+		fs.file, err = os.Open(filePath)
+		require.NoError(t, err)
+		assert.Error(t, fs.Add(ctx, "short2", "https://example2.com", userID))
+		require.NoError(t, fs.file.Close())
+
+		// Лог с оборванной строкой больше не дописывается
+		fs.file = writable
+		assert.Error(t, fs.Add(ctx, "short3", "https://example3.com", userID))
+		_, err = fs.Get(ctx, "short3")
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+		// Сжатие переписывает лог, после него запись возобновляется
+		require.NoError(t, fs.Compact())
+		require.NoError(t, fs.Add(ctx, "short3", "https://example3.com", userID))
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+		urls, err := reopened.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+		assert.Len(t, urls, 2)
+	})
+
+	t.Run("Legacy records", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.json")
+		content := fmt.Sprintf(`{"uuid":"%s","short_url":"short1","original_url":"https://example1.com","user_id":"%s","deleted":false}
+`, uuid.New(), userID)
+		require.NoError(t, os.WriteFile(filePath, []byte(content), 0644))
+
+		fs, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer fs.Close()
+
+		record, err := fs.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, userID, record.UserID)
+	})
+
+	t.Run("Compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncNever})
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.NoError(t, fs.Add(ctx, fmt.Sprintf("short%d", i), fmt.Sprintf("https://example%d.com", i), userID))
+		}
+		require.NoError(t, fs.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: "short0"})))
+
+		require.NoError(t, fs.Compact())
+
+		// Лог очищен, состояние перенесено в снимок
+		info, err := os.Stat(filePath)
+		require.NoError(t, err)
+		assert.Zero(t, info.Size())
+		_, err = os.Stat(filePath + snapshotSuffix)
+		require.NoError(t, err)
+
+		require.NoError(t, fs.Add(ctx, "short10", "https://example10.com", userID))
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		stats, err := reopened.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 10, stats.URLs)
+		assert.Equal(t, 1, stats.Users)
+
+		record, err := reopened.Get(ctx, "short0")
+		require.NoError(t, err)
+		assert.True(t, record.Deleted)
+	})
+
+	t.Run("Sequence survives restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		var last int64
+		for i := 0; i < sequenceBlock+1; i++ {
+			last, err = fs.NextID(ctx)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int64(sequenceBlock+1), last)
+		require.NoError(t, fs.Close())
+
+		// После перезапуска значения продолжаются с конца зарезервированного блока
+		reopened, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		id, err := reopened.NextID(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, id, last)
+		last = id
+
+		require.NoError(t, reopened.Compact())
+		require.NoError(t, reopened.Close())
+
+		compacted, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer compacted.Close()
+
+		id, err = compacted.NextID(ctx)
+		require.NoError(t, err)
+		assert.Greater(t, id, last)
+	})
+
+	t.Run("Click stats survive restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		now := time.Now()
+		click := models.Click{ShortURL: "short1", Time: now, Referrer: "https://ref.example", UserAgent: "curl", IP: "10.0.0.1"}
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID))
+		require.NoError(t, fs.RecordClicks(ctx, []models.Click{click, click}))
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		stats, err := reopened.GetLinkStats(ctx, "short1", 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stats.Clicks)
+		assert.Equal(t, int64(1), stats.UniqueVisitors)
+
+		// Снимок содержит накопленную статистику, а новые переходы пишутся в лог
+		require.NoError(t, reopened.Compact())
+		click.IP = "10.0.0.2"
+		require.NoError(t, reopened.RecordClicks(ctx, []models.Click{click}))
+		require.NoError(t, reopened.Close())
+
+		compacted, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer compacted.Close()
+
+		stats, err = compacted.GetLinkStats(ctx, "short1", 10)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.Clicks)
+		assert.Equal(t, int64(2), stats.UniqueVisitors)
+		assert.Equal(t, []models.ClickCount{{Value: "https://ref.example", Clicks: 3}}, stats.TopReferrers)
+		require.Len(t, stats.TimeSeries, 1)
+		assert.Equal(t, int64(3), stats.TimeSeries[0].Clicks)
+	})
+
+	t.Run("History survives restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID))
+		_, err = fs.UpdateOriginalURL(ctx, "short1", userID, "https://example2.com")
+		require.NoError(t, err)
+		_, err = fs.UpdateOriginalURL(ctx, "short1", userID, "https://example3.com")
+		require.NoError(t, err)
+		logData, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.NoError(t, fs.Compact())
+		require.NoError(t, fs.Close())
+
+		// Сбой до усечения лога: старый лог проигрывается поверх снимка
+		require.NoError(t, os.WriteFile(filePath, logData, 0644))
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		record, err := reopened.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example3.com", record.OriginalURL)
+		history, err := reopened.GetURLHistory(ctx, "short1")
+		require.NoError(t, err)
+		require.Len(t, history, 2)
+		assert.Equal(t, "https://example1.com", history[0].PreviousURL)
+		assert.Equal(t, 2, history[1].Version)
+
+		// Старый адрес освобожден, а новый занят ссылкой
+		assert.NoError(t, reopened.Add(ctx, "short2", "https://example1.com", userID))
+		assert.ErrorAs(t, reopened.Add(ctx, "short3", "https://example3.com", userID), new(*storeerr.ErrConflict))
+	})
+
+	t.Run("Used clicks survive restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID, models.WithMaxClicks(3)))
+		for i := 0; i < 2; i++ {
+			_, err = fs.ConsumeClick(ctx, "short1")
+			require.NoError(t, err)
+		}
+		logData, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.NoError(t, fs.Compact())
+		require.NoError(t, fs.Close())
+
+		// Повторное проигрывание старого лога не расходует переходы второй раз
+		require.NoError(t, os.WriteFile(filePath, logData, 0644))
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		left, err := reopened.ConsumeClick(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, 0, left)
+		_, err = reopened.ConsumeClick(ctx, "short1")
+		assert.ErrorIs(t, err, storeerr.ErrLinkExhausted)
+	})
+
+	t.Run("Accounts and merged URLs survive restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		account := models.Account{ID: uuid.New(), Email: "user@example.com", PasswordHash: "hash", CreatedAt: time.Now().UTC()}
+		anonymous := uuid.New()
+		require.NoError(t, fs.CreateAccount(ctx, account))
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", anonymous))
+		merged, err := fs.MergeUserURLs(ctx, anonymous, account.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"short1"}, merged)
+		logData, err := os.ReadFile(filePath)
+		require.NoError(t, err)
+		require.NoError(t, fs.Compact())
+		require.NoError(t, fs.Close())
+
+		// Сбой до усечения лога: старый лог проигрывается поверх снимка
+		require.NoError(t, os.WriteFile(filePath, logData, 0644))
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		stored, err := reopened.GetAccountByEmail(ctx, "user@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, account.ID, stored.ID)
+		assert.ErrorIs(t, reopened.CreateAccount(ctx, account), storeerr.ErrAccountExists)
+
+		record, err := reopened.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, account.ID, record.UserID)
+		urls, err := reopened.GetUserURLs(ctx, anonymous)
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+	})
+
+	t.Run("API keys and revocations survive restart and compaction", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		createdAt := time.Now().UTC().Truncate(time.Second)
+		active := models.APIKey{ID: uuid.New(), UserID: userID, Name: "ci", Prefix: "gsk_active", Hash: "hash1", Scopes: []string{models.ScopeLinksRead}, CreatedAt: createdAt}
+		revoked := models.APIKey{ID: uuid.New(), UserID: userID, Name: "old", Prefix: "gsk_revoked", Hash: "hash2", Scopes: []string{models.ScopeLinksWrite}, CreatedAt: createdAt.Add(time.Second)}
+		require.NoError(t, fs.CreateAPIKey(ctx, active))
+		require.NoError(t, fs.Compact())
+		require.NoError(t, fs.CreateAPIKey(ctx, revoked))
+		_, err = fs.RevokeAPIKey(ctx, userID, revoked.ID, createdAt.Add(time.Minute))
+		require.NoError(t, err)
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		keys, err := reopened.ListAPIKeys(ctx, userID)
+		require.NoError(t, err)
+		require.Len(t, keys, 2)
+		assert.Equal(t, active, keys[0])
+		require.NotNil(t, keys[1].RevokedAt)
+		assert.True(t, createdAt.Add(time.Minute).Equal(*keys[1].RevokedAt))
+
+		// Отозванный ключ нельзя отозвать повторно
+		_, err = reopened.RevokeAPIKey(ctx, userID, revoked.ID, time.Now())
+		assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+		stored, err := reopened.GetAPIKeyByHash(ctx, "hash1")
+		require.NoError(t, err)
+		assert.Equal(t, active.ID, stored.ID)
+	})
+
+	t.Run("Deletion time, restore and purge survive restart", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		for _, shortURL := range []string{"short1", "short2", "short3"} {
+			require.NoError(t, fs.Add(ctx, shortURL, "https://"+shortURL+".com", userID))
+		}
+		require.NoError(t, fs.DeleteUserURLs(ctx, worker.DeleteUserURLs(
+			models.UserShortURL{UserID: userID, ShortURL: "short1"},
+			models.UserShortURL{UserID: userID, ShortURL: "short2"},
+			models.UserShortURL{UserID: userID, ShortURL: "short3"},
+		)))
+		deleted, err := fs.Get(ctx, "short1")
+		require.NoError(t, err)
+		require.NotNil(t, deleted.DeletedAt)
+
+		restored, err := fs.RestoreUserURLs(ctx, userID, []string{"short2"}, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"short2"}, restored)
+
+		require.NoError(t, fs.Close())
+
+		// Время удаления берется из лога, а не из времени загрузки
+		reopened, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+		record, err := reopened.Get(ctx, "short1")
+		require.NoError(t, err)
+		require.NotNil(t, record.DeletedAt)
+		assert.True(t, deleted.DeletedAt.Equal(*record.DeletedAt))
+		record, err = reopened.Get(ctx, "short2")
+		require.NoError(t, err)
+		assert.False(t, record.Deleted)
+
+		purged, err := reopened.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, 2, purged)
+		require.NoError(t, reopened.Close())
+
+		purgedStore, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer purgedStore.Close()
+
+		for _, shortURL := range []string{"short1", "short3"} {
+			_, err = purgedStore.Get(ctx, shortURL)
+			assert.ErrorIs(t, err, ErrURLNotFound)
+		}
+		_, err = purgedStore.Get(ctx, "short2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Expiry and purge survive restart", func(t *testing.T) {
+		filePath := filepath.Join(t.TempDir(), "urls.log")
+		fs, err := NewFileStore(filePath, Options{SyncPolicy: SyncAlways})
+		require.NoError(t, err)
+
+		expiresAt := time.Now().Add(time.Hour).UTC()
+		require.NoError(t, fs.Add(ctx, "short1", "https://example1.com", userID, models.WithExpiresAt(expiresAt)))
+		require.NoError(t, fs.Add(ctx, "short2", "https://example2.com", userID, models.WithExpiresAt(time.Now().Add(-time.Hour))))
+
+		purged, err := fs.PurgeExpired(ctx, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+		require.NoError(t, fs.Close())
+
+		reopened, err := NewFileStore(filePath, Options{})
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		record, err := reopened.Get(ctx, "short1")
+		require.NoError(t, err)
+		require.NotNil(t, record.ExpiresAt)
+		assert.True(t, expiresAt.Equal(*record.ExpiresAt))
+
+		_, err = reopened.Get(ctx, "short2")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+	})
+
+	t.Run("Unknown sync policy", func(t *testing.T) {
+		_, err := NewFileStore("", Options{SyncPolicy: "sometimes"})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,179 @@
+package memstore
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// accounts holds the registered accounts indexed by ID and by email.
+type accounts struct {
+	mu     sync.RWMutex
+	byID   map[uuid.UUID]models.Account
+	emails map[string]uuid.UUID
+}
+
+func newAccounts() *accounts {
+	return &accounts{
+		byID:   make(map[uuid.UUID]models.Account),
+		emails: make(map[string]uuid.UUID),
+	}
+}
+
+// CreateAccount stores the account. It fails with storeerr.ErrAccountExists
+// if the email or the ID is already registered.
+func (m *MemStore) CreateAccount(ctx context.Context, account models.Account) error {
+	a := m.accounts
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.check(account); err != nil {
+		return err
+	}
+	a.put(account)
+
+	return nil
+}
+
+// CheckAccount reports whether CreateAccount would accept the account without storing it.
+func (m *MemStore) CheckAccount(account models.Account) error {
+	a := m.accounts
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.check(account)
+}
+
+// GetAccount returns the account with the ID.
+func (m *MemStore) GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error) {
+	a := m.accounts
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	account, ok := a.byID[id]
+	if !ok {
+		return models.Account{}, storeerr.ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+// GetAccountByEmail returns the account registered with the email.
+func (m *MemStore) GetAccountByEmail(ctx context.Context, email string) (models.Account, error) {
+	a := m.accounts
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	id, ok := a.emails[email]
+	if !ok {
+		return models.Account{}, storeerr.ErrAccountNotFound
+	}
+
+	return a.byID[id], nil
+}
+
+// PutAccounts stores the accounts without checking for conflicts, which
+// makes it suitable for restoring previously saved state.
+func (m *MemStore) PutAccounts(list ...models.Account) {
+	a := m.accounts
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, account := range list {
+		a.put(account)
+	}
+}
+
+// Accounts returns a copy of all stored accounts.
+func (m *MemStore) Accounts() []models.Account {
+	a := m.accounts
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	list := make([]models.Account, 0, len(a.byID))
+	for _, account := range a.byID {
+		list = append(list, account)
+	}
+
+	return list
+}
+
+// MergeUserURLs moves all the URLs of the user from, including deleted ones,
+// to the user to and returns their short URLs.
+func (m *MemStore) MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error) {
+	if from == to {
+		return nil, nil
+	}
+
+	unlock := m.lockAll()
+	defer unlock()
+
+	shortURLs := m.userURLs(from)
+	m.setOwner(to, shortURLs)
+
+	return shortURLs, nil
+}
+
+// UserURLs returns the short URLs of the user, including deleted ones.
+func (m *MemStore) UserURLs(userID uuid.UUID) []string {
+	s := m.shard(userKey(userID))
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return m.userURLs(userID)
+}
+
+// SetOwner moves the URLs to the user. Unknown URLs are ignored.
+func (m *MemStore) SetOwner(userID uuid.UUID, shortURLs ...string) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	m.setOwner(userID, shortURLs)
+}
+
+// userURLs returns a copy of the short URLs of the user. The caller must
+// hold the lock of the user's shard.
+func (m *MemStore) userURLs(userID uuid.UUID) []string {
+	o, ok := m.shard(userKey(userID)).owners[userID]
+	if !ok {
+		return nil
+	}
+	return append([]string{}, o.shortURLs...)
+}
+
+// setOwner moves the URLs to the user. The caller must hold the locks of all shards.
+func (m *MemStore) setOwner(userID uuid.UUID, shortURLs []string) {
+	for _, shortURL := range shortURLs {
+		record, ok := m.shard(shortURL).records[shortURL]
+		if !ok || record.UserID == userID {
+			continue
+		}
+		m.unlink(record)
+		record.UserID = userID
+		m.link(record)
+	}
+}
+
+// check looks for an account with the same email or ID. The caller must hold the lock.
+func (a *accounts) check(account models.Account) error {
+	if _, ok := a.byID[account.ID]; ok {
+		return storeerr.ErrAccountExists
+	}
+	if _, ok := a.emails[account.Email]; ok {
+		return storeerr.ErrAccountExists
+	}
+	return nil
+}
+
+// put stores the account. The caller must hold the lock.
+func (a *accounts) put(account models.Account) {
+	if previous, ok := a.byID[account.ID]; ok {
+		delete(a.emails, previous.Email)
+	}
+	a.byID[account.ID] = account
+	a.emails[account.Email] = account.ID
+}
@@ -0,0 +1,189 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// linkClicks holds the aggregated clicks of a single short URL.
+type linkClicks struct {
+	// buckets maps the start of a time bucket in Unix seconds to its clicks.
+	buckets    map[int64]int64
+	referrers  map[string]int64
+	userAgents map[string]int64
+	visitors   map[string]struct{}
+}
+
+func newLinkClicks() *linkClicks {
+	return &linkClicks{
+		buckets:    make(map[int64]int64),
+		referrers:  make(map[string]int64),
+		userAgents: make(map[string]int64),
+		visitors:   make(map[string]struct{}),
+	}
+}
+
+// add counts a single click.
+func (c *linkClicks) add(click models.Click) {
+	c.buckets[models.ClickBucketStart(click.Time).Unix()]++
+	if click.Referrer != "" {
+		c.referrers[click.Referrer]++
+	}
+	if click.UserAgent != "" {
+		c.userAgents[click.UserAgent]++
+	}
+	if click.IP != "" {
+		c.visitors[click.IP] = struct{}{}
+	}
+}
+
+// ClickAggregate holds the aggregated clicks of a short URL.
+// It is used to save click analytics and restore them later.
+type ClickAggregate struct {
+	ShortURL   string               `json:"short_url"`
+	Buckets    []models.ClickBucket `json:"buckets"`
+	Referrers  []models.ClickCount  `json:"referrers,omitempty"`
+	UserAgents []models.ClickCount  `json:"user_agents,omitempty"`
+	Visitors   []string             `json:"visitors,omitempty"`
+}
+
+// RecordClicks adds the clicks to the analytics of their short URLs.
+// Clicks on unknown short URLs are ignored.
+func (m *MemStore) RecordClicks(ctx context.Context, clicks []models.Click) error {
+	for _, click := range clicks {
+		s := m.shard(click.ShortURL)
+		s.mu.Lock()
+		if _, ok := s.records[click.ShortURL]; ok {
+			c, ok := s.clicks[click.ShortURL]
+			if !ok {
+				c = newLinkClicks()
+				s.clicks[click.ShortURL] = c
+			}
+			c.add(click)
+		}
+		s.mu.Unlock()
+	}
+
+	return nil
+}
+
+// GetLinkStats returns the click analytics of the short URL with at most top
+// referrers and user agents. The time series is sorted by time.
+func (m *MemStore) GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error) {
+	s := m.shard(shortURL)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.records[shortURL]; !ok {
+		return models.LinkStats{}, storeerr.ErrURLNotFound
+	}
+
+	stats := models.LinkStats{
+		ShortURL:      shortURL,
+		TimeSeries:    []models.ClickBucket{},
+		TopReferrers:  []models.ClickCount{},
+		TopUserAgents: []models.ClickCount{},
+	}
+	c, ok := s.clicks[shortURL]
+	if !ok {
+		return stats, nil
+	}
+
+	stats.TimeSeries = sortedBuckets(c.buckets)
+	for _, bucket := range stats.TimeSeries {
+		stats.Clicks += bucket.Clicks
+	}
+	stats.UniqueVisitors = int64(len(c.visitors))
+	stats.TopReferrers = topCounts(c.referrers, top)
+	stats.TopUserAgents = topCounts(c.userAgents, top)
+
+	return stats, nil
+}
+
+// ClickAggregates returns a copy of the click analytics of all short URLs.
+func (m *MemStore) ClickAggregates() []ClickAggregate {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	var aggregates []ClickAggregate
+	for _, s := range m.shards {
+		for shortURL, c := range s.clicks {
+			aggregate := ClickAggregate{
+				ShortURL:   shortURL,
+				Buckets:    sortedBuckets(c.buckets),
+				Referrers:  topCounts(c.referrers, 0),
+				UserAgents: topCounts(c.userAgents, 0),
+			}
+			for visitor := range c.visitors {
+				aggregate.Visitors = append(aggregate.Visitors, visitor)
+			}
+			sort.Strings(aggregate.Visitors)
+			aggregates = append(aggregates, aggregate)
+		}
+	}
+
+	return aggregates
+}
+
+// PutClickAggregates replaces the click analytics of the aggregated short URLs.
+// Unlike RecordClicks it does not require the short URLs to be stored, so
+// analytics can be restored before the records.
+func (m *MemStore) PutClickAggregates(aggregates ...ClickAggregate) {
+	for _, aggregate := range aggregates {
+		c := newLinkClicks()
+		for _, bucket := range aggregate.Buckets {
+			c.buckets[bucket.Time.Unix()] += bucket.Clicks
+		}
+		for _, referrer := range aggregate.Referrers {
+			c.referrers[referrer.Value] += referrer.Clicks
+		}
+		for _, userAgent := range aggregate.UserAgents {
+			c.userAgents[userAgent.Value] += userAgent.Clicks
+		}
+		for _, visitor := range aggregate.Visitors {
+			c.visitors[visitor] = struct{}{}
+		}
+
+		s := m.shard(aggregate.ShortURL)
+		s.mu.Lock()
+		s.clicks[aggregate.ShortURL] = c
+		s.mu.Unlock()
+	}
+}
+
+// sortedBuckets converts the buckets into a time series sorted by time.
+func sortedBuckets(buckets map[int64]int64) []models.ClickBucket {
+	series := make([]models.ClickBucket, 0, len(buckets))
+	for start, clicks := range buckets {
+		series = append(series, models.ClickBucket{Time: time.Unix(start, 0).UTC(), Clicks: clicks})
+	}
+	sort.Slice(series, func(i, j int) bool {
+		return series[i].Time.Before(series[j].Time)
+	})
+
+	return series
+}
+
+// topCounts returns the n values with the most clicks, ordered by clicks and
+// then by value. A non-positive n returns all values.
+func topCounts(counts map[string]int64, n int) []models.ClickCount {
+	result := make([]models.ClickCount, 0, len(counts))
+	for value, clicks := range counts {
+		result = append(result, models.ClickCount{Value: value, Clicks: clicks})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Clicks != result[j].Clicks {
+			return result[i].Clicks > result[j].Clicks
+		}
+		return result[i].Value < result[j].Value
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+
+	return result
+}
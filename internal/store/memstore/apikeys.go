@@ -0,0 +1,133 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// apiKeys holds the API keys indexed by ID and by hash.
+type apiKeys struct {
+	mu     sync.RWMutex
+	byID   map[uuid.UUID]models.APIKey
+	hashes map[string]uuid.UUID
+}
+
+func newAPIKeys() *apiKeys {
+	return &apiKeys{
+		byID:   make(map[uuid.UUID]models.APIKey),
+		hashes: make(map[string]uuid.UUID),
+	}
+}
+
+// CreateAPIKey stores the API key.
+func (m *MemStore) CreateAPIKey(ctx context.Context, key models.APIKey) error {
+	m.PutAPIKeys(key)
+	return nil
+}
+
+// GetAPIKeyByHash returns the API key with the hash, including a revoked one.
+func (m *MemStore) GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error) {
+	k := m.apiKeys
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	id, ok := k.hashes[hash]
+	if !ok {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+
+	return cloneAPIKey(k.byID[id]), nil
+}
+
+// ListAPIKeys returns the API keys of the user in the order of creation.
+func (m *MemStore) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error) {
+	k := m.apiKeys
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	var list []models.APIKey
+	for _, key := range k.byID {
+		if key.UserID == userID {
+			list = append(list, cloneAPIKey(key))
+		}
+	}
+	slices.SortFunc(list, func(a, b models.APIKey) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	return list, nil
+}
+
+// RevokeAPIKey revokes the active API key of the user and returns it.
+func (m *MemStore) RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error) {
+	key, err := m.CheckRevokeAPIKey(userID, id)
+	if err != nil {
+		return models.APIKey{}, err
+	}
+
+	key.RevokedAt = &at
+	m.PutAPIKeys(key)
+
+	return key, nil
+}
+
+// CheckRevokeAPIKey returns the API key that RevokeAPIKey would revoke without revoking it.
+func (m *MemStore) CheckRevokeAPIKey(userID, id uuid.UUID) (models.APIKey, error) {
+	k := m.apiKeys
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	key, ok := k.byID[id]
+	if !ok || key.UserID != userID || key.RevokedAt != nil {
+		return models.APIKey{}, storeerr.ErrAPIKeyNotFound
+	}
+
+	return cloneAPIKey(key), nil
+}
+
+// PutAPIKeys stores the API keys, replacing the keys with the same IDs,
+// which makes it suitable for restoring previously saved state.
+func (m *MemStore) PutAPIKeys(list ...models.APIKey) {
+	k := m.apiKeys
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, key := range list {
+		if previous, ok := k.byID[key.ID]; ok {
+			delete(k.hashes, previous.Hash)
+		}
+		k.byID[key.ID] = cloneAPIKey(key)
+		k.hashes[key.Hash] = key.ID
+	}
+}
+
+// APIKeys returns a copy of all stored API keys.
+func (m *MemStore) APIKeys() []models.APIKey {
+	k := m.apiKeys
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	list := make([]models.APIKey, 0, len(k.byID))
+	for _, key := range k.byID {
+		list = append(list, cloneAPIKey(key))
+	}
+
+	return list
+}
+
+// cloneAPIKey copies the key, so callers cannot change the stored scopes.
+func cloneAPIKey(key models.APIKey) models.APIKey {
+	key.Scopes = slices.Clone(key.Scopes)
+	if key.RevokedAt != nil {
+		revokedAt := *key.RevokedAt
+		key.RevokedAt = &revokedAt
+	}
+	return key
+}
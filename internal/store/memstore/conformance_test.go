@@ -0,0 +1,13 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storetest"
+)
+
+func TestConformance(t *testing.T) {
+	storetest.Run(t, config.Config{StorageBackend: store.BackendMemory}, store.DefaultStoreConstructor)
+}
@@ -0,0 +1,139 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// UpdateOriginalURL changes the original URL of the user's short URL and
+// records the change as a new version. It fails with storeerr.ErrURLNotFound
+// if the link is unknown, deleted or owned by another user, and with
+// *storeerr.ErrConflict if the new original URL is stored under another link.
+func (m *MemStore) UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	for {
+		record, err := m.Get(ctx, shortURL)
+		if err != nil {
+			return models.URLVersion{}, err
+		}
+
+		// The shard of the current original URL is known only after the record
+		// is read, so the record is checked again once all the locks are held.
+		unlock := m.lock(shortURL, record.OriginalURL, originalURL)
+		current, ok := m.shard(shortURL).records[shortURL]
+		if ok && current.OriginalURL != record.OriginalURL {
+			unlock()
+			continue
+		}
+
+		version, err := m.checkUpdate(shortURL, userID, originalURL)
+		if err == nil {
+			m.putVersion(version)
+		}
+		unlock()
+
+		return version, err
+	}
+}
+
+// CheckUpdate returns the version that UpdateOriginalURL would record
+// without changing the store.
+func (m *MemStore) CheckUpdate(shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	return m.checkUpdate(shortURL, userID, originalURL)
+}
+
+// GetURLHistory returns the changes of the original URL of the short URL
+// ordered by version.
+func (m *MemStore) GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error) {
+	s := m.shard(shortURL)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.records[shortURL]; !ok {
+		return nil, storeerr.ErrURLNotFound
+	}
+
+	return append([]models.URLVersion{}, s.history[shortURL]...), nil
+}
+
+// PutVersions stores the versions and points their links to the original URL
+// of the latest version. Versions that are already stored are skipped, and
+// versions of unknown links are kept until the links are put.
+// The versions of a link must be given in ascending order.
+func (m *MemStore) PutVersions(versions ...models.URLVersion) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	for _, version := range versions {
+		m.putVersion(version)
+	}
+}
+
+// History returns a copy of the changes of all stored links.
+func (m *MemStore) History() []models.URLVersion {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	var versions []models.URLVersion
+	for _, s := range m.shards {
+		for _, history := range s.history {
+			versions = append(versions, history...)
+		}
+	}
+
+	return versions
+}
+
+// checkUpdate builds the next version of the link. The caller must hold the
+// locks of the shards of the short URL and of both original URLs.
+func (m *MemStore) checkUpdate(shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error) {
+	record, ok := m.shard(shortURL).records[shortURL]
+	if !ok || record.UserID != userID || record.Deleted {
+		return models.URLVersion{}, storeerr.ErrURLNotFound
+	}
+	if existing, ok := m.shard(originalURL).originals[originalURL]; ok && existing != shortURL {
+		return models.URLVersion{}, &storeerr.ErrConflict{ShortURL: existing}
+	}
+
+	return models.URLVersion{
+		ShortURL:    shortURL,
+		Version:     len(m.shard(shortURL).history[shortURL]) + 1,
+		OriginalURL: originalURL,
+		PreviousURL: record.OriginalURL,
+		ChangedAt:   time.Now().UTC(),
+		ChangedBy:   userID,
+	}, nil
+}
+
+// putVersion appends the version to the history of its link and points the
+// link to the latest original URL. The caller must hold the locks of the
+// shards of the short URL and of both original URLs.
+func (m *MemStore) putVersion(version models.URLVersion) {
+	s := m.shard(version.ShortURL)
+	history := s.history[version.ShortURL]
+	if n := len(history); n == 0 || history[n-1].Version < version.Version {
+		history = append(history, version)
+		s.history[version.ShortURL] = history
+	}
+
+	// A replayed record may hold an outdated URL, so the link always points
+	// to the latest version, even if this one is already stored.
+	record, ok := s.records[version.ShortURL]
+	latest := history[len(history)-1].OriginalURL
+	if !ok || record.OriginalURL == latest {
+		return
+	}
+	if m.shard(record.OriginalURL).originals[record.OriginalURL] == record.ShortURL {
+		delete(m.shard(record.OriginalURL).originals, record.OriginalURL)
+	}
+	record.OriginalURL = latest
+	s.records[record.ShortURL] = record
+	m.shard(record.OriginalURL).originals[record.OriginalURL] = record.ShortURL
+}
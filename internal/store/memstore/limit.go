@@ -0,0 +1,76 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// ConsumeClick uses one redirect of a link with a click limit and returns the
+// number of redirects left. It returns -1 for links without a limit and
+// storeerr.ErrLinkExhausted once all the redirects are used.
+func (m *MemStore) ConsumeClick(ctx context.Context, shortURL string) (int, error) {
+	s := m.shard(shortURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, err := checkConsume(s, shortURL)
+	if err != nil || record.MaxClicks <= 0 {
+		return -1, err
+	}
+
+	record.UsedClicks++
+	s.records[shortURL] = record
+
+	return *record.ClicksLeft(), nil
+}
+
+// CheckConsume returns the record of the short URL as ConsumeClick would
+// leave it without changing the store.
+func (m *MemStore) CheckConsume(shortURL string) (models.ShortenStore, error) {
+	s := m.shard(shortURL)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, err := checkConsume(s, shortURL)
+	if err != nil {
+		return models.ShortenStore{}, err
+	}
+	if record.MaxClicks > 0 {
+		record.UsedClicks++
+	}
+
+	return record, nil
+}
+
+// PutUsedClicks raises the number of used redirects of the short URL to used.
+// A lower number is ignored, which makes repeated calls safe.
+// Unknown URLs are ignored.
+func (m *MemStore) PutUsedClicks(shortURL string, used int) {
+	s := m.shard(shortURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[shortURL]
+	if !ok || record.UsedClicks >= used {
+		return
+	}
+
+	record.UsedClicks = used
+	s.records[shortURL] = record
+}
+
+// checkConsume returns the record of the short URL if one more redirect is allowed.
+// The caller must hold the shard lock.
+func checkConsume(s *shard, shortURL string) (models.ShortenStore, error) {
+	record, ok := s.records[shortURL]
+	if !ok {
+		return models.ShortenStore{}, storeerr.ErrURLNotFound
+	}
+	if record.Exhausted() {
+		return models.ShortenStore{}, storeerr.ErrLinkExhausted
+	}
+
+	return record, nil
+}
@@ -0,0 +1,627 @@
+// Package memstore provides a concurrent in-memory storage implementation for the URL shortener service.
+//
+// Records are spread over shards by hashing their keys, so requests for
+// different links rarely wait for the same lock. An operation that touches
+// several shards locks them in ascending order to avoid deadlocks.
+package memstore
+
+import (
+	"context"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// DefaultShardCount is the number of shards used by NewMemStore.
+const DefaultShardCount = 32
+
+// owner holds the short URLs of a single user.
+type owner struct {
+	shortURLs []string
+}
+
+// shard holds the part of the data whose keys hash to it.
+type shard struct {
+	mu sync.RWMutex
+	// records maps a short URL to its record.
+	records map[string]models.ShortenStore
+	// originals maps an original URL to its short URL.
+	originals map[string]string
+	// owners maps a user ID to the user's URLs.
+	owners map[uuid.UUID]*owner
+	// clicks maps a short URL to its click analytics.
+	clicks map[string]*linkClicks
+	// history maps a short URL to the changes of its original URL.
+	history map[string][]models.URLVersion
+}
+
+// MemStore is a struct that represents the in-memory store.
+type MemStore struct {
+	shards []*shard
+	// sequence is the last value returned by NextID.
+	sequence atomic.Int64
+	// accounts holds the registered accounts, which are few compared to links.
+	accounts *accounts
+	// apiKeys holds the API keys of users.
+	apiKeys *apiKeys
+}
+
+// NewMemStore is a function that creates a new in-memory store.
+func NewMemStore() *MemStore {
+	return NewMemStoreWithShards(DefaultShardCount)
+}
+
+// NewMemStoreWithShards is a function that creates a new in-memory store
+// with the given number of shards.
+func NewMemStoreWithShards(count int) *MemStore {
+	if count < 1 {
+		count = 1
+	}
+
+	m := &MemStore{shards: make([]*shard, count), accounts: newAccounts(), apiKeys: newAPIKeys()}
+	for i := range m.shards {
+		m.shards[i] = &shard{
+			records:   make(map[string]models.ShortenStore),
+			originals: make(map[string]string),
+			owners:    make(map[uuid.UUID]*owner),
+			clicks:    make(map[string]*linkClicks),
+			history:   make(map[string][]models.URLVersion),
+		}
+	}
+
+	return m
+}
+
+// Add is a method that adds a new URL to the in-memory store.
+func (m *MemStore) Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error {
+	now := time.Now().UTC()
+	return m.Insert(models.ShortenStore{
+		UUID:        uuid.New(),
+		CreatedAt:   &now,
+		ShortURL:    shortURL,
+		OriginalURL: originalURL,
+		UserID:      userID,
+		LinkOptions: models.NewLinkOptions(opts...),
+	})
+}
+
+// Get is a method that retrieves the original URL from the in-memory store.
+func (m *MemStore) Get(ctx context.Context, shortURL string) (models.ShortenStore, error) {
+	s := m.shard(shortURL)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	record, ok := s.records[shortURL]
+	if !ok {
+		return models.ShortenStore{}, storeerr.ErrURLNotFound
+	}
+
+	return record, nil
+}
+
+// AddBatch is a method that adds a batch of URLs to the in-memory store.
+// The batch is stored atomically: if any URL conflicts, nothing is added.
+func (m *MemStore) AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error {
+	now := time.Now().UTC()
+	records := make([]models.ShortenStore, len(batchRequest))
+	for i, request := range batchRequest {
+		records[i] = models.ShortenStore{
+			UUID:        uuid.New(),
+			CreatedAt:   &now,
+			ShortURL:    request.ShortURL,
+			OriginalURL: request.OriginalURL,
+			UserID:      userID,
+			LinkOptions: request.LinkOptions,
+		}
+	}
+
+	return m.Insert(records...)
+}
+
+// GetUserURLs is a method that retrieves all URLs associated with the user ID.
+func (m *MemStore) GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error) {
+	s := m.shard(userKey(userID))
+	s.mu.RLock()
+	var shortURLs []string
+	if o, ok := s.owners[userID]; ok {
+		shortURLs = append(shortURLs, o.shortURLs...)
+	}
+	s.mu.RUnlock()
+
+	var urls []models.UserURLResponse
+	for _, shortURL := range shortURLs {
+		record, err := m.Get(ctx, shortURL)
+		if err != nil {
+			continue
+		}
+		urls = append(urls, models.UserURLResponse{
+			ShortURL:    record.ShortURL,
+			OriginalURL: record.OriginalURL,
+			ExpiresAt:   record.ExpiresAt,
+			DeletedAt:   record.DeletedAt,
+			Protected:   record.Protected(),
+			ClicksLeft:  record.ClicksLeft(),
+		})
+	}
+
+	return urls, nil
+}
+
+// DeleteUserURLs is a method that deletes URLs associated with the user ID.
+// URLs owned by other users are left untouched.
+func (m *MemStore) DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error {
+	var items []models.UserShortURL
+	var keys []string
+	for userShortURL := range userShortURLs {
+		items = append(items, userShortURL)
+		keys = append(keys, userShortURL.ShortURL, userKey(userShortURL.UserID))
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	unlock := m.lock(keys...)
+	defer unlock()
+
+	now := time.Now()
+	for _, item := range items {
+		record, ok := m.shard(item.ShortURL).records[item.ShortURL]
+		if !ok || record.UserID != item.UserID {
+			continue
+		}
+		m.setDeleted(record, true, now)
+	}
+
+	return nil
+}
+
+// RestoreUserURLs restores the user's URLs deleted at or after deletedAfter
+// and returns the restored short URLs. Other URLs are left untouched.
+func (m *MemStore) RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error) {
+	unlock := m.lock(append([]string{userKey(userID)}, shortURLs...)...)
+	defer unlock()
+
+	restored := m.restorable(userID, shortURLs, deletedAfter)
+	for _, shortURL := range restored {
+		m.setDeleted(m.shard(shortURL).records[shortURL], false, time.Time{})
+	}
+
+	return restored, nil
+}
+
+// RestorableUserURLs returns the short URLs that RestoreUserURLs would restore.
+func (m *MemStore) RestorableUserURLs(userID uuid.UUID, shortURLs []string, deletedAfter time.Time) []string {
+	unlock := m.rlock(shortURLs...)
+	defer unlock()
+
+	return m.restorable(userID, shortURLs, deletedAfter)
+}
+
+// PurgeDeleted removes the URLs deleted before the given time together with
+// their click analytics and history and returns their number.
+func (m *MemStore) PurgeDeleted(ctx context.Context, before time.Time) (int, error) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	purged := m.deletedBefore(before)
+	for _, shortURL := range purged {
+		m.drop(m.shard(shortURL).records[shortURL])
+	}
+
+	return len(purged), nil
+}
+
+// DeletedBefore returns the short URLs deleted before the given time.
+func (m *MemStore) DeletedBefore(before time.Time) []string {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	return m.deletedBefore(before)
+}
+
+// Ping is a method that checks the in-memory store, which is always available.
+func (m *MemStore) Ping() error {
+	return nil
+}
+
+// GetStats returns the number of active and expired URLs and of users with
+// active URLs in the in-memory store. Expiry depends on the current time,
+// so the records are counted on every call.
+func (m *MemStore) GetStats(ctx context.Context) (models.Stats, error) {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	now := time.Now()
+	var stats models.Stats
+	users := make(map[uuid.UUID]struct{})
+	for _, s := range m.shards {
+		for _, record := range s.records {
+			switch {
+			case record.Deleted:
+			case record.Expired(now):
+				stats.ExpiredURLs++
+			default:
+				stats.URLs++
+				users[record.UserID] = struct{}{}
+			}
+		}
+	}
+	stats.Users = len(users)
+
+	return stats, nil
+}
+
+// PurgeExpired removes the URLs that expired before the given time together
+// with their click analytics and history and returns their number.
+func (m *MemStore) PurgeExpired(ctx context.Context, before time.Time) (int, error) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	purged := m.expiredBefore(before)
+	for _, shortURL := range purged {
+		m.drop(m.shard(shortURL).records[shortURL])
+	}
+
+	return len(purged), nil
+}
+
+// ExpiredBefore returns the short URLs that expired before the given time.
+func (m *MemStore) ExpiredBefore(before time.Time) []string {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	return m.expiredBefore(before)
+}
+
+// Remove deletes the records of the short URLs together with their click
+// analytics and history. Unknown URLs are ignored.
+func (m *MemStore) Remove(shortURLs ...string) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	for _, shortURL := range shortURLs {
+		if record, ok := m.shard(shortURL).records[shortURL]; ok {
+			m.drop(record)
+		}
+	}
+}
+
+// NextID returns the next value of the store sequence, starting from 1.
+func (m *MemStore) NextID(ctx context.Context) (int64, error) {
+	return m.sequence.Add(1), nil
+}
+
+// Close is a method that releases the store. The in-memory store holds no resources.
+func (m *MemStore) Close() error {
+	return nil
+}
+
+// Insert adds the records atomically. It fails with *storeerr.ErrConflict if an
+// original URL is already stored, and with storeerr.ErrURLExists if a short URL
+// is taken or a URL is repeated in the records.
+func (m *MemStore) Insert(records ...models.ShortenStore) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	unlock := m.lock(insertKeys(records)...)
+	defer unlock()
+
+	if err := m.checkInsert(records); err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		m.link(record)
+	}
+
+	return nil
+}
+
+// CheckInsert reports whether Insert would accept the records without storing them.
+func (m *MemStore) CheckInsert(records ...models.ShortenStore) error {
+	unlock := m.rlock(insertKeys(records)...)
+	defer unlock()
+
+	return m.checkInsert(records)
+}
+
+// Put stores the records, replacing any records with the same short URL.
+// Unlike Insert it does not check for conflicts, which makes it suitable for
+// restoring previously saved state.
+func (m *MemStore) Put(records ...models.ShortenStore) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	for _, record := range records {
+		if previous, ok := m.shard(record.ShortURL).records[record.ShortURL]; ok {
+			m.unlink(previous)
+		}
+		m.link(record)
+	}
+}
+
+// SetDeleted marks the URLs as deleted at the given time or restores them.
+// Unknown URLs are ignored.
+func (m *MemStore) SetDeleted(deleted bool, at time.Time, shortURLs ...string) {
+	unlock := m.lockAll()
+	defer unlock()
+
+	for _, shortURL := range shortURLs {
+		if record, ok := m.shard(shortURL).records[shortURL]; ok {
+			m.setDeleted(record, deleted, at)
+		}
+	}
+}
+
+// Records returns a copy of all stored records.
+func (m *MemStore) Records() []models.ShortenStore {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	var records []models.ShortenStore
+	for _, s := range m.shards {
+		for _, record := range s.records {
+			records = append(records, record)
+		}
+	}
+
+	return records
+}
+
+// Len returns the number of stored records, including deleted ones.
+func (m *MemStore) Len() int {
+	unlock := m.rlockAll()
+	defer unlock()
+
+	count := 0
+	for _, s := range m.shards {
+		count += len(s.records)
+	}
+
+	return count
+}
+
+// checkInsert looks for conflicts among the records and with stored data.
+// The caller must hold the locks of the affected shards.
+func (m *MemStore) checkInsert(records []models.ShortenStore) error {
+	shortURLs := make(map[string]struct{}, len(records))
+	originalURLs := make(map[string]struct{}, len(records))
+	for _, record := range records {
+		// The original URL is checked first: a deterministic shortener produces
+		// the same short URL for it, and the caller needs the existing link.
+		if shortURL, ok := m.shard(record.OriginalURL).originals[record.OriginalURL]; ok {
+			return &storeerr.ErrConflict{ShortURL: shortURL}
+		}
+		if _, ok := m.shard(record.ShortURL).records[record.ShortURL]; ok {
+			return storeerr.ErrURLExists
+		}
+		if _, ok := shortURLs[record.ShortURL]; ok {
+			return storeerr.ErrURLExists
+		}
+		if _, ok := originalURLs[record.OriginalURL]; ok {
+			return storeerr.ErrURLExists
+		}
+		shortURLs[record.ShortURL] = struct{}{}
+		originalURLs[record.OriginalURL] = struct{}{}
+	}
+
+	return nil
+}
+
+// link adds the record and its indexes. The caller must hold the locks.
+func (m *MemStore) link(record models.ShortenStore) {
+	m.shard(record.ShortURL).records[record.ShortURL] = record
+	m.shard(record.OriginalURL).originals[record.OriginalURL] = record.ShortURL
+
+	s := m.shard(userKey(record.UserID))
+	o, ok := s.owners[record.UserID]
+	if !ok {
+		o = &owner{}
+		s.owners[record.UserID] = o
+	}
+	o.shortURLs = append(o.shortURLs, record.ShortURL)
+}
+
+// unlink removes the record and its indexes. The caller must hold the locks.
+func (m *MemStore) unlink(record models.ShortenStore) {
+	delete(m.shard(record.ShortURL).records, record.ShortURL)
+	delete(m.shard(record.OriginalURL).originals, record.OriginalURL)
+
+	s := m.shard(userKey(record.UserID))
+	o, ok := s.owners[record.UserID]
+	if !ok {
+		return
+	}
+	for i, shortURL := range o.shortURLs {
+		if shortURL == record.ShortURL {
+			o.shortURLs = append(o.shortURLs[:i], o.shortURLs[i+1:]...)
+			break
+		}
+	}
+	if len(o.shortURLs) == 0 {
+		delete(s.owners, record.UserID)
+	}
+}
+
+// drop removes the record together with its click analytics and history.
+// The caller must hold the locks of all shards.
+func (m *MemStore) drop(record models.ShortenStore) {
+	m.unlink(record)
+	delete(m.shard(record.ShortURL).clicks, record.ShortURL)
+	delete(m.shard(record.ShortURL).history, record.ShortURL)
+}
+
+// setDeleted changes the deleted flag of a stored record. A deleted record
+// keeps the time of its first deletion. The caller must hold the locks of the
+// record's shard and of its owner's shard.
+func (m *MemStore) setDeleted(record models.ShortenStore, deleted bool, at time.Time) {
+	if record.Deleted == deleted {
+		return
+	}
+
+	record.Deleted = deleted
+	record.DeletedAt = nil
+	if deleted {
+		deletedAt := at.UTC()
+		record.DeletedAt = &deletedAt
+	}
+	m.shard(record.ShortURL).records[record.ShortURL] = record
+}
+
+// restorable collects the user's deleted URLs among shortURLs that were
+// deleted at or after deletedAfter. The caller must hold the locks of the
+// URLs' shards.
+func (m *MemStore) restorable(userID uuid.UUID, shortURLs []string, deletedAfter time.Time) []string {
+	restorable := make([]string, 0, len(shortURLs))
+	for _, shortURL := range shortURLs {
+		record, ok := m.shard(shortURL).records[shortURL]
+		if !ok || record.UserID != userID || !record.Deleted {
+			continue
+		}
+		if record.DeletedAt != nil && record.DeletedAt.Before(deletedAfter) {
+			continue
+		}
+		if slices.Contains(restorable, shortURL) {
+			continue
+		}
+		restorable = append(restorable, shortURL)
+	}
+
+	return restorable
+}
+
+// deletedBefore collects the short URLs deleted before the given time.
+// The caller must hold the locks of all shards.
+func (m *MemStore) deletedBefore(before time.Time) []string {
+	var deleted []string
+	for _, s := range m.shards {
+		for _, record := range s.records {
+			if record.Deleted && record.DeletedAt != nil && record.DeletedAt.Before(before) {
+				deleted = append(deleted, record.ShortURL)
+			}
+		}
+	}
+
+	return deleted
+}
+
+// expiredBefore collects the short URLs that expired before the given time.
+// The caller must hold the locks of all shards.
+func (m *MemStore) expiredBefore(before time.Time) []string {
+	var expired []string
+	for _, s := range m.shards {
+		for _, record := range s.records {
+			if record.ExpiresAt != nil && record.ExpiresAt.Before(before) {
+				expired = append(expired, record.ShortURL)
+			}
+		}
+	}
+
+	return expired
+}
+
+// shard returns the shard that holds the key.
+func (m *MemStore) shard(key string) *shard {
+	return m.shards[m.shardIndex(key)]
+}
+
+func (m *MemStore) shardIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(m.shards)))
+}
+
+// indexes returns the sorted unique shard indexes of the keys.
+func (m *MemStore) indexes(keys []string) []int {
+	seen := make(map[int]struct{}, len(keys))
+	indexes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		i := m.shardIndex(key)
+		if _, ok := seen[i]; ok {
+			continue
+		}
+		seen[i] = struct{}{}
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	return indexes
+}
+
+// lock write-locks the shards of the keys and returns a function that unlocks them.
+func (m *MemStore) lock(keys ...string) func() {
+	indexes := m.indexes(keys)
+	for _, i := range indexes {
+		m.shards[i].mu.Lock()
+	}
+
+	return func() {
+		for j := len(indexes) - 1; j >= 0; j-- {
+			m.shards[indexes[j]].mu.Unlock()
+		}
+	}
+}
+
+// rlock read-locks the shards of the keys and returns a function that unlocks them.
+func (m *MemStore) rlock(keys ...string) func() {
+	indexes := m.indexes(keys)
+	for _, i := range indexes {
+		m.shards[i].mu.RLock()
+	}
+
+	return func() {
+		for j := len(indexes) - 1; j >= 0; j-- {
+			m.shards[indexes[j]].mu.RUnlock()
+		}
+	}
+}
+
+// lockAll write-locks every shard.
+func (m *MemStore) lockAll() func() {
+	for _, s := range m.shards {
+		s.mu.Lock()
+	}
+
+	return func() {
+		for j := len(m.shards) - 1; j >= 0; j-- {
+			m.shards[j].mu.Unlock()
+		}
+	}
+}
+
+// rlockAll read-locks every shard.
+func (m *MemStore) rlockAll() func() {
+	for _, s := range m.shards {
+		s.mu.RLock()
+	}
+
+	return func() {
+		for j := len(m.shards) - 1; j >= 0; j-- {
+			m.shards[j].mu.RUnlock()
+		}
+	}
+}
+
+// insertKeys returns the keys of all shards that Insert touches.
+func insertKeys(records []models.ShortenStore) []string {
+	keys := make([]string, 0, len(records)*3)
+	for _, record := range records {
+		keys = append(keys, record.ShortURL, record.OriginalURL, userKey(record.UserID))
+	}
+	return keys
+}
+
+// userKey returns the shard key of the user.
+func userKey(userID uuid.UUID) string {
+	return string(userID[:])
+}
@@ -0,0 +1,150 @@
+package memstore
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+func TestMemStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Single shard", func(t *testing.T) {
+		// Все ключи попадают в один шард, блокировки не должны зависать
+		ms := NewMemStoreWithShards(1)
+		userID := uuid.New()
+
+		require.NoError(t, ms.Add(ctx, "short1", "https://example1.com", userID))
+		require.NoError(t, ms.AddBatch(ctx, []models.ShortenBatchStore{
+			{CorrelationID: "1", ShortURL: "short2", OriginalURL: "https://example2.com"},
+		}, userID))
+		require.NoError(t, ms.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: "short1"})))
+
+		stats, err := ms.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.URLs)
+		assert.Equal(t, 1, stats.Users)
+	})
+
+	t.Run("Insert conflicts", func(t *testing.T) {
+		ms := NewMemStore()
+		record := models.ShortenStore{UUID: uuid.New(), ShortURL: "short1", OriginalURL: "https://example1.com", UserID: uuid.New()}
+		require.NoError(t, ms.Insert(record))
+
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, ms.CheckInsert(record), &conflict)
+		assert.Equal(t, "short1", conflict.ShortURL)
+
+		// Занятый короткий URL с другим оригинальным URL
+		other := models.ShortenStore{ShortURL: "short1", OriginalURL: "https://example2.com"}
+		assert.ErrorIs(t, ms.Insert(other), storeerr.ErrURLExists)
+		assert.NoError(t, ms.CheckInsert(models.ShortenStore{ShortURL: "short2", OriginalURL: "https://example2.com"}))
+		assert.Equal(t, 1, ms.Len())
+	})
+
+	t.Run("Put replaces records", func(t *testing.T) {
+		ms := NewMemStore()
+		alice, bob := uuid.New(), uuid.New()
+
+		ms.Put(models.ShortenStore{ShortURL: "short1", OriginalURL: "https://example1.com", UserID: alice})
+		ms.Put(models.ShortenStore{ShortURL: "short1", OriginalURL: "https://example2.com", UserID: bob})
+
+		record, err := ms.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://example2.com", record.OriginalURL)
+
+		// Старый исходный URL освобожден, а ссылка перешла к другому владельцу
+		assert.NoError(t, ms.CheckInsert(models.ShortenStore{ShortURL: "short2", OriginalURL: "https://example1.com"}))
+
+		urls, err := ms.GetUserURLs(ctx, alice)
+		require.NoError(t, err)
+		assert.Empty(t, urls)
+
+		stats, err := ms.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.URLs)
+		assert.Equal(t, 1, stats.Users)
+	})
+
+	t.Run("SetDeleted", func(t *testing.T) {
+		ms := NewMemStore()
+		userID := uuid.New()
+		require.NoError(t, ms.Add(ctx, "short1", "https://example1.com", userID))
+
+		deletedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		ms.SetDeleted(true, deletedAt, "short1", "unknown")
+		record, err := ms.Get(ctx, "short1")
+		require.NoError(t, err)
+		require.NotNil(t, record.DeletedAt)
+		assert.True(t, deletedAt.Equal(*record.DeletedAt))
+
+		stats, err := ms.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, stats.URLs)
+		assert.Zero(t, stats.Users)
+
+		ms.SetDeleted(false, time.Time{}, "short1")
+		record, err = ms.Get(ctx, "short1")
+		require.NoError(t, err)
+		assert.False(t, record.Deleted)
+		assert.Nil(t, record.DeletedAt)
+
+		stats, err = ms.GetStats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, stats.URLs)
+		assert.Equal(t, 1, stats.Users)
+	})
+
+	t.Run("GetUserURLs keeps insertion order", func(t *testing.T) {
+		ms := NewMemStore()
+		userID := uuid.New()
+
+		var expected []models.UserURLResponse
+		for i := 0; i < 10; i++ {
+			url := models.UserURLResponse{
+				ShortURL:    fmt.Sprintf("short%d", i),
+				OriginalURL: fmt.Sprintf("https://example%d.com", i),
+			}
+			require.NoError(t, ms.Add(ctx, url.ShortURL, url.OriginalURL, userID))
+			expected = append(expected, url)
+		}
+
+		urls, err := ms.GetUserURLs(ctx, userID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, urls)
+	})
+
+	t.Run("Records", func(t *testing.T) {
+		ms := NewMemStore()
+		require.NoError(t, ms.Add(ctx, "short1", "https://example1.com", uuid.New()))
+		require.NoError(t, ms.Add(ctx, "short2", "https://example2.com", uuid.New()))
+
+		records := ms.Records()
+		assert.Len(t, records, 2)
+	})
+}
+
+func BenchmarkGet(b *testing.B) {
+	ms := NewMemStore()
+	ctx := context.Background()
+	for i := 0; i < 10000; i++ {
+		ms.Add(ctx, fmt.Sprintf("short%d", i), fmt.Sprintf("https://example%d.com", i), uuid.New())
+	}
+
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			ms.Get(ctx, fmt.Sprintf("short%d", i%10000))
+			i++
+		}
+	})
+}
@@ -3,6 +3,9 @@ package store
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -11,38 +14,141 @@ import (
 	"github.com/learies/goShortener/internal/models"
 	"github.com/learies/goShortener/internal/store/dbstore"
 	"github.com/learies/goShortener/internal/store/filestore"
+	"github.com/learies/goShortener/internal/store/memstore"
 )
 
 // Store is an interface that defines the methods for the store.
 type Store interface {
-	Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID) error
+	Add(ctx context.Context, shortURL, originalURL string, userID uuid.UUID, opts ...models.LinkOption) error
 	Get(ctx context.Context, shortURL string) (models.ShortenStore, error)
 	AddBatch(ctx context.Context, batchRequest []models.ShortenBatchStore, userID uuid.UUID) error
 	GetUserURLs(ctx context.Context, userID uuid.UUID) ([]models.UserURLResponse, error)
 	DeleteUserURLs(ctx context.Context, userShortURLs <-chan models.UserShortURL) error
 	Ping() error
-	GetStats(ctx context.Context) (int, int, error)
+	GetStats(ctx context.Context) (models.Stats, error)
+	// PurgeExpired удаляет ссылки, срок действия которых истек до before,
+	// и возвращает их количество
+	PurgeExpired(ctx context.Context, before time.Time) (int, error)
+	// RestoreUserURLs восстанавливает удаленные не раньше deletedAfter ссылки
+	// пользователя и возвращает восстановленные короткие URL
+	RestoreUserURLs(ctx context.Context, userID uuid.UUID, shortURLs []string, deletedAfter time.Time) ([]string, error)
+	// PurgeDeleted окончательно удаляет ссылки, удаленные до before,
+	// и возвращает их количество
+	PurgeDeleted(ctx context.Context, before time.Time) (int, error)
+	// UpdateOriginalURL заменяет оригинальный URL действующей ссылки пользователя
+	// и сохраняет изменение в истории версий
+	UpdateOriginalURL(ctx context.Context, shortURL string, userID uuid.UUID, originalURL string) (models.URLVersion, error)
+	// GetURLHistory возвращает историю изменений оригинального URL по возрастанию версий
+	GetURLHistory(ctx context.Context, shortURL string) ([]models.URLVersion, error)
+	// ConsumeClick атомарно расходует один переход ссылки с ограничением числа
+	// переходов и возвращает число оставшихся. Для ссылки без ограничения
+	// возвращает -1, для исчерпанной — storeerr.ErrLinkExhausted
+	ConsumeClick(ctx context.Context, shortURL string) (int, error)
+	// RecordClicks добавляет переходы в аналитику коротких URL
+	RecordClicks(ctx context.Context, clicks []models.Click) error
+	// GetLinkStats возвращает аналитику переходов по короткому URL
+	// с не более чем top источниками и user agent
+	GetLinkStats(ctx context.Context, shortURL string, top int) (models.LinkStats, error)
+	// NextID возвращает следующее значение последовательности хранилища
+	NextID(ctx context.Context) (int64, error)
+	// CreateAccount сохраняет учетную запись. Если email или ID уже
+	// зарегистрированы, возвращает storeerr.ErrAccountExists
+	CreateAccount(ctx context.Context, account models.Account) error
+	// GetAccount возвращает учетную запись по ID или storeerr.ErrAccountNotFound
+	GetAccount(ctx context.Context, id uuid.UUID) (models.Account, error)
+	// GetAccountByEmail возвращает учетную запись по email или storeerr.ErrAccountNotFound
+	GetAccountByEmail(ctx context.Context, email string) (models.Account, error)
+	// MergeUserURLs передает все ссылки пользователя from, включая удаленные,
+	// пользователю to и возвращает их короткие URL
+	MergeUserURLs(ctx context.Context, from, to uuid.UUID) ([]string, error)
+	// CreateAPIKey сохраняет API-ключ пользователя
+	CreateAPIKey(ctx context.Context, key models.APIKey) error
+	// GetAPIKeyByHash возвращает API-ключ по хэшу, включая отозванные,
+	// или storeerr.ErrAPIKeyNotFound
+	GetAPIKeyByHash(ctx context.Context, hash string) (models.APIKey, error)
+	// ListAPIKeys возвращает API-ключи пользователя, включая отозванные,
+	// в порядке создания
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]models.APIKey, error)
+	// RevokeAPIKey отзывает API-ключ пользователя и возвращает его. Если у
+	// пользователя нет действующего ключа с таким ID, возвращает storeerr.ErrAPIKeyNotFound
+	RevokeAPIKey(ctx context.Context, userID, id uuid.UUID, at time.Time) (models.APIKey, error)
+	Close() error
 }
 
 // StoreConstructor определяет функцию создания хранилища
 type StoreConstructor func(cfg config.Config) (Store, error)
 
-// DefaultStoreConstructor реализует создание хранилища по умолчанию
+// Storage backends
+const (
+	BackendMemory   = "memory"
+	BackendFile     = "file"
+	BackendPostgres = "postgres"
+)
+
+// DefaultStoreConstructor реализует создание хранилища по умолчанию.
+// Хранилище выбирается по cfg.StorageBackend, а если он не задан —
+// по тому, какой из параметров DatabaseDSN и FilePath указан.
+// При cfg.CacheSize > 0 хранилище любого типа оборачивается кэшем CachedStore.
 func DefaultStoreConstructor(cfg config.Config) (Store, error) {
-	if cfg.DatabaseDSN != "" {
+	store, err := newBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CacheSize > 0 {
+		return NewCachedStore(store, CacheOptions{
+			Size:        cfg.CacheSize,
+			TTL:         cfg.CacheTTL,
+			NegativeTTL: cfg.CacheNegativeTTL,
+		}), nil
+	}
+
+	return store, nil
+}
+
+// newBackend создает хранилище, выбранное конфигурацией
+func newBackend(cfg config.Config) (Store, error) {
+	switch backend(cfg) {
+	case BackendPostgres:
+		if cfg.DatabaseDSN == "" {
+			return nil, errors.New("database DSN is required for the postgres storage backend")
+		}
 		db, err := database.Connect(cfg.DatabaseDSN)
 		if err != nil {
 			return nil, err
 		}
 		return &dbstore.DBStore{DB: db}, nil
+	case BackendFile:
+		if cfg.FilePath == "" {
+			return nil, errors.New("file path is required for the file storage backend")
+		}
+		store, err := filestore.NewFileStore(cfg.FilePath, filestore.Options{
+			SyncPolicy:      filestore.SyncPolicy(cfg.FileSyncPolicy),
+			CompactInterval: cfg.FileCompactInterval,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case BackendMemory:
+		return memstore.NewMemStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
 	}
+}
 
-	store := &filestore.FileStore{
-		URLMapping: make(map[string]string),
-		FilePath:   cfg.FilePath,
+// backend возвращает имя хранилища, выбранного конфигурацией
+func backend(cfg config.Config) string {
+	switch {
+	case cfg.StorageBackend != "":
+		return cfg.StorageBackend
+	case cfg.DatabaseDSN != "":
+		return BackendPostgres
+	case cfg.FilePath != "":
+		return BackendFile
+	default:
+		return BackendMemory
 	}
-
-	return store, nil
 }
 
 // NewStore хранит текущую функцию создания хранилища
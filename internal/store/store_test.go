@@ -0,0 +1,87 @@
+package store
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/config/logger"
+	"github.com/learies/goShortener/internal/store/filestore"
+	"github.com/learies/goShortener/internal/store/memstore"
+)
+
+func init() {
+	// Инициализация логгера для тестов
+	logger.Log = slog.New(slog.NewTextHandler(os.Stdout, nil))
+}
+
+func TestDefaultStoreConstructor(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "urls.log")
+
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		want    Store
+		wantErr bool
+	}{
+		{
+			name: "Memory by default",
+			cfg:  config.Config{},
+			want: &memstore.MemStore{},
+		},
+		{
+			name: "File when path is set",
+			cfg:  config.Config{FilePath: filePath},
+			want: &filestore.FileStore{},
+		},
+		{
+			name: "Cached file store",
+			cfg:  config.Config{FilePath: filePath, CacheSize: 10},
+			want: &CachedStore{},
+		},
+		{
+			name: "Cached memory store",
+			cfg:  config.Config{CacheSize: 10},
+			want: &CachedStore{},
+		},
+		{
+			name: "Explicit memory ignores file path",
+			cfg:  config.Config{StorageBackend: BackendMemory, FilePath: filePath},
+			want: &memstore.MemStore{},
+		},
+		{
+			name:    "File without path",
+			cfg:     config.Config{StorageBackend: BackendFile},
+			wantErr: true,
+		},
+		{
+			name:    "Postgres without DSN",
+			cfg:     config.Config{StorageBackend: BackendPostgres},
+			wantErr: true,
+		},
+		{
+			name:    "Unknown backend",
+			cfg:     config.Config{StorageBackend: "redis"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := DefaultStoreConstructor(tt.cfg)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer s.Close()
+
+			assert.IsType(t, tt.want, s)
+		})
+	}
+}
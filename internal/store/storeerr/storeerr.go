@@ -0,0 +1,40 @@
+// Package storeerr defines errors shared by all store implementations.
+package storeerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrURLNotFound is an error that indicates the URL was not found.
+var ErrURLNotFound = errors.New("URL not found")
+
+// ErrURLExists is an error that indicates the short URL is already taken
+// or that a batch repeats a URL.
+var ErrURLExists = errors.New("URL already exists")
+
+// ErrLinkExhausted is an error that indicates the link has used up all the
+// redirects allowed by its click limit.
+var ErrLinkExhausted = errors.New("link has no clicks left")
+
+// ErrAccountNotFound is an error that indicates the account was not found.
+var ErrAccountNotFound = errors.New("account not found")
+
+// ErrAccountExists is an error that indicates the email or the ID of an
+// account is already registered.
+var ErrAccountExists = errors.New("account already exists")
+
+// ErrAPIKeyNotFound is an error that indicates the API key was not found
+// or is already revoked.
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+// ErrConflict is an error that indicates the original URL is already shortened.
+// It carries the short URL stored for it.
+type ErrConflict struct {
+	ShortURL string
+}
+
+// Error implements the error interface.
+func (e *ErrConflict) Error() string {
+	return fmt.Sprintf("original URL is already shortened as %q", e.ShortURL)
+}
@@ -0,0 +1,1014 @@
+// Package storetest provides a conformance test suite for store.Store implementations.
+//
+// Every backend is expected to behave the same way through the store.Store
+// interface. A backend test calls Run with a constructor for that backend:
+//
+//	func TestConformance(t *testing.T) {
+//		storetest.Run(t, config.Config{FilePath: path}, store.DefaultStoreConstructor)
+//	}
+//
+// The suite only relies on data it creates itself, so it can run against a
+// store that already holds records, such as a shared test database.
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/learies/goShortener/internal/config"
+	"github.com/learies/goShortener/internal/models"
+	"github.com/learies/goShortener/internal/services/worker"
+	"github.com/learies/goShortener/internal/store"
+	"github.com/learies/goShortener/internal/store/storeerr"
+)
+
+// shortURLLength matches the length of the generated short URLs.
+const shortURLLength = 8
+
+const alphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Run checks that stores created by newStore satisfy the store.Store contract.
+// Each subtest gets its own store created from cfg.
+func Run(t *testing.T, cfg config.Config, newStore store.StoreConstructor) {
+	t.Helper()
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, s store.Store)
+	}{
+		{"AddAndGet", testAddAndGet},
+		{"GetNotFound", testGetNotFound},
+		{"DuplicateShortURL", testDuplicateShortURL},
+		{"DuplicateOriginalURL", testDuplicateOriginalURL},
+		{"LongShortURL", testLongShortURL},
+		{"AddBatch", testAddBatch},
+		{"AddBatchAtomic", testAddBatchAtomic},
+		{"DeleteUserURLs", testDeleteUserURLs},
+		{"DeleteForeignURLs", testDeleteForeignURLs},
+		{"DeleteMixedBatch", testDeleteMixedBatch},
+		{"RestoreAndPurgeDeleted", testRestoreAndPurgeDeleted},
+		{"History", testHistory},
+		{"UserIsolation", testUserIsolation},
+		{"Stats", testStats},
+		{"Expiration", testExpiration},
+		{"RedirectCode", testRedirectCode},
+		{"Preview", testPreview},
+		{"PasswordHash", testPasswordHash},
+		{"ClickLimit", testClickLimit},
+		{"Clicks", testClicks},
+		{"ConcurrentAccess", testConcurrentAccess},
+		{"Sequence", testSequence},
+		{"Accounts", testAccounts},
+		{"MergeUserURLs", testMergeUserURLs},
+		{"APIKeys", testAPIKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := newStore(cfg)
+			require.NoError(t, err)
+			t.Cleanup(func() {
+				assert.NoError(t, s.Close())
+			})
+
+			tt.run(t, s)
+		})
+	}
+}
+
+// newShortURL returns a random short URL that does not collide with other runs.
+func newShortURL() string {
+	b := make([]byte, shortURLLength)
+	for i := range b {
+		b[i] = alphabet[rand.IntN(len(alphabet))]
+	}
+	return string(b)
+}
+
+// newOriginalURL returns a unique original URL.
+func newOriginalURL() string {
+	return fmt.Sprintf("https://example.com/%s", uuid.NewString())
+}
+
+func testAddAndGet(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	shortURL, originalURL, userID := newShortURL(), newOriginalURL(), uuid.New()
+
+	require.NoError(t, s.Add(ctx, shortURL, originalURL, userID))
+
+	record, err := s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, originalURL, record.OriginalURL)
+	assert.False(t, record.Deleted)
+}
+
+func testGetNotFound(t *testing.T, s store.Store) {
+	_, err := s.Get(context.Background(), newShortURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+}
+
+func testDuplicateShortURL(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	shortURL, originalURL := newShortURL(), newOriginalURL()
+
+	require.NoError(t, s.Add(ctx, shortURL, originalURL, uuid.New()))
+	assert.ErrorIs(t, s.Add(ctx, shortURL, newOriginalURL(), uuid.New()), storeerr.ErrURLExists)
+
+	record, err := s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, originalURL, record.OriginalURL)
+}
+
+func testDuplicateOriginalURL(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	shortURL, originalURL := newShortURL(), newOriginalURL()
+
+	require.NoError(t, s.Add(ctx, shortURL, originalURL, uuid.New()))
+
+	otherShortURL := newShortURL()
+	err := s.Add(ctx, otherShortURL, originalURL, uuid.New())
+
+	var conflict *storeerr.ErrConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, shortURL, conflict.ShortURL)
+
+	_, err = s.Get(ctx, otherShortURL)
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+}
+
+// maxShortURLLength is the longest short URL a store must accept, as used by custom aliases.
+const maxShortURLLength = 64
+
+func testLongShortURL(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	shortURL := newShortURL() + strings.Repeat("-", maxShortURLLength-2*shortURLLength) + newShortURL()
+	originalURL := newOriginalURL()
+
+	require.NoError(t, s.Add(ctx, shortURL, originalURL, uuid.New()))
+
+	record, err := s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, originalURL, record.OriginalURL)
+
+	assert.ErrorIs(t, s.Add(ctx, shortURL, newOriginalURL(), uuid.New()), storeerr.ErrURLExists)
+}
+
+func testAddBatch(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	batch := []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+		{CorrelationID: "2", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+		{CorrelationID: "3", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+	}
+	require.NoError(t, s.AddBatch(ctx, batch, userID))
+
+	for _, item := range batch {
+		record, err := s.Get(ctx, item.ShortURL)
+		require.NoError(t, err)
+		assert.Equal(t, item.OriginalURL, record.OriginalURL)
+	}
+
+	// Correlation IDs are client strings, so the next batch may reuse them
+	again := []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+		{CorrelationID: "2", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+	}
+	require.NoError(t, s.AddBatch(ctx, again, userID))
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, len(batch)+len(again))
+}
+
+func testAddBatchAtomic(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+
+	existing, existingShortURL := newOriginalURL(), newShortURL()
+	require.NoError(t, s.Add(ctx, existingShortURL, existing, uuid.New()))
+
+	t.Run("ConflictWithStored", func(t *testing.T) {
+		batch := []models.ShortenBatchStore{
+			{CorrelationID: "1", ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+			{CorrelationID: "2", ShortURL: newShortURL(), OriginalURL: existing},
+		}
+		err := s.AddBatch(ctx, batch, userID)
+
+		var conflict *storeerr.ErrConflict
+		require.ErrorAs(t, err, &conflict)
+		assert.Equal(t, existingShortURL, conflict.ShortURL)
+
+		_, err = s.Get(ctx, batch[0].ShortURL)
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+
+	t.Run("ConflictWithinBatch", func(t *testing.T) {
+		shortURL := newShortURL()
+		batch := []models.ShortenBatchStore{
+			{CorrelationID: "1", ShortURL: shortURL, OriginalURL: newOriginalURL()},
+			{CorrelationID: "2", ShortURL: shortURL, OriginalURL: newOriginalURL()},
+		}
+		assert.ErrorIs(t, s.AddBatch(ctx, batch, userID), storeerr.ErrURLExists)
+
+		_, err := s.Get(ctx, shortURL)
+		assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	})
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testDeleteUserURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	deletedURL, keptURL := newShortURL(), newShortURL()
+
+	require.NoError(t, s.Add(ctx, deletedURL, newOriginalURL(), userID))
+	require.NoError(t, s.Add(ctx, keptURL, newOriginalURL(), userID))
+
+	err := s.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: deletedURL}))
+	require.NoError(t, err)
+
+	record, err := s.Get(ctx, deletedURL)
+	require.NoError(t, err)
+	assert.True(t, record.Deleted)
+
+	record, err = s.Get(ctx, keptURL)
+	require.NoError(t, err)
+	assert.False(t, record.Deleted)
+
+	// Deleting twice or deleting an unknown URL is not an error
+	err = s.DeleteUserURLs(ctx, worker.DeleteUserURLs(
+		models.UserShortURL{UserID: userID, ShortURL: deletedURL},
+		models.UserShortURL{UserID: userID, ShortURL: newShortURL()},
+	))
+	assert.NoError(t, err)
+}
+
+func testDeleteForeignURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	shortURL := newShortURL()
+
+	require.NoError(t, s.Add(ctx, shortURL, newOriginalURL(), uuid.New()))
+
+	err := s.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: uuid.New(), ShortURL: shortURL}))
+	require.NoError(t, err)
+
+	record, err := s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.False(t, record.Deleted)
+}
+
+// testDeleteMixedBatch checks a batch collected from several users' requests,
+// as the background deleter passes it to the store
+func testDeleteMixedBatch(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+	aliceURLs := []string{newShortURL(), newShortURL()}
+	bobURL := newShortURL()
+
+	for _, shortURL := range aliceURLs {
+		require.NoError(t, s.Add(ctx, shortURL, newOriginalURL(), alice))
+	}
+	require.NoError(t, s.Add(ctx, bobURL, newOriginalURL(), bob))
+
+	err := s.DeleteUserURLs(ctx, worker.DeleteUserURLs(
+		models.UserShortURL{UserID: alice, ShortURL: aliceURLs[0]},
+		models.UserShortURL{UserID: bob, ShortURL: aliceURLs[1]},
+		models.UserShortURL{UserID: bob, ShortURL: bobURL},
+		models.UserShortURL{UserID: alice, ShortURL: newShortURL()},
+	))
+	require.NoError(t, err)
+
+	for shortURL, deleted := range map[string]bool{aliceURLs[0]: true, aliceURLs[1]: false, bobURL: true} {
+		record, err := s.Get(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, deleted, record.Deleted, shortURL)
+	}
+}
+
+func testRestoreAndPurgeDeleted(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	restoredURL, purgedURL, liveURL := newShortURL(), newShortURL(), newShortURL()
+	purgedOriginal := newOriginalURL()
+
+	require.NoError(t, s.Add(ctx, restoredURL, newOriginalURL(), userID))
+	require.NoError(t, s.Add(ctx, purgedURL, purgedOriginal, userID))
+	require.NoError(t, s.Add(ctx, liveURL, newOriginalURL(), userID))
+
+	before := time.Now().Add(-time.Minute)
+	err := s.DeleteUserURLs(ctx, worker.DeleteUserURLs(
+		models.UserShortURL{UserID: userID, ShortURL: restoredURL},
+		models.UserShortURL{UserID: userID, ShortURL: purgedURL},
+	))
+	require.NoError(t, err)
+
+	record, err := s.Get(ctx, restoredURL)
+	require.NoError(t, err)
+	require.True(t, record.Deleted)
+	require.NotNil(t, record.DeletedAt)
+	assert.WithinDuration(t, time.Now(), *record.DeletedAt, time.Minute)
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	deletedAt := make(map[string]*time.Time)
+	for _, url := range urls {
+		deletedAt[url.ShortURL] = url.DeletedAt
+	}
+	assert.NotNil(t, deletedAt[restoredURL])
+	assert.Nil(t, deletedAt[liveURL])
+
+	// URLs deleted before the grace period started are not restored
+	restored, err := s.RestoreUserURLs(ctx, userID, []string{restoredURL}, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	// Neither are URLs of other users
+	restored, err = s.RestoreUserURLs(ctx, uuid.New(), []string{restoredURL}, before)
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	restored, err = s.RestoreUserURLs(ctx, userID, []string{restoredURL, liveURL, newShortURL()}, before)
+	require.NoError(t, err)
+	assert.Equal(t, []string{restoredURL}, restored)
+
+	record, err = s.Get(ctx, restoredURL)
+	require.NoError(t, err)
+	assert.False(t, record.Deleted)
+	assert.Nil(t, record.DeletedAt)
+
+	// Links deleted after the cutoff are kept
+	_, err = s.PurgeDeleted(ctx, before)
+	require.NoError(t, err)
+	_, err = s.Get(ctx, purgedURL)
+	require.NoError(t, err)
+
+	purged, err := s.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, 1)
+
+	_, err = s.Get(ctx, purgedURL)
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	for _, shortURL := range []string{restoredURL, liveURL} {
+		_, err = s.Get(ctx, shortURL)
+		assert.NoError(t, err)
+	}
+
+	// The original URL of a purged link can be shortened again
+	assert.NoError(t, s.Add(ctx, newShortURL(), purgedOriginal, userID))
+}
+
+func testHistory(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	shortURL, otherURL := newShortURL(), newShortURL()
+	firstURL, secondURL, thirdURL, otherOriginal := newOriginalURL(), newOriginalURL(), newOriginalURL(), newOriginalURL()
+
+	require.NoError(t, s.Add(ctx, shortURL, firstURL, userID))
+	require.NoError(t, s.Add(ctx, otherURL, otherOriginal, userID))
+
+	history, err := s.GetURLHistory(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+
+	version, err := s.UpdateOriginalURL(ctx, shortURL, userID, secondURL)
+	require.NoError(t, err)
+	assert.Equal(t, shortURL, version.ShortURL)
+	assert.Equal(t, 1, version.Version)
+	assert.Equal(t, secondURL, version.OriginalURL)
+	assert.Equal(t, firstURL, version.PreviousURL)
+	assert.Equal(t, userID, version.ChangedBy)
+	assert.WithinDuration(t, time.Now(), version.ChangedAt, time.Minute)
+
+	record, err := s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, secondURL, record.OriginalURL)
+
+	_, err = s.UpdateOriginalURL(ctx, shortURL, userID, thirdURL)
+	require.NoError(t, err)
+
+	history, err = s.GetURLHistory(ctx, shortURL)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, []int{1, 2}, []int{history[0].Version, history[1].Version})
+	assert.Equal(t, secondURL, history[1].PreviousURL)
+	assert.Equal(t, thirdURL, history[1].OriginalURL)
+
+	// The old original URL is free again, the one of another link is not
+	assert.NoError(t, s.Add(ctx, newShortURL(), firstURL, userID))
+	_, err = s.UpdateOriginalURL(ctx, shortURL, userID, otherOriginal)
+	var conflict *storeerr.ErrConflict
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, otherURL, conflict.ShortURL)
+
+	// Links of other users, deleted and unknown links cannot be changed
+	_, err = s.UpdateOriginalURL(ctx, shortURL, uuid.New(), newOriginalURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	_, err = s.UpdateOriginalURL(ctx, newShortURL(), userID, newOriginalURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	_, err = s.GetURLHistory(ctx, newShortURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+	err = s.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: shortURL}))
+	require.NoError(t, err)
+	_, err = s.UpdateOriginalURL(ctx, shortURL, userID, newOriginalURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+	record, err = s.Get(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Equal(t, thirdURL, record.OriginalURL)
+
+	// The history is purged together with the link
+	_, err = s.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.NoError(t, s.Add(ctx, shortURL, newOriginalURL(), userID))
+	history, err = s.GetURLHistory(ctx, shortURL)
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func testUserIsolation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice, bob := uuid.New(), uuid.New()
+
+	aliceURLs := []models.UserURLResponse{
+		{ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+		{ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+	}
+	bobURLs := []models.UserURLResponse{
+		{ShortURL: newShortURL(), OriginalURL: newOriginalURL()},
+	}
+	for _, url := range aliceURLs {
+		require.NoError(t, s.Add(ctx, url.ShortURL, url.OriginalURL, alice))
+	}
+	for _, url := range bobURLs {
+		require.NoError(t, s.Add(ctx, url.ShortURL, url.OriginalURL, bob))
+	}
+
+	urls, err := s.GetUserURLs(ctx, alice)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, aliceURLs, urls)
+
+	urls, err = s.GetUserURLs(ctx, bob)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, bobURLs, urls)
+
+	urls, err = s.GetUserURLs(ctx, uuid.New())
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testStats(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	before, err := s.GetStats(ctx)
+	require.NoError(t, err)
+
+	alice, bob := uuid.New(), uuid.New()
+	aliceURL, bobURL := newShortURL(), newShortURL()
+	require.NoError(t, s.Add(ctx, aliceURL, newOriginalURL(), alice))
+	require.NoError(t, s.Add(ctx, newShortURL(), newOriginalURL(), alice))
+	require.NoError(t, s.Add(ctx, bobURL, newOriginalURL(), bob))
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.URLs+3, stats.URLs)
+	assert.Equal(t, before.Users+2, stats.Users)
+
+	// Deleted URLs are not counted, neither are users without live URLs
+	err = s.DeleteUserURLs(ctx, worker.DeleteUserURLs(
+		models.UserShortURL{UserID: alice, ShortURL: aliceURL},
+		models.UserShortURL{UserID: bob, ShortURL: bobURL},
+	))
+	require.NoError(t, err)
+
+	stats, err = s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.URLs+1, stats.URLs)
+	assert.Equal(t, before.Users+1, stats.Users)
+	assert.Equal(t, before.ExpiredURLs, stats.ExpiredURLs)
+}
+
+func testExpiration(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	now := time.Now()
+
+	before, err := s.GetStats(ctx)
+	require.NoError(t, err)
+
+	userID := uuid.New()
+	expiredURL, activeURL, permanentURL := newShortURL(), newShortURL(), newShortURL()
+	require.NoError(t, s.Add(ctx, expiredURL, newOriginalURL(), userID, models.WithExpiresAt(now.Add(-time.Hour))))
+	require.NoError(t, s.Add(ctx, activeURL, newOriginalURL(), userID, models.WithExpiresAt(now.Add(time.Hour))))
+	require.NoError(t, s.Add(ctx, permanentURL, newOriginalURL(), userID))
+
+	record, err := s.Get(ctx, expiredURL)
+	require.NoError(t, err)
+	require.NotNil(t, record.ExpiresAt)
+	assert.WithinDuration(t, now.Add(-time.Hour), *record.ExpiresAt, time.Millisecond)
+	assert.True(t, record.Expired(now))
+
+	record, err = s.Get(ctx, activeURL)
+	require.NoError(t, err)
+	assert.False(t, record.Expired(now))
+
+	record, err = s.Get(ctx, permanentURL)
+	require.NoError(t, err)
+	assert.Nil(t, record.ExpiresAt)
+
+	// The expiry is stored for batches too
+	batchURL := newShortURL()
+	expiresAt := now.Add(time.Minute)
+	err = s.AddBatch(ctx, []models.ShortenBatchStore{{
+		CorrelationID: "1",
+		ShortURL:      batchURL,
+		OriginalURL:   newOriginalURL(),
+		LinkOptions:   models.LinkOptions{ExpiresAt: &expiresAt},
+	}}, userID)
+	require.NoError(t, err)
+
+	record, err = s.Get(ctx, batchURL)
+	require.NoError(t, err)
+	require.NotNil(t, record.ExpiresAt)
+	assert.WithinDuration(t, expiresAt, *record.ExpiresAt, time.Millisecond)
+
+	stats, err := s.GetStats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, before.URLs+3, stats.URLs)
+	assert.Equal(t, before.ExpiredURLs+1, stats.ExpiredURLs)
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 4)
+
+	// Links that expired after the cutoff are kept
+	_, err = s.PurgeExpired(ctx, now.Add(-2*time.Hour))
+	require.NoError(t, err)
+	_, err = s.Get(ctx, expiredURL)
+	require.NoError(t, err)
+
+	purged, err := s.PurgeExpired(ctx, now)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, purged, 1)
+
+	_, err = s.Get(ctx, expiredURL)
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+	for _, shortURL := range []string{activeURL, permanentURL, batchURL} {
+		_, err = s.Get(ctx, shortURL)
+		assert.NoError(t, err)
+	}
+
+	urls, err = s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, urls, 3)
+}
+
+func testRedirectCode(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	permanentURL, defaultURL, batchURL := newShortURL(), newShortURL(), newShortURL()
+
+	require.NoError(t, s.Add(ctx, permanentURL, newOriginalURL(), userID, models.WithRedirectCode(http.StatusPermanentRedirect)))
+	require.NoError(t, s.Add(ctx, defaultURL, newOriginalURL(), userID))
+	require.NoError(t, s.AddBatch(ctx, []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: batchURL, OriginalURL: newOriginalURL(), LinkOptions: models.LinkOptions{RedirectCode: http.StatusFound}},
+	}, userID))
+
+	for shortURL, code := range map[string]int{permanentURL: http.StatusPermanentRedirect, defaultURL: 0, batchURL: http.StatusFound} {
+		record, err := s.Get(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, code, record.RedirectCode)
+	}
+}
+
+func testPreview(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	titledURL, plainURL, batchURL := newShortURL(), newShortURL(), newShortURL()
+	before := time.Now().Add(-time.Second)
+
+	require.NoError(t, s.Add(ctx, titledURL, newOriginalURL(), userID, models.WithTitle("Заголовок"), models.WithPreview()))
+	require.NoError(t, s.Add(ctx, plainURL, newOriginalURL(), userID))
+	require.NoError(t, s.AddBatch(ctx, []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: batchURL, OriginalURL: newOriginalURL(), LinkOptions: models.LinkOptions{Title: "Пакет", Preview: true}},
+	}, userID))
+
+	tests := []struct {
+		shortURL string
+		title    string
+		preview  bool
+	}{
+		{titledURL, "Заголовок", true},
+		{plainURL, "", false},
+		{batchURL, "Пакет", true},
+	}
+	for _, tt := range tests {
+		record, err := s.Get(ctx, tt.shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, tt.title, record.Title)
+		assert.Equal(t, tt.preview, record.Preview)
+		require.NotNil(t, record.CreatedAt)
+		assert.WithinRange(t, *record.CreatedAt, before, time.Now().Add(time.Second))
+	}
+}
+
+func testPasswordHash(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	protectedURL, publicURL, batchURL := newShortURL(), newShortURL(), newShortURL()
+
+	require.NoError(t, s.Add(ctx, protectedURL, newOriginalURL(), userID, models.WithPasswordHash("hash1")))
+	require.NoError(t, s.Add(ctx, publicURL, newOriginalURL(), userID))
+	require.NoError(t, s.AddBatch(ctx, []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: batchURL, OriginalURL: newOriginalURL(), LinkOptions: models.LinkOptions{PasswordHash: "hash2"}},
+	}, userID))
+
+	hashes := map[string]string{protectedURL: "hash1", publicURL: "", batchURL: "hash2"}
+	for shortURL, hash := range hashes {
+		record, err := s.Get(ctx, shortURL)
+		require.NoError(t, err)
+		assert.Equal(t, hash, record.PasswordHash)
+	}
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, urls, 3)
+	for _, url := range urls {
+		assert.Equal(t, hashes[url.ShortURL] != "", url.Protected, url.ShortURL)
+	}
+}
+
+func testClickLimit(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID := uuid.New()
+	limitedURL, unlimitedURL, batchURL := newShortURL(), newShortURL(), newShortURL()
+	const maxClicks = 3
+
+	require.NoError(t, s.Add(ctx, limitedURL, newOriginalURL(), userID, models.WithMaxClicks(maxClicks)))
+	require.NoError(t, s.Add(ctx, unlimitedURL, newOriginalURL(), userID))
+	require.NoError(t, s.AddBatch(ctx, []models.ShortenBatchStore{
+		{CorrelationID: "1", ShortURL: batchURL, OriginalURL: newOriginalURL(), LinkOptions: models.LinkOptions{MaxClicks: 1}},
+	}, userID))
+
+	_, err := s.ConsumeClick(ctx, newShortURL())
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+	left, err := s.ConsumeClick(ctx, unlimitedURL)
+	require.NoError(t, err)
+	assert.Equal(t, -1, left)
+
+	// Конкурентные переходы не превышают ограничение
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var consumed, exhausted int
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.ConsumeClick(ctx, limitedURL)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				consumed++
+			case errors.Is(err, storeerr.ErrLinkExhausted):
+				exhausted++
+			default:
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, maxClicks, consumed)
+	assert.Equal(t, 10-maxClicks, exhausted)
+
+	record, err := s.Get(ctx, limitedURL)
+	require.NoError(t, err)
+	assert.True(t, record.Exhausted())
+
+	left, err = s.ConsumeClick(ctx, batchURL)
+	require.NoError(t, err)
+	assert.Equal(t, 0, left)
+
+	urls, err := s.GetUserURLs(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, urls, 3)
+	for _, url := range urls {
+		switch url.ShortURL {
+		case unlimitedURL:
+			assert.Nil(t, url.ClicksLeft)
+		default:
+			require.NotNil(t, url.ClicksLeft, url.ShortURL)
+			assert.Zero(t, *url.ClicksLeft)
+		}
+	}
+}
+
+func testClicks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	hour := models.ClickBucketStart(time.Now()).Add(-2 * time.Hour)
+
+	shortURL, unknownURL := newShortURL(), newShortURL()
+	require.NoError(t, s.Add(ctx, shortURL, newOriginalURL(), uuid.New()))
+
+	_, err := s.GetLinkStats(ctx, unknownURL, 10)
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+
+	stats, err := s.GetLinkStats(ctx, shortURL, 10)
+	require.NoError(t, err)
+	assert.Equal(t, shortURL, stats.ShortURL)
+	assert.Zero(t, stats.Clicks)
+	assert.Empty(t, stats.TimeSeries)
+
+	click := func(at time.Time, referrer, userAgent, ip string) models.Click {
+		return models.Click{ShortURL: shortURL, Time: at, Referrer: referrer, UserAgent: userAgent, IP: ip}
+	}
+	err = s.RecordClicks(ctx, []models.Click{
+		click(hour.Add(time.Minute), "https://a.example", "curl", "10.0.0.1"),
+		click(hour.Add(30*time.Minute), "https://a.example", "firefox", "10.0.0.2"),
+		click(hour.Add(time.Hour), "https://b.example", "curl", "10.0.0.1"),
+		click(hour.Add(time.Hour+time.Second), "", "", ""),
+		// Переходы по неизвестным ссылкам игнорируются
+		{ShortURL: unknownURL, Time: hour, IP: "10.0.0.3"},
+	})
+	require.NoError(t, err)
+
+	// Повторная запись суммируется с уже накопленной статистикой
+	err = s.RecordClicks(ctx, []models.Click{click(hour.Add(time.Hour), "https://b.example", "firefox", "10.0.0.3")})
+	require.NoError(t, err)
+
+	stats, err = s.GetLinkStats(ctx, shortURL, 10)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), stats.Clicks)
+	assert.Equal(t, int64(3), stats.UniqueVisitors)
+	require.Len(t, stats.TimeSeries, 2)
+	assert.True(t, hour.Equal(stats.TimeSeries[0].Time))
+	assert.Equal(t, int64(2), stats.TimeSeries[0].Clicks)
+	assert.True(t, hour.Add(time.Hour).Equal(stats.TimeSeries[1].Time))
+	assert.Equal(t, int64(3), stats.TimeSeries[1].Clicks)
+	assert.Equal(t, []models.ClickCount{
+		{Value: "https://a.example", Clicks: 2},
+		{Value: "https://b.example", Clicks: 2},
+	}, stats.TopReferrers)
+	assert.Equal(t, []models.ClickCount{
+		{Value: "curl", Clicks: 2},
+		{Value: "firefox", Clicks: 2},
+	}, stats.TopUserAgents)
+
+	stats, err = s.GetLinkStats(ctx, shortURL, 1)
+	require.NoError(t, err)
+	assert.Len(t, stats.TopReferrers, 1)
+	assert.Len(t, stats.TopUserAgents, 1)
+
+	_, err = s.GetLinkStats(ctx, unknownURL, 10)
+	assert.ErrorIs(t, err, storeerr.ErrURLNotFound)
+}
+
+func testConcurrentAccess(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	const workers = 8
+	const perWorker = 20
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			userID := uuid.New()
+			shortURLs := make([]string, 0, perWorker)
+			for j := 0; j < perWorker; j++ {
+				shortURL := newShortURL()
+				if !assert.NoError(t, s.Add(ctx, shortURL, newOriginalURL(), userID)) {
+					return
+				}
+				shortURLs = append(shortURLs, shortURL)
+
+				_, err := s.Get(ctx, shortURL)
+				assert.NoError(t, err)
+				_, err = s.GetStats(ctx)
+				assert.NoError(t, err)
+			}
+
+			err := s.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: userID, ShortURL: shortURLs[0]}))
+			assert.NoError(t, err)
+
+			urls, err := s.GetUserURLs(ctx, userID)
+			assert.NoError(t, err)
+			assert.Len(t, urls, perWorker)
+		}()
+	}
+	wg.Wait()
+}
+
+func testSequence(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	const workers = 8
+	const perWorker = 50
+
+	var mu sync.Mutex
+	seen := make(map[int64]struct{}, workers*perWorker)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var last int64
+			for j := 0; j < perWorker; j++ {
+				id, err := s.NextID(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+				// Values grow for every caller and are never repeated
+				assert.Greater(t, id, last)
+				last = id
+
+				mu.Lock()
+				_, dup := seen[id]
+				seen[id] = struct{}{}
+				mu.Unlock()
+				assert.False(t, dup, "duplicate id %d", id)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, seen, workers*perWorker)
+}
+
+// newAccount returns an account with a unique email.
+func newAccount() models.Account {
+	return models.Account{
+		ID:           uuid.New(),
+		Email:        uuid.NewString() + "@example.com",
+		PasswordHash: "$2a$10$" + uuid.NewString(),
+		CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+	}
+}
+
+func testAccounts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	account := newAccount()
+
+	_, err := s.GetAccount(ctx, account.ID)
+	assert.ErrorIs(t, err, storeerr.ErrAccountNotFound)
+	_, err = s.GetAccountByEmail(ctx, account.Email)
+	assert.ErrorIs(t, err, storeerr.ErrAccountNotFound)
+
+	require.NoError(t, s.CreateAccount(ctx, account))
+
+	stored, err := s.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, account.Email, stored.Email)
+	assert.Equal(t, account.PasswordHash, stored.PasswordHash)
+	assert.True(t, account.CreatedAt.Equal(stored.CreatedAt))
+
+	stored, err = s.GetAccountByEmail(ctx, account.Email)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, stored.ID)
+
+	// Email и ID учетной записи уникальны
+	sameEmail := newAccount()
+	sameEmail.Email = account.Email
+	assert.ErrorIs(t, s.CreateAccount(ctx, sameEmail), storeerr.ErrAccountExists)
+	sameID := newAccount()
+	sameID.ID = account.ID
+	assert.ErrorIs(t, s.CreateAccount(ctx, sameID), storeerr.ErrAccountExists)
+
+	_, err = s.GetAccount(ctx, sameEmail.ID)
+	assert.ErrorIs(t, err, storeerr.ErrAccountNotFound)
+	_, err = s.GetAccountByEmail(ctx, sameID.Email)
+	assert.ErrorIs(t, err, storeerr.ErrAccountNotFound)
+}
+
+func testMergeUserURLs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	anonymous, account, other := uuid.New(), uuid.New(), uuid.New()
+	activeURL, deletedURL, accountURL, otherURL := newShortURL(), newShortURL(), newShortURL(), newShortURL()
+
+	require.NoError(t, s.Add(ctx, activeURL, newOriginalURL(), anonymous))
+	require.NoError(t, s.Add(ctx, deletedURL, newOriginalURL(), anonymous))
+	require.NoError(t, s.Add(ctx, accountURL, newOriginalURL(), account))
+	require.NoError(t, s.Add(ctx, otherURL, newOriginalURL(), other))
+	require.NoError(t, s.DeleteUserURLs(ctx, worker.DeleteUserURLs(models.UserShortURL{UserID: anonymous, ShortURL: deletedURL})))
+
+	merged, err := s.MergeUserURLs(ctx, anonymous, account)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{activeURL, deletedURL}, merged)
+
+	urls, err := s.GetUserURLs(ctx, anonymous)
+	require.NoError(t, err)
+	assert.Empty(t, urls)
+
+	urls, err = s.GetUserURLs(ctx, account)
+	require.NoError(t, err)
+	var shortURLs []string
+	for _, url := range urls {
+		shortURLs = append(shortURLs, url.ShortURL)
+	}
+	assert.ElementsMatch(t, []string{activeURL, deletedURL, accountURL}, shortURLs)
+
+	record, err := s.Get(ctx, activeURL)
+	require.NoError(t, err)
+	assert.Equal(t, account, record.UserID)
+
+	// Удаленные ссылки переходят вместе с корзиной
+	restored, err := s.RestoreUserURLs(ctx, account, []string{deletedURL}, time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []string{deletedURL}, restored)
+
+	record, err = s.Get(ctx, otherURL)
+	require.NoError(t, err)
+	assert.Equal(t, other, record.UserID)
+
+	merged, err = s.MergeUserURLs(ctx, anonymous, account)
+	require.NoError(t, err)
+	assert.Empty(t, merged)
+}
+
+func testAPIKeys(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	userID, other := uuid.New(), uuid.New()
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	newKey := func(userID uuid.UUID, createdAt time.Time, scopes ...string) models.APIKey {
+		id := uuid.New()
+		return models.APIKey{
+			ID:        id,
+			UserID:    userID,
+			Name:      "key " + id.String()[:8],
+			Prefix:    models.APIKeyPrefix + id.String()[:8],
+			Hash:      "hash-" + id.String(),
+			Scopes:    scopes,
+			CreatedAt: createdAt,
+		}
+	}
+
+	keys, err := s.ListAPIKeys(ctx, userID)
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	_, err = s.GetAPIKeyByHash(ctx, "hash-unknown")
+	assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+
+	second := newKey(userID, createdAt.Add(time.Second), models.ScopeLinksRead, models.ScopeStatsRead)
+	first := newKey(userID, createdAt, models.ScopeLinksWrite)
+	foreign := newKey(other, createdAt, models.ScopeLinksDelete)
+	for _, key := range []models.APIKey{second, first, foreign} {
+		require.NoError(t, s.CreateAPIKey(ctx, key))
+	}
+
+	stored, err := s.GetAPIKeyByHash(ctx, second.Hash)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, stored.ID)
+	assert.Equal(t, userID, stored.UserID)
+	assert.Equal(t, second.Prefix, stored.Prefix)
+	assert.Equal(t, second.Scopes, stored.Scopes)
+	assert.True(t, second.CreatedAt.Equal(stored.CreatedAt))
+	assert.Nil(t, stored.RevokedAt)
+
+	// Ключи возвращаются в порядке создания и только владельцу
+	keys, err = s.ListAPIKeys(ctx, userID)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	assert.Equal(t, first.ID, keys[0].ID)
+	assert.Equal(t, second.ID, keys[1].ID)
+
+	// Чужой ключ отозвать нельзя
+	_, err = s.RevokeAPIKey(ctx, userID, foreign.ID, createdAt)
+	assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+
+	revokedAt := createdAt.Add(time.Minute)
+	revoked, err := s.RevokeAPIKey(ctx, userID, first.ID, revokedAt)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, revoked.ID)
+	require.NotNil(t, revoked.RevokedAt)
+	assert.True(t, revokedAt.Equal(*revoked.RevokedAt))
+
+	// Отозванный ключ остается в списке и находится по хэшу
+	stored, err = s.GetAPIKeyByHash(ctx, first.Hash)
+	require.NoError(t, err)
+	require.NotNil(t, stored.RevokedAt)
+	_, err = s.RevokeAPIKey(ctx, userID, first.ID, revokedAt)
+	assert.ErrorIs(t, err, storeerr.ErrAPIKeyNotFound)
+	keys, err = s.ListAPIKeys(ctx, userID)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
@@ -23,8 +23,24 @@ const (
 
 // Request/Response messages
 type CreateShortURLRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Url           string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Url   string                 `protobuf:"bytes,1,opt,name=url,proto3" json:"url,omitempty"`
+	// Optional custom alias used instead of a generated short URL
+	Alias string `protobuf:"bytes,2,opt,name=alias,proto3" json:"alias,omitempty"`
+	// Optional lifetime, a duration such as "24h" or a number of seconds
+	ExpiresIn string `protobuf:"bytes,3,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"`
+	// Optional RFC 3339 expiry time, mutually exclusive with expires_in
+	ExpiresAt string `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// Optional HTTP status of the redirect: 301, 302, 307 or 308
+	RedirectCode int32 `protobuf:"varint,5,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"`
+	// Optional title shown on the preview page of the link
+	Title string `protobuf:"bytes,6,opt,name=title,proto3" json:"title,omitempty"`
+	// Show the preview page instead of redirecting for every visitor
+	Preview bool `protobuf:"varint,7,opt,name=preview,proto3" json:"preview,omitempty"`
+	// Optional password visitors must enter before the redirect
+	Password string `protobuf:"bytes,8,opt,name=password,proto3" json:"password,omitempty"`
+	// Optional number of redirects after which the link is used up; 1 makes a one-time link
+	MaxClicks     int32 `protobuf:"varint,9,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -66,6 +82,62 @@ func (x *CreateShortURLRequest) GetUrl() string {
 	return ""
 }
 
+func (x *CreateShortURLRequest) GetAlias() string {
+	if x != nil {
+		return x.Alias
+	}
+	return ""
+}
+
+func (x *CreateShortURLRequest) GetExpiresIn() string {
+	if x != nil {
+		return x.ExpiresIn
+	}
+	return ""
+}
+
+func (x *CreateShortURLRequest) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *CreateShortURLRequest) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
+	}
+	return 0
+}
+
+func (x *CreateShortURLRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *CreateShortURLRequest) GetPreview() bool {
+	if x != nil {
+		return x.Preview
+	}
+	return false
+}
+
+func (x *CreateShortURLRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *CreateShortURLRequest) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
 type CreateShortURLResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Result        string                 `protobuf:"bytes,1,opt,name=result,proto3" json:"result,omitempty"`
@@ -111,8 +183,10 @@ func (x *CreateShortURLResponse) GetResult() string {
 }
 
 type GetOriginalURLRequest struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	// Password of a protected link
+	Password      string `protobuf:"bytes,2,opt,name=password,proto3" json:"password,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -154,6 +228,13 @@ func (x *GetOriginalURLRequest) GetShortUrl() string {
 	return ""
 }
 
+func (x *GetOriginalURLRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
 type GetOriginalURLResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	OriginalUrl   string                 `protobuf:"bytes,1,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
@@ -246,6 +327,13 @@ type BatchURLRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	CorrelationId string                 `protobuf:"bytes,1,opt,name=correlation_id,json=correlationId,proto3" json:"correlation_id,omitempty"`
 	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	ExpiresIn     string                 `protobuf:"bytes,3,opt,name=expires_in,json=expiresIn,proto3" json:"expires_in,omitempty"`
+	ExpiresAt     string                 `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	RedirectCode  int32                  `protobuf:"varint,5,opt,name=redirect_code,json=redirectCode,proto3" json:"redirect_code,omitempty"`
+	Title         string                 `protobuf:"bytes,6,opt,name=title,proto3" json:"title,omitempty"`
+	Preview       bool                   `protobuf:"varint,7,opt,name=preview,proto3" json:"preview,omitempty"`
+	Password      string                 `protobuf:"bytes,8,opt,name=password,proto3" json:"password,omitempty"`
+	MaxClicks     int32                  `protobuf:"varint,9,opt,name=max_clicks,json=maxClicks,proto3" json:"max_clicks,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -294,6 +382,55 @@ func (x *BatchURLRequest) GetOriginalUrl() string {
 	return ""
 }
 
+func (x *BatchURLRequest) GetExpiresIn() string {
+	if x != nil {
+		return x.ExpiresIn
+	}
+	return ""
+}
+
+func (x *BatchURLRequest) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *BatchURLRequest) GetRedirectCode() int32 {
+	if x != nil {
+		return x.RedirectCode
+	}
+	return 0
+}
+
+func (x *BatchURLRequest) GetTitle() string {
+	if x != nil {
+		return x.Title
+	}
+	return ""
+}
+
+func (x *BatchURLRequest) GetPreview() bool {
+	if x != nil {
+		return x.Preview
+	}
+	return false
+}
+
+func (x *BatchURLRequest) GetPassword() string {
+	if x != nil {
+		return x.Password
+	}
+	return ""
+}
+
+func (x *BatchURLRequest) GetMaxClicks() int32 {
+	if x != nil {
+		return x.MaxClicks
+	}
+	return 0
+}
+
 type CreateBatchShortURLResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Urls          []*BatchURLResponse    `protobuf:"bytes,1,rep,name=urls,proto3" json:"urls,omitempty"`
@@ -479,9 +616,13 @@ func (x *GetUserURLsResponse) GetUrls() []*UserURL {
 }
 
 type UserURL struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	ShortUrl      string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
-	OriginalUrl   string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl    string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	OriginalUrl string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	// RFC 3339 expiry time, empty for links without expiry
+	ExpiresAt string `protobuf:"bytes,3,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	// Redirects left, unset for links without a click limit
+	ClicksLeft    *int32 `protobuf:"varint,4,opt,name=clicks_left,json=clicksLeft,proto3,oneof" json:"clicks_left,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -530,6 +671,20 @@ func (x *UserURL) GetOriginalUrl() string {
 	return ""
 }
 
+func (x *UserURL) GetExpiresAt() string {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return ""
+}
+
+func (x *UserURL) GetClicksLeft() int32 {
+	if x != nil && x.ClicksLeft != nil {
+		return *x.ClicksLeft
+	}
+	return 0
+}
+
 type DeleteUserURLsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
@@ -663,11 +818,12 @@ func (*GetStatsRequest) Descriptor() ([]byte, []int) {
 }
 
 type GetStatsResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	UrlsCount     int32                  `protobuf:"varint,1,opt,name=urls_count,json=urlsCount,proto3" json:"urls_count,omitempty"`
-	UsersCount    int32                  `protobuf:"varint,2,opt,name=users_count,json=usersCount,proto3" json:"users_count,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	UrlsCount        int32                  `protobuf:"varint,1,opt,name=urls_count,json=urlsCount,proto3" json:"urls_count,omitempty"`
+	UsersCount       int32                  `protobuf:"varint,2,opt,name=users_count,json=usersCount,proto3" json:"users_count,omitempty"`
+	ExpiredUrlsCount int32                  `protobuf:"varint,3,opt,name=expired_urls_count,json=expiredUrlsCount,proto3" json:"expired_urls_count,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
 }
 
 func (x *GetStatsResponse) Reset() {
@@ -714,24 +870,441 @@ func (x *GetStatsResponse) GetUsersCount() int32 {
 	return 0
 }
 
+func (x *GetStatsResponse) GetExpiredUrlsCount() int32 {
+	if x != nil {
+		return x.ExpiredUrlsCount
+	}
+	return 0
+}
+
+type GetLinkStatsRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	UserId   string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ShortUrl string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	// "hour" (default) or "day"
+	Granularity string `protobuf:"bytes,3,opt,name=granularity,proto3" json:"granularity,omitempty"`
+	// Number of top referrers and user agents, 0 for the default
+	Top           int32 `protobuf:"varint,4,opt,name=top,proto3" json:"top,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLinkStatsRequest) Reset() {
+	*x = GetLinkStatsRequest{}
+	mi := &file_proto_urlshortener_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkStatsRequest) ProtoMessage() {}
+
+func (x *GetLinkStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkStatsRequest.ProtoReflect.Descriptor instead.
+func (*GetLinkStatsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *GetLinkStatsRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *GetLinkStatsRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *GetLinkStatsRequest) GetGranularity() string {
+	if x != nil {
+		return x.Granularity
+	}
+	return ""
+}
+
+func (x *GetLinkStatsRequest) GetTop() int32 {
+	if x != nil {
+		return x.Top
+	}
+	return 0
+}
+
+type GetLinkStatsResponse struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ShortUrl       string                 `protobuf:"bytes,1,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	Clicks         int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	UniqueVisitors int64                  `protobuf:"varint,3,opt,name=unique_visitors,json=uniqueVisitors,proto3" json:"unique_visitors,omitempty"`
+	TimeSeries     []*ClickBucket         `protobuf:"bytes,4,rep,name=time_series,json=timeSeries,proto3" json:"time_series,omitempty"`
+	TopReferrers   []*ClickCount          `protobuf:"bytes,5,rep,name=top_referrers,json=topReferrers,proto3" json:"top_referrers,omitempty"`
+	TopUserAgents  []*ClickCount          `protobuf:"bytes,6,rep,name=top_user_agents,json=topUserAgents,proto3" json:"top_user_agents,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *GetLinkStatsResponse) Reset() {
+	*x = GetLinkStatsResponse{}
+	mi := &file_proto_urlshortener_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkStatsResponse) ProtoMessage() {}
+
+func (x *GetLinkStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkStatsResponse.ProtoReflect.Descriptor instead.
+func (*GetLinkStatsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *GetLinkStatsResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *GetLinkStatsResponse) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+func (x *GetLinkStatsResponse) GetUniqueVisitors() int64 {
+	if x != nil {
+		return x.UniqueVisitors
+	}
+	return 0
+}
+
+func (x *GetLinkStatsResponse) GetTimeSeries() []*ClickBucket {
+	if x != nil {
+		return x.TimeSeries
+	}
+	return nil
+}
+
+func (x *GetLinkStatsResponse) GetTopReferrers() []*ClickCount {
+	if x != nil {
+		return x.TopReferrers
+	}
+	return nil
+}
+
+func (x *GetLinkStatsResponse) GetTopUserAgents() []*ClickCount {
+	if x != nil {
+		return x.TopUserAgents
+	}
+	return nil
+}
+
+type ClickBucket struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// RFC 3339 start of the interval
+	Time          string `protobuf:"bytes,1,opt,name=time,proto3" json:"time,omitempty"`
+	Clicks        int64  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickBucket) Reset() {
+	*x = ClickBucket{}
+	mi := &file_proto_urlshortener_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickBucket) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickBucket) ProtoMessage() {}
+
+func (x *ClickBucket) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickBucket.ProtoReflect.Descriptor instead.
+func (*ClickBucket) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *ClickBucket) GetTime() string {
+	if x != nil {
+		return x.Time
+	}
+	return ""
+}
+
+func (x *ClickBucket) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type ClickCount struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Value         string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Clicks        int64                  `protobuf:"varint,2,opt,name=clicks,proto3" json:"clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClickCount) Reset() {
+	*x = ClickCount{}
+	mi := &file_proto_urlshortener_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClickCount) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClickCount) ProtoMessage() {}
+
+func (x *ClickCount) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClickCount.ProtoReflect.Descriptor instead.
+func (*ClickCount) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ClickCount) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *ClickCount) GetClicks() int64 {
+	if x != nil {
+		return x.Clicks
+	}
+	return 0
+}
+
+type UpdateShortURLRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	OriginalUrl   string                 `protobuf:"bytes,3,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateShortURLRequest) Reset() {
+	*x = UpdateShortURLRequest{}
+	mi := &file_proto_urlshortener_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShortURLRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShortURLRequest) ProtoMessage() {}
+
+func (x *UpdateShortURLRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShortURLRequest.ProtoReflect.Descriptor instead.
+func (*UpdateShortURLRequest) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *UpdateShortURLRequest) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *UpdateShortURLRequest) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+func (x *UpdateShortURLRequest) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+type UpdateShortURLResponse struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Version     int32                  `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	OriginalUrl string                 `protobuf:"bytes,2,opt,name=original_url,json=originalUrl,proto3" json:"original_url,omitempty"`
+	PreviousUrl string                 `protobuf:"bytes,3,opt,name=previous_url,json=previousUrl,proto3" json:"previous_url,omitempty"`
+	// RFC 3339 time of the change
+	ChangedAt     string `protobuf:"bytes,4,opt,name=changed_at,json=changedAt,proto3" json:"changed_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateShortURLResponse) Reset() {
+	*x = UpdateShortURLResponse{}
+	mi := &file_proto_urlshortener_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateShortURLResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateShortURLResponse) ProtoMessage() {}
+
+func (x *UpdateShortURLResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_urlshortener_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateShortURLResponse.ProtoReflect.Descriptor instead.
+func (*UpdateShortURLResponse) Descriptor() ([]byte, []int) {
+	return file_proto_urlshortener_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *UpdateShortURLResponse) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *UpdateShortURLResponse) GetOriginalUrl() string {
+	if x != nil {
+		return x.OriginalUrl
+	}
+	return ""
+}
+
+func (x *UpdateShortURLResponse) GetPreviousUrl() string {
+	if x != nil {
+		return x.PreviousUrl
+	}
+	return ""
+}
+
+func (x *UpdateShortURLResponse) GetChangedAt() string {
+	if x != nil {
+		return x.ChangedAt
+	}
+	return ""
+}
+
 var File_proto_urlshortener_proto protoreflect.FileDescriptor
 
 const file_proto_urlshortener_proto_rawDesc = "" +
 	"\n" +
-	"\x18proto/urlshortener.proto\x12\furlshortener\")\n" +
+	"\x18proto/urlshortener.proto\x12\furlshortener\"\x8d\x02\n" +
 	"\x15CreateShortURLRequest\x12\x10\n" +
-	"\x03url\x18\x01 \x01(\tR\x03url\"0\n" +
+	"\x03url\x18\x01 \x01(\tR\x03url\x12\x14\n" +
+	"\x05alias\x18\x02 \x01(\tR\x05alias\x12\x1d\n" +
+	"\n" +
+	"expires_in\x18\x03 \x01(\tR\texpiresIn\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\tR\texpiresAt\x12#\n" +
+	"\rredirect_code\x18\x05 \x01(\x05R\fredirectCode\x12\x14\n" +
+	"\x05title\x18\x06 \x01(\tR\x05title\x12\x18\n" +
+	"\apreview\x18\a \x01(\bR\apreview\x12\x1a\n" +
+	"\bpassword\x18\b \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\t \x01(\x05R\tmaxClicks\"0\n" +
 	"\x16CreateShortURLResponse\x12\x16\n" +
-	"\x06result\x18\x01 \x01(\tR\x06result\"4\n" +
+	"\x06result\x18\x01 \x01(\tR\x06result\"P\n" +
 	"\x15GetOriginalURLRequest\x12\x1b\n" +
-	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\";\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12\x1a\n" +
+	"\bpassword\x18\x02 \x01(\tR\bpassword\";\n" +
 	"\x16GetOriginalURLResponse\x12!\n" +
 	"\foriginal_url\x18\x01 \x01(\tR\voriginalUrl\"O\n" +
 	"\x1aCreateBatchShortURLRequest\x121\n" +
-	"\x04urls\x18\x01 \x03(\v2\x1d.urlshortener.BatchURLRequestR\x04urls\"[\n" +
+	"\x04urls\x18\x01 \x03(\v2\x1d.urlshortener.BatchURLRequestR\x04urls\"\xa9\x02\n" +
 	"\x0fBatchURLRequest\x12%\n" +
 	"\x0ecorrelation_id\x18\x01 \x01(\tR\rcorrelationId\x12!\n" +
-	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"Q\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1d\n" +
+	"\n" +
+	"expires_in\x18\x03 \x01(\tR\texpiresIn\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\tR\texpiresAt\x12#\n" +
+	"\rredirect_code\x18\x05 \x01(\x05R\fredirectCode\x12\x14\n" +
+	"\x05title\x18\x06 \x01(\tR\x05title\x12\x18\n" +
+	"\apreview\x18\a \x01(\bR\apreview\x12\x1a\n" +
+	"\bpassword\x18\b \x01(\tR\bpassword\x12\x1d\n" +
+	"\n" +
+	"max_clicks\x18\t \x01(\x05R\tmaxClicks\"Q\n" +
 	"\x1bCreateBatchShortURLResponse\x122\n" +
 	"\x04urls\x18\x01 \x03(\v2\x1e.urlshortener.BatchURLResponseR\x04urls\"V\n" +
 	"\x10BatchURLResponse\x12%\n" +
@@ -740,29 +1313,67 @@ const file_proto_urlshortener_proto_rawDesc = "" +
 	"\x12GetUserURLsRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\"@\n" +
 	"\x13GetUserURLsResponse\x12)\n" +
-	"\x04urls\x18\x01 \x03(\v2\x15.urlshortener.UserURLR\x04urls\"I\n" +
+	"\x04urls\x18\x01 \x03(\v2\x15.urlshortener.UserURLR\x04urls\"\x9e\x01\n" +
 	"\aUserURL\x12\x1b\n" +
 	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12!\n" +
-	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\"O\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x03 \x01(\tR\texpiresAt\x12$\n" +
+	"\vclicks_left\x18\x04 \x01(\x05H\x00R\n" +
+	"clicksLeft\x88\x01\x01B\x0e\n" +
+	"\f_clicks_left\"O\n" +
 	"\x15DeleteUserURLsRequest\x12\x17\n" +
 	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1d\n" +
 	"\n" +
 	"short_urls\x18\x02 \x03(\tR\tshortUrls\"2\n" +
 	"\x16DeleteUserURLsResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\"\x11\n" +
-	"\x0fGetStatsRequest\"R\n" +
+	"\x0fGetStatsRequest\"\x80\x01\n" +
 	"\x10GetStatsResponse\x12\x1d\n" +
 	"\n" +
 	"urls_count\x18\x01 \x01(\x05R\turlsCount\x12\x1f\n" +
 	"\vusers_count\x18\x02 \x01(\x05R\n" +
-	"usersCount2\xbc\x04\n" +
+	"usersCount\x12,\n" +
+	"\x12expired_urls_count\x18\x03 \x01(\x05R\x10expiredUrlsCount\"\x7f\n" +
+	"\x13GetLinkStatsRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12 \n" +
+	"\vgranularity\x18\x03 \x01(\tR\vgranularity\x12\x10\n" +
+	"\x03top\x18\x04 \x01(\x05R\x03top\"\xb1\x02\n" +
+	"\x14GetLinkStatsResponse\x12\x1b\n" +
+	"\tshort_url\x18\x01 \x01(\tR\bshortUrl\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\x12'\n" +
+	"\x0funique_visitors\x18\x03 \x01(\x03R\x0euniqueVisitors\x12:\n" +
+	"\vtime_series\x18\x04 \x03(\v2\x19.urlshortener.ClickBucketR\n" +
+	"timeSeries\x12=\n" +
+	"\rtop_referrers\x18\x05 \x03(\v2\x18.urlshortener.ClickCountR\ftopReferrers\x12@\n" +
+	"\x0ftop_user_agents\x18\x06 \x03(\v2\x18.urlshortener.ClickCountR\rtopUserAgents\"9\n" +
+	"\vClickBucket\x12\x12\n" +
+	"\x04time\x18\x01 \x01(\tR\x04time\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\":\n" +
+	"\n" +
+	"ClickCount\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x16\n" +
+	"\x06clicks\x18\x02 \x01(\x03R\x06clicks\"p\n" +
+	"\x15UpdateShortURLRequest\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\x12!\n" +
+	"\foriginal_url\x18\x03 \x01(\tR\voriginalUrl\"\x97\x01\n" +
+	"\x16UpdateShortURLResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x05R\aversion\x12!\n" +
+	"\foriginal_url\x18\x02 \x01(\tR\voriginalUrl\x12!\n" +
+	"\fprevious_url\x18\x03 \x01(\tR\vpreviousUrl\x12\x1d\n" +
+	"\n" +
+	"changed_at\x18\x04 \x01(\tR\tchangedAt2\xf4\x05\n" +
 	"\fURLShortener\x12]\n" +
 	"\x0eCreateShortURL\x12#.urlshortener.CreateShortURLRequest\x1a$.urlshortener.CreateShortURLResponse\"\x00\x12]\n" +
 	"\x0eGetOriginalURL\x12#.urlshortener.GetOriginalURLRequest\x1a$.urlshortener.GetOriginalURLResponse\"\x00\x12l\n" +
 	"\x13CreateBatchShortURL\x12(.urlshortener.CreateBatchShortURLRequest\x1a).urlshortener.CreateBatchShortURLResponse\"\x00\x12T\n" +
 	"\vGetUserURLs\x12 .urlshortener.GetUserURLsRequest\x1a!.urlshortener.GetUserURLsResponse\"\x00\x12]\n" +
 	"\x0eDeleteUserURLs\x12#.urlshortener.DeleteUserURLsRequest\x1a$.urlshortener.DeleteUserURLsResponse\"\x00\x12K\n" +
-	"\bGetStats\x12\x1d.urlshortener.GetStatsRequest\x1a\x1e.urlshortener.GetStatsResponse\"\x00B&Z$github.com/learies/goShortener/protob\x06proto3"
+	"\bGetStats\x12\x1d.urlshortener.GetStatsRequest\x1a\x1e.urlshortener.GetStatsResponse\"\x00\x12W\n" +
+	"\fGetLinkStats\x12!.urlshortener.GetLinkStatsRequest\x1a\".urlshortener.GetLinkStatsResponse\"\x00\x12]\n" +
+	"\x0eUpdateShortURL\x12#.urlshortener.UpdateShortURLRequest\x1a$.urlshortener.UpdateShortURLResponse\"\x00B&Z$github.com/learies/goShortener/protob\x06proto3"
 
 var (
 	file_proto_urlshortener_proto_rawDescOnce sync.Once
@@ -776,7 +1387,7 @@ func file_proto_urlshortener_proto_rawDescGZIP() []byte {
 	return file_proto_urlshortener_proto_rawDescData
 }
 
-var file_proto_urlshortener_proto_msgTypes = make([]protoimpl.MessageInfo, 15)
+var file_proto_urlshortener_proto_msgTypes = make([]protoimpl.MessageInfo, 21)
 var file_proto_urlshortener_proto_goTypes = []any{
 	(*CreateShortURLRequest)(nil),       // 0: urlshortener.CreateShortURLRequest
 	(*CreateShortURLResponse)(nil),      // 1: urlshortener.CreateShortURLResponse
@@ -793,28 +1404,41 @@ var file_proto_urlshortener_proto_goTypes = []any{
 	(*DeleteUserURLsResponse)(nil),      // 12: urlshortener.DeleteUserURLsResponse
 	(*GetStatsRequest)(nil),             // 13: urlshortener.GetStatsRequest
 	(*GetStatsResponse)(nil),            // 14: urlshortener.GetStatsResponse
+	(*GetLinkStatsRequest)(nil),         // 15: urlshortener.GetLinkStatsRequest
+	(*GetLinkStatsResponse)(nil),        // 16: urlshortener.GetLinkStatsResponse
+	(*ClickBucket)(nil),                 // 17: urlshortener.ClickBucket
+	(*ClickCount)(nil),                  // 18: urlshortener.ClickCount
+	(*UpdateShortURLRequest)(nil),       // 19: urlshortener.UpdateShortURLRequest
+	(*UpdateShortURLResponse)(nil),      // 20: urlshortener.UpdateShortURLResponse
 }
 var file_proto_urlshortener_proto_depIdxs = []int32{
 	5,  // 0: urlshortener.CreateBatchShortURLRequest.urls:type_name -> urlshortener.BatchURLRequest
 	7,  // 1: urlshortener.CreateBatchShortURLResponse.urls:type_name -> urlshortener.BatchURLResponse
 	10, // 2: urlshortener.GetUserURLsResponse.urls:type_name -> urlshortener.UserURL
-	0,  // 3: urlshortener.URLShortener.CreateShortURL:input_type -> urlshortener.CreateShortURLRequest
-	2,  // 4: urlshortener.URLShortener.GetOriginalURL:input_type -> urlshortener.GetOriginalURLRequest
-	4,  // 5: urlshortener.URLShortener.CreateBatchShortURL:input_type -> urlshortener.CreateBatchShortURLRequest
-	8,  // 6: urlshortener.URLShortener.GetUserURLs:input_type -> urlshortener.GetUserURLsRequest
-	11, // 7: urlshortener.URLShortener.DeleteUserURLs:input_type -> urlshortener.DeleteUserURLsRequest
-	13, // 8: urlshortener.URLShortener.GetStats:input_type -> urlshortener.GetStatsRequest
-	1,  // 9: urlshortener.URLShortener.CreateShortURL:output_type -> urlshortener.CreateShortURLResponse
-	3,  // 10: urlshortener.URLShortener.GetOriginalURL:output_type -> urlshortener.GetOriginalURLResponse
-	6,  // 11: urlshortener.URLShortener.CreateBatchShortURL:output_type -> urlshortener.CreateBatchShortURLResponse
-	9,  // 12: urlshortener.URLShortener.GetUserURLs:output_type -> urlshortener.GetUserURLsResponse
-	12, // 13: urlshortener.URLShortener.DeleteUserURLs:output_type -> urlshortener.DeleteUserURLsResponse
-	14, // 14: urlshortener.URLShortener.GetStats:output_type -> urlshortener.GetStatsResponse
-	9,  // [9:15] is the sub-list for method output_type
-	3,  // [3:9] is the sub-list for method input_type
-	3,  // [3:3] is the sub-list for extension type_name
-	3,  // [3:3] is the sub-list for extension extendee
-	0,  // [0:3] is the sub-list for field type_name
+	17, // 3: urlshortener.GetLinkStatsResponse.time_series:type_name -> urlshortener.ClickBucket
+	18, // 4: urlshortener.GetLinkStatsResponse.top_referrers:type_name -> urlshortener.ClickCount
+	18, // 5: urlshortener.GetLinkStatsResponse.top_user_agents:type_name -> urlshortener.ClickCount
+	0,  // 6: urlshortener.URLShortener.CreateShortURL:input_type -> urlshortener.CreateShortURLRequest
+	2,  // 7: urlshortener.URLShortener.GetOriginalURL:input_type -> urlshortener.GetOriginalURLRequest
+	4,  // 8: urlshortener.URLShortener.CreateBatchShortURL:input_type -> urlshortener.CreateBatchShortURLRequest
+	8,  // 9: urlshortener.URLShortener.GetUserURLs:input_type -> urlshortener.GetUserURLsRequest
+	11, // 10: urlshortener.URLShortener.DeleteUserURLs:input_type -> urlshortener.DeleteUserURLsRequest
+	13, // 11: urlshortener.URLShortener.GetStats:input_type -> urlshortener.GetStatsRequest
+	15, // 12: urlshortener.URLShortener.GetLinkStats:input_type -> urlshortener.GetLinkStatsRequest
+	19, // 13: urlshortener.URLShortener.UpdateShortURL:input_type -> urlshortener.UpdateShortURLRequest
+	1,  // 14: urlshortener.URLShortener.CreateShortURL:output_type -> urlshortener.CreateShortURLResponse
+	3,  // 15: urlshortener.URLShortener.GetOriginalURL:output_type -> urlshortener.GetOriginalURLResponse
+	6,  // 16: urlshortener.URLShortener.CreateBatchShortURL:output_type -> urlshortener.CreateBatchShortURLResponse
+	9,  // 17: urlshortener.URLShortener.GetUserURLs:output_type -> urlshortener.GetUserURLsResponse
+	12, // 18: urlshortener.URLShortener.DeleteUserURLs:output_type -> urlshortener.DeleteUserURLsResponse
+	14, // 19: urlshortener.URLShortener.GetStats:output_type -> urlshortener.GetStatsResponse
+	16, // 20: urlshortener.URLShortener.GetLinkStats:output_type -> urlshortener.GetLinkStatsResponse
+	20, // 21: urlshortener.URLShortener.UpdateShortURL:output_type -> urlshortener.UpdateShortURLResponse
+	14, // [14:22] is the sub-list for method output_type
+	6,  // [6:14] is the sub-list for method input_type
+	6,  // [6:6] is the sub-list for extension type_name
+	6,  // [6:6] is the sub-list for extension extendee
+	0,  // [0:6] is the sub-list for field type_name
 }
 
 func init() { file_proto_urlshortener_proto_init() }
@@ -822,13 +1446,14 @@ func file_proto_urlshortener_proto_init() {
 	if File_proto_urlshortener_proto != nil {
 		return
 	}
+	file_proto_urlshortener_proto_msgTypes[10].OneofWrappers = []any{}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_urlshortener_proto_rawDesc), len(file_proto_urlshortener_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   15,
+			NumMessages:   21,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
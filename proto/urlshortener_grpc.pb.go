@@ -25,6 +25,8 @@ const (
 	URLShortener_GetUserURLs_FullMethodName         = "/urlshortener.URLShortener/GetUserURLs"
 	URLShortener_DeleteUserURLs_FullMethodName      = "/urlshortener.URLShortener/DeleteUserURLs"
 	URLShortener_GetStats_FullMethodName            = "/urlshortener.URLShortener/GetStats"
+	URLShortener_GetLinkStats_FullMethodName        = "/urlshortener.URLShortener/GetLinkStats"
+	URLShortener_UpdateShortURL_FullMethodName      = "/urlshortener.URLShortener/UpdateShortURL"
 )
 
 // URLShortenerClient is the client API for URLShortener service.
@@ -45,6 +47,10 @@ type URLShortenerClient interface {
 	DeleteUserURLs(ctx context.Context, in *DeleteUserURLsRequest, opts ...grpc.CallOption) (*DeleteUserURLsResponse, error)
 	// Get service statistics
 	GetStats(ctx context.Context, in *GetStatsRequest, opts ...grpc.CallOption) (*GetStatsResponse, error)
+	// Get click analytics for a user's link
+	GetLinkStats(ctx context.Context, in *GetLinkStatsRequest, opts ...grpc.CallOption) (*GetLinkStatsResponse, error)
+	// Change the original URL of a user's link
+	UpdateShortURL(ctx context.Context, in *UpdateShortURLRequest, opts ...grpc.CallOption) (*UpdateShortURLResponse, error)
 }
 
 type uRLShortenerClient struct {
@@ -115,6 +121,26 @@ func (c *uRLShortenerClient) GetStats(ctx context.Context, in *GetStatsRequest,
 	return out, nil
 }
 
+func (c *uRLShortenerClient) GetLinkStats(ctx context.Context, in *GetLinkStatsRequest, opts ...grpc.CallOption) (*GetLinkStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetLinkStatsResponse)
+	err := c.cc.Invoke(ctx, URLShortener_GetLinkStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *uRLShortenerClient) UpdateShortURL(ctx context.Context, in *UpdateShortURLRequest, opts ...grpc.CallOption) (*UpdateShortURLResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateShortURLResponse)
+	err := c.cc.Invoke(ctx, URLShortener_UpdateShortURL_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // URLShortenerServer is the server API for URLShortener service.
 // All implementations must embed UnimplementedURLShortenerServer
 // for forward compatibility.
@@ -133,6 +159,10 @@ type URLShortenerServer interface {
 	DeleteUserURLs(context.Context, *DeleteUserURLsRequest) (*DeleteUserURLsResponse, error)
 	// Get service statistics
 	GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error)
+	// Get click analytics for a user's link
+	GetLinkStats(context.Context, *GetLinkStatsRequest) (*GetLinkStatsResponse, error)
+	// Change the original URL of a user's link
+	UpdateShortURL(context.Context, *UpdateShortURLRequest) (*UpdateShortURLResponse, error)
 	mustEmbedUnimplementedURLShortenerServer()
 }
 
@@ -161,6 +191,12 @@ func (UnimplementedURLShortenerServer) DeleteUserURLs(context.Context, *DeleteUs
 func (UnimplementedURLShortenerServer) GetStats(context.Context, *GetStatsRequest) (*GetStatsResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetStats not implemented")
 }
+func (UnimplementedURLShortenerServer) GetLinkStats(context.Context, *GetLinkStatsRequest) (*GetLinkStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLinkStats not implemented")
+}
+func (UnimplementedURLShortenerServer) UpdateShortURL(context.Context, *UpdateShortURLRequest) (*UpdateShortURLResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateShortURL not implemented")
+}
 func (UnimplementedURLShortenerServer) mustEmbedUnimplementedURLShortenerServer() {}
 func (UnimplementedURLShortenerServer) testEmbeddedByValue()                      {}
 
@@ -290,6 +326,42 @@ func _URLShortener_GetStats_Handler(srv interface{}, ctx context.Context, dec fu
 	return interceptor(ctx, in, info, handler)
 }
 
+func _URLShortener_GetLinkStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLinkStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).GetLinkStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_GetLinkStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).GetLinkStats(ctx, req.(*GetLinkStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _URLShortener_UpdateShortURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateShortURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(URLShortenerServer).UpdateShortURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: URLShortener_UpdateShortURL_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(URLShortenerServer).UpdateShortURL(ctx, req.(*UpdateShortURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // URLShortener_ServiceDesc is the grpc.ServiceDesc for URLShortener service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -321,6 +393,14 @@ var URLShortener_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetStats",
 			Handler:    _URLShortener_GetStats_Handler,
 		},
+		{
+			MethodName: "GetLinkStats",
+			Handler:    _URLShortener_GetLinkStats_Handler,
+		},
+		{
+			MethodName: "UpdateShortURL",
+			Handler:    _URLShortener_UpdateShortURL_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/urlshortener.proto",